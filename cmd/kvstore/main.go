@@ -3,44 +3,149 @@ package main
 import (
 	"log"
 	"memstash/env"
+	"memstash/internal/acl"
 	"memstash/internal/cli"
+	"memstash/internal/config"
+	"memstash/internal/lifecycle"
+	"memstash/internal/pubsub"
+	"memstash/internal/ratelimit"
 	"memstash/internal/server"
 	"memstash/internal/store"
+	"memstash/internal/tlsconf"
+	"os"
+	"strconv"
 	"time"
 )
 
 func main() {
 	dotenvs := env.LoadEnv()
 
-	myStore := store.NewStore(*dotenvs.Capacity)
-	snapshotPath := "memstash_data.json"
-	err := myStore.LoadSnapshot(snapshotPath)
+	myStore := store.NewStoreWithDatabases(*dotenvs.Capacity, *dotenvs.Databases)
+	notifyFlags, err := store.ParseNotifyFlags(*dotenvs.Notify_events)
+	if err != nil {
+		log.Fatalf("Invalid NOTIFY_KEYSPACE_EVENTS: %v", err)
+	}
+	myStore.SetNotifyKeyspaceEvents(notifyFlags)
+	snapshotPath := *dotenvs.Snapshot_file
+	err = myStore.LoadSnapshot(snapshotPath)
 	if err != nil {
 		log.Printf("Load failed: %v\n", err)
 	}
 
-	// Enable auto-save every 5 minutes
-	myStore.EnableAutoSave(snapshotPath, 1*time.Minute)
+	// Enable auto-save (CONFIG SET autosave-interval retunes it)
+	myStore.EnableAutoSave(snapshotPath, time.Duration(*dotenvs.Autosave)*time.Second)
 
-	// Save on shutdown (Ctrl+C)
-	done := myStore.SaveOnShutdown(snapshotPath)
 	// Start TTL cleaner
-	myStore.StartTTLCleaner(1 * time.Minute)
+	myStore.StartTTLCleaner(time.Duration(*dotenvs.Ttl_cleaner) * time.Second)
 
 	// Start TCP server in background (shares the same store)
 	srv := server.NewServer(myStore, *dotenvs.Tcp_port)
-	go srv.Start()
+	if *dotenvs.Unix_socket != "" {
+		srv.SetUnixSocket(*dotenvs.Unix_socket, os.FileMode(*dotenvs.Unix_perm))
+		if *dotenvs.Tcp_port == 0 {
+			srv.DisableTCP()
+		}
+	}
+	policy, _ := pubsub.ParsePolicy(*dotenvs.Pubsub_overflow)
+	srv.SetPubSub(pubsub.NewHub(*dotenvs.Pubsub_buffer, policy))
+
+	users := acl.New()
+	if *dotenvs.Requirepass != "" {
+		if err := users.SetRequirePass(*dotenvs.Requirepass); err != nil {
+			log.Fatalf("Invalid REQUIREPASS: %v", err)
+		}
+	}
+	if *dotenvs.Acl_file != "" {
+		if err := users.LoadFile(*dotenvs.Acl_file); err != nil {
+			log.Fatalf("ACL load failed: %v", err)
+		}
+	}
+	srv.SetACL(users)
+
+	// Negative thresholds disable the slow log, as in Redis
+	slowlogThreshold := time.Duration(*dotenvs.Slowlog_slower) * time.Microsecond
+	if slowlogThreshold < 0 {
+		slowlogThreshold = -1
+	}
+	srv.SlowLog().SetThreshold(slowlogThreshold)
+	srv.SlowLog().SetMaxLen(*dotenvs.Slowlog_max_len)
+
+	// Token buckets per connection and per authenticated user; 0 is unlimited
+	srv.RateLimiter().SetRates(ratelimit.Connection, ratelimit.Rates{
+		Commands: int64(*dotenvs.Rate_conn_cmds),
+		Bytes:    int64(*dotenvs.Rate_conn_bytes),
+	})
+	srv.RateLimiter().SetRates(ratelimit.User, ratelimit.Rates{
+		Commands: int64(*dotenvs.Rate_user_cmds),
+		Bytes:    int64(*dotenvs.Rate_user_bytes),
+	})
+
+	// CONFIG REWRITE persists runtime changes back to .env
+	cfg := srv.Config()
+	cfg.SetFile(".env")
+	for _, p := range []struct{ name, env, value string }{
+		{"http-port", "HTTP_PORT", strconv.Itoa(*dotenvs.Http_port)},
+		{"pubsub-buffer", "PUBSUB_BUFFER", strconv.Itoa(*dotenvs.Pubsub_buffer)},
+		{"pubsub-overflow", "PUBSUB_OVERFLOW", *dotenvs.Pubsub_overflow},
+		{"acl-file", "ACL_FILE", *dotenvs.Acl_file},
+		{"tls-cert-file", "TLS_CERT_FILE", *dotenvs.Tls_cert_file},
+		{"tls-key-file", "TLS_KEY_FILE", *dotenvs.Tls_key_file},
+		{"tls-ca-cert-file", "TLS_CA_CERT_FILE", *dotenvs.Tls_ca_file},
+		{"tls-auth-clients", "TLS_AUTH_CLIENTS", *dotenvs.Tls_auth},
+		{"tls-reload-interval", "TLS_RELOAD_INTERVAL", strconv.Itoa(*dotenvs.Tls_reload)},
+	} {
+		value := p.value
+		cfg.Register(config.Param{Name: p.name, Env: p.env, Get: func() string { return value }})
+	}
 
 	// Start HTTP REST API server in background
 	httpSrv := server.NewHTTPServer(myStore, *dotenvs.Http_port)
-	go httpSrv.Start()
+	httpSrv.Attach(srv)
 
-	c := cli.NewCLI(myStore)
-	c.Start()
+	// Ctrl+C, SIGTERM, SHUTDOWN, POST /shutdown and leaving the CLI all stop
+	// the process the same way: drain both servers, save, stop the tickers
+	shutdown := lifecycle.New(time.Duration(*dotenvs.Shutdown_timeout) * time.Second)
+	shutdown.Add(srv)
+	shutdown.Add(httpSrv)
+	shutdown.OnSave(func() error {
+		return myStore.SaveSnapshot(myStore.SnapshotPath())
+	})
+	shutdown.OnStop(myStore.Close)
+	shutdown.HandleSignals()
+	srv.SetLifecycle(shutdown)
 
-	// If CLI exited via Ctrl+C, wait for save to finish
-	select {
-	case <-done:
-	default:
+	// Both listeners share one certificate, reloaded when the files change
+	if *dotenvs.Tls_cert_file != "" {
+		clientAuth, _ := tlsconf.ParseClientAuth(*dotenvs.Tls_auth)
+		certs, err := tlsconf.New(tlsconf.Options{
+			CertFile:   *dotenvs.Tls_cert_file,
+			KeyFile:    *dotenvs.Tls_key_file,
+			CAFile:     *dotenvs.Tls_ca_file,
+			ClientAuth: clientAuth,
+		})
+		if err != nil {
+			log.Fatalf("TLS setup failed: %v", err)
+		}
+		if *dotenvs.Tls_reload > 0 {
+			stopWatch := certs.Watch(time.Duration(*dotenvs.Tls_reload)*time.Second, func(err error) {
+				log.Printf("TLS reload failed: %v", err)
+			})
+			shutdown.OnStop(stopWatch)
+		}
+		srv.SetTLS(certs.Config())
+		httpSrv.SetTLS(certs.Config())
 	}
+
+	go srv.Start()
+	go httpSrv.Start()
+
+	// The CLI dispatches through the same command table as TCP clients,
+	// so its commands also show up in MONITOR, INFO and the slow log
+	c := cli.NewCLI(srv.NewSession("cli"))
+	go func() {
+		c.Start()
+		shutdown.Shutdown(lifecycle.Save)
+	}()
+
+	<-shutdown.Done()
 }
@@ -9,10 +9,33 @@ import (
 )
 
 type EnvVars struct {
-	Capacity  *int
-	Tcp_port  *int
-	Http_port *int
-	Memory    *int
+	Capacity         *int
+	Tcp_port         *int
+	Unix_socket      *string
+	Unix_perm        *int
+	Http_port        *int
+	Memory           *int
+	Databases        *int
+	Pubsub_buffer    *int
+	Pubsub_overflow  *string
+	Notify_events    *string
+	Requirepass      *string
+	Acl_file         *string
+	Tls_cert_file    *string
+	Tls_key_file     *string
+	Tls_ca_file      *string
+	Tls_auth         *string
+	Tls_reload       *int
+	Snapshot_file    *string
+	Autosave         *int
+	Ttl_cleaner      *int
+	Slowlog_slower   *int
+	Slowlog_max_len  *int
+	Shutdown_timeout *int
+	Rate_conn_cmds   *int
+	Rate_conn_bytes  *int
+	Rate_user_cmds   *int
+	Rate_user_bytes  *int
 }
 
 func LoadEnv() EnvVars {
@@ -41,9 +64,38 @@ func LoadEnv() EnvVars {
 
 		envs.Capacity = &capacityInt
 	}
+	databases := os.Getenv("DATABASES")
+	if databases == "" {
+		databases = "16" // default
+	}
+	databasesInt, err := strconv.Atoi(databases)
+	if err != nil || databasesInt <= 0 {
+		log.Fatalln("Invalid databases value: must be a positive integer")
+	}
+	envs.Databases = &databasesInt
+
+	unix_socket := os.Getenv("UNIX_SOCKET")
+	envs.Unix_socket = &unix_socket
+
+	unix_perm := os.Getenv("UNIX_SOCKET_PERM")
+	if unix_perm == "" {
+		unix_perm = "700" // default, octal
+	}
+	unix_permInt, err := strconv.ParseUint(unix_perm, 8, 32)
+	if err != nil || unix_permInt > 0777 {
+		log.Fatalln("Invalid unix_socket_perm value: must be octal permissions such as 770")
+	}
+	unix_permMode := int(unix_permInt)
+	envs.Unix_perm = &unix_permMode
+
+	// With a Unix socket, TCP is optional: TCP_PORT=0 or no TCP_PORT
+	// listens on the socket only.
 	tcp_port := os.Getenv("TCP_PORT")
+	if tcp_port == "" && unix_socket != "" {
+		tcp_port = "0"
+	}
 	if tcp_port == "" {
-		log.Fatalln("Please Provide TCP_PORT in environment variable ")
+		log.Fatalln("Please Provide TCP_PORT or UNIX_SOCKET in environment variable ")
 	}
 	tcp_portInt, err := strconv.Atoi(tcp_port)
 	if err != nil {
@@ -61,5 +113,165 @@ func LoadEnv() EnvVars {
 	}
 	envs.Http_port = &http_portInt
 
+	pubsub_buffer := os.Getenv("PUBSUB_BUFFER")
+	if pubsub_buffer == "" {
+		pubsub_buffer = "1024" // default
+	}
+	pubsub_bufferInt, err := strconv.Atoi(pubsub_buffer)
+	if err != nil || pubsub_bufferInt <= 0 {
+		log.Fatalln("Invalid pubsub_buffer value: must be a positive integer")
+	}
+	envs.Pubsub_buffer = &pubsub_bufferInt
+
+	pubsub_overflow := os.Getenv("PUBSUB_OVERFLOW")
+	if pubsub_overflow == "" {
+		pubsub_overflow = "drop" // default
+	}
+	if pubsub_overflow != "drop" && pubsub_overflow != "disconnect" {
+		log.Fatalln("Invalid pubsub_overflow value: must be drop or disconnect")
+	}
+	envs.Pubsub_overflow = &pubsub_overflow
+
+	notify_events := os.Getenv("NOTIFY_KEYSPACE_EVENTS")
+	envs.Notify_events = &notify_events
+
+	requirepass := os.Getenv("REQUIREPASS")
+	envs.Requirepass = &requirepass
+
+	acl_file := os.Getenv("ACL_FILE")
+	envs.Acl_file = &acl_file
+
+	tls_cert_file := os.Getenv("TLS_CERT_FILE")
+	tls_key_file := os.Getenv("TLS_KEY_FILE")
+	if (tls_cert_file == "") != (tls_key_file == "") {
+		log.Fatalln("Please Provide both TLS_CERT_FILE and TLS_KEY_FILE to enable TLS")
+	}
+	envs.Tls_cert_file = &tls_cert_file
+	envs.Tls_key_file = &tls_key_file
+
+	tls_ca_file := os.Getenv("TLS_CA_CERT_FILE")
+	envs.Tls_ca_file = &tls_ca_file
+
+	tls_auth := os.Getenv("TLS_AUTH_CLIENTS")
+	if tls_auth == "" {
+		tls_auth = "no" // default
+		if tls_ca_file != "" {
+			tls_auth = "yes"
+		}
+	}
+	if tls_auth != "no" && tls_auth != "optional" && tls_auth != "yes" {
+		log.Fatalln("Invalid tls_auth_clients value: must be no, optional or yes")
+	}
+	if tls_auth != "no" && tls_ca_file == "" {
+		log.Fatalln("Please Provide TLS_CA_CERT_FILE to verify client certificates")
+	}
+	envs.Tls_auth = &tls_auth
+
+	tls_reload := os.Getenv("TLS_RELOAD_INTERVAL")
+	if tls_reload == "" {
+		tls_reload = "60" // default, seconds
+	}
+	tls_reloadInt, err := strconv.Atoi(tls_reload)
+	if err != nil || tls_reloadInt < 0 {
+		log.Fatalln("Invalid tls_reload_interval value: must be a non-negative integer")
+	}
+	envs.Tls_reload = &tls_reloadInt
+
+	snapshot_file := os.Getenv("SNAPSHOT_FILE")
+	if snapshot_file == "" {
+		snapshot_file = "memstash_data.json" // default
+	}
+	envs.Snapshot_file = &snapshot_file
+
+	autosave := os.Getenv("AUTOSAVE_INTERVAL")
+	if autosave == "" {
+		autosave = "60" // default, seconds
+	}
+	autosaveInt, err := strconv.Atoi(autosave)
+	if err != nil || autosaveInt < 0 {
+		log.Fatalln("Invalid autosave_interval value: must be a non-negative integer")
+	}
+	envs.Autosave = &autosaveInt
+
+	ttl_cleaner := os.Getenv("TTL_CLEANER_INTERVAL")
+	if ttl_cleaner == "" {
+		ttl_cleaner = "60" // default, seconds
+	}
+	ttl_cleanerInt, err := strconv.Atoi(ttl_cleaner)
+	if err != nil || ttl_cleanerInt < 0 {
+		log.Fatalln("Invalid ttl_cleaner_interval value: must be a non-negative integer")
+	}
+	envs.Ttl_cleaner = &ttl_cleanerInt
+
+	slowlog_slower := os.Getenv("SLOWLOG_LOG_SLOWER_THAN")
+	if slowlog_slower == "" {
+		slowlog_slower = "10000" // default, microseconds
+	}
+	slowlog_slowerInt, err := strconv.Atoi(slowlog_slower)
+	if err != nil {
+		log.Fatalln("Invalid slowlog_log_slower_than value: must be an integer")
+	}
+	envs.Slowlog_slower = &slowlog_slowerInt
+
+	slowlog_max_len := os.Getenv("SLOWLOG_MAX_LEN")
+	if slowlog_max_len == "" {
+		slowlog_max_len = "128" // default
+	}
+	slowlog_max_lenInt, err := strconv.Atoi(slowlog_max_len)
+	if err != nil || slowlog_max_lenInt < 0 {
+		log.Fatalln("Invalid slowlog_max_len value: must be a non-negative integer")
+	}
+	envs.Slowlog_max_len = &slowlog_max_lenInt
+
+	shutdown_timeout := os.Getenv("SHUTDOWN_TIMEOUT")
+	if shutdown_timeout == "" {
+		shutdown_timeout = "10" // default, seconds
+	}
+	shutdown_timeoutInt, err := strconv.Atoi(shutdown_timeout)
+	if err != nil || shutdown_timeoutInt < 0 {
+		log.Fatalln("Invalid shutdown_timeout value: must be a non-negative integer")
+	}
+	envs.Shutdown_timeout = &shutdown_timeoutInt
+
+	rate_conn_cmds := os.Getenv("RATELIMIT_CONNECTION_COMMANDS")
+	if rate_conn_cmds == "" {
+		rate_conn_cmds = "0" // default, unlimited
+	}
+	rate_conn_cmdsInt, err := strconv.Atoi(rate_conn_cmds)
+	if err != nil || rate_conn_cmdsInt < 0 {
+		log.Fatalln("Invalid ratelimit_connection_commands value: must be a non-negative integer")
+	}
+	envs.Rate_conn_cmds = &rate_conn_cmdsInt
+
+	rate_conn_bytes := os.Getenv("RATELIMIT_CONNECTION_BYTES")
+	if rate_conn_bytes == "" {
+		rate_conn_bytes = "0" // default, unlimited
+	}
+	rate_conn_bytesInt, err := strconv.Atoi(rate_conn_bytes)
+	if err != nil || rate_conn_bytesInt < 0 {
+		log.Fatalln("Invalid ratelimit_connection_bytes value: must be a non-negative integer")
+	}
+	envs.Rate_conn_bytes = &rate_conn_bytesInt
+
+	rate_user_cmds := os.Getenv("RATELIMIT_USER_COMMANDS")
+	if rate_user_cmds == "" {
+		rate_user_cmds = "0" // default, unlimited
+	}
+	rate_user_cmdsInt, err := strconv.Atoi(rate_user_cmds)
+	if err != nil || rate_user_cmdsInt < 0 {
+		log.Fatalln("Invalid ratelimit_user_commands value: must be a non-negative integer")
+	}
+	envs.Rate_user_cmds = &rate_user_cmdsInt
+
+	rate_user_bytes := os.Getenv("RATELIMIT_USER_BYTES")
+	if rate_user_bytes == "" {
+		rate_user_bytes = "0" // default, unlimited
+	}
+	rate_user_bytesInt, err := strconv.Atoi(rate_user_bytes)
+	if err != nil || rate_user_bytesInt < 0 {
+		log.Fatalln("Invalid ratelimit_user_bytes value: must be a non-negative integer")
+	}
+	envs.Rate_user_bytes = &rate_user_bytesInt
+
 	return envs
 }
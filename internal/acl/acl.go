@@ -0,0 +1,451 @@
+package acl
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"memstash/internal/protocol"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultUser is the user unauthenticated connections run as.
+const DefaultUser = "default"
+
+// Categories that rules may refer to with +@name / -@name.
+var Categories = []string{"read", "write", "admin", "dangerous", "connection", "transaction", "pubsub", "list", "hash", "set", "blocking"}
+
+var (
+	ErrWrongPass   = errors.New("invalid username-password pair or user is disabled.")
+	ErrDefaultUser = errors.New("The 'default' user cannot be removed")
+)
+
+// commandRule is one +cmd / -cmd / +@category / -@category rule. Rules are
+// applied in order, so the last rule matching a command decides.
+type commandRule struct {
+	allow    bool
+	command  string // upper-cased command name, empty for category rules
+	category string // "all" matches every command
+}
+
+func (r commandRule) String() string {
+	sign := "-"
+	if r.allow {
+		sign = "+"
+	}
+	if r.category != "" {
+		return sign + "@" + r.category
+	}
+	return sign + strings.ToLower(r.command)
+}
+
+// User is a set of credentials plus the commands and keys they may use.
+// Users are immutable once published in an ACL; SETUSER swaps in a copy.
+type User struct {
+	Name      string
+	enabled   bool
+	nopass    bool
+	passwords map[string]struct{} // SHA-256 hex digests
+	commands  []commandRule
+	keys      []string // glob patterns, "*" for every key
+}
+
+func newUser(name string) *User {
+	return &User{Name: name, passwords: make(map[string]struct{})}
+}
+
+func (u *User) clone() *User {
+	c := *u
+	c.passwords = make(map[string]struct{}, len(u.passwords))
+	for p := range u.passwords {
+		c.passwords[p] = struct{}{}
+	}
+	c.commands = append([]commandRule(nil), u.commands...)
+	c.keys = append([]string(nil), u.keys...)
+	return &c
+}
+
+// Enabled reports whether the user may authenticate.
+func (u *User) Enabled() bool {
+	return u.enabled
+}
+
+// CanRun reports whether the user may run cmd, which belongs to categories.
+func (u *User) CanRun(cmd string, categories []string) bool {
+	allowed := false
+	for _, rule := range u.commands {
+		switch {
+		case rule.command != "":
+			if rule.command == cmd {
+				allowed = rule.allow
+			}
+		case rule.category == "all":
+			allowed = rule.allow
+		default:
+			for _, c := range categories {
+				if c == rule.category {
+					allowed = rule.allow
+					break
+				}
+			}
+		}
+	}
+	return allowed
+}
+
+// CanAccessKey reports whether key matches one of the user's key patterns.
+func (u *User) CanAccessKey(key string) bool {
+	for _, pattern := range u.keys {
+		if protocol.Match(pattern, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *User) checkPassword(password string) bool {
+	if u.nopass {
+		return true
+	}
+	digest := hashPassword(password)
+	for p := range u.passwords {
+		if subtle.ConstantTimeCompare([]byte(p), []byte(digest)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// Describe renders the user as an ACL rule line, as shown by ACL LIST and
+// written to the ACL file.
+func (u *User) Describe() string {
+	parts := []string{"user", u.Name}
+	if u.enabled {
+		parts = append(parts, "on")
+	} else {
+		parts = append(parts, "off")
+	}
+	if u.nopass {
+		parts = append(parts, "nopass")
+	}
+	hashes := make([]string, 0, len(u.passwords))
+	for p := range u.passwords {
+		hashes = append(hashes, "#"+p)
+	}
+	sort.Strings(hashes)
+	parts = append(parts, hashes...)
+	for _, pattern := range u.keys {
+		parts = append(parts, "~"+pattern)
+	}
+	if len(u.keys) == 0 {
+		parts = append(parts, "resetkeys")
+	}
+	if len(u.commands) == 0 {
+		parts = append(parts, "-@all")
+	}
+	for _, rule := range u.commands {
+		parts = append(parts, rule.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+// apply changes the user according to one ACL SETUSER rule.
+func (u *User) apply(rule string) error {
+	lower := strings.ToLower(rule)
+	switch {
+	case lower == "on":
+		u.enabled = true
+	case lower == "off":
+		u.enabled = false
+	case lower == "nopass":
+		u.nopass = true
+		u.passwords = make(map[string]struct{})
+	case lower == "resetpass":
+		u.nopass = false
+		u.passwords = make(map[string]struct{})
+	case strings.HasPrefix(rule, ">"):
+		u.nopass = false
+		u.passwords[hashPassword(rule[1:])] = struct{}{}
+	case strings.HasPrefix(rule, "<"):
+		delete(u.passwords, hashPassword(rule[1:]))
+	case strings.HasPrefix(rule, "#"):
+		digest := strings.ToLower(rule[1:])
+		if len(digest) != sha256.Size*2 || !isHex(digest) {
+			return fmt.Errorf("invalid password hash '%s'", rule[1:])
+		}
+		u.nopass = false
+		u.passwords[digest] = struct{}{}
+	case strings.HasPrefix(rule, "~"):
+		u.keys = append(u.keys, rule[1:])
+	case lower == "allkeys":
+		u.keys = []string{"*"}
+	case lower == "resetkeys":
+		u.keys = nil
+	case lower == "allcommands":
+		u.commands = []commandRule{{allow: true, category: "all"}}
+	case lower == "nocommands":
+		u.commands = nil
+	case strings.HasPrefix(rule, "+@") || strings.HasPrefix(rule, "-@"):
+		category := lower[2:]
+		if category == "all" {
+			// +@all / -@all override everything before them
+			u.commands = nil
+			if rule[0] == '+' {
+				u.commands = []commandRule{{allow: true, category: "all"}}
+			}
+			return nil
+		}
+		if !isCategory(category) {
+			return fmt.Errorf("unknown command category '%s'", category)
+		}
+		u.commands = append(u.commands, commandRule{allow: rule[0] == '+', category: category})
+	case strings.HasPrefix(rule, "+") || strings.HasPrefix(rule, "-"):
+		if len(rule) == 1 {
+			return fmt.Errorf("syntax error in ACL rule '%s'", rule)
+		}
+		u.commands = append(u.commands, commandRule{allow: rule[0] == '+', command: strings.ToUpper(rule[1:])})
+	case lower == "reset":
+		*u = *newUser(u.Name)
+	default:
+		return fmt.Errorf("syntax error in ACL rule '%s'", rule)
+	}
+	return nil
+}
+
+// ACL holds the users known to the server.
+type ACL struct {
+	mu    sync.RWMutex
+	users map[string]*User
+	path  string // ACL file used by LOAD and SAVE, empty if none
+}
+
+// New returns an ACL with only the default user, which can run every
+// command on every key without a password (i.e. authentication is off).
+func New() *ACL {
+	a := &ACL{users: make(map[string]*User)}
+	a.users[DefaultUser] = defaultUser()
+	return a
+}
+
+func defaultUser() *User {
+	u := newUser(DefaultUser)
+	u.enabled = true
+	u.nopass = true
+	u.keys = []string{"*"}
+	u.commands = []commandRule{{allow: true, category: "all"}}
+	return u
+}
+
+// SetRequirePass gives the default user a password, like Redis' requirepass.
+func (a *ACL) SetRequirePass(password string) error {
+	return a.SetUser(DefaultUser, "resetpass", ">"+password)
+}
+
+// DefaultUser returns the default user if it can be used without a
+// password, or nil if connections must authenticate first.
+func (a *ACL) DefaultUser() *User {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	u := a.users[DefaultUser]
+	if u == nil || !u.enabled || !u.nopass {
+		return nil
+	}
+	return u
+}
+
+// Authenticate checks a username/password pair and returns the user.
+func (a *ACL) Authenticate(username, password string) (*User, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	u, ok := a.users[username]
+	if !ok || !u.enabled || !u.checkPassword(password) {
+		return nil, ErrWrongPass
+	}
+	return u, nil
+}
+
+// User returns the current definition of a user, or nil.
+func (a *ACL) User(name string) *User {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.users[name]
+}
+
+// SetUser creates or modifies a user by applying rules in order. Nothing
+// changes if any rule is invalid.
+func (a *ACL) SetUser(name string, rules ...string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	u, ok := a.users[name]
+	if ok {
+		u = u.clone()
+	} else {
+		u = newUser(name)
+	}
+	for _, rule := range rules {
+		if err := u.apply(rule); err != nil {
+			return err
+		}
+	}
+	a.users[name] = u
+	return nil
+}
+
+// DelUser removes users and returns how many existed.
+func (a *ACL) DelUser(names ...string) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	deleted := 0
+	for _, name := range names {
+		if name == DefaultUser {
+			return 0, ErrDefaultUser
+		}
+	}
+	for _, name := range names {
+		if _, ok := a.users[name]; ok {
+			delete(a.users, name)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// Users returns the sorted user names.
+func (a *ACL) Users() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	names := make([]string, 0, len(a.users))
+	for name := range a.users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// List returns every user as an ACL rule line, sorted by name.
+func (a *ACL) List() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	names := make([]string, 0, len(a.users))
+	for name := range a.users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		lines = append(lines, a.users[name].Describe())
+	}
+	return lines
+}
+
+// LoadFile replaces every user with the ones defined in path. Each line has
+// the form "user <name> <rule> ..."; blank lines and '#' comments are
+// ignored. If the file has no default user, the built-in one is kept.
+// The path is remembered for Reload and Save.
+func (a *ACL) LoadFile(path string) error {
+	users, err := parseFile(path)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := users[DefaultUser]; !ok {
+		if current, ok := a.users[DefaultUser]; ok {
+			users[DefaultUser] = current
+		} else {
+			users[DefaultUser] = defaultUser()
+		}
+	}
+	a.users = users
+	a.path = path
+	return nil
+}
+
+// Reload re-reads the file given to LoadFile.
+func (a *ACL) Reload() error {
+	a.mu.RLock()
+	path := a.path
+	a.mu.RUnlock()
+	if path == "" {
+		return errors.New("This instance is not configured to use an ACL file.")
+	}
+	return a.LoadFile(path)
+}
+
+// Save writes every user to the file given to LoadFile.
+func (a *ACL) Save() error {
+	a.mu.RLock()
+	path := a.path
+	a.mu.RUnlock()
+	if path == "" {
+		return errors.New("This instance is not configured to use an ACL file.")
+	}
+	data := strings.Join(a.List(), "\n") + "\n"
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		return fmt.Errorf("write ACL file failed: %w", err)
+	}
+	return nil
+}
+
+func parseFile(path string) (map[string]*User, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("read ACL file failed: %w", err)
+	}
+	defer f.Close()
+
+	users := make(map[string]*User)
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "user" {
+			return nil, fmt.Errorf("%s:%d: lines must start with 'user <name>'", path, lineNo)
+		}
+		u := newUser(fields[1])
+		for _, rule := range fields[2:] {
+			if err := u.apply(rule); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+			}
+		}
+		users[u.Name] = u
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read ACL file failed: %w", err)
+	}
+	return users, nil
+}
+
+func hashPassword(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+func isCategory(name string) bool {
+	for _, c := range Categories {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -3,22 +3,23 @@ package cli
 import (
 	"bufio"
 	"fmt"
-	"memstash/internal/store"
+	"memstash/internal/server"
 	"os"
-	"strconv"
 	"strings"
-	"time"
 )
 
+// CLI is an interactive prompt on stdin. Commands run through a server
+// session, so they are parsed, validated and executed exactly like those
+// sent over TCP.
 type CLI struct {
-	store  *store.Store
-	reader *bufio.Reader
+	session *server.Session
+	reader  *bufio.Reader
 }
 
-func NewCLI(s *store.Store) *CLI {
+func NewCLI(s *server.Session) *CLI {
 	return &CLI{
-		store:  s,
-		reader: bufio.NewReader(os.Stdin),
+		session: s,
+		reader:  bufio.NewReader(os.Stdin),
 	}
 }
 
@@ -38,7 +39,11 @@ func (c *CLI) Start() {
 	fmt.Println()
 
 	for {
-		fmt.Print("memstash> ")
+		if db := c.session.DB(); db != 0 {
+			fmt.Printf("memstash[%d]> ", db)
+		} else {
+			fmt.Print("memstash> ")
+		}
 
 		input, err := c.reader.ReadString('\n')
 		if err != nil {
@@ -54,206 +59,16 @@ func (c *CLI) Start() {
 		cmd, args := c.parseCommand(input)
 
 		if cmd == "QUIT" || cmd == "EXIT" {
+			// Saving is left to the shutdown that follows
 			fmt.Println("Goodbye!")
-			c.store.SaveSnapshot("memstash_data.json")
-
 			break
 		}
 
 		c.executeCommand(cmd, args)
 	}
 }
-func (c *CLI) executeCommand(cmd string, args []string) {
-	switch cmd {
-	case "SET":
-		c.handleSet(args)
-	case "GET":
-		c.handleGet(args)
-	case "DELETE", "DEL":
-		c.handleDelete(args)
-	case "SETEX":
-		c.handleSetEx(args)
-	case "TTL":
-		c.handleTTL(args)
-	case "HELP":
-		c.handleHelp(args)
-	case "STATS":
-		c.handleStats(args)
-	case "KEYS":
-		c.handleKeys()
-	case "SAVE":
-		c.store.SaveSnapshot("memstash_data.json")
-	case "LOAD":
-		c.store.LoadSnapshot("memstash_data.json")
-	case "PRINT":
-		c.store.PrintList()
-	case "EXISTS":
-		c.handleExists(args)
-	case "CLEAR":
-		c.store.Clear()
-	case "EXPIRE":
-		c.handleExpire(args)
-	default:
-		fmt.Printf("Unknown command: %s. Type HELP for commands.\n", cmd)
-	}
-}
-
-func (c *CLI) handleStats(args []string) {
-	stats := c.store.Stats()
-	fmt.Println("Keys:", stats.Keys)
-	fmt.Println("Capacity:", stats.Capacity)
-	fmt.Println("Hits:", stats.Hits)
-	fmt.Println("Misses:", stats.Misses)
-	fmt.Println("Evictions:", stats.Evictions)
-}
-
-func (c *CLI) handleSet(args []string) {
-	if len(args) < 2 {
-		fmt.Println("Usage: SET <key> <value>")
-		return
-	}
-
-	key := args[0]
-	value := strings.Join(args[1:], " ")
-
-	err := c.store.Set(key, value)
-	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-	} else {
-		fmt.Println("OK")
-	}
-}
 
-func (c *CLI) handleGet(args []string) {
-	if len(args) < 1 {
-		fmt.Println("Usage: GET <key>")
-		return
-	}
-
-	key := args[0]
-	value, err := c.store.Get(key)
-	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-	} else {
-		fmt.Printf("\"%s\"\n", value)
-	}
-}
-
-func (c *CLI) handleDelete(args []string) {
-	if len(args) < 1 {
-		fmt.Println("Usage: DELETE <key>")
-		return
-	}
-
-	key := args[0]
-	err := c.store.Delete(key)
-	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-	} else {
-		fmt.Println("OK")
-	}
-}
-
-func (c *CLI) handleSetEx(args []string) {
-	if len(args) < 3 {
-		fmt.Println("Usage: SETEX <key> <seconds> <value>")
-		return
-	}
-
-	key := args[0]
-	seconds, err := strconv.Atoi(args[1])
-	if err != nil {
-		fmt.Println("Invalid seconds value")
-		return
-	}
-
-	value := strings.Join(args[2:], " ")
-	ttl := time.Duration(seconds) * time.Second
-
-	err = c.store.SetWithTTL(key, value, ttl)
-	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-	} else {
-		fmt.Printf("OK (expires in %ds)\n", seconds)
-	}
-}
-
-func (c *CLI) handleTTL(args []string) {
-	if len(args) < 1 {
-		fmt.Println("Usage: TTL <key>")
-		return
-	}
-
-	key := args[0]
-	ttl, err := c.store.GetTTL(key)
-	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-	} else {
-		if ttl < 0 {
-			fmt.Println("-1 (no expiration)")
-		} else {
-			fmt.Printf("%d (seconds)\n", int(ttl.Seconds()))
-		}
-	}
-}
-func (c *CLI) handleKeys() {
-	keys := c.store.Keys()
-	fmt.Println(keys)
-}
-
-func (c *CLI) handleExists(args []string) {
-	if len(args) < 1 {
-		fmt.Println("Usage: EXISTS <key>")
-		return
-	}
-	key := args[0]
-	exists := c.store.Exists(key)
-	if exists {
-		fmt.Println("1")
-	} else {
-		fmt.Println("0")
-	}
-}
-func (c *CLI) handleExpire(args []string) {
-	if len(args) < 2 {
-		fmt.Println("Usage: EXPIRE <key> <seconds>")
-		return
-	}
-	key := args[0]
-	seconds, err := strconv.Atoi(args[1])
-	if err != nil {
-		fmt.Println("Invalid seconds value")
-		return
-	}
-	ttl := time.Duration(seconds) * time.Second
-	err = c.store.SetExpiry(key, ttl)
-	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-	} else {
-		fmt.Printf("OK (expires in %ds)\n", seconds)
-	}
-
-}
-
-func (c *CLI) handleHelp(args []string) {
-	fmt.Print(`
-Available Commands:
-  SET <key> <value>          - Set a key-value pair
-  GET <key>                  - Get value by key
-  DELETE <key>               - Delete a key
-  EXISTS <key>               - Check if key exists
-  KEYS                       - List all keys
-  CLEAR                      - Remove all keys
-
-  SETEX <key> <sec> <value>  - Set with expiration (seconds)
-  TTL <key>                  - Get time to live in seconds
-  EXPIRE <key> <seconds>     - Set expiration on existing key
-
-  SAVE [file]                - Save snapshot to disk
-  LOAD [file]                - Load snapshot from disk
-
-  STATS                      - Show statistics
-  HELP                       - Show this help
-  QUIT                       - Exit CLI
-`)
+func (c *CLI) executeCommand(cmd string, args []string) {
+	reply := c.session.Do(append([]string{cmd}, args...))
+	fmt.Println(FormatReply(reply))
 }
@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// FormatReply renders a RESP2 reply for humans, the way redis-cli does:
+// OK, (integer) 1, "value", (nil), (error) ERR ..., and numbered lines for
+// arrays. Bulk strings spanning several lines (HELP, INFO, STATS) are
+// printed as they are.
+func FormatReply(reply string) string {
+	r := bufio.NewReader(strings.NewReader(reply))
+	out, err := formatValue(r, "")
+	if err != nil {
+		return strings.TrimRight(reply, "\r\n")
+	}
+	return out
+}
+
+// formatValue reads one value from r. indent prefixes the lines after the
+// first one of nested arrays.
+func formatValue(r *bufio.Reader, indent string) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return "", fmt.Errorf("empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return "(error) " + line[1:], nil
+	case ':':
+		return "(integer) " + line[1:], nil
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return "", err
+		}
+		if n < 0 {
+			return "(nil)", nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return "", err
+		}
+		s := string(buf[:n])
+		if strings.Contains(s, "\n") {
+			return strings.TrimRight(strings.ReplaceAll(s, "\r\n", "\n"), "\n"), nil
+		}
+		return strconv.Quote(s), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return "", err
+		}
+		if n < 0 {
+			return "(nil)", nil
+		}
+		if n == 0 {
+			return "(empty array)", nil
+		}
+		width := len(strconv.Itoa(n))
+		lines := make([]string, n)
+		for i := 0; i < n; i++ {
+			prefix := fmt.Sprintf("%*d) ", width, i+1)
+			item, err := formatValue(r, indent+strings.Repeat(" ", len(prefix)))
+			if err != nil {
+				return "", err
+			}
+			lines[i] = prefix + item
+		}
+		return strings.Join(lines, "\n"+indent), nil
+	}
+	return "", fmt.Errorf("unexpected reply type %q", line[0])
+}
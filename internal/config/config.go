@@ -0,0 +1,213 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"memstash/internal/protocol"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrUnknownParam = errors.New("unknown configuration parameter")
+	ErrReadOnly     = errors.New("can't set immutable config")
+	ErrNoFile       = errors.New("The server is running without a config file")
+)
+
+// Param is one configuration parameter. Get and Set convert between the
+// parameter's string form, as shown by CONFIG GET, and the live setting.
+type Param struct {
+	Name string
+	Env  string // variable in the config file; "" if it is never persisted
+	Get  func() string
+	Set  func(value string) error // nil for parameters fixed at startup
+}
+
+// Entry is a parameter name and its current value.
+type Entry struct {
+	Name  string
+	Value string
+}
+
+// Registry holds the parameters that CONFIG GET, CONFIG SET and CONFIG
+// REWRITE operate on.
+type Registry struct {
+	mu      sync.Mutex
+	params  map[string]Param
+	changed map[string]bool // set since startup, written by Rewrite
+	file    string
+}
+
+// New creates an empty registry. REWRITE writes to file, a .env style
+// file of KEY=value lines; pass "" if there is none.
+func New(file string) *Registry {
+	return &Registry{
+		params:  make(map[string]Param),
+		changed: make(map[string]bool),
+		file:    file,
+	}
+}
+
+// Register adds p, replacing any parameter with the same name.
+func (r *Registry) Register(p Param) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.params[strings.ToLower(p.Name)] = p
+}
+
+// SetFile changes the file Rewrite writes to.
+func (r *Registry) SetFile(file string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.file = file
+}
+
+// Get returns the parameters whose name matches the glob pattern, sorted
+// by name.
+func (r *Registry) Get(pattern string) []Entry {
+	r.mu.Lock()
+	params := make([]Param, 0, len(r.params))
+	for name, p := range r.params {
+		if protocol.Match(strings.ToLower(pattern), name) {
+			params = append(params, p)
+		}
+	}
+	r.mu.Unlock()
+
+	sort.Slice(params, func(i, j int) bool { return params[i].Name < params[j].Name })
+	entries := make([]Entry, len(params))
+	for i, p := range params {
+		entries[i] = Entry{Name: p.Name, Value: p.Get()}
+	}
+	return entries
+}
+
+// Set changes the parameter name to value.
+func (r *Registry) Set(name, value string) error {
+	r.mu.Lock()
+	p, ok := r.params[strings.ToLower(name)]
+	r.mu.Unlock()
+	if !ok {
+		return ErrUnknownParam
+	}
+	if p.Set == nil {
+		return ErrReadOnly
+	}
+	if err := p.Set(value); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.changed[p.Name] = true
+	r.mu.Unlock()
+	return nil
+}
+
+// Rewrite updates the config file with the current value of every
+// persisted parameter. Lines for parameters already in the file are
+// rewritten in place; parameters changed since startup that the file does
+// not mention are appended. Other lines and comments are kept as they are.
+func (r *Registry) Rewrite() error {
+	r.mu.Lock()
+	file := r.file
+	byEnv := make(map[string]Param)
+	for _, p := range r.params {
+		if p.Env != "" {
+			byEnv[p.Env] = p
+		}
+	}
+	changed := make(map[string]bool, len(r.changed))
+	for name := range r.changed {
+		changed[name] = true
+	}
+	r.mu.Unlock()
+	if file == "" {
+		return ErrNoFile
+	}
+
+	lines, err := readLines(file)
+	if err != nil {
+		return err
+	}
+	written := make(map[string]bool)
+	for i, line := range lines {
+		key, _, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok || strings.HasPrefix(key, "#") {
+			continue
+		}
+		key = strings.TrimSpace(strings.TrimPrefix(key, "export "))
+		if p, ok := byEnv[key]; ok {
+			lines[i] = formatLine(key, p.Get())
+			written[key] = true
+		}
+	}
+	envs := make([]string, 0, len(byEnv))
+	for env, p := range byEnv {
+		if changed[p.Name] && !written[env] {
+			envs = append(envs, env)
+		}
+	}
+	sort.Strings(envs)
+	for _, env := range envs {
+		lines = append(lines, formatLine(env, byEnv[env].Get()))
+	}
+
+	// Write a temporary file and rename it so that a crash never leaves a
+	// truncated config behind.
+	data := strings.Join(lines, "\n") + "\n"
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".tmp")
+	if err != nil {
+		return fmt.Errorf("rewrite config failed: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("rewrite config failed: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("rewrite config failed: %w", err)
+	}
+	if info, err := os.Stat(file); err == nil {
+		os.Chmod(tmp.Name(), info.Mode().Perm())
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		return fmt.Errorf("rewrite config failed: %w", err)
+	}
+	r.mu.Lock()
+	r.changed = make(map[string]bool)
+	r.mu.Unlock()
+	return nil
+}
+
+// readLines returns the lines of file, or none if it does not exist yet.
+func readLines(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read config failed: %w", err)
+	}
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read config failed: %w", err)
+	}
+	return lines, nil
+}
+
+// formatLine writes one KEY=value line, quoting values that a .env parser
+// would otherwise split or strip.
+func formatLine(key, value string) string {
+	if value == "" || strings.ContainsAny(value, " \t#\"'\\") {
+		value = fmt.Sprintf("%q", value)
+	}
+	return key + "=" + value
+}
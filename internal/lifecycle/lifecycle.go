@@ -0,0 +1,161 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultTimeout is how long in-flight requests may take to finish once a
+// shutdown starts.
+const DefaultTimeout = 10 * time.Second
+
+// Mode says whether a shutdown saves a snapshot.
+type Mode int
+
+const (
+	Save   Mode = iota // save before exiting (the default)
+	NoSave             // exit without saving
+)
+
+// ParseMode converts the argument of SHUTDOWN ("", "save" or "nosave").
+func ParseMode(s string) (Mode, error) {
+	switch strings.ToLower(s) {
+	case "", "save":
+		return Save, nil
+	case "nosave":
+		return NoSave, nil
+	}
+	return Save, fmt.Errorf("invalid shutdown mode %q: must be SAVE or NOSAVE", s)
+}
+
+// Component is a part of the process that stops gracefully, such as a
+// listener: it stops accepting work and waits for in-flight work until ctx
+// expires.
+type Component interface {
+	Shutdown(ctx context.Context) error
+}
+
+// Coordinator shuts the process down in order: it stops every component
+// and drains their in-flight requests, saves, then runs the stop hooks
+// (e.g. background tickers). Shutdown may be triggered by a signal, the
+// SHUTDOWN command or the HTTP API; only the first trigger runs it.
+type Coordinator struct {
+	timeout time.Duration
+
+	mu         sync.Mutex
+	components []Component
+	save       func() error
+	stops      []func()
+
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+// New creates a coordinator that gives components timeout to drain.
+func New(timeout time.Duration) *Coordinator {
+	return &Coordinator{timeout: timeout, done: make(chan struct{})}
+}
+
+// Add registers a component. All components are shut down concurrently.
+func (c *Coordinator) Add(comp Component) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.components = append(c.components, comp)
+}
+
+// OnSave sets the function that flushes persistence once the components
+// have drained.
+func (c *Coordinator) OnSave(fn func() error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.save = fn
+}
+
+// OnStop registers a function run after saving, in registration order.
+func (c *Coordinator) OnStop(fn func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stops = append(c.stops, fn)
+}
+
+// Shutdown runs the shutdown sequence once and waits for it to finish.
+// Later calls, whatever their mode, wait for the first one and return its
+// result.
+func (c *Coordinator) Shutdown(mode Mode) error {
+	c.once.Do(func() {
+		c.err = c.run(mode)
+		close(c.done)
+	})
+	<-c.done
+	return c.err
+}
+
+// Done is closed when a shutdown has completed.
+func (c *Coordinator) Done() <-chan struct{} {
+	return c.done
+}
+
+// HandleSignals starts a shutdown (with Save) on SIGINT or SIGTERM.
+func (c *Coordinator) HandleSignals() {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-sigChan:
+			log.Printf("Received %v, shutting down", sig)
+			c.Shutdown(Save)
+		case <-c.done:
+		}
+		signal.Stop(sigChan)
+	}()
+}
+
+func (c *Coordinator) run(mode Mode) error {
+	c.mu.Lock()
+	components := append([]Component(nil), c.components...)
+	save := c.save
+	stops := append([]func(){}, c.stops...)
+	c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	// Drain every listener at once so the deadline applies to all of them.
+	errs := make([]error, len(components))
+	var wg sync.WaitGroup
+	for i, comp := range components {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = comp.Shutdown(ctx)
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		log.Printf("Shutdown: not every request finished in time: %v", err)
+	}
+
+	var saveErr error
+	if mode == Save && save != nil {
+		if saveErr = save(); saveErr != nil {
+			log.Printf("Shutdown: save failed: %v", saveErr)
+		} else {
+			log.Println("Shutdown: data saved")
+		}
+	}
+
+	for _, stop := range stops {
+		stop()
+	}
+	log.Println("Shutdown complete")
+	return errors.Join(append(errs, saveErr)...)
+}
@@ -0,0 +1,108 @@
+package protocol
+
+// Match reports whether s matches the Redis-style glob pattern. '*'
+// matches any sequence of bytes (including none), '?' exactly one byte,
+// "[abc]" one of the listed bytes (ranges such as "[a-z]" are allowed),
+// "[^abc]" any byte except the listed ones, and '\' makes the next byte
+// literal. Matching works on bytes, so patterns are binary safe like keys.
+func Match(pattern, s string) bool {
+	p, i := 0, 0
+	// Position to resume from when the last '*' has to swallow one more byte.
+	starP, starI := -1, 0
+
+	for i < len(s) {
+		if p < len(pattern) {
+			switch pattern[p] {
+			case '*':
+				// Collapse consecutive stars; a trailing star matches everything.
+				for p < len(pattern) && pattern[p] == '*' {
+					p++
+				}
+				if p == len(pattern) {
+					return true
+				}
+				starP, starI = p, i
+				continue
+			case '?':
+				p++
+				i++
+				continue
+			case '[':
+				if next, ok := matchClass(pattern, p, s[i]); ok {
+					p = next
+					i++
+					continue
+				}
+			case '\\':
+				if p+1 < len(pattern) {
+					if pattern[p+1] == s[i] {
+						p += 2
+						i++
+						continue
+					}
+					break
+				}
+				fallthrough
+			default:
+				if pattern[p] == s[i] {
+					p++
+					i++
+					continue
+				}
+			}
+		}
+		// Mismatch: let the last star absorb one more byte and retry.
+		if starP < 0 {
+			return false
+		}
+		starI++
+		p, i = starP, starI
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// matchClass matches c against the character class starting at pattern[p]
+// ('['). It returns the index just past the class and whether c matched.
+// An unterminated class extends to the end of the pattern, as in Redis.
+func matchClass(pattern string, p int, c byte) (int, bool) {
+	p++ // skip '['
+	negate := false
+	if p < len(pattern) && pattern[p] == '^' {
+		negate = true
+		p++
+	}
+
+	matched := false
+	for p < len(pattern) && pattern[p] != ']' {
+		switch {
+		case pattern[p] == '\\' && p+1 < len(pattern):
+			p++
+			if pattern[p] == c {
+				matched = true
+			}
+			p++
+		case p+2 < len(pattern) && pattern[p+1] == '-' && pattern[p+2] != ']':
+			lo, hi := pattern[p], pattern[p+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			if c >= lo && c <= hi {
+				matched = true
+			}
+			p += 3
+		default:
+			if pattern[p] == c {
+				matched = true
+			}
+			p++
+		}
+	}
+	if p < len(pattern) {
+		p++ // skip ']'
+	}
+	return p, matched != negate
+}
@@ -0,0 +1,308 @@
+package protocol
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MaxBulkLen is the largest bulk string a client may send (512 MB, as in Redis).
+	MaxBulkLen = 512 * 1024 * 1024
+	// MaxMultiBulkLen is the largest number of arguments in a single request.
+	MaxMultiBulkLen = 1024 * 1024
+	// MaxInlineLen caps inline commands and the header lines of multi-bulk
+	// requests: a line is rejected as soon as it grows past it, so a client
+	// sending no newline cannot grow the buffer forever.
+	MaxInlineLen = 64 * 1024
+
+	// bulkPrealloc is the most a bulk string header makes the reader
+	// allocate before the data arrives; larger strings grow as they are read.
+	bulkPrealloc = 64 * 1024
+)
+
+// errLineTooLong is returned by readLine for a line over MaxInlineLen.
+var errLineTooLong = errors.New("line too long")
+
+// ErrProtocol is returned (wrapped) when the client sends malformed RESP.
+// The connection cannot be resynchronised after one of these.
+var ErrProtocol = errors.New("Protocol error")
+
+func protocolError(format string, a ...any) error {
+	return fmt.Errorf("%w: %s", ErrProtocol, fmt.Sprintf(format, a...))
+}
+
+// Reader is a streaming RESP request decoder. It understands the multi-bulk
+// arrays sent by Redis client libraries ("*2\r\n$3\r\nGET\r\n$3\r\nfoo\r\n")
+// as well as inline commands typed into telnet or nc ("GET foo\r\n").
+// Bulk strings are read by length, so arguments are binary safe.
+type Reader struct {
+	rd *bufio.Reader
+}
+
+// NewReader wraps r in a RESP request decoder.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{rd: bufio.NewReader(r)}
+}
+
+// Buffered returns the number of bytes already read from the connection
+// but not yet decoded.
+func (r *Reader) Buffered() int {
+	return r.rd.Buffered()
+}
+
+// Wait blocks until input arrives or the connection fails, without
+// consuming anything, and returns the read error in the second case.
+func (r *Reader) Wait() error {
+	_, err := r.rd.Peek(1)
+	return err
+}
+
+// ReadCommand decodes the next request. Blank inline lines and empty arrays
+// are skipped, so a nil error always comes with at least one argument.
+func (r *Reader) ReadCommand() ([]string, error) {
+	for {
+		args, err := r.readRequest()
+		if err != nil {
+			return nil, err
+		}
+		if len(args) > 0 {
+			return args, nil
+		}
+	}
+}
+
+func (r *Reader) readRequest() ([]string, error) {
+	prefix, err := r.rd.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if prefix[0] != '*' {
+		return r.readInline()
+	}
+
+	line, err := r.readLine()
+	if errors.Is(err, errLineTooLong) {
+		return nil, protocolError("too big mbulk count string")
+	}
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(line[1:])
+	if err != nil || count > MaxMultiBulkLen {
+		return nil, protocolError("invalid multibulk length")
+	}
+	if count <= 0 {
+		return nil, nil
+	}
+
+	args := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		arg, err := r.readBulk()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+func (r *Reader) readBulk() (string, error) {
+	line, err := r.readLine()
+	if errors.Is(err, errLineTooLong) {
+		return "", protocolError("too big bulk count string")
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(line) == 0 || line[0] != '$' {
+		return "", protocolError("expected '$', got '%s'", firstByte(line))
+	}
+	size, err := strconv.Atoi(line[1:])
+	if err != nil || size < 0 || size > MaxBulkLen {
+		return "", protocolError("invalid bulk length")
+	}
+
+	buf, err := r.readN(size + 2)
+	if err != nil {
+		return "", unexpectedEOF(err)
+	}
+	if buf[size] != '\r' || buf[size+1] != '\n' {
+		return "", protocolError("bulk string not terminated by CRLF")
+	}
+	return string(buf[:size]), nil
+}
+
+func (r *Reader) readInline() ([]string, error) {
+	line, err := r.readLine()
+	if errors.Is(err, errLineTooLong) {
+		return nil, protocolError("too big inline request")
+	}
+	if err != nil {
+		return nil, err
+	}
+	args, err := SplitArgs(line)
+	if err != nil {
+		return nil, protocolError("%s", err.Error())
+	}
+	return args, nil
+}
+
+// readLine reads up to '\n' and strips the line terminator. Inline requests
+// are allowed to end with a bare '\n', like they are in Redis. A line longer
+// than MaxInlineLen fails with errLineTooLong before it is read in full.
+func (r *Reader) readLine() (string, error) {
+	var buf []byte
+	for {
+		chunk, err := r.rd.ReadSlice('\n')
+		if len(buf)+len(chunk) > MaxInlineLen+2 {
+			return "", errLineTooLong
+		}
+		buf = append(buf, chunk...)
+		if err == nil {
+			break
+		}
+		if err != bufio.ErrBufferFull {
+			if len(buf) > 0 {
+				return "", unexpectedEOF(err)
+			}
+			return "", err
+		}
+	}
+	line := strings.TrimSuffix(string(buf), "\n")
+	line = strings.TrimSuffix(line, "\r")
+	return line, nil
+}
+
+// readN reads exactly n bytes. The buffer starts at bulkPrealloc bytes at
+// most and doubles as data arrives, so a large length costs memory only
+// once the client actually sends that much.
+func (r *Reader) readN(n int) ([]byte, error) {
+	buf := make([]byte, 0, min(n, bulkPrealloc))
+	for len(buf) < n {
+		if len(buf) == cap(buf) {
+			buf = slices.Grow(buf, min(cap(buf), n-len(buf)))
+		}
+		m, err := r.rd.Read(buf[len(buf):min(cap(buf), n)])
+		buf = buf[:len(buf)+m]
+		if err != nil && len(buf) < n {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func firstByte(s string) string {
+	if s == "" {
+		return ""
+	}
+	return s[:1]
+}
+
+// SplitArgs splits an inline command line into arguments. Arguments are
+// separated by whitespace and may be quoted: "double quotes" understand the
+// usual escapes (\n, \t, \", \xHH ...), 'single quotes' only understand \'.
+func SplitArgs(line string) ([]string, error) {
+	var args []string
+	i := 0
+	for {
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i >= len(line) {
+			return args, nil
+		}
+
+		var (
+			current  strings.Builder
+			inDouble bool
+			inSingle bool
+			done     bool
+		)
+		for !done {
+			if i >= len(line) {
+				if inDouble || inSingle {
+					return nil, errors.New("unbalanced quotes in request")
+				}
+				break
+			}
+			c := line[i]
+			switch {
+			case inDouble:
+				if c == '\\' && i+3 < len(line) && line[i+1] == 'x' && isHex(line[i+2]) && isHex(line[i+3]) {
+					b, _ := strconv.ParseUint(line[i+2:i+4], 16, 8)
+					current.WriteByte(byte(b))
+					i += 3
+				} else if c == '\\' && i+1 < len(line) {
+					i++
+					switch line[i] {
+					case 'n':
+						current.WriteByte('\n')
+					case 'r':
+						current.WriteByte('\r')
+					case 't':
+						current.WriteByte('\t')
+					case 'b':
+						current.WriteByte('\b')
+					case 'a':
+						current.WriteByte('\a')
+					default:
+						current.WriteByte(line[i])
+					}
+				} else if c == '"' {
+					// closing quote must be followed by a space or nothing
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, errors.New("unbalanced quotes in request")
+					}
+					done = true
+				} else {
+					current.WriteByte(c)
+				}
+			case inSingle:
+				if c == '\\' && i+1 < len(line) && line[i+1] == '\'' {
+					i++
+					current.WriteByte('\'')
+				} else if c == '\'' {
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, errors.New("unbalanced quotes in request")
+					}
+					done = true
+				} else {
+					current.WriteByte(c)
+				}
+			default:
+				switch {
+				case isSpace(c):
+					done = true
+				case c == '"':
+					inDouble = true
+				case c == '\'':
+					inSingle = true
+				default:
+					current.WriteByte(c)
+				}
+			}
+			i++
+		}
+		args = append(args, current.String())
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
@@ -0,0 +1,127 @@
+package protocol
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ReplyError is an error reply, e.g. "-WRONGTYPE Operation against...".
+type ReplyError struct {
+	Code string // "ERR", "WRONGTYPE", "NOPERM", ...
+	Msg  string
+}
+
+func (e *ReplyError) Error() string {
+	return e.Msg
+}
+
+// ParseReply decodes one RESP2 or RESP3 reply into Go values, for front
+// ends that run commands in-process and need their result rather than the
+// wire format:
+//
+//	simple and bulk strings, verbatim text  string
+//	integers                                 int64
+//	doubles                                  float64
+//	booleans                                 bool
+//	big numbers                              *big.Int
+//	arrays, sets and pushes                  []any
+//	maps                                     map[string]any
+//	nulls                                    nil
+//
+// An error reply is returned as a *ReplyError error; nested in an array it
+// is a *ReplyError value.
+func ParseReply(reply string) (any, error) {
+	v, err := readReply(bufio.NewReader(strings.NewReader(reply)))
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := v.(*ReplyError); ok {
+		return nil, e
+	}
+	return v, nil
+}
+
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, protocolError("empty reply")
+	}
+	kind, body := line[0], line[1:]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		code, msg, _ := strings.Cut(body, " ")
+		return &ReplyError{Code: code, Msg: msg}, nil
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case ',':
+		return strconv.ParseFloat(body, 64)
+	case '#':
+		return body == "t", nil
+	case '(':
+		n, ok := new(big.Int).SetString(body, 10)
+		if !ok {
+			return nil, protocolError("invalid big number %q", body)
+		}
+		return n, nil
+	case '_':
+		return nil, nil
+	case '$', '=':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, protocolError("invalid bulk length")
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		s := string(buf[:n])
+		if kind == '=' && len(s) >= 4 {
+			s = s[4:] // the "txt:" format hint
+		}
+		return s, nil
+	case '*', '~', '>', '%':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, protocolError("invalid aggregate length")
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		if kind == '%' {
+			m := make(map[string]any, n)
+			for range n {
+				k, err := readReply(r)
+				if err != nil {
+					return nil, err
+				}
+				v, err := readReply(r)
+				if err != nil {
+					return nil, err
+				}
+				m[fmt.Sprint(k)] = v
+			}
+			return m, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, protocolError("unexpected reply type %q", kind)
+}
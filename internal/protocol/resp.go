@@ -29,6 +29,11 @@ func FormatNull() string {
 	return "$-1\r\n"
 }
 
+// FormatNullArray returns a RESP null array "*-1\r\n"
+func FormatNullArray() string {
+	return "*-1\r\n"
+}
+
 // FormatInteger returns a RESP integer ":<n>\r\n"
 func FormatInteger(n int64) string {
 	return fmt.Sprintf(":%d\r\n", n)
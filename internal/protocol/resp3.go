@@ -0,0 +1,111 @@
+package protocol
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Protocol versions a client can negotiate with HELLO.
+const (
+	RESP2 = 2
+	RESP3 = 3
+)
+
+// FormatSimpleString returns a RESP simple string "+<s>\r\n"
+func FormatSimpleString(s string) string {
+	return "+" + s + "\r\n"
+}
+
+// FormatErrorCode returns a RESP error with a custom code, e.g. "-NOPROTO <msg>\r\n"
+func FormatErrorCode(code, msg string) string {
+	return fmt.Sprintf("-%s %s\r\n", code, msg)
+}
+
+// FormatArray returns a RESP array "*<n>\r\n" followed by the already
+// formatted items.
+func FormatArray(items []string) string {
+	return aggregate('*', len(items), items)
+}
+
+// FormatBulkStrings returns a RESP array of bulk strings.
+func FormatBulkStrings(vals []string) string {
+	items := make([]string, len(vals))
+	for i, v := range vals {
+		items[i] = FormatBulkString(v)
+	}
+	return FormatArray(items)
+}
+
+// FormatNull3 returns the RESP3 null "_\r\n"
+func FormatNull3() string {
+	return "_\r\n"
+}
+
+// FormatMap returns a RESP3 map "%<n>\r\n". pairs holds already formatted
+// keys and values, alternating.
+func FormatMap(pairs []string) string {
+	return aggregate('%', len(pairs)/2, pairs)
+}
+
+// FormatSet returns a RESP3 set "~<n>\r\n" followed by the formatted items.
+func FormatSet(items []string) string {
+	return aggregate('~', len(items), items)
+}
+
+// FormatPush returns a RESP3 push frame ">n\r\n" followed by the formatted
+// items. Pushes are out-of-band data such as pub/sub messages.
+func FormatPush(items []string) string {
+	return aggregate('>', len(items), items)
+}
+
+// FormatDouble returns a RESP3 double ",<f>\r\n"
+func FormatDouble(f float64) string {
+	return "," + FloatString(f) + "\r\n"
+}
+
+// FormatBoolean returns a RESP3 boolean "#t\r\n" or "#f\r\n"
+func FormatBoolean(b bool) string {
+	if b {
+		return "#t\r\n"
+	}
+	return "#f\r\n"
+}
+
+// FormatBigNumber returns a RESP3 big number "(<n>\r\n"
+func FormatBigNumber(n *big.Int) string {
+	return "(" + n.String() + "\r\n"
+}
+
+// FormatVerbatim returns a RESP3 verbatim string "=<len>\r\n<fmt>:<text>\r\n".
+// format is a three letter hint such as "txt" or "mkd".
+func FormatVerbatim(format, text string) string {
+	return fmt.Sprintf("=%d\r\n%s:%s\r\n", len(text)+4, format, text)
+}
+
+func aggregate(kind byte, n int, items []string) string {
+	var b strings.Builder
+	b.WriteByte(kind)
+	b.WriteString(strconv.Itoa(n))
+	b.WriteString("\r\n")
+	for _, item := range items {
+		b.WriteString(item)
+	}
+	return b.String()
+}
+
+// FloatString renders f the way Redis does: "inf", "-inf" and "nan" for
+// the special values, the shortest exact representation otherwise.
+func FloatString(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
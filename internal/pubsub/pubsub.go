@@ -0,0 +1,299 @@
+package pubsub
+
+import (
+	"memstash/internal/protocol"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultBufferSize is the number of undelivered messages a subscriber may
+// have queued before the overflow policy kicks in.
+const DefaultBufferSize = 1024
+
+// OverflowPolicy decides what happens to a subscriber whose buffer is full.
+type OverflowPolicy int
+
+const (
+	// DropMessages discards new messages until the subscriber catches up.
+	DropMessages OverflowPolicy = iota
+	// Disconnect closes the subscriber; its connection is dropped.
+	Disconnect
+)
+
+// ParsePolicy converts "drop" or "disconnect" to an OverflowPolicy.
+func ParsePolicy(name string) (OverflowPolicy, bool) {
+	switch name {
+	case "drop":
+		return DropMessages, true
+	case "disconnect":
+		return Disconnect, true
+	}
+	return DropMessages, false
+}
+
+// Message is a published message as delivered to one subscriber.
+type Message struct {
+	Pattern string // pattern that matched, empty for exact channel subscriptions
+	Channel string
+	Payload string
+}
+
+// Subscriber is one consumer of the hub, usually a client connection.
+// Publishers never block on it: messages go to a bounded buffer.
+type Subscriber struct {
+	messages   chan Message
+	done       chan struct{}
+	closeOnce  sync.Once
+	overflowed atomic.Bool
+	dropped    atomic.Int64
+
+	// Guarded by Hub.mu
+	channels map[string]struct{}
+	patterns map[string]struct{}
+}
+
+// Messages returns the channel messages are delivered on.
+func (s *Subscriber) Messages() <-chan Message {
+	return s.messages
+}
+
+// Done is closed once the subscriber has been removed from the hub, either
+// by Remove or because it overflowed under the Disconnect policy.
+func (s *Subscriber) Done() <-chan struct{} {
+	return s.done
+}
+
+// Overflowed reports whether the subscriber was closed for being too slow.
+func (s *Subscriber) Overflowed() bool {
+	return s.overflowed.Load()
+}
+
+// Dropped returns the number of messages discarded under DropMessages.
+func (s *Subscriber) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+func (s *Subscriber) close() {
+	s.closeOnce.Do(func() { close(s.done) })
+}
+
+// Hub routes published messages to subscribers of exact channels and of
+// glob patterns (see protocol.Match).
+type Hub struct {
+	mu       sync.RWMutex
+	channels map[string]map[*Subscriber]struct{}
+	patterns map[string]map[*Subscriber]struct{}
+	buffer   int
+	policy   OverflowPolicy
+}
+
+// NewHub creates a hub whose subscribers buffer up to bufferSize messages
+// and are handled according to policy when the buffer is full.
+func NewHub(bufferSize int, policy OverflowPolicy) *Hub {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+	return &Hub{
+		channels: make(map[string]map[*Subscriber]struct{}),
+		patterns: make(map[string]map[*Subscriber]struct{}),
+		buffer:   bufferSize,
+		policy:   policy,
+	}
+}
+
+// NewSubscriber creates a subscriber with no subscriptions.
+func (h *Hub) NewSubscriber() *Subscriber {
+	return &Subscriber{
+		messages: make(chan Message, h.buffer),
+		done:     make(chan struct{}),
+		channels: make(map[string]struct{}),
+		patterns: make(map[string]struct{}),
+	}
+}
+
+// Subscribe adds sub to channel and returns its total subscription count.
+func (h *Hub) Subscribe(sub *Subscriber, channel string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	add(h.channels, sub, channel)
+	sub.channels[channel] = struct{}{}
+	return len(sub.channels) + len(sub.patterns)
+}
+
+// Unsubscribe removes sub from channel and returns its remaining subscription count.
+func (h *Hub) Unsubscribe(sub *Subscriber, channel string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	remove(h.channels, sub, channel)
+	delete(sub.channels, channel)
+	return len(sub.channels) + len(sub.patterns)
+}
+
+// PSubscribe adds sub to every channel matching pattern.
+func (h *Hub) PSubscribe(sub *Subscriber, pattern string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	add(h.patterns, sub, pattern)
+	sub.patterns[pattern] = struct{}{}
+	return len(sub.channels) + len(sub.patterns)
+}
+
+// PUnsubscribe removes the pattern subscription.
+func (h *Hub) PUnsubscribe(sub *Subscriber, pattern string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	remove(h.patterns, sub, pattern)
+	delete(sub.patterns, pattern)
+	return len(sub.channels) + len(sub.patterns)
+}
+
+// SubscribedChannels returns the exact channels sub is subscribed to, sorted.
+func (h *Hub) SubscribedChannels(sub *Subscriber) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return sortedKeys(sub.channels)
+}
+
+// SubscribedPatterns returns the patterns sub is subscribed to, sorted.
+func (h *Hub) SubscribedPatterns(sub *Subscriber) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return sortedKeys(sub.patterns)
+}
+
+// Count returns the number of channels and patterns sub is subscribed to.
+func (h *Hub) Count(sub *Subscriber) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(sub.channels) + len(sub.patterns)
+}
+
+// Remove drops every subscription of sub and closes it.
+func (h *Hub) Remove(sub *Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.removeLocked(sub)
+}
+
+func (h *Hub) removeLocked(sub *Subscriber) {
+	for channel := range sub.channels {
+		remove(h.channels, sub, channel)
+	}
+	for pattern := range sub.patterns {
+		remove(h.patterns, sub, pattern)
+	}
+	sub.channels = make(map[string]struct{})
+	sub.patterns = make(map[string]struct{})
+	sub.close()
+}
+
+// Publish delivers payload to every subscriber of channel and of a matching
+// pattern, and returns how many subscriptions it was routed to. It never
+// blocks: a full subscriber either misses the message or is disconnected.
+func (h *Hub) Publish(channel, payload string) int {
+	h.mu.RLock()
+	var slow []*Subscriber
+	receivers := 0
+	for sub := range h.channels[channel] {
+		receivers++
+		if !h.deliver(sub, Message{Channel: channel, Payload: payload}) {
+			slow = append(slow, sub)
+		}
+	}
+	for pattern, subs := range h.patterns {
+		if !protocol.Match(pattern, channel) {
+			continue
+		}
+		for sub := range subs {
+			receivers++
+			if !h.deliver(sub, Message{Pattern: pattern, Channel: channel, Payload: payload}) {
+				slow = append(slow, sub)
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	if len(slow) > 0 {
+		h.mu.Lock()
+		for _, sub := range slow {
+			h.removeLocked(sub)
+		}
+		h.mu.Unlock()
+	}
+	return receivers
+}
+
+// deliver queues msg for sub without blocking. It returns false if sub must
+// be disconnected.
+func (h *Hub) deliver(sub *Subscriber, msg Message) bool {
+	select {
+	case sub.messages <- msg:
+		return true
+	default:
+	}
+	if h.policy == Disconnect {
+		sub.overflowed.Store(true)
+		return false
+	}
+	sub.dropped.Add(1)
+	return true
+}
+
+// Channels returns the active channels (with at least one subscriber)
+// matching pattern; an empty pattern matches all of them.
+func (h *Hub) Channels(pattern string) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	channels := make([]string, 0, len(h.channels))
+	for channel := range h.channels {
+		if pattern == "" || protocol.Match(pattern, channel) {
+			channels = append(channels, channel)
+		}
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// NumSub returns the number of exact subscribers of channel.
+func (h *Hub) NumSub(channel string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.channels[channel])
+}
+
+// NumPat returns the number of distinct subscribed patterns.
+func (h *Hub) NumPat() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.patterns)
+}
+
+func add(index map[string]map[*Subscriber]struct{}, sub *Subscriber, name string) {
+	subs, ok := index[name]
+	if !ok {
+		subs = make(map[*Subscriber]struct{})
+		index[name] = subs
+	}
+	subs[sub] = struct{}{}
+}
+
+func remove(index map[string]map[*Subscriber]struct{}, sub *Subscriber, name string) {
+	subs, ok := index[name]
+	if !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(index, name)
+	}
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,135 @@
+package ratelimit
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Scope is what a limit applies to.
+type Scope int
+
+const (
+	Connection Scope = iota // one TCP connection or HTTP keep-alive connection
+	User                    // every connection authenticated as the same user
+)
+
+// Rates are the limits of one scope. Zero means unlimited.
+type Rates struct {
+	Commands int64 // commands per second
+	Bytes    int64 // request bytes per second
+}
+
+// bucket is a token bucket holding up to one second worth of tokens.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take refills the bucket at rate tokens per second and takes n tokens
+// from it. A request larger than the whole bucket is let through once the
+// bucket is full and leaves it in debt, so it is never refused forever.
+func (b *bucket) take(rate int64, n float64, now time.Time) bool {
+	if rate <= 0 {
+		return true
+	}
+	burst := float64(rate)
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else {
+		b.tokens = min(burst, b.tokens+now.Sub(b.last).Seconds()*burst)
+	}
+	b.last = now
+	if b.tokens < min(n, burst) {
+		return false
+	}
+	b.tokens -= n
+	return true
+}
+
+// Buckets hold the tokens of one connection or user. The zero value is
+// ready to use and starts full.
+type Buckets struct {
+	mu       sync.Mutex
+	commands bucket
+	bytes    bucket
+}
+
+// take charges one command of size bytes against rates, taking nothing
+// unless both buckets have room.
+func (b *Buckets) take(rates Rates, size int, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	commands, bytes := b.commands, b.bytes
+	if !commands.take(rates.Commands, 1, now) || !bytes.take(rates.Bytes, float64(size), now) {
+		return false
+	}
+	b.commands, b.bytes = commands, bytes
+	return true
+}
+
+// Limiter applies per-connection and per-user rates, which may be changed
+// at any time, and counts the commands it refused.
+type Limiter struct {
+	rates     [2]atomic.Pointer[Rates]
+	throttled [2]atomic.Int64
+
+	mu    sync.Mutex
+	users map[string]*Buckets
+}
+
+// New creates a limiter without limits.
+func New() *Limiter {
+	l := &Limiter{users: make(map[string]*Buckets)}
+	for i := range l.rates {
+		l.rates[i].Store(&Rates{})
+	}
+	return l
+}
+
+// Rates returns the limits of scope.
+func (l *Limiter) Rates(scope Scope) Rates {
+	return *l.rates[scope].Load()
+}
+
+// SetRates changes the limits of scope for the commands that follow.
+func (l *Limiter) SetRates(scope Scope, r Rates) {
+	l.rates[scope].Store(&r)
+}
+
+// Allow charges one command of size bytes to conn, then to user unless
+// user is "" (not authenticated), and reports whether the command may run.
+// A refused command is counted against the scope that refused it. conn may
+// be nil when the caller has no connection to charge.
+func (l *Limiter) Allow(conn *Buckets, user string, size int) bool {
+	now := time.Now()
+	if conn != nil && !conn.take(l.Rates(Connection), size, now) {
+		l.throttled[Connection].Add(1)
+		return false
+	}
+	if user == "" {
+		return true
+	}
+	rates := l.Rates(User)
+	if rates == (Rates{}) {
+		return true
+	}
+	l.mu.Lock()
+	b, ok := l.users[user]
+	if !ok {
+		b = &Buckets{}
+		l.users[user] = b
+	}
+	l.mu.Unlock()
+	if !b.take(rates, size, now) {
+		l.throttled[User].Add(1)
+		return false
+	}
+	return true
+}
+
+// Throttled returns the number of commands refused because of the limits
+// of scope.
+func (l *Limiter) Throttled(scope Scope) int64 {
+	return l.throttled[scope].Load()
+}
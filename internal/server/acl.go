@@ -0,0 +1,164 @@
+package server
+
+import (
+	"fmt"
+	"memstash/internal/acl"
+	"memstash/internal/protocol"
+	"strings"
+)
+
+// selfSubcommands only concern the calling connection, so every
+// authenticated user may run them regardless of its command rules.
+var selfSubcommands = map[string]bool{
+	"CLIENT ID":      true,
+	"CLIENT INFO":    true,
+	"CLIENT SETNAME": true,
+	"CLIENT GETNAME": true,
+
+	"CLIENT TRACKING":     true,
+	"CLIENT TRACKINGINFO": true,
+	"CLIENT GETREDIR":     true,
+}
+
+// SetACL replaces the server's users. Call before Start.
+func (srv *Server) SetACL(a *acl.ACL) {
+	srv.acl = a
+}
+
+// permissionError is returned by checkPermission. code is the RESP error
+// prefix (NOAUTH or NOPERM).
+type permissionError struct {
+	code string
+	msg  string
+}
+
+func (e *permissionError) Error() string {
+	return e.code + " " + e.msg
+}
+
+// checkPermission reports whether u may run cmd on the keys in args. A nil
+// user means the connection has not authenticated.
+func checkPermission(u *acl.User, cmd *Command, args []string) *permissionError {
+	if cmd.hasFlag("no_auth") || (cmd.Name == "ACL" && len(args) > 0 && strings.EqualFold(args[0], "WHOAMI")) {
+		return nil
+	}
+	if u == nil || !u.Enabled() {
+		return &permissionError{"NOAUTH", "Authentication required."}
+	}
+	if len(args) > 0 && selfSubcommands[cmd.Name+" "+strings.ToUpper(args[0])] {
+		return nil
+	}
+	if !u.CanRun(cmd.Name, cmd.Categories) {
+		return &permissionError{"NOPERM",
+			fmt.Sprintf("User %s has no permissions to run the '%s' command", u.Name, strings.ToLower(cmd.Name))}
+	}
+	for _, key := range cmd.keys(args) {
+		if !u.CanAccessKey(key) {
+			return &permissionError{"NOPERM", "No permissions to access a key"}
+		}
+	}
+	return nil
+}
+
+// user returns the current definition of the user c is authenticated as,
+// or nil if c has not authenticated (and the default user needs a password).
+func (srv *Server) user(c *client) *acl.User {
+	if c.user == "" {
+		return srv.acl.DefaultUser()
+	}
+	return srv.acl.User(c.user)
+}
+
+// authenticate switches c to username if the password matches.
+func (srv *Server) authenticate(c *client, username, password string) string {
+	u, err := srv.acl.Authenticate(username, password)
+	if err != nil {
+		return protocol.FormatErrorCode("WRONGPASS", err.Error())
+	}
+	c.mu.Lock()
+	c.user = u.Name
+	c.mu.Unlock()
+	return ""
+}
+
+// handleAuth: AUTH <password> | AUTH <username> <password>
+func (srv *Server) handleAuth(c *client, args []string) string {
+	var username, password string
+	switch len(args) {
+	case 1:
+		username, password = acl.DefaultUser, args[0]
+	case 2:
+		username, password = args[0], args[1]
+	default:
+		return protocol.FormatError("wrong number of arguments for 'AUTH' command")
+	}
+	if errReply := srv.authenticate(c, username, password); errReply != "" {
+		return errReply
+	}
+	return protocol.FormatOK()
+}
+
+// handleACL serves ACL WHOAMI | LIST | USERS | GETUSER | SETUSER | DELUSER | CAT | LOAD | SAVE
+func (srv *Server) handleACL(c *client, args []string) string {
+	sub := strings.ToUpper(args[0])
+	switch sub {
+	case "WHOAMI":
+		if c.user == "" {
+			return protocol.FormatBulkString(acl.DefaultUser)
+		}
+		return protocol.FormatBulkString(c.user)
+
+	case "LIST":
+		return protocol.FormatBulkStrings(srv.acl.List())
+
+	case "USERS":
+		return protocol.FormatBulkStrings(srv.acl.Users())
+
+	case "GETUSER":
+		if len(args) != 2 {
+			return protocol.FormatError("wrong number of arguments for 'ACL|GETUSER' command")
+		}
+		u := srv.acl.User(args[1])
+		if u == nil {
+			return c.formatNull()
+		}
+		return protocol.FormatBulkString(u.Describe())
+
+	case "SETUSER":
+		if len(args) < 2 {
+			return protocol.FormatError("wrong number of arguments for 'ACL|SETUSER' command")
+		}
+		if err := srv.acl.SetUser(args[1], args[2:]...); err != nil {
+			return protocol.FormatError(fmt.Sprintf("Error in ACL SETUSER modifier: %s", err.Error()))
+		}
+		return protocol.FormatOK()
+
+	case "DELUSER":
+		if len(args) < 2 {
+			return protocol.FormatError("wrong number of arguments for 'ACL|DELUSER' command")
+		}
+		n, err := srv.acl.DelUser(args[1:]...)
+		if err != nil {
+			return protocol.FormatError(err.Error())
+		}
+		return protocol.FormatInteger(int64(n))
+
+	case "CAT":
+		return protocol.FormatBulkStrings(acl.Categories)
+
+	case "LOAD":
+		if err := srv.acl.Reload(); err != nil {
+			return protocol.FormatError(err.Error())
+		}
+		return protocol.FormatOK()
+
+	case "SAVE":
+		if err := srv.acl.Save(); err != nil {
+			return protocol.FormatError(err.Error())
+		}
+		return protocol.FormatOK()
+
+	default:
+		return protocol.FormatError(fmt.Sprintf("unknown subcommand '%s'. Try ACL HELP.", args[0]))
+	}
+}
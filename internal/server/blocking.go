@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"math"
+	"memstash/internal/protocol"
+	"memstash/internal/store"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseTimeout reads the timeout of a blocking command, in seconds with
+// an optional fraction; 0 means forever.
+func parseTimeout(arg string) (time.Duration, string) {
+	sec, err := strconv.ParseFloat(arg, 64)
+	if err != nil || math.IsNaN(sec) || math.IsInf(sec, 0) {
+		return 0, protocol.FormatError("timeout is not a float or out of range")
+	}
+	if sec < 0 {
+		return 0, protocol.FormatError("timeout is negative")
+	}
+	return time.Duration(sec * float64(time.Second)), ""
+}
+
+// parseEnd reads the LEFT|RIGHT argument of BLMOVE; LEFT is the head.
+func parseEnd(arg string) (bool, bool) {
+	switch strings.ToUpper(arg) {
+	case "LEFT":
+		return true, true
+	case "RIGHT":
+		return false, true
+	}
+	return false, false
+}
+
+// block runs wait, a blocking pop, for c. The replies of the commands
+// before it in the batch are flushed first, and the writer is released
+// meanwhile so that pub/sub messages and invalidations still reach c.
+// wait's context is cancelled when c disconnects or the server shuts down.
+// The time spent in wait is added to c.waited.
+func (srv *Server) block(c *client, wait func(ctx context.Context)) {
+	// Sessions have no connection to watch, and transaction views never
+	// wait.
+	if c.conn == nil || c.tx != nil {
+		start := time.Now()
+		wait(srv.ctx)
+		c.waited += time.Since(start)
+		return
+	}
+	c.w.Flush()
+	ctx, cancel := context.WithCancel(srv.ctx)
+	defer cancel()
+
+	// Nothing else reads the connection while c waits: a read error
+	// means it is gone. Input arriving stops the watch; it is left in the
+	// buffer for the next command.
+	watched := make(chan struct{})
+	go func() {
+		defer close(watched)
+		if err := c.reader.Wait(); err != nil {
+			cancel()
+		}
+	}()
+
+	c.wmu.Unlock()
+	start := time.Now()
+	wait(ctx)
+	c.waited += time.Since(start)
+	c.wmu.Lock()
+
+	// Stop the watcher before the connection goroutine reads again.
+	c.mu.Lock()
+	c.conn.SetReadDeadline(time.Now())
+	c.mu.Unlock()
+	<-watched
+	c.mu.Lock()
+	if !c.interrupted {
+		c.conn.SetReadDeadline(time.Time{})
+	}
+	c.mu.Unlock()
+}
+
+// handleBLPop: BLPOP key [key ...] timeout
+func (srv *Server) handleBLPop(c *client, args []string) string {
+	return srv.blockingPop(c, args, srv.db(c).BLPop)
+}
+
+// handleBRPop: BRPOP key [key ...] timeout
+func (srv *Server) handleBRPop(c *client, args []string) string {
+	return srv.blockingPop(c, args, srv.db(c).BRPop)
+}
+
+// blockingPop runs BLPOP or BRPOP: it replies with the key and the element
+// popped, or a null array if none arrived in time.
+func (srv *Server) blockingPop(c *client, args []string, pop func(context.Context, time.Duration, ...string) (string, string, error)) string {
+	timeout, errReply := parseTimeout(args[len(args)-1])
+	if errReply != "" {
+		return errReply
+	}
+	var key, v string
+	var err error
+	srv.block(c, func(ctx context.Context) {
+		key, v, err = pop(ctx, timeout, args[:len(args)-1]...)
+	})
+	switch {
+	case errors.Is(err, store.ErrTimeout), errors.Is(err, context.Canceled):
+		return c.formatNullArray()
+	case err != nil:
+		return storeError(err)
+	}
+	return protocol.FormatBulkStrings([]string{key, v})
+}
+
+// handleBLMove: BLMOVE source destination LEFT|RIGHT LEFT|RIGHT timeout
+func (srv *Server) handleBLMove(c *client, args []string) string {
+	srcFront, ok1 := parseEnd(args[2])
+	dstFront, ok2 := parseEnd(args[3])
+	if !ok1 || !ok2 {
+		return protocol.FormatError("syntax error")
+	}
+	timeout, errReply := parseTimeout(args[4])
+	if errReply != "" {
+		return errReply
+	}
+	var v string
+	var err error
+	srv.block(c, func(ctx context.Context) {
+		v, err = srv.db(c).BLMove(ctx, args[0], args[1], srcFront, dstFront, timeout)
+	})
+	switch {
+	case errors.Is(err, store.ErrTimeout), errors.Is(err, context.Canceled):
+		return c.formatNullArray()
+	case err != nil:
+		return storeError(err)
+	}
+	return protocol.FormatBulkString(v)
+}
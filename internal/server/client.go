@@ -0,0 +1,176 @@
+package server
+
+import (
+	"bufio"
+	"memstash/internal/protocol"
+	"memstash/internal/pubsub"
+	"memstash/internal/ratelimit"
+	"memstash/internal/store"
+	"net"
+	"sync"
+	"time"
+)
+
+// client holds the state of a single TCP or Unix socket connection.
+type client struct {
+	id      int64
+	conn    net.Conn
+	reader  *protocol.Reader // nil for sessions
+	addr    string
+	laddr   string
+	unix    bool // connected through the Unix socket
+	local   bool // an in-process Session; not subject to ACL checks
+	created time.Time
+	proto   int    // RESP version negotiated with HELLO; written under mu
+	db      int    // selected database; written under mu
+	user    string // authenticated user, "" until AUTH succeeds; written under mu
+
+	// limits holds the connection's rate limit tokens.
+	limits ratelimit.Buckets
+
+	// waited adds up the time spent waiting in blocking commands, which
+	// call leaves out of their duration.
+	waited time.Duration
+
+	// closeAfterReply is set when a client kills itself with CLIENT KILL:
+	// the reply is still flushed before the connection closes.
+	closeAfterReply bool
+
+	// Registry view, updated by the connection goroutine after every
+	// command and read by CLIENT LIST and GET /clients.
+	mu         sync.Mutex
+	name       string
+	lastCmd    string
+	lastActive time.Time
+	flags      string
+	qbuf, obuf int
+
+	// interrupted is set by Shutdown once it has put a past read
+	// deadline on conn; a blocking command then leaves it in place.
+	interrupted bool
+
+	// Replies and pub/sub messages share the writer; wmu serialises them.
+	wmu sync.Mutex
+	w   *bufio.Writer
+
+	// Pub/sub state; sub is created on the first (P)SUBSCRIBE.
+	sub      *pubsub.Subscriber
+	subCount int
+
+	// monitor receives the lines shown to this connection after MONITOR;
+	// nil for ordinary clients.
+	monitor chan string
+
+	// tracking is set by CLIENT TRACKING ON; nil when tracking is off.
+	// Only the connection goroutine reads or replaces it.
+	tracking *tracker
+
+	// MULTI/EXEC state
+	inMulti  bool
+	multiErr bool                  // a command failed to queue; EXEC aborts
+	queue    [][]string            // queued commands, name first
+	watched  map[watchedKey]uint64 // WATCHed keys and their versions
+	tx       *store.Store          // transaction view while EXEC runs
+}
+
+// watchedKey names a WATCHed key in a specific database.
+type watchedKey struct {
+	db  int
+	key string
+}
+
+func newClient(id int64, conn net.Conn) *client {
+	now := time.Now()
+	c := &client{
+		id:         id,
+		conn:       conn,
+		addr:       conn.RemoteAddr().String(),
+		laddr:      conn.LocalAddr().String(),
+		created:    now,
+		lastActive: now,
+		flags:      "N",
+		proto:      protocol.RESP2,
+		w:          bufio.NewWriter(conn),
+	}
+	// Unix socket peers have no address of their own; name them after
+	// the socket like Redis does ("/tmp/memstash.sock:0").
+	if laddr, ok := conn.LocalAddr().(*net.UnixAddr); ok {
+		c.unix = true
+		c.addr = laddr.Name + ":0"
+		c.laddr = laddr.Name + ":0"
+		c.flags = "U"
+	}
+	return c
+}
+
+// subscribed reports whether the client is in RESP2 subscriber mode, where
+// only pub/sub commands are accepted.
+func (c *client) subscribed() bool {
+	return c.subCount > 0 && !c.resp3()
+}
+
+// interruptRead wakes the connection goroutine if it waits for input, and
+// makes its next read fail.
+func (c *client) interruptRead() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.interrupted = true
+	c.conn.SetReadDeadline(time.Now())
+}
+
+func (c *client) resetMulti() {
+	c.inMulti = false
+	c.multiErr = false
+	c.queue = nil
+}
+
+// ── Reply helpers ───────────────────────────────────────────────────────
+// RESP3 types fall back to their RESP2 shape unless the client
+// negotiated protocol 3 with HELLO.
+
+func (c *client) resp3() bool {
+	return c.proto >= protocol.RESP3
+}
+
+func (c *client) formatNull() string {
+	if c.resp3() {
+		return protocol.FormatNull3()
+	}
+	return protocol.FormatNull()
+}
+
+func (c *client) formatNullArray() string {
+	if c.resp3() {
+		return protocol.FormatNull3()
+	}
+	return protocol.FormatNullArray()
+}
+
+// formatMap sends a map to RESP3 clients and a flat key/value array to RESP2 clients.
+func (c *client) formatMap(pairs []string) string {
+	if c.resp3() {
+		return protocol.FormatMap(pairs)
+	}
+	return protocol.FormatArray(pairs)
+}
+
+func (c *client) formatSet(items []string) string {
+	if c.resp3() {
+		return protocol.FormatSet(items)
+	}
+	return protocol.FormatArray(items)
+}
+
+func (c *client) formatPush(items []string) string {
+	if c.resp3() {
+		return protocol.FormatPush(items)
+	}
+	return protocol.FormatArray(items)
+}
+
+func (c *client) formatVerbatim(format, text string) string {
+	if c.resp3() {
+		return protocol.FormatVerbatim(format, text)
+	}
+	return protocol.FormatBulkString(text)
+}
@@ -0,0 +1,327 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"memstash/internal/acl"
+	"memstash/internal/protocol"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clientInfo is a point-in-time view of a connection, as reported by
+// CLIENT LIST and GET /clients.
+type clientInfo struct {
+	ID        int64  `json:"id"`
+	Addr      string `json:"addr"`
+	LocalAddr string `json:"laddr"`
+	Name      string `json:"name"`
+	Age       int64  `json:"age"`  // seconds since the connection was accepted
+	Idle      int64  `json:"idle"` // seconds since the last command
+	Flags     string `json:"flags"`
+	DB        int    `json:"db"`
+	QueryBuf  int    `json:"qbuf"` // bytes read but not yet parsed
+	OutputBuf int    `json:"obuf"` // reply bytes not yet flushed
+	LastCmd   string `json:"cmd"`
+	User      string `json:"user"`
+	Resp      int    `json:"resp"`
+}
+
+// String formats the info as one CLIENT LIST line.
+func (ci clientInfo) String() string {
+	return fmt.Sprintf("id=%d addr=%s laddr=%s name=%s age=%d idle=%d flags=%s db=%d qbuf=%d obuf=%d cmd=%s user=%s resp=%d",
+		ci.ID, ci.Addr, ci.LocalAddr, ci.Name, ci.Age, ci.Idle, ci.Flags, ci.DB,
+		ci.QueryBuf, ci.OutputBuf, ci.LastCmd, ci.User, ci.Resp)
+}
+
+// touch records the command c just ran. Called by the connection
+// goroutine with wmu held.
+func (c *client) touch(cmd string, qbuf int) {
+	flags := ""
+	if c.subCount > 0 {
+		flags += "P"
+	}
+	if c.inMulti {
+		flags += "x"
+	}
+	if c.closeAfterReply {
+		flags += "c"
+	}
+	if c.monitor != nil {
+		flags += "O"
+	}
+	if c.unix {
+		flags += "U"
+	}
+	if c.tracking != nil {
+		flags += "t"
+		if c.tracking.bcast {
+			flags += "B"
+		}
+	}
+	if flags == "" {
+		flags = "N"
+	}
+
+	c.mu.Lock()
+	c.lastCmd = strings.ToLower(cmd)
+	c.lastActive = time.Now()
+	c.flags = flags
+	c.qbuf = qbuf
+	c.obuf = c.w.Buffered()
+	c.mu.Unlock()
+}
+
+// info returns a snapshot of c for the registry.
+func (c *client) info() clientInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	user := c.user
+	if user == "" {
+		user = acl.DefaultUser
+	}
+	now := time.Now()
+	return clientInfo{
+		ID:        c.id,
+		Addr:      c.addr,
+		LocalAddr: c.laddr,
+		Name:      c.name,
+		Age:       int64(now.Sub(c.created) / time.Second),
+		Idle:      int64(now.Sub(c.lastActive) / time.Second),
+		Flags:     c.flags,
+		DB:        c.db,
+		QueryBuf:  c.qbuf,
+		OutputBuf: c.obuf,
+		LastCmd:   c.lastCmd,
+		User:      user,
+		Resp:      c.proto,
+	}
+}
+
+// ── Registry ────────────────────────────────────────────────────────────
+
+func (srv *Server) registerClient(c *client) {
+	srv.clientsMu.Lock()
+	srv.clients[c.id] = c
+	srv.clientsMu.Unlock()
+}
+
+func (srv *Server) unregisterClient(c *client) {
+	srv.clientsMu.Lock()
+	delete(srv.clients, c.id)
+	srv.clientsMu.Unlock()
+}
+
+// connectedClients returns the registered clients ordered by id.
+func (srv *Server) connectedClients() []*client {
+	srv.clientsMu.Lock()
+	list := make([]*client, 0, len(srv.clients))
+	for _, c := range srv.clients {
+		list = append(list, c)
+	}
+	srv.clientsMu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].id < list[j].id })
+	return list
+}
+
+// ClientCount returns the number of open client connections.
+func (srv *Server) ClientCount() int {
+	srv.clientsMu.Lock()
+	defer srv.clientsMu.Unlock()
+	return len(srv.clients)
+}
+
+// clientInfos returns a snapshot of every connection, ordered by id.
+func (srv *Server) clientInfos() []clientInfo {
+	clients := srv.connectedClients()
+	infos := make([]clientInfo, len(clients))
+	for i, c := range clients {
+		infos[i] = c.info()
+	}
+	return infos
+}
+
+// killClient disconnects target. When self kills itself the connection
+// stays open until its reply has been written.
+func killClient(self, target *client) {
+	if target == self {
+		target.closeAfterReply = true
+		return
+	}
+	target.conn.Close()
+}
+
+// clientFilter selects connections for CLIENT KILL.
+type clientFilter struct {
+	id     int64
+	addr   string
+	laddr  string
+	user   string
+	skipMe bool
+}
+
+func (f clientFilter) matches(self, c *client) bool {
+	if f.skipMe && c == self {
+		return false
+	}
+	if f.id != 0 && c.id != f.id {
+		return false
+	}
+	if f.addr != "" && c.addr != f.addr {
+		return false
+	}
+	if f.laddr != "" && c.laddr != f.laddr {
+		return false
+	}
+	if f.user != "" {
+		c.mu.Lock()
+		user := c.user
+		c.mu.Unlock()
+		if user == "" {
+			user = acl.DefaultUser
+		}
+		if user != f.user {
+			return false
+		}
+	}
+	return true
+}
+
+// parseClientFilter parses the <filter> <value> pairs of CLIENT KILL.
+func parseClientFilter(args []string) (clientFilter, error) {
+	f := clientFilter{skipMe: true}
+	if len(args)%2 != 0 {
+		return f, errors.New("syntax error")
+	}
+	for i := 0; i < len(args); i += 2 {
+		value := args[i+1]
+		switch strings.ToUpper(args[i]) {
+		case "ID":
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || id <= 0 {
+				return f, errors.New("client-id should be greater than 0")
+			}
+			f.id = id
+		case "ADDR":
+			f.addr = value
+		case "LADDR":
+			f.laddr = value
+		case "USER":
+			f.user = value
+		case "SKIPME":
+			switch strings.ToLower(value) {
+			case "yes":
+				f.skipMe = true
+			case "no":
+				f.skipMe = false
+			default:
+				return f, errors.New("syntax error")
+			}
+		default:
+			return f, errors.New("syntax error")
+		}
+	}
+	return f, nil
+}
+
+// ── CLIENT command ──────────────────────────────────────────────────────
+
+// handleClient serves CLIENT ID | INFO | LIST | KILL | SETNAME | GETNAME |
+// TRACKING | TRACKINGINFO | GETREDIR
+func (srv *Server) handleClient(c *client, args []string) string {
+	switch strings.ToUpper(args[0]) {
+	case "ID":
+		return protocol.FormatInteger(c.id)
+
+	case "INFO":
+		return c.formatVerbatim("txt", c.info().String()+"\n")
+
+	case "LIST":
+		ids := map[int64]bool{}
+		if len(args) > 1 {
+			if len(args) < 3 || !strings.EqualFold(args[1], "ID") {
+				return protocol.FormatError("syntax error")
+			}
+			for _, arg := range args[2:] {
+				id, err := strconv.ParseInt(arg, 10, 64)
+				if err != nil || id <= 0 {
+					return protocol.FormatError("Invalid client ID")
+				}
+				ids[id] = true
+			}
+		}
+		var b strings.Builder
+		for _, info := range srv.clientInfos() {
+			if len(ids) > 0 && !ids[info.ID] {
+				continue
+			}
+			b.WriteString(info.String())
+			b.WriteString("\n")
+		}
+		return c.formatVerbatim("txt", b.String())
+
+	case "KILL":
+		// Old form: CLIENT KILL ip:port
+		if len(args) == 2 {
+			for _, target := range srv.connectedClients() {
+				if target.addr == args[1] {
+					killClient(c, target)
+					return protocol.FormatOK()
+				}
+			}
+			return protocol.FormatError("No such client")
+		}
+		if len(args) < 3 {
+			return protocol.FormatError("syntax error")
+		}
+		filter, err := parseClientFilter(args[1:])
+		if err != nil {
+			return protocol.FormatError(err.Error())
+		}
+		killed := 0
+		for _, target := range srv.connectedClients() {
+			if filter.matches(c, target) {
+				killClient(c, target)
+				killed++
+			}
+		}
+		return protocol.FormatInteger(int64(killed))
+
+	case "SETNAME":
+		if len(args) != 2 {
+			return protocol.FormatError("wrong number of arguments for 'CLIENT|SETNAME' command")
+		}
+		for _, ch := range args[1] {
+			if ch <= ' ' || ch > '~' {
+				return protocol.FormatError("Client names cannot contain spaces, newlines or special characters.")
+			}
+		}
+		c.mu.Lock()
+		c.name = args[1]
+		c.mu.Unlock()
+		return protocol.FormatOK()
+
+	case "GETNAME":
+		c.mu.Lock()
+		name := c.name
+		c.mu.Unlock()
+		if name == "" {
+			return c.formatNull()
+		}
+		return protocol.FormatBulkString(name)
+
+	case "TRACKING":
+		return srv.handleTracking(c, args[1:])
+
+	case "TRACKINGINFO":
+		return srv.handleTrackingInfo(c)
+
+	case "GETREDIR":
+		return srv.handleGetRedir(c)
+
+	default:
+		return protocol.FormatError(fmt.Sprintf("unknown subcommand '%s'. Try CLIENT HELP.", args[0]))
+	}
+}
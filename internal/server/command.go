@@ -0,0 +1,374 @@
+package server
+
+import (
+	"fmt"
+	"memstash/internal/protocol"
+	"strings"
+)
+
+// Command describes one command of the table that TCP connections, the
+// HTTP API and the CLI all dispatch through. COMMAND, COMMAND INFO and
+// COMMAND DOCS, HELP, the ACL categories and the arity check are all read
+// from it.
+type Command struct {
+	Name string // upper case
+
+	// Arity counts the command name, as in Redis: N means exactly N,
+	// -N means at least N.
+	Arity int
+
+	// Flags are the Redis command flags: write, readonly, admin, pubsub,
+	// fast, blocking, noscript, loading, stale, no_auth, no_multi,
+	// skip_monitor, skip_slowlog.
+	Flags []string
+
+	// Key positions, as in COMMAND INFO: the first and last argument
+	// holding a key (1 is the first argument, -1 the last one) and the
+	// step between keys. All three are 0 for commands without keys.
+	FirstKey, LastKey, Step int
+
+	Categories []string // ACL categories, without the @
+	Group      string   // COMMAND DOCS group
+	Syntax     string   // arguments after the name, shown by HELP
+	Summary    string
+
+	handler commandFunc
+}
+
+// commandFunc is the signature shared by every command handler.
+type commandFunc func(srv *Server, c *client, args []string) string
+
+// commandTable lists every command in the order HELP prints them, and
+// commands indexes it by name. Both are filled in init because some
+// handlers (EXEC, COMMAND) read them themselves.
+var (
+	commandTable []*Command
+	commands     map[string]*Command
+)
+
+func init() {
+	commandTable = []*Command{
+		{Name: "PING", Arity: -1, Flags: []string{"fast", "stale"}, Categories: []string{"connection"}, Group: "connection",
+			Syntax: "[message]", Summary: "Test connection", handler: (*Server).handlePing},
+		{Name: "HELLO", Arity: -1, Flags: []string{"noscript", "loading", "stale", "fast", "no_auth", "skip_monitor", "skip_slowlog"}, Categories: []string{"connection"}, Group: "connection",
+			Syntax: "[2|3] [AUTH u p]", Summary: "Negotiate the RESP protocol version", handler: (*Server).handleHello},
+		{Name: "AUTH", Arity: -2, Flags: []string{"noscript", "loading", "stale", "fast", "no_auth", "skip_monitor", "skip_slowlog"}, Categories: []string{"connection"}, Group: "connection",
+			Syntax: "[user] <password>", Summary: "Authenticate the connection", handler: (*Server).handleAuth},
+		{Name: "ACL", Arity: -2, Flags: []string{"admin", "noscript", "loading", "stale"}, Categories: []string{"admin", "dangerous"}, Group: "server",
+			Syntax: "WHOAMI|LIST|SETUSER|...", Summary: "Inspect and manage users", handler: (*Server).handleACL},
+		{Name: "CLIENT", Arity: -2, Flags: []string{"admin", "noscript", "loading", "stale"}, Categories: []string{"admin", "connection", "dangerous"}, Group: "connection",
+			Syntax: "LIST|KILL|SETNAME|TRACKING|...", Summary: "Inspect and manage connections", handler: (*Server).handleClient},
+
+		{Name: "SET", Arity: -3, Flags: []string{"write"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"write"}, Group: "string",
+			Syntax: "<key> <value>", Summary: "Set a key-value pair", handler: (*Server).handleSet},
+		{Name: "GET", Arity: 2, Flags: []string{"readonly", "fast"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"read"}, Group: "string",
+			Syntax: "<key>", Summary: "Get value by key", handler: (*Server).handleGet},
+		{Name: "DEL", Arity: -2, Flags: []string{"write"}, FirstKey: 1, LastKey: -1, Step: 1, Categories: []string{"write"}, Group: "generic",
+			Syntax: "<key> [key ...]", Summary: "Delete keys", handler: (*Server).handleDelete},
+		{Name: "DELETE", Arity: -2, Flags: []string{"write"}, FirstKey: 1, LastKey: -1, Step: 1, Categories: []string{"write"}, Group: "generic",
+			Syntax: "<key> [key ...]", Summary: "Delete keys (alias of DEL)", handler: (*Server).handleDelete},
+		{Name: "EXISTS", Arity: -2, Flags: []string{"readonly", "fast"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"read"}, Group: "generic",
+			Syntax: "<key>", Summary: "Check if key exists (1/0)", handler: (*Server).handleExists},
+		{Name: "SETEX", Arity: -4, Flags: []string{"write"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"write"}, Group: "string",
+			Syntax: "<key> <sec> <value>", Summary: "Set with expiration", handler: (*Server).handleSetEx},
+		{Name: "TTL", Arity: 2, Flags: []string{"readonly", "fast"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"read"}, Group: "generic",
+			Syntax: "<key>", Summary: "Get time to live", handler: (*Server).handleTTL},
+		{Name: "EXPIRE", Arity: 3, Flags: []string{"write", "fast"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"write"}, Group: "generic",
+			Syntax: "<key> <seconds>", Summary: "Set expiration on key", handler: (*Server).handleExpire},
+		{Name: "KEYS", Arity: -1, Flags: []string{"readonly"}, Categories: []string{"read", "dangerous"}, Group: "generic",
+			Syntax: "[pattern]", Summary: "List keys matching a glob pattern", handler: (*Server).handleKeys},
+		{Name: "SCAN", Arity: -2, Flags: []string{"readonly"}, Categories: []string{"read"}, Group: "generic",
+			Syntax: "<cursor> [MATCH p] ...", Summary: "Iterate over keys in batches", handler: (*Server).handleScan},
+		{Name: "TYPE", Arity: 2, Flags: []string{"readonly", "fast"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"read"}, Group: "generic",
+			Syntax: "<key>", Summary: "Get the type of a key's value", handler: (*Server).handleType},
+		{Name: "SAVE", Arity: 1, Flags: []string{"admin", "noscript"}, Categories: []string{"admin", "dangerous"}, Group: "server",
+			Summary: "Save snapshot to disk", handler: (*Server).handleSave},
+		{Name: "LOAD", Arity: 1, Flags: []string{"admin", "noscript"}, Categories: []string{"admin", "dangerous"}, Group: "server",
+			Summary: "Load snapshot from disk", handler: (*Server).handleLoad},
+		{Name: "CLEAR", Arity: 1, Flags: []string{"write"}, Categories: []string{"write", "dangerous"}, Group: "server",
+			Summary: "Remove all keys of the current database", handler: (*Server).handleClear},
+		{Name: "SELECT", Arity: 2, Flags: []string{"loading", "stale", "fast"}, Categories: []string{"connection"}, Group: "connection",
+			Syntax: "<db>", Summary: "Switch to another database", handler: (*Server).handleSelect},
+		{Name: "MOVE", Arity: 3, Flags: []string{"write", "fast"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"write"}, Group: "generic",
+			Syntax: "<key> <db>", Summary: "Move a key to another database", handler: (*Server).handleMove},
+		{Name: "SWAPDB", Arity: 3, Flags: []string{"write", "fast"}, Categories: []string{"write", "dangerous"}, Group: "server",
+			Syntax: "<db1> <db2>", Summary: "Swap the contents of two databases", handler: (*Server).handleSwapDB},
+		{Name: "FLUSHDB", Arity: -1, Flags: []string{"write"}, Categories: []string{"write", "dangerous"}, Group: "server",
+			Syntax: "[ASYNC|SYNC]", Summary: "Remove all keys of the current database", handler: (*Server).handleFlushDB},
+		{Name: "FLUSHALL", Arity: -1, Flags: []string{"write"}, Categories: []string{"write", "dangerous"}, Group: "server",
+			Syntax: "[ASYNC|SYNC]", Summary: "Remove all keys of every database", handler: (*Server).handleFlushAll},
+		{Name: "STATS", Arity: 1, Flags: []string{"readonly", "fast"}, Categories: []string{"read"}, Group: "server",
+			Summary: "Show statistics", handler: (*Server).handleStats},
+		{Name: "INFO", Arity: -1, Flags: []string{"loading", "stale"}, Categories: []string{"read", "dangerous"}, Group: "server",
+			Syntax: "[section ...]", Summary: "Show server information and counters", handler: (*Server).handleInfo},
+		{Name: "CONFIG", Arity: -2, Flags: []string{"admin", "noscript", "loading", "stale", "no_multi"}, Categories: []string{"admin", "dangerous"}, Group: "server",
+			Syntax: "GET|SET|REWRITE", Summary: "Read and change settings at runtime", handler: (*Server).handleConfig},
+		{Name: "COMMAND", Arity: -1, Flags: []string{"loading", "stale"}, Categories: []string{"connection"}, Group: "server",
+			Syntax: "[COUNT|INFO|DOCS ...]", Summary: "Describe the commands of this table", handler: (*Server).handleCommand},
+		{Name: "MONITOR", Arity: 1, Flags: []string{"admin", "noscript", "loading", "stale", "no_multi"}, Categories: []string{"admin", "dangerous"}, Group: "server",
+			Summary: "Stream every command the server runs", handler: (*Server).handleMonitor},
+		{Name: "SLOWLOG", Arity: -2, Flags: []string{"admin", "loading", "stale"}, Categories: []string{"admin", "dangerous"}, Group: "server",
+			Syntax: "GET [n]|LEN|RESET", Summary: "Inspect commands that exceeded the threshold", handler: (*Server).handleSlowLog},
+
+		{Name: "LPUSH", Arity: -3, Flags: []string{"write", "fast"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"write", "list"}, Group: "list",
+			Syntax: "<key> <elem> [elem ...]", Summary: "Prepend elements to a list", handler: (*Server).handleLPush},
+		{Name: "RPUSH", Arity: -3, Flags: []string{"write", "fast"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"write", "list"}, Group: "list",
+			Syntax: "<key> <elem> [elem ...]", Summary: "Append elements to a list", handler: (*Server).handleRPush},
+		{Name: "LPOP", Arity: -2, Flags: []string{"write", "fast"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"write", "list"}, Group: "list",
+			Syntax: "<key> [count]", Summary: "Remove and return the first elements of a list", handler: (*Server).handleLPop},
+		{Name: "RPOP", Arity: -2, Flags: []string{"write", "fast"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"write", "list"}, Group: "list",
+			Syntax: "<key> [count]", Summary: "Remove and return the last elements of a list", handler: (*Server).handleRPop},
+		{Name: "LRANGE", Arity: 4, Flags: []string{"readonly"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"read", "list"}, Group: "list",
+			Syntax: "<key> <start> <stop>", Summary: "Get a range of elements of a list", handler: (*Server).handleLRange},
+		{Name: "LLEN", Arity: 2, Flags: []string{"readonly", "fast"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"read", "list"}, Group: "list",
+			Syntax: "<key>", Summary: "Get the length of a list", handler: (*Server).handleLLen},
+		{Name: "LINDEX", Arity: 3, Flags: []string{"readonly"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"read", "list"}, Group: "list",
+			Syntax: "<key> <index>", Summary: "Get an element of a list by index", handler: (*Server).handleLIndex},
+		{Name: "LSET", Arity: 4, Flags: []string{"write"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"write", "list"}, Group: "list",
+			Syntax: "<key> <index> <elem>", Summary: "Replace an element of a list by index", handler: (*Server).handleLSet},
+		{Name: "LREM", Arity: 4, Flags: []string{"write"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"write", "list"}, Group: "list",
+			Syntax: "<key> <count> <elem>", Summary: "Remove elements equal to elem from a list", handler: (*Server).handleLRem},
+		{Name: "LTRIM", Arity: 4, Flags: []string{"write"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"write", "list"}, Group: "list",
+			Syntax: "<key> <start> <stop>", Summary: "Keep only a range of elements of a list", handler: (*Server).handleLTrim},
+		{Name: "BLPOP", Arity: -3, Flags: []string{"write", "blocking"}, FirstKey: 1, LastKey: -2, Step: 1, Categories: []string{"write", "list", "blocking"}, Group: "list",
+			Syntax: "<key> [key ...] <timeout>", Summary: "Pop the first element of a list, or wait for one", handler: (*Server).handleBLPop},
+		{Name: "BRPOP", Arity: -3, Flags: []string{"write", "blocking"}, FirstKey: 1, LastKey: -2, Step: 1, Categories: []string{"write", "list", "blocking"}, Group: "list",
+			Syntax: "<key> [key ...] <timeout>", Summary: "Pop the last element of a list, or wait for one", handler: (*Server).handleBRPop},
+		{Name: "BLMOVE", Arity: 6, Flags: []string{"write", "blocking"}, FirstKey: 1, LastKey: 2, Step: 1, Categories: []string{"write", "list", "blocking"}, Group: "list",
+			Syntax: "<src> <dst> <from> <to> <sec>", Summary: "Move an element between lists, or wait for one", handler: (*Server).handleBLMove},
+
+		{Name: "HSET", Arity: -4, Flags: []string{"write", "fast"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"write", "hash"}, Group: "hash",
+			Syntax: "<key> <field> <value> [...]", Summary: "Set fields of a hash", handler: (*Server).handleHSet},
+		{Name: "HGET", Arity: 3, Flags: []string{"readonly", "fast"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"read", "hash"}, Group: "hash",
+			Syntax: "<key> <field>", Summary: "Get the value of a hash field", handler: (*Server).handleHGet},
+		{Name: "HMGET", Arity: -3, Flags: []string{"readonly", "fast"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"read", "hash"}, Group: "hash",
+			Syntax: "<key> <field> [field ...]", Summary: "Get the values of several hash fields", handler: (*Server).handleHMGet},
+		{Name: "HDEL", Arity: -3, Flags: []string{"write", "fast"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"write", "hash"}, Group: "hash",
+			Syntax: "<key> <field> [field ...]", Summary: "Delete fields of a hash", handler: (*Server).handleHDel},
+		{Name: "HGETALL", Arity: 2, Flags: []string{"readonly"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"read", "hash"}, Group: "hash",
+			Syntax: "<key>", Summary: "Get every field and value of a hash", handler: (*Server).handleHGetAll},
+		{Name: "HINCRBY", Arity: 4, Flags: []string{"write", "fast"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"write", "hash"}, Group: "hash",
+			Syntax: "<key> <field> <n>", Summary: "Add to the integer value of a hash field", handler: (*Server).handleHIncrBy},
+		{Name: "HEXISTS", Arity: 3, Flags: []string{"readonly", "fast"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"read", "hash"}, Group: "hash",
+			Syntax: "<key> <field>", Summary: "Check if a hash field exists", handler: (*Server).handleHExists},
+		{Name: "HLEN", Arity: 2, Flags: []string{"readonly", "fast"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"read", "hash"}, Group: "hash",
+			Syntax: "<key>", Summary: "Get the number of fields of a hash", handler: (*Server).handleHLen},
+		{Name: "HSCAN", Arity: -3, Flags: []string{"readonly"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"read", "hash"}, Group: "hash",
+			Syntax: "<key> <cursor> [MATCH p] ...", Summary: "Iterate over the fields of a hash", handler: (*Server).handleHScan},
+		{Name: "HEXPIRE", Arity: -6, Flags: []string{"write", "fast"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"write", "hash"}, Group: "hash",
+			Syntax: "<key> <sec> FIELDS <n> <f> ...", Summary: "Set a TTL on hash fields", handler: (*Server).handleHExpire},
+		{Name: "HPERSIST", Arity: -5, Flags: []string{"write", "fast"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"write", "hash"}, Group: "hash",
+			Syntax: "<key> FIELDS <n> <f> ...", Summary: "Remove the TTL of hash fields", handler: (*Server).handleHPersist},
+		{Name: "HTTL", Arity: -5, Flags: []string{"readonly", "fast"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"read", "hash"}, Group: "hash",
+			Syntax: "<key> FIELDS <n> <f> ...", Summary: "Get the remaining TTL of hash fields", handler: (*Server).handleHTTL},
+
+		{Name: "SADD", Arity: -3, Flags: []string{"write", "fast"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"write", "set"}, Group: "set",
+			Syntax: "<key> <member> [member ...]", Summary: "Add members to a set", handler: (*Server).handleSAdd},
+		{Name: "SREM", Arity: -3, Flags: []string{"write", "fast"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"write", "set"}, Group: "set",
+			Syntax: "<key> <member> [member ...]", Summary: "Remove members from a set", handler: (*Server).handleSRem},
+		{Name: "SMEMBERS", Arity: 2, Flags: []string{"readonly"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"read", "set"}, Group: "set",
+			Syntax: "<key>", Summary: "Get every member of a set", handler: (*Server).handleSMembers},
+		{Name: "SISMEMBER", Arity: 3, Flags: []string{"readonly", "fast"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"read", "set"}, Group: "set",
+			Syntax: "<key> <member>", Summary: "Check if a value is a member of a set", handler: (*Server).handleSIsMember},
+		{Name: "SCARD", Arity: 2, Flags: []string{"readonly", "fast"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"read", "set"}, Group: "set",
+			Syntax: "<key>", Summary: "Get the number of members of a set", handler: (*Server).handleSCard},
+		{Name: "SINTER", Arity: -2, Flags: []string{"readonly"}, FirstKey: 1, LastKey: -1, Step: 1, Categories: []string{"read", "set"}, Group: "set",
+			Syntax: "<key> [key ...]", Summary: "Intersect sets", handler: (*Server).handleSInter},
+		{Name: "SINTERSTORE", Arity: -3, Flags: []string{"write"}, FirstKey: 1, LastKey: -1, Step: 1, Categories: []string{"write", "set"}, Group: "set",
+			Syntax: "<dst> <key> [key ...]", Summary: "Store the intersection of sets", handler: (*Server).handleSInterStore},
+		{Name: "SUNION", Arity: -2, Flags: []string{"readonly"}, FirstKey: 1, LastKey: -1, Step: 1, Categories: []string{"read", "set"}, Group: "set",
+			Syntax: "<key> [key ...]", Summary: "Get the union of sets", handler: (*Server).handleSUnion},
+		{Name: "SUNIONSTORE", Arity: -3, Flags: []string{"write"}, FirstKey: 1, LastKey: -1, Step: 1, Categories: []string{"write", "set"}, Group: "set",
+			Syntax: "<dst> <key> [key ...]", Summary: "Store the union of sets", handler: (*Server).handleSUnionStore},
+		{Name: "SDIFF", Arity: -2, Flags: []string{"readonly"}, FirstKey: 1, LastKey: -1, Step: 1, Categories: []string{"read", "set"}, Group: "set",
+			Syntax: "<key> [key ...]", Summary: "Subtract the other sets from the first", handler: (*Server).handleSDiff},
+		{Name: "SDIFFSTORE", Arity: -3, Flags: []string{"write"}, FirstKey: 1, LastKey: -1, Step: 1, Categories: []string{"write", "set"}, Group: "set",
+			Syntax: "<dst> <key> [key ...]", Summary: "Store the difference of sets", handler: (*Server).handleSDiffStore},
+		{Name: "SRANDMEMBER", Arity: -2, Flags: []string{"readonly"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"read", "set"}, Group: "set",
+			Syntax: "<key> [count]", Summary: "Get random members of a set", handler: (*Server).handleSRandMember},
+		{Name: "SPOP", Arity: -2, Flags: []string{"write", "fast"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"write", "set"}, Group: "set",
+			Syntax: "<key> [count]", Summary: "Remove and return random members of a set", handler: (*Server).handleSPop},
+		{Name: "SSCAN", Arity: -3, Flags: []string{"readonly"}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"read", "set"}, Group: "set",
+			Syntax: "<key> <cursor> [MATCH p] ...", Summary: "Iterate over the members of a set", handler: (*Server).handleSScan},
+
+		{Name: "MULTI", Arity: 1, Flags: []string{"noscript", "loading", "stale", "fast"}, Categories: []string{"transaction"}, Group: "transactions",
+			Summary: "Start a transaction; commands are queued", handler: (*Server).handleMulti},
+		{Name: "EXEC", Arity: 1, Flags: []string{"noscript", "loading", "stale", "skip_slowlog"}, Categories: []string{"transaction"}, Group: "transactions",
+			Summary: "Run the queued commands atomically", handler: (*Server).handleExec},
+		{Name: "DISCARD", Arity: 1, Flags: []string{"noscript", "loading", "stale", "fast"}, Categories: []string{"transaction"}, Group: "transactions",
+			Summary: "Drop the queued commands", handler: (*Server).handleDiscard},
+		{Name: "WATCH", Arity: -2, Flags: []string{"noscript", "loading", "stale", "fast"}, FirstKey: 1, LastKey: -1, Step: 1, Categories: []string{"transaction"}, Group: "transactions",
+			Syntax: "<key> [key ...]", Summary: "Abort the next EXEC if a key changes", handler: (*Server).handleWatch},
+		{Name: "UNWATCH", Arity: 1, Flags: []string{"noscript", "loading", "stale", "fast"}, Categories: []string{"transaction"}, Group: "transactions",
+			Summary: "Forget all watched keys", handler: (*Server).handleUnwatch},
+
+		{Name: "SUBSCRIBE", Arity: -2, Flags: []string{"pubsub", "noscript", "loading", "stale"}, Categories: []string{"pubsub"}, Group: "pubsub",
+			Syntax: "<ch> [ch ...]", Summary: "Listen for messages on channels", handler: (*Server).handleSubscribe},
+		{Name: "PSUBSCRIBE", Arity: -2, Flags: []string{"pubsub", "noscript", "loading", "stale"}, Categories: []string{"pubsub"}, Group: "pubsub",
+			Syntax: "<pat> [pat ...]", Summary: "Listen on channels matching glob patterns", handler: (*Server).handlePSubscribe},
+		{Name: "UNSUBSCRIBE", Arity: -1, Flags: []string{"pubsub", "noscript", "loading", "stale"}, Categories: []string{"pubsub"}, Group: "pubsub",
+			Syntax: "[ch ...]", Summary: "Stop listening (all when no args given)", handler: (*Server).handleUnsubscribe},
+		{Name: "PUNSUBSCRIBE", Arity: -1, Flags: []string{"pubsub", "noscript", "loading", "stale"}, Categories: []string{"pubsub"}, Group: "pubsub",
+			Syntax: "[pat ...]", Summary: "Stop listening to patterns", handler: (*Server).handlePUnsubscribe},
+		{Name: "PUBLISH", Arity: 3, Flags: []string{"pubsub", "loading", "stale", "fast"}, Categories: []string{"pubsub"}, Group: "pubsub",
+			Syntax: "<channel> <message>", Summary: "Send a message to a channel", handler: (*Server).handlePublish},
+		{Name: "PUBSUB", Arity: -2, Flags: []string{"pubsub", "loading", "stale"}, Categories: []string{"pubsub"}, Group: "pubsub",
+			Syntax: "CHANNELS|NUMSUB|NUMPAT", Summary: "Inspect pub/sub state", handler: (*Server).handlePubSub},
+
+		{Name: "SHUTDOWN", Arity: -1, Flags: []string{"admin", "noscript", "loading", "stale"}, Categories: []string{"admin", "dangerous"}, Group: "server",
+			Syntax: "[NOSAVE|SAVE]", Summary: "Drain clients, save and stop the server", handler: (*Server).handleShutdown},
+		{Name: "HELP", Arity: -1, Flags: []string{"loading", "stale"}, Categories: []string{"connection"}, Group: "connection",
+			Summary: "Show this help", handler: (*Server).handleHelp},
+		{Name: "QUIT", Arity: -1, Flags: []string{"noscript", "loading", "stale", "fast", "no_auth"}, Categories: []string{"connection"}, Group: "connection",
+			Summary: "Close connection", handler: (*Server).handleQuit},
+	}
+
+	commands = make(map[string]*Command, len(commandTable))
+	for _, cmd := range commandTable {
+		commands[cmd.Name] = cmd
+	}
+}
+
+// lookupCommand returns the command called name (in any case), or nil.
+func lookupCommand(name string) *Command {
+	return commands[strings.ToUpper(name)]
+}
+
+// hasFlag reports whether the command has the given flag.
+func (cmd *Command) hasFlag(flag string) bool {
+	for _, f := range cmd.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkArity reports whether n arguments (not counting the name) suit cmd.
+func (cmd *Command) checkArity(n int) bool {
+	if cmd.Arity < 0 {
+		return n+1 >= -cmd.Arity
+	}
+	return n+1 == cmd.Arity
+}
+
+// keys returns the arguments that are key names, following the key
+// positions of the table.
+func (cmd *Command) keys(args []string) []string {
+	if cmd.FirstKey == 0 || cmd.FirstKey > len(args) {
+		return nil
+	}
+	last := cmd.LastKey
+	if last < 0 {
+		last = len(args) + 1 + last
+	}
+	last = min(last, len(args))
+	var keys []string
+	for i := cmd.FirstKey; i <= last; i += cmd.Step {
+		keys = append(keys, args[i-1])
+	}
+	return keys
+}
+
+// formatInfo formats cmd as an entry of COMMAND and COMMAND INFO: name,
+// arity, flags, first key, last key, step and ACL categories.
+func (cmd *Command) formatInfo(c *client) string {
+	flags := make([]string, len(cmd.Flags))
+	for i, f := range cmd.Flags {
+		flags[i] = protocol.FormatSimpleString(f)
+	}
+	categories := make([]string, len(cmd.Categories))
+	for i, cat := range cmd.Categories {
+		categories[i] = protocol.FormatSimpleString("@" + cat)
+	}
+	return protocol.FormatArray([]string{
+		protocol.FormatBulkString(strings.ToLower(cmd.Name)),
+		protocol.FormatInteger(int64(cmd.Arity)),
+		c.formatSet(flags),
+		protocol.FormatInteger(int64(cmd.FirstKey)),
+		protocol.FormatInteger(int64(cmd.LastKey)),
+		protocol.FormatInteger(int64(cmd.Step)),
+		c.formatSet(categories),
+	})
+}
+
+// formatDocs formats the documentation of cmd as COMMAND DOCS does.
+func (cmd *Command) formatDocs(c *client) string {
+	usage := cmd.Name
+	if cmd.Syntax != "" {
+		usage += " " + cmd.Syntax
+	}
+	return c.formatMap([]string{
+		protocol.FormatBulkString("summary"), protocol.FormatBulkString(cmd.Summary),
+		protocol.FormatBulkString("group"), protocol.FormatBulkString(cmd.Group),
+		protocol.FormatBulkString("syntax"), protocol.FormatBulkString(usage),
+	})
+}
+
+// handleCommand describes the command table:
+// COMMAND | COMMAND COUNT | COMMAND INFO [name ...] | COMMAND DOCS [name ...]
+func (srv *Server) handleCommand(c *client, args []string) string {
+	if len(args) == 0 {
+		entries := make([]string, len(commandTable))
+		for i, cmd := range commandTable {
+			entries[i] = cmd.formatInfo(c)
+		}
+		return protocol.FormatArray(entries)
+	}
+	switch strings.ToUpper(args[0]) {
+	case "COUNT":
+		if len(args) != 1 {
+			return protocol.FormatError("wrong number of arguments for 'COMMAND|COUNT' command")
+		}
+		return protocol.FormatInteger(int64(len(commandTable)))
+
+	case "INFO":
+		// Without names, every command; unknown names get a null.
+		if len(args) == 1 {
+			return srv.handleCommand(c, nil)
+		}
+		entries := make([]string, len(args)-1)
+		for i, name := range args[1:] {
+			if cmd := lookupCommand(name); cmd != nil {
+				entries[i] = cmd.formatInfo(c)
+			} else {
+				entries[i] = c.formatNullArray()
+			}
+		}
+		return protocol.FormatArray(entries)
+
+	case "DOCS":
+		// Unknown names are left out of the reply.
+		var docs []*Command
+		if len(args) == 1 {
+			docs = commandTable
+		}
+		for _, name := range args[1:] {
+			if cmd := lookupCommand(name); cmd != nil {
+				docs = append(docs, cmd)
+			}
+		}
+		pairs := make([]string, 0, 2*len(docs))
+		for _, cmd := range docs {
+			pairs = append(pairs, protocol.FormatBulkString(strings.ToLower(cmd.Name)), cmd.formatDocs(c))
+		}
+		return c.formatMap(pairs)
+
+	default:
+		return protocol.FormatError(fmt.Sprintf("unknown subcommand '%s'. Try COMMAND HELP.", args[0]))
+	}
+}
+
+// helpText lists the commands of the table, one per line.
+func helpText() string {
+	var b strings.Builder
+	b.WriteString("Commands:")
+	for _, cmd := range commandTable {
+		usage := cmd.Name
+		if cmd.Syntax != "" {
+			usage += " " + cmd.Syntax
+		}
+		fmt.Fprintf(&b, "\n  %-32s - %s", usage, cmd.Summary)
+	}
+	return b.String()
+}
@@ -0,0 +1,236 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"memstash/internal/config"
+	"memstash/internal/protocol"
+	"memstash/internal/ratelimit"
+	"memstash/internal/store"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config returns the registry CONFIG operates on. It already holds the
+// parameters of the server and its store; callers can register more.
+func (srv *Server) Config() *config.Registry {
+	return srv.config
+}
+
+// newConfig registers the parameters owned by the server and its store.
+func (srv *Server) newConfig() *config.Registry {
+	r := config.New("")
+	r.Register(config.Param{
+		Name: "capacity",
+		Env:  "CAPACITY",
+		Get:  func() string { return strconv.Itoa(srv.store.Stats().Capacity) },
+		Set: func(value string) error {
+			n, err := parsePositive(value)
+			if err != nil {
+				return err
+			}
+			_, err = srv.store.SetCapacity(n)
+			return err
+		},
+	})
+	r.Register(config.Param{
+		Name: "databases",
+		Env:  "DATABASES",
+		Get:  func() string { return strconv.Itoa(srv.store.Databases()) },
+	})
+	r.Register(config.Param{
+		Name: "port",
+		Env:  "TCP_PORT",
+		Get:  func() string { return strconv.Itoa(srv.port) },
+	})
+	r.Register(config.Param{
+		Name: "unixsocket",
+		Env:  "UNIX_SOCKET",
+		Get:  func() string { return srv.unixPath },
+	})
+	r.Register(config.Param{
+		Name: "unixsocketperm",
+		Env:  "UNIX_SOCKET_PERM",
+		Get:  func() string { return strconv.FormatUint(uint64(srv.unixPerm), 8) },
+	})
+	r.Register(config.Param{
+		Name: "dbfilename",
+		Env:  "SNAPSHOT_FILE",
+		Get:  func() string { return srv.store.SnapshotPath() },
+		Set: func(value string) error {
+			if value == "" {
+				return errors.New("dbfilename can't be empty")
+			}
+			srv.store.SetSnapshotPath(value)
+			return nil
+		},
+	})
+	r.Register(config.Param{
+		Name: "autosave-interval",
+		Env:  "AUTOSAVE_INTERVAL",
+		Get:  func() string { return formatSeconds(srv.store.AutoSaveInterval()) },
+		Set: func(value string) error {
+			d, err := parseSeconds(value)
+			if err != nil {
+				return err
+			}
+			srv.store.SetAutoSaveInterval(d)
+			return nil
+		},
+	})
+	r.Register(config.Param{
+		Name: "ttl-cleaner-interval",
+		Env:  "TTL_CLEANER_INTERVAL",
+		Get:  func() string { return formatSeconds(srv.store.TTLCleanerInterval()) },
+		Set: func(value string) error {
+			d, err := parseSeconds(value)
+			if err != nil {
+				return err
+			}
+			srv.store.SetTTLCleanerInterval(d)
+			return nil
+		},
+	})
+	r.Register(config.Param{
+		Name: "notify-keyspace-events",
+		Env:  "NOTIFY_KEYSPACE_EVENTS",
+		Get:  func() string { return srv.store.NotifyKeyspaceEvents().String() },
+		Set: func(value string) error {
+			flags, err := store.ParseNotifyFlags(value)
+			if err != nil {
+				return err
+			}
+			srv.store.SetNotifyKeyspaceEvents(flags)
+			return nil
+		},
+	})
+	r.Register(config.Param{
+		Name: "slowlog-log-slower-than",
+		Env:  "SLOWLOG_LOG_SLOWER_THAN",
+		Get:  func() string { return strconv.FormatInt(srv.slowlog.Threshold().Microseconds(), 10) },
+		Set: func(value string) error {
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return errors.New("argument must be a number of microseconds")
+			}
+			if n < 0 {
+				n = -1
+			}
+			srv.slowlog.SetThreshold(time.Duration(n) * time.Microsecond)
+			return nil
+		},
+	})
+	r.Register(config.Param{
+		Name: "slowlog-max-len",
+		Env:  "SLOWLOG_MAX_LEN",
+		Get:  func() string { return strconv.Itoa(srv.slowlog.MaxLen()) },
+		Set: func(value string) error {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return errors.New("argument must be a non-negative integer")
+			}
+			return srv.slowlog.SetMaxLen(n)
+		},
+	})
+	commands := func(r *ratelimit.Rates) *int64 { return &r.Commands }
+	bytes := func(r *ratelimit.Rates) *int64 { return &r.Bytes }
+	for _, p := range []struct {
+		name, env string
+		scope     ratelimit.Scope
+		field     func(*ratelimit.Rates) *int64
+	}{
+		{"ratelimit-connection-commands", "RATELIMIT_CONNECTION_COMMANDS", ratelimit.Connection, commands},
+		{"ratelimit-connection-bytes", "RATELIMIT_CONNECTION_BYTES", ratelimit.Connection, bytes},
+		{"ratelimit-user-commands", "RATELIMIT_USER_COMMANDS", ratelimit.User, commands},
+		{"ratelimit-user-bytes", "RATELIMIT_USER_BYTES", ratelimit.User, bytes},
+	} {
+		r.Register(config.Param{
+			Name: p.name,
+			Env:  p.env,
+			Get: func() string {
+				rates := srv.limiter.Rates(p.scope)
+				return strconv.FormatInt(*p.field(&rates), 10)
+			},
+			Set: func(value string) error {
+				n, err := strconv.ParseInt(value, 10, 64)
+				if err != nil || n < 0 {
+					return errors.New("argument must be a non-negative integer (0 for unlimited)")
+				}
+				rates := srv.limiter.Rates(p.scope)
+				*p.field(&rates) = n
+				srv.limiter.SetRates(p.scope, rates)
+				return nil
+			},
+		})
+	}
+	return r
+}
+
+func parsePositive(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, errors.New("argument must be a positive integer")
+	}
+	return n, nil
+}
+
+// parseSeconds reads an interval in whole seconds; 0 disables the task.
+func parseSeconds(value string) (time.Duration, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, errors.New("argument must be a non-negative number of seconds")
+	}
+	return time.Duration(n) * time.Second, nil
+}
+
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(d/time.Second), 10)
+}
+
+// handleConfig reads and changes parameters at runtime:
+// CONFIG GET pattern [pattern ...] | SET name value [name value ...] | REWRITE
+func (srv *Server) handleConfig(c *client, args []string) string {
+	switch strings.ToUpper(args[0]) {
+	case "GET":
+		if len(args) < 2 {
+			return protocol.FormatError("wrong number of arguments for 'CONFIG|GET' command")
+		}
+		seen := make(map[string]bool)
+		var pairs []string
+		for _, pattern := range args[1:] {
+			for _, e := range srv.config.Get(pattern) {
+				if seen[e.Name] {
+					continue
+				}
+				seen[e.Name] = true
+				pairs = append(pairs, protocol.FormatBulkString(e.Name), protocol.FormatBulkString(e.Value))
+			}
+		}
+		return c.formatMap(pairs)
+
+	case "SET":
+		if len(args) < 3 || len(args)%2 == 0 {
+			return protocol.FormatError("wrong number of arguments for 'CONFIG|SET' command")
+		}
+		for i := 1; i < len(args); i += 2 {
+			err := srv.config.Set(args[i], args[i+1])
+			switch {
+			case errors.Is(err, config.ErrUnknownParam):
+				return protocol.FormatError(fmt.Sprintf("Unknown option or number of arguments for CONFIG SET - '%s'", args[i]))
+			case err != nil:
+				return protocol.FormatError(fmt.Sprintf("CONFIG SET failed (possibly related to argument '%s') - %s", args[i], err.Error()))
+			}
+		}
+		return protocol.FormatOK()
+
+	case "REWRITE":
+		if err := srv.config.Rewrite(); err != nil {
+			return protocol.FormatError(err.Error())
+		}
+		return protocol.FormatOK()
+
+	default:
+		return protocol.FormatError(fmt.Sprintf("unknown subcommand '%s'. Try CONFIG HELP.", args[0]))
+	}
+}
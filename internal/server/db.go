@@ -0,0 +1,93 @@
+package server
+
+import (
+	"memstash/internal/protocol"
+	"strconv"
+	"strings"
+)
+
+// parseDBIndex validates a database number argument.
+func (srv *Server) parseDBIndex(arg string) (int, string) {
+	index, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, protocol.FormatError("value is not an integer or out of range")
+	}
+	if index < 0 || index >= srv.store.Databases() {
+		return 0, protocol.FormatError("DB index is out of range")
+	}
+	return index, ""
+}
+
+// handleSelect: SELECT <db>
+func (srv *Server) handleSelect(c *client, args []string) string {
+	index, errReply := srv.parseDBIndex(args[0])
+	if errReply != "" {
+		return errReply
+	}
+	c.mu.Lock()
+	c.db = index
+	c.mu.Unlock()
+	return protocol.FormatOK()
+}
+
+// handleMove: MOVE <key> <db>
+func (srv *Server) handleMove(c *client, args []string) string {
+	index, errReply := srv.parseDBIndex(args[1])
+	if errReply != "" {
+		return errReply
+	}
+	moved, err := srv.db(c).Move(args[0], index)
+	if err != nil {
+		return protocol.FormatError(err.Error())
+	}
+	if moved {
+		return protocol.FormatInteger(1)
+	}
+	return protocol.FormatInteger(0)
+}
+
+// handleSwapDB: SWAPDB <db1> <db2>
+func (srv *Server) handleSwapDB(c *client, args []string) string {
+	a, errReply := srv.parseDBIndex(args[0])
+	if errReply != "" {
+		return protocol.FormatError("invalid first DB index")
+	}
+	b, errReply := srv.parseDBIndex(args[1])
+	if errReply != "" {
+		return protocol.FormatError("invalid second DB index")
+	}
+	if err := srv.db(c).SwapDB(a, b); err != nil {
+		return protocol.FormatError(err.Error())
+	}
+	return protocol.FormatOK()
+}
+
+// flushMode accepts the optional ASYNC / SYNC argument of FLUSHDB and
+// FLUSHALL. Flushing is always synchronous here.
+func flushMode(cmd string, args []string) string {
+	if len(args) > 1 {
+		return protocol.FormatError("wrong number of arguments for '" + strings.ToLower(cmd) + "' command")
+	}
+	if len(args) == 1 && !strings.EqualFold(args[0], "ASYNC") && !strings.EqualFold(args[0], "SYNC") {
+		return protocol.FormatError("syntax error")
+	}
+	return ""
+}
+
+// handleFlushDB: FLUSHDB [ASYNC|SYNC]
+func (srv *Server) handleFlushDB(c *client, args []string) string {
+	if errReply := flushMode("FLUSHDB", args); errReply != "" {
+		return errReply
+	}
+	srv.db(c).Clear()
+	return protocol.FormatOK()
+}
+
+// handleFlushAll: FLUSHALL [ASYNC|SYNC]
+func (srv *Server) handleFlushAll(c *client, args []string) string {
+	if errReply := flushMode("FLUSHALL", args); errReply != "" {
+		return errReply
+	}
+	srv.db(c).FlushAll()
+	return protocol.FormatOK()
+}
@@ -0,0 +1,338 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"memstash/internal/protocol"
+	"memstash/internal/store"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseFieldsArg parses the FIELDS numfields field [field ...] arguments
+// of HEXPIRE, HTTL and HPERSIST.
+func parseFieldsArg(args []string) ([]string, string) {
+	if len(args) < 3 || !strings.EqualFold(args[0], "FIELDS") {
+		return nil, protocol.FormatError("syntax error")
+	}
+	n, err := strconv.Atoi(args[1])
+	if err != nil || n <= 0 {
+		return nil, protocol.FormatError("Parameter `numFields` should be greater than 0")
+	}
+	if n != len(args)-2 {
+		return nil, protocol.FormatError("The `numfields` parameter must match the number of arguments")
+	}
+	return args[2:], ""
+}
+
+// formatIntegers replies with an array of integers.
+func formatIntegers(ns []int64) string {
+	items := make([]string, len(ns))
+	for i, n := range ns {
+		items[i] = protocol.FormatInteger(n)
+	}
+	return protocol.FormatArray(items)
+}
+
+// handleHSet: HSET key field value [field value ...]
+func (srv *Server) handleHSet(c *client, args []string) string {
+	if len(args)%2 != 1 {
+		return protocol.FormatError("wrong number of arguments for 'HSET' command")
+	}
+	fields := make(map[string]string, len(args)/2)
+	for i := 1; i < len(args); i += 2 {
+		fields[args[i]] = args[i+1]
+	}
+	n, err := srv.db(c).HSet(args[0], fields)
+	if err != nil {
+		return storeError(err)
+	}
+	return protocol.FormatInteger(int64(n))
+}
+
+// handleHGet: HGET key field
+func (srv *Server) handleHGet(c *client, args []string) string {
+	v, found, err := srv.db(c).HGet(args[0], args[1])
+	switch {
+	case err != nil:
+		return storeError(err)
+	case !found:
+		return c.formatNull()
+	}
+	return protocol.FormatBulkString(v)
+}
+
+// handleHMGet: HMGET key field [field ...]
+func (srv *Server) handleHMGet(c *client, args []string) string {
+	values, err := srv.db(c).HMGet(args[0], args[1:]...)
+	if err != nil {
+		return storeError(err)
+	}
+	items := make([]string, len(args)-1)
+	for i, field := range args[1:] {
+		if v, ok := values[field]; ok {
+			items[i] = protocol.FormatBulkString(v)
+		} else {
+			items[i] = c.formatNull()
+		}
+	}
+	return protocol.FormatArray(items)
+}
+
+// handleHDel: HDEL key field [field ...]
+func (srv *Server) handleHDel(c *client, args []string) string {
+	n, err := srv.db(c).HDel(args[0], args[1:]...)
+	if err != nil {
+		return storeError(err)
+	}
+	return protocol.FormatInteger(int64(n))
+}
+
+// handleHGetAll: HGETALL key
+// Fields are sorted so that replies are stable.
+func (srv *Server) handleHGetAll(c *client, args []string) string {
+	fields, err := srv.db(c).HGetAll(args[0])
+	if err != nil {
+		return storeError(err)
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, 2*len(names))
+	for _, name := range names {
+		pairs = append(pairs, protocol.FormatBulkString(name), protocol.FormatBulkString(fields[name]))
+	}
+	return c.formatMap(pairs)
+}
+
+// handleHIncrBy: HINCRBY key field increment
+func (srv *Server) handleHIncrBy(c *client, args []string) string {
+	delta, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return protocol.FormatError("value is not an integer or out of range")
+	}
+	n, err := srv.db(c).HIncrBy(args[0], args[1], delta)
+	if err != nil {
+		return storeError(err)
+	}
+	return protocol.FormatInteger(n)
+}
+
+// handleHExists: HEXISTS key field
+func (srv *Server) handleHExists(c *client, args []string) string {
+	ok, err := srv.db(c).HExists(args[0], args[1])
+	switch {
+	case err != nil:
+		return storeError(err)
+	case ok:
+		return protocol.FormatInteger(1)
+	}
+	return protocol.FormatInteger(0)
+}
+
+// handleHLen: HLEN key
+func (srv *Server) handleHLen(c *client, args []string) string {
+	n, err := srv.db(c).HLen(args[0])
+	if err != nil {
+		return storeError(err)
+	}
+	return protocol.FormatInteger(int64(n))
+}
+
+// handleHScan: HSCAN key cursor [MATCH pattern] [COUNT count]
+func (srv *Server) handleHScan(c *client, args []string) string {
+	cursor, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		return protocol.FormatError("invalid cursor")
+	}
+	opts, errReply := parseScanOptions(args[2:])
+	if errReply != "" {
+		return errReply
+	}
+	if opts.typ != "" {
+		return protocol.FormatError("syntax error")
+	}
+	next, pairs, err := srv.db(c).HScan(args[0], cursor, opts.match, opts.count)
+	if err != nil {
+		return storeError(err)
+	}
+	return protocol.FormatArray([]string{
+		protocol.FormatBulkString(strconv.FormatUint(next, 10)),
+		protocol.FormatBulkStrings(pairs),
+	})
+}
+
+// handleHExpire: HEXPIRE key seconds FIELDS numfields field [field ...]
+func (srv *Server) handleHExpire(c *client, args []string) string {
+	seconds, err := strconv.Atoi(args[1])
+	if err != nil {
+		return protocol.FormatError("value is not an integer or out of range")
+	}
+	fields, errReply := parseFieldsArg(args[2:])
+	if errReply != "" {
+		return errReply
+	}
+	results, err := srv.db(c).HExpire(args[0], time.Duration(seconds)*time.Second, fields...)
+	if err != nil {
+		return storeError(err)
+	}
+	return formatFieldResults(results)
+}
+
+// handleHPersist: HPERSIST key FIELDS numfields field [field ...]
+func (srv *Server) handleHPersist(c *client, args []string) string {
+	fields, errReply := parseFieldsArg(args[1:])
+	if errReply != "" {
+		return errReply
+	}
+	results, err := srv.db(c).HPersist(args[0], fields...)
+	if err != nil {
+		return storeError(err)
+	}
+	return formatFieldResults(results)
+}
+
+func formatFieldResults(results []int) string {
+	ns := make([]int64, len(results))
+	for i, r := range results {
+		ns[i] = int64(r)
+	}
+	return formatIntegers(ns)
+}
+
+// handleHTTL: HTTL key FIELDS numfields field [field ...]
+// Replies with seconds per field, -1 without TTL and -2 for a missing one.
+func (srv *Server) handleHTTL(c *client, args []string) string {
+	fields, errReply := parseFieldsArg(args[1:])
+	if errReply != "" {
+		return errReply
+	}
+	ttls, err := srv.db(c).HTTL(args[0], fields...)
+	if err != nil {
+		return storeError(err)
+	}
+	ns := make([]int64, len(ttls))
+	for i, ttl := range ttls {
+		ns[i] = fieldTTL(ttl)
+	}
+	return formatIntegers(ns)
+}
+
+// fieldTTL converts a TTL returned by Store.HTTL to seconds, keeping the
+// -1 and -2 markers.
+func fieldTTL(ttl time.Duration) int64 {
+	if ttl < 0 {
+		return int64(ttl)
+	}
+	return int64(ttl.Seconds())
+}
+
+// ── HTTP ────────────────────────────────────────────────────────────────
+
+// GET /keys/{key}/hash (HGETALL)
+func (h *HTTPServer) handleHashGetAll(w http.ResponseWriter, r *http.Request, c *client) {
+	key := r.PathValue("key")
+	fields, err := h.run(c, "HGETALL", key)
+	if err != nil {
+		replyFailed(w, err, http.StatusInternalServerError)
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]any{"key": key, "fields": fields})
+}
+
+// GET /keys/{key}/hash/{field} (HGET, then HTTL)
+// The response includes the field's remaining TTL in seconds, -1 without
+// one.
+func (h *HTTPServer) handleHashGet(w http.ResponseWriter, r *http.Request, c *client) {
+	key, field := r.PathValue("key"), r.PathValue("field")
+	v, err := h.run(c, "HGET", key, field)
+	if err != nil {
+		replyFailed(w, err, http.StatusInternalServerError)
+		return
+	}
+	if v == nil {
+		jsonError(w, http.StatusNotFound, fmt.Sprintf("field '%s' of '%s' not found", field, key))
+		return
+	}
+	ttls, err := h.run(c, "HTTL", key, "FIELDS", "1", field)
+	if err != nil {
+		replyFailed(w, err, http.StatusInternalServerError)
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]any{
+		"key":   key,
+		"field": field,
+		"value": v,
+		"ttl":   ttls.([]any)[0],
+	})
+}
+
+// PUT /keys/{key}/hash/{field} (HSET, then HEXPIRE with a TTL)
+// Body: {"value": "...", "ttl": <optional seconds>}
+func (h *HTTPServer) handleHashSet(w http.ResponseWriter, r *http.Request, c *client) {
+	key, field := r.PathValue("key"), r.PathValue("field")
+	var body struct {
+		Value string `json:"value"`
+		TTL   *int   `json:"ttl,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	added, err := h.run(c, "HSET", key, field, body.Value)
+	if err == nil && body.TTL != nil && *body.TTL > 0 {
+		_, err = h.run(c, "HEXPIRE", key, strconv.Itoa(*body.TTL), "FIELDS", "1", field)
+	}
+	if err != nil {
+		replyFailed(w, err, http.StatusInternalServerError)
+		return
+	}
+	status := http.StatusOK
+	if added != int64(0) {
+		status = http.StatusCreated
+	}
+	jsonResponse(w, status, map[string]string{"status": "OK", "key": key, "field": field})
+}
+
+// DELETE /keys/{key}/hash/{field} (HDEL)
+func (h *HTTPServer) handleHashDelete(w http.ResponseWriter, r *http.Request, c *client) {
+	key, field := r.PathValue("key"), r.PathValue("field")
+	n, err := h.run(c, "HDEL", key, field)
+	if err != nil {
+		replyFailed(w, err, http.StatusInternalServerError)
+		return
+	}
+	if n == int64(0) {
+		jsonError(w, http.StatusNotFound, fmt.Sprintf("field '%s' of '%s' not found", field, key))
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "OK", "key": key, "field": field})
+}
+
+// POST /keys/{key}/hash/{field}/incr (HINCRBY)
+// Body: {"by": <integer>}
+func (h *HTTPServer) handleHashIncr(w http.ResponseWriter, r *http.Request, c *client) {
+	key, field := r.PathValue("key"), r.PathValue("field")
+	var body struct {
+		By *int64 `json:"by"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.By == nil {
+		jsonError(w, http.StatusBadRequest, "body must hold an integer by")
+		return
+	}
+	n, err := h.run(c, "HINCRBY", key, field, strconv.FormatInt(*body.By, 10))
+	switch {
+	case replyErrorIs(err, store.ErrHashValueNotInteger.Error()), replyErrorIs(err, store.ErrIncrOverflow.Error()):
+		jsonError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		replyFailed(w, err, http.StatusInternalServerError)
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]any{"key": key, "field": field, "value": n})
+}
@@ -1,52 +1,94 @@
 package server
 
 import (
+	"bufio"
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"memstash/internal/protocol"
 	"memstash/internal/store"
 	"net"
 	"net/http"
-	"strings"
+	"strconv"
 	"time"
 )
 
 // HTTPServer exposes the store via a JSON REST API.
 type HTTPServer struct {
-	store        *store.Store
-	port         int
-	snapshotPath string
-	server       *http.Server
-	listener     net.Listener
+	store     *store.Store
+	srv       *Server // TCP server whose users (and other state) are shared
+	port      int
+	server    *http.Server
+	listener  net.Listener
+	tlsConfig *tls.Config
 }
 
 // NewHTTPServer creates a new HTTP server sharing the given store.
 func NewHTTPServer(s *store.Store, port int) *HTTPServer {
 	return &HTTPServer{
-		store:        s,
-		port:         port,
-		snapshotPath: "memstash_data.json",
+		store: s,
+		srv:   NewServer(s, 0),
+		port:  port,
 	}
 }
 
+// Attach makes the HTTP API share srv's state, so users and ACL rules
+// apply to both servers. Call before Start.
+func (h *HTTPServer) Attach(srv *Server) {
+	h.srv = srv
+}
+
 // Start binds to the configured port and serves HTTP requests.
 func (h *HTTPServer) Start() {
-	mux := h.routes()
-	h.server = &http.Server{Handler: mux}
+	ln := h.listen()
+	h.serve(ln)
+}
+
+// SetTLS makes the API serve HTTPS only, configured by cfg (see
+// tlsconf.Reloader.Config). Call before Start.
+func (h *HTTPServer) SetTLS(cfg *tls.Config) {
+	h.tlsConfig = cfg
+}
+
+// listen creates the http.Server and binds the port, wrapping it in TLS
+// when configured.
+func (h *HTTPServer) listen() net.Listener {
+	h.server = &http.Server{Handler: h.routes(), ConnContext: withConnBuckets}
 
 	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", h.port))
 	if err != nil {
 		log.Fatalf("HTTP server failed to start: %v", err)
 	}
+	if h.tlsConfig != nil {
+		ln = tls.NewListener(ln, h.tlsConfig)
+		log.Printf("HTTP server listening on %s (TLS)", ln.Addr().String())
+	} else {
+		log.Printf("HTTP server listening on %s", ln.Addr().String())
+	}
 	h.listener = ln
-	log.Printf("HTTP server listening on %s", ln.Addr().String())
+	return ln
+}
 
+func (h *HTTPServer) serve(ln net.Listener) {
 	if err := h.server.Serve(ln); err != nil && err != http.ErrServerClosed {
 		log.Printf("HTTP server error: %v", err)
 	}
 }
 
-// Stop gracefully shuts down the HTTP server.
+// Shutdown stops accepting requests and waits for the active ones to
+// finish, or for ctx to expire.
+func (h *HTTPServer) Shutdown(ctx context.Context) error {
+	if h.server == nil {
+		return nil
+	}
+	return h.server.Shutdown(ctx)
+}
+
+// Stop closes the HTTP server at once, cutting off active requests.
 func (h *HTTPServer) Stop() {
 	if h.server != nil {
 		h.server.Close()
@@ -66,42 +108,65 @@ func (h *HTTPServer) Addr() net.Addr {
 func (h *HTTPServer) StartAndReady() <-chan struct{} {
 	ready := make(chan struct{})
 	go func() {
-		mux := h.routes()
-		h.server = &http.Server{Handler: mux}
-
-		ln, err := net.Listen("tcp", fmt.Sprintf(":%d", h.port))
-		if err != nil {
-			log.Fatalf("HTTP server failed to start: %v", err)
-		}
-		h.listener = ln
-		log.Printf("HTTP server listening on %s", ln.Addr().String())
+		ln := h.listen()
 		close(ready)
-
-		if err := h.server.Serve(ln); err != nil && err != http.ErrServerClosed {
-			log.Printf("HTTP server error: %v", err)
-		}
+		h.serve(ln)
 	}()
 	return ready
 }
 
-// routes configures the HTTP mux with all endpoints.
+// routes configures the HTTP mux with all endpoints. Most of them are a
+// front end to commands of the table (see dispatch); the others report
+// server state that a command reply would flatten.
 func (h *HTTPServer) routes() *http.ServeMux {
 	mux := http.NewServeMux()
 
 	// Key-specific operations: /keys/{key}
-	mux.HandleFunc("POST /keys/{key}", h.handleSetKey)
-	mux.HandleFunc("GET /keys/{key}", h.handleGetKey)
-	mux.HandleFunc("DELETE /keys/{key}", h.handleDeleteKey)
+	mux.HandleFunc("POST /keys/{key}", h.dispatch(h.handleSetKey))
+	mux.HandleFunc("GET /keys/{key}", h.dispatch(h.handleGetKey))
+	mux.HandleFunc("DELETE /keys/{key}", h.dispatch(h.handleDeleteKey))
+
+	// Lists: /keys/{key}/list
+	mux.HandleFunc("GET /keys/{key}/list", h.dispatch(h.handleListRange))
+	mux.HandleFunc("GET /keys/{key}/list/len", h.dispatch(h.handleListLen))
+	mux.HandleFunc("GET /keys/{key}/list/{index}", h.dispatch(h.handleListIndex))
+	mux.HandleFunc("PUT /keys/{key}/list/{index}", h.dispatch(h.handleListSet))
+	mux.HandleFunc("POST /keys/{key}/list/left", h.dispatch(h.handleListPush))
+	mux.HandleFunc("POST /keys/{key}/list/right", h.dispatch(h.handleListPush))
+	mux.HandleFunc("DELETE /keys/{key}/list/left", h.dispatch(h.handleListPop))
+	mux.HandleFunc("DELETE /keys/{key}/list/right", h.dispatch(h.handleListPop))
+	mux.HandleFunc("DELETE /keys/{key}/list", h.dispatch(h.handleListRemove))
+	mux.HandleFunc("POST /keys/{key}/list/trim", h.dispatch(h.handleListTrim))
+
+	// Hashes: /keys/{key}/hash
+	mux.HandleFunc("GET /keys/{key}/hash", h.dispatch(h.handleHashGetAll))
+	mux.HandleFunc("GET /keys/{key}/hash/{field}", h.dispatch(h.handleHashGet))
+	mux.HandleFunc("PUT /keys/{key}/hash/{field}", h.dispatch(h.handleHashSet))
+	mux.HandleFunc("DELETE /keys/{key}/hash/{field}", h.dispatch(h.handleHashDelete))
+	mux.HandleFunc("POST /keys/{key}/hash/{field}/incr", h.dispatch(h.handleHashIncr))
 
 	// List all keys
-	mux.HandleFunc("GET /keys", h.handleListKeys)
+	mux.HandleFunc("GET /keys", h.dispatch(h.handleListKeys))
+
+	// Deleting by pattern can empty a whole database, so it needs the
+	// same rights as FLUSHDB
+	mux.HandleFunc("DELETE /keys", h.guard("FLUSHDB", h.handleDeleteKeys))
+
+	// Commands that exceeded the latency threshold
+	mux.HandleFunc("GET /slowlog", h.guard("SLOWLOG", h.handleGetSlowLog))
+
+	// Connected TCP clients
+	mux.HandleFunc("GET /clients", h.guard("CLIENT", h.handleListClients))
 
 	// Stats
-	mux.HandleFunc("GET /stats", h.handleGetStats)
+	mux.HandleFunc("GET /stats", h.dispatch(h.handleGetStats))
 
 	// Persistence
-	mux.HandleFunc("POST /save", h.handleSave)
-	mux.HandleFunc("POST /load", h.handleLoad)
+	mux.HandleFunc("POST /save", h.dispatch(h.handleSave))
+	mux.HandleFunc("POST /load", h.dispatch(h.handleLoad))
+
+	// Stop the whole process (SHUTDOWN)
+	mux.HandleFunc("POST /shutdown", h.dispatch(h.handleShutdown))
 
 	return mux
 }
@@ -118,17 +183,147 @@ func jsonError(w http.ResponseWriter, status int, msg string) {
 	jsonResponse(w, status, map[string]string{"error": msg})
 }
 
+// replyFailed writes the response for err, an error returned by run: 401
+// and 403 for ACL errors, 409 when the key holds another type, and status
+// for the others.
+func replyFailed(w http.ResponseWriter, err error, status int) {
+	var e *protocol.ReplyError
+	if errors.As(err, &e) {
+		switch e.Code {
+		case "NOAUTH":
+			w.Header().Set("WWW-Authenticate", `Basic realm="memstash"`)
+			status = http.StatusUnauthorized
+		case "NOPERM":
+			status = http.StatusForbidden
+		case "WRONGTYPE":
+			status = http.StatusConflict
+		}
+	}
+	jsonError(w, status, err.Error())
+}
+
+// replyErrorIs reports whether err is the error reply msg.
+func replyErrorIs(err error, msg string) bool {
+	var e *protocol.ReplyError
+	return errors.As(err, &e) && e.Msg == msg
+}
+
+// ── Middleware ──────────────────────────────────────────────────────────
+
+// requestClient authenticates r and returns the client its commands run
+// as, scoped to the request: it has the user, database and address of the
+// request, and speaks RESP3 so that replies decode to typed values.
+// Credentials are read from HTTP Basic auth; without them the request runs
+// as the default user. It returns nil once it has written an error
+// response.
+func (h *HTTPServer) requestClient(w http.ResponseWriter, r *http.Request) *client {
+	authenticated := ""
+	if username, password, ok := r.BasicAuth(); ok {
+		user, err := h.srv.acl.Authenticate(username, password)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="memstash"`)
+			jsonError(w, http.StatusUnauthorized, err.Error())
+			return nil
+		}
+		authenticated = user.Name
+	}
+	if !h.allowRequest(r, authenticated) {
+		w.Header().Set("Retry-After", "1")
+		jsonError(w, http.StatusTooManyRequests, "rate limited")
+		return nil
+	}
+	db, err := h.db(r)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return nil
+	}
+
+	laddr := ""
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		laddr = addr.String()
+	}
+	now := time.Now()
+	return &client{
+		addr:       r.RemoteAddr,
+		laddr:      laddr,
+		created:    now,
+		lastActive: now,
+		flags:      "N",
+		proto:      protocol.RESP3,
+		db:         db.Index(),
+		user:       authenticated,
+		w:          bufio.NewWriter(io.Discard),
+	}
+}
+
+// dispatch serves an endpoint through the command table: handle builds
+// the arguments of commands from the request, runs them with h.run as the
+// request's client and writes the response from their replies.
+func (h *HTTPServer) dispatch(handle func(w http.ResponseWriter, r *http.Request, c *client)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if c := h.requestClient(w, r); c != nil {
+			handle(w, r, c)
+		}
+	}
+}
+
+// run runs one command, name first, for c the way a TCP connection runs
+// it: through its handler, with the arity and ACL checks, the MONITOR feed,
+// the statistics and the slow log. It returns the decoded reply (see
+// protocol.ParseReply), or a *protocol.ReplyError for an error reply.
+func (h *HTTPServer) run(c *client, args ...string) (any, error) {
+	return protocol.ParseReply(h.srv.executeCommand(c, args[0], args[1:]))
+}
+
+// guard serves an endpoint that reads server state directly rather than
+// through a command. It authenticates the request, applies the ACL rules
+// of the command name, and runs next like that command would be run (see
+// Server.call).
+func (h *HTTPServer) guard(name string, next http.HandlerFunc) http.HandlerFunc {
+	cmd := lookupCommand(name)
+	if cmd == nil {
+		panic("http: route for unknown command " + name)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := h.requestClient(w, r)
+		if c == nil {
+			return
+		}
+		if err := checkPermission(h.srv.user(c), cmd, nil); err != nil {
+			replyFailed(w, &protocol.ReplyError{Code: err.code, Msg: err.msg}, http.StatusForbidden)
+			return
+		}
+		h.srv.call(cmd, nil, c.addr, c.db, c, func() {
+			next(w, r)
+		})
+	}
+}
+
+// db returns the database selected with the ?db= query parameter
+// (database 0 without it). Handlers may ignore the error: the guard
+// middleware has already rejected invalid values.
+func (h *HTTPServer) db(r *http.Request) (*store.Store, error) {
+	param := r.URL.Query().Get("db")
+	if param == "" {
+		return h.store, nil
+	}
+	index, err := strconv.Atoi(param)
+	if err != nil {
+		return nil, fmt.Errorf("invalid db %q: must be an integer", param)
+	}
+	db := h.store.DB(index)
+	if db == nil {
+		return nil, store.ErrInvalidDB
+	}
+	return db, nil
+}
+
 // ── Handlers ────────────────────────────────────────────────────────────
 
-// POST /keys/{key}
+// POST /keys/{key} (SET, or SETEX with a TTL)
 // Body: {"value": "...", "ttl": <optional seconds>}
-func (h *HTTPServer) handleSetKey(w http.ResponseWriter, r *http.Request) {
+func (h *HTTPServer) handleSetKey(w http.ResponseWriter, r *http.Request, c *client) {
 	key := r.PathValue("key")
-	if key == "" {
-		jsonError(w, http.StatusBadRequest, "key is required")
-		return
-	}
-
 	var body struct {
 		Value string `json:"value"`
 		TTL   *int   `json:"ttl,omitempty"`
@@ -138,109 +333,211 @@ func (h *HTTPServer) handleSetKey(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	args := []string{"SET", key, body.Value}
 	if body.TTL != nil && *body.TTL > 0 {
-		ttl := time.Duration(*body.TTL) * time.Second
-		if err := h.store.SetWithTTL(key, body.Value, ttl); err != nil {
-			jsonError(w, http.StatusInternalServerError, err.Error())
-			return
-		}
-	} else {
-		if err := h.store.Set(key, body.Value); err != nil {
-			jsonError(w, http.StatusInternalServerError, err.Error())
-			return
-		}
+		args = []string{"SETEX", key, strconv.Itoa(*body.TTL), body.Value}
+	}
+	if _, err := h.run(c, args...); err != nil {
+		replyFailed(w, err, http.StatusInternalServerError)
+		return
 	}
-
 	jsonResponse(w, http.StatusCreated, map[string]string{
 		"status": "OK",
 		"key":    key,
 	})
 }
 
-// GET /keys/{key}
-func (h *HTTPServer) handleGetKey(w http.ResponseWriter, r *http.Request) {
+// GET /keys/{key} (GET)
+func (h *HTTPServer) handleGetKey(w http.ResponseWriter, r *http.Request, c *client) {
 	key := r.PathValue("key")
-	if key == "" {
-		jsonError(w, http.StatusBadRequest, "key is required")
+	value, err := h.run(c, "GET", key)
+	if err != nil {
+		replyFailed(w, err, http.StatusInternalServerError)
 		return
 	}
-
-	value, err := h.store.Get(key)
-	if err != nil {
-		// Distinguish between not-found and expired
-		msg := err.Error()
-		if strings.Contains(msg, "not found") || strings.Contains(msg, "expired") {
-			jsonError(w, http.StatusNotFound, fmt.Sprintf("key '%s' not found", key))
-			return
-		}
-		jsonError(w, http.StatusInternalServerError, msg)
+	if value == nil {
+		jsonError(w, http.StatusNotFound, fmt.Sprintf("key '%s' not found", key))
 		return
 	}
-
-	jsonResponse(w, http.StatusOK, map[string]string{
+	jsonResponse(w, http.StatusOK, map[string]any{
 		"key":   key,
 		"value": value,
 	})
 }
 
-// DELETE /keys/{key}
-func (h *HTTPServer) handleDeleteKey(w http.ResponseWriter, r *http.Request) {
+// DELETE /keys/{key} (DEL)
+func (h *HTTPServer) handleDeleteKey(w http.ResponseWriter, r *http.Request, c *client) {
 	key := r.PathValue("key")
-	if key == "" {
-		jsonError(w, http.StatusBadRequest, "key is required")
+	n, err := h.run(c, "DEL", key)
+	if err != nil {
+		replyFailed(w, err, http.StatusInternalServerError)
 		return
 	}
-
-	err := h.store.Delete(key)
-	if err != nil {
+	if n == int64(0) {
 		jsonError(w, http.StatusNotFound, fmt.Sprintf("key '%s' not found", key))
 		return
 	}
-
 	jsonResponse(w, http.StatusOK, map[string]string{
 		"status": "OK",
 		"key":    key,
 	})
 }
 
-// GET /keys
-func (h *HTTPServer) handleListKeys(w http.ResponseWriter, r *http.Request) {
-	keys := h.store.Keys()
-	if keys == nil {
-		keys = []string{}
+// GET /keys (KEYS)
+// GET /keys?pattern=<glob> returns only the keys matching the pattern.
+// GET /keys?cursor=<c>&match=<pattern>&count=<n> pages through the keys
+// with SCAN; pass the returned cursor back until it is "0".
+func (h *HTTPServer) handleListKeys(w http.ResponseWriter, r *http.Request, c *client) {
+	query := r.URL.Query()
+	if query.Has("cursor") || query.Has("match") || query.Has("count") {
+		h.scanKeys(w, r, c)
+		return
+	}
+	pattern := "*"
+	if query.Has("pattern") {
+		pattern = query.Get("pattern")
+	}
+	keys, err := h.run(c, "KEYS", pattern)
+	if err != nil {
+		replyFailed(w, err, http.StatusInternalServerError)
+		return
 	}
 	jsonResponse(w, http.StatusOK, map[string]any{
 		"keys":  keys,
-		"count": len(keys),
+		"count": len(keys.([]any)),
 	})
 }
 
-// GET /stats
-func (h *HTTPServer) handleGetStats(w http.ResponseWriter, r *http.Request) {
-	stats := h.store.Stats()
+func (h *HTTPServer) scanKeys(w http.ResponseWriter, r *http.Request, c *client) {
+	query := r.URL.Query()
+
+	cursor := "0"
+	if param := query.Get("cursor"); param != "" {
+		if _, err := strconv.ParseUint(param, 10, 64); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+		cursor = param
+	}
+	count := 10
+	if param := query.Get("count"); param != "" {
+		n, err := strconv.Atoi(param)
+		if err != nil || n < 1 {
+			jsonError(w, http.StatusBadRequest, "count must be a positive integer")
+			return
+		}
+		count = n
+	}
+
+	args := []string{"SCAN", cursor, "COUNT", strconv.Itoa(count)}
+	if match := query.Get("match"); match != "" {
+		args = append(args, "MATCH", match)
+	}
+	if typ := query.Get("type"); typ != "" {
+		args = append(args, "TYPE", typ)
+	}
+	reply, err := h.run(c, args...)
+	if err != nil {
+		replyFailed(w, err, http.StatusBadRequest)
+		return
+	}
+	page := reply.([]any)
+	keys := page[1].([]any)
+	jsonResponse(w, http.StatusOK, map[string]any{
+		"cursor": page[0],
+		"keys":   keys,
+		"count":  len(keys),
+	})
+}
+
+// DELETE /keys?pattern=<glob>
+func (h *HTTPServer) handleDeleteKeys(w http.ResponseWriter, r *http.Request) {
+	db, _ := h.db(r)
+	pattern := r.URL.Query().Get("pattern")
+	if pattern == "" {
+		jsonError(w, http.StatusBadRequest, "pattern is required")
+		return
+	}
+	deleted := db.DeleteMatching(pattern)
+	jsonResponse(w, http.StatusOK, map[string]any{
+		"status":  "OK",
+		"deleted": deleted,
+	})
+}
+
+// GET /slowlog?count=N
+// Newest entries first; count defaults to 10, -1 returns every entry.
+func (h *HTTPServer) handleGetSlowLog(w http.ResponseWriter, r *http.Request) {
+	count := 10
+	if param := r.URL.Query().Get("count"); param != "" {
+		n, err := strconv.Atoi(param)
+		if err != nil || n < -1 {
+			jsonError(w, http.StatusBadRequest, "count should be greater than or equal to -1")
+			return
+		}
+		count = n
+	}
+	entries := h.srv.slowlog.Get(count)
+	jsonResponse(w, http.StatusOK, map[string]any{
+		"entries": entries,
+		"count":   len(entries),
+		"len":     h.srv.slowlog.Len(),
+	})
+}
+
+// GET /clients
+func (h *HTTPServer) handleListClients(w http.ResponseWriter, r *http.Request) {
+	clients := h.srv.clientInfos()
 	jsonResponse(w, http.StatusOK, map[string]any{
-		"keys":      stats.Keys,
-		"capacity":  stats.Capacity,
-		"hits":      stats.Hits,
-		"misses":    stats.Misses,
-		"evictions": stats.Evictions,
+		"clients": clients,
+		"count":   len(clients),
 	})
 }
 
-// POST /save
-func (h *HTTPServer) handleSave(w http.ResponseWriter, r *http.Request) {
-	if err := h.store.SaveSnapshot(h.snapshotPath); err != nil {
-		jsonError(w, http.StatusInternalServerError, err.Error())
+// GET /stats (STATS)
+func (h *HTTPServer) handleGetStats(w http.ResponseWriter, r *http.Request, c *client) {
+	stats, err := h.run(c, "STATS")
+	if err != nil {
+		replyFailed(w, err, http.StatusInternalServerError)
+		return
+	}
+	jsonResponse(w, http.StatusOK, stats)
+}
+
+// POST /save (SAVE)
+func (h *HTTPServer) handleSave(w http.ResponseWriter, r *http.Request, c *client) {
+	if _, err := h.run(c, "SAVE"); err != nil {
+		replyFailed(w, err, http.StatusInternalServerError)
 		return
 	}
 	jsonResponse(w, http.StatusOK, map[string]string{"status": "OK"})
 }
 
-// POST /load
-func (h *HTTPServer) handleLoad(w http.ResponseWriter, r *http.Request) {
-	if err := h.store.LoadSnapshot(h.snapshotPath); err != nil {
-		jsonError(w, http.StatusInternalServerError, err.Error())
+// POST /load (LOAD)
+func (h *HTTPServer) handleLoad(w http.ResponseWriter, r *http.Request, c *client) {
+	if _, err := h.run(c, "LOAD"); err != nil {
+		replyFailed(w, err, http.StatusInternalServerError)
 		return
 	}
 	jsonResponse(w, http.StatusOK, map[string]string{"status": "OK"})
 }
+
+// POST /shutdown?mode=save|nosave (SHUTDOWN)
+// The shutdown runs in the background and drains both servers, this
+// request included, so the reply still goes out.
+func (h *HTTPServer) handleShutdown(w http.ResponseWriter, r *http.Request, c *client) {
+	args := []string{"SHUTDOWN"}
+	if mode := r.URL.Query().Get("mode"); mode != "" {
+		args = append(args, mode)
+	}
+	_, err := h.run(c, args...)
+	switch {
+	case replyErrorIs(err, "SHUTDOWN is not enabled on this server"):
+		replyFailed(w, err, http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		replyFailed(w, err, http.StatusBadRequest)
+		return
+	}
+	jsonResponse(w, http.StatusAccepted, map[string]string{"status": "OK"})
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"fmt"
+	"memstash/internal/ratelimit"
+	"memstash/internal/store"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// commandStat counts the calls of one command and the time spent in them.
+type commandStat struct {
+	calls atomic.Int64
+	usec  atomic.Int64
+}
+
+// newCommandStats creates a counter for every command in the table. The
+// map is never written afterwards, so it is read without a lock.
+func newCommandStats() map[string]*commandStat {
+	stats := make(map[string]*commandStat, len(commands))
+	for name := range commands {
+		stats[name] = &commandStat{}
+	}
+	return stats
+}
+
+// record accounts one run of cmd that took d.
+func (srv *Server) record(cmd string, d time.Duration) {
+	srv.totalCommands.Add(1)
+	if stat, ok := srv.cmdStats[cmd]; ok {
+		stat.calls.Add(1)
+		stat.usec.Add(d.Microseconds())
+	}
+}
+
+// infoSections lists the INFO sections in the order they are printed.
+// commandstats is only included when asked for, "all" or "everything".
+var infoSections = []string{"server", "clients", "memory", "persistence", "stats", "commandstats", "keyspace"}
+
+// handleInfo reports server state: INFO [section ...]
+func (srv *Server) handleInfo(c *client, args []string) string {
+	wanted := make(map[string]bool)
+	if len(args) == 0 {
+		args = []string{"default"}
+	}
+	for _, arg := range args {
+		switch section := strings.ToLower(arg); section {
+		case "default":
+			for _, s := range infoSections {
+				wanted[s] = s != "commandstats"
+			}
+		case "all", "everything":
+			for _, s := range infoSections {
+				wanted[s] = true
+			}
+		default:
+			wanted[section] = true
+		}
+	}
+
+	// Collected once: it walks every key. Inside EXEC the store lock is
+	// already held, so it is read through the transaction view.
+	info := srv.db(c).Info()
+	var b strings.Builder
+	for _, section := range infoSections {
+		if !wanted[section] {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\r\n")
+		}
+		fmt.Fprintf(&b, "# %s\r\n", strings.ToUpper(section[:1])+section[1:])
+		for _, line := range srv.infoSection(section, info) {
+			b.WriteString(line)
+			b.WriteString("\r\n")
+		}
+	}
+	return c.formatVerbatim("txt", b.String())
+}
+
+// infoSection returns the "field:value" lines of one INFO section.
+func (srv *Server) infoSection(section string, info store.Info) []string {
+	switch section {
+	case "server":
+		uptime := int64(time.Since(srv.started).Seconds())
+		return []string{
+			"memstash_version:" + Version,
+			"go_version:" + runtime.Version(),
+			"os:" + runtime.GOOS,
+			"arch:" + runtime.GOARCH,
+			fmt.Sprintf("process_id:%d", os.Getpid()),
+			fmt.Sprintf("tcp_port:%d", srv.port),
+			fmt.Sprintf("uptime_in_seconds:%d", uptime),
+			fmt.Sprintf("uptime_in_days:%d", uptime/86400),
+		}
+	case "clients":
+		return []string{
+			fmt.Sprintf("connected_clients:%d", srv.ClientCount()),
+			fmt.Sprintf("blocked_clients:%d", info.Blocked),
+		}
+	case "memory":
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		return []string{
+			fmt.Sprintf("used_memory:%d", mem.HeapAlloc),
+			"used_memory_human:" + humanBytes(mem.HeapAlloc),
+			fmt.Sprintf("used_memory_sys:%d", mem.Sys),
+			fmt.Sprintf("used_memory_dataset:%d", info.DatasetBytes),
+			fmt.Sprintf("maxkeys:%d", info.Capacity),
+		}
+	case "persistence":
+		status := "ok"
+		if info.LastSaveErr != nil {
+			status = "err"
+		}
+		return []string{
+			fmt.Sprintf("rdb_changes_since_last_save:%d", info.ChangesSinceSave),
+			fmt.Sprintf("rdb_last_save_time:%d", info.LastSave.Unix()),
+			"rdb_last_bgsave_status:" + status,
+		}
+	case "stats":
+		return []string{
+			fmt.Sprintf("total_connections_received:%d", srv.nextClientID.Load()),
+			fmt.Sprintf("total_commands_processed:%d", srv.totalCommands.Load()),
+			fmt.Sprintf("expired_keys:%d", info.Expired),
+			fmt.Sprintf("evicted_keys:%d", info.Evictions),
+			fmt.Sprintf("keyspace_hits:%d", info.Hits),
+			fmt.Sprintf("keyspace_misses:%d", info.Misses),
+			fmt.Sprintf("pubsub_channels:%d", len(srv.pubsub.Channels(""))),
+			fmt.Sprintf("pubsub_patterns:%d", srv.pubsub.NumPat()),
+			fmt.Sprintf("throttled_by_connection:%d", srv.limiter.Throttled(ratelimit.Connection)),
+			fmt.Sprintf("throttled_by_user:%d", srv.limiter.Throttled(ratelimit.User)),
+		}
+	case "commandstats":
+		names := make([]string, 0, len(srv.cmdStats))
+		for name, stat := range srv.cmdStats {
+			if stat.calls.Load() > 0 {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		lines := make([]string, 0, len(names))
+		for _, name := range names {
+			stat := srv.cmdStats[name]
+			calls, usec := stat.calls.Load(), stat.usec.Load()
+			lines = append(lines, fmt.Sprintf("cmdstat_%s:calls=%d,usec=%d,usec_per_call=%.2f",
+				strings.ToLower(name), calls, usec, float64(usec)/float64(calls)))
+		}
+		return lines
+	case "keyspace":
+		var lines []string
+		for _, db := range info.Databases {
+			lines = append(lines, fmt.Sprintf("db%d:keys=%d,expires=%d", db.Index, db.Keys, db.Expires))
+		}
+		return lines
+	}
+	return nil
+}
+
+// humanBytes formats n like Redis' used_memory_human, e.g. "1.50M".
+func humanBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	value := float64(n)
+	for _, suffix := range []string{"K", "M", "G", "T"} {
+		value /= unit
+		if value < unit || suffix == "T" {
+			return fmt.Sprintf("%.2f%s", value, suffix)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,357 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"memstash/internal/protocol"
+	"memstash/internal/store"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// storeError formats an error returned by the store, giving type
+// mismatches their WRONGTYPE code.
+func storeError(err error) string {
+	if errors.Is(err, store.ErrWrongType) {
+		return protocol.FormatErrorCode("WRONGTYPE", err.Error())
+	}
+	return protocol.FormatError(err.Error())
+}
+
+// parseIndex reads a list index or count argument.
+func parseIndex(arg string) (int, bool) {
+	n, err := strconv.Atoi(arg)
+	return n, err == nil
+}
+
+// handleLPush: LPUSH key element [element ...]
+func (srv *Server) handleLPush(c *client, args []string) string {
+	n, err := srv.db(c).LPush(args[0], args[1:]...)
+	if err != nil {
+		return storeError(err)
+	}
+	return protocol.FormatInteger(int64(n))
+}
+
+// handleRPush: RPUSH key element [element ...]
+func (srv *Server) handleRPush(c *client, args []string) string {
+	n, err := srv.db(c).RPush(args[0], args[1:]...)
+	if err != nil {
+		return storeError(err)
+	}
+	return protocol.FormatInteger(int64(n))
+}
+
+// handleLPop: LPOP key [count]
+func (srv *Server) handleLPop(c *client, args []string) string {
+	return srv.pop(c, args, srv.db(c).LPop)
+}
+
+// handleRPop: RPOP key [count]
+func (srv *Server) handleRPop(c *client, args []string) string {
+	return srv.pop(c, args, srv.db(c).RPop)
+}
+
+// pop runs LPOP or RPOP. Without a count it replies with one element, with
+// a count with an array of up to count elements; a missing key is null.
+func (srv *Server) pop(c *client, args []string, pop func(string, int) ([]string, error)) string {
+	if len(args) > 2 {
+		return protocol.FormatError("syntax error")
+	}
+	count := 1
+	if len(args) == 2 {
+		n, ok := parseIndex(args[1])
+		if !ok || n < 0 {
+			return protocol.FormatError("value is out of range, must be positive")
+		}
+		count = n
+	}
+	popped, err := pop(args[0], count)
+	switch {
+	case err != nil:
+		return storeError(err)
+	case len(args) == 2 && popped == nil:
+		return c.formatNullArray()
+	case len(args) == 2:
+		return protocol.FormatBulkStrings(popped)
+	case len(popped) == 0:
+		return c.formatNull()
+	}
+	return protocol.FormatBulkString(popped[0])
+}
+
+// handleLRange: LRANGE key start stop
+func (srv *Server) handleLRange(c *client, args []string) string {
+	start, ok1 := parseIndex(args[1])
+	stop, ok2 := parseIndex(args[2])
+	if !ok1 || !ok2 {
+		return protocol.FormatError("value is not an integer or out of range")
+	}
+	values, err := srv.db(c).LRange(args[0], start, stop)
+	if err != nil {
+		return storeError(err)
+	}
+	return protocol.FormatBulkStrings(values)
+}
+
+// handleLLen: LLEN key
+func (srv *Server) handleLLen(c *client, args []string) string {
+	n, err := srv.db(c).LLen(args[0])
+	if err != nil {
+		return storeError(err)
+	}
+	return protocol.FormatInteger(int64(n))
+}
+
+// handleLIndex: LINDEX key index
+func (srv *Server) handleLIndex(c *client, args []string) string {
+	index, ok := parseIndex(args[1])
+	if !ok {
+		return protocol.FormatError("value is not an integer or out of range")
+	}
+	v, found, err := srv.db(c).LIndex(args[0], index)
+	switch {
+	case err != nil:
+		return storeError(err)
+	case !found:
+		return c.formatNull()
+	}
+	return protocol.FormatBulkString(v)
+}
+
+// handleLSet: LSET key index element
+func (srv *Server) handleLSet(c *client, args []string) string {
+	index, ok := parseIndex(args[1])
+	if !ok {
+		return protocol.FormatError("value is not an integer or out of range")
+	}
+	err := srv.db(c).LSet(args[0], index, args[2])
+	switch {
+	case errors.Is(err, store.ErrKeyNotFound):
+		return protocol.FormatError("no such key")
+	case err != nil:
+		return storeError(err)
+	}
+	return protocol.FormatOK()
+}
+
+// handleLRem: LREM key count element
+func (srv *Server) handleLRem(c *client, args []string) string {
+	count, ok := parseIndex(args[1])
+	if !ok {
+		return protocol.FormatError("value is not an integer or out of range")
+	}
+	n, err := srv.db(c).LRem(args[0], count, args[2])
+	if err != nil {
+		return storeError(err)
+	}
+	return protocol.FormatInteger(int64(n))
+}
+
+// handleLTrim: LTRIM key start stop
+func (srv *Server) handleLTrim(c *client, args []string) string {
+	start, ok1 := parseIndex(args[1])
+	stop, ok2 := parseIndex(args[2])
+	if !ok1 || !ok2 {
+		return protocol.FormatError("value is not an integer or out of range")
+	}
+	if err := srv.db(c).LTrim(args[0], start, stop); err != nil {
+		return storeError(err)
+	}
+	return protocol.FormatOK()
+}
+
+// ── HTTP ────────────────────────────────────────────────────────────────
+
+// queryInt reads an integer query parameter, def when it is absent.
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	param := r.URL.Query().Get(name)
+	if param == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(param)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: must be an integer", name, param)
+	}
+	return n, nil
+}
+
+// GET /keys/{key}/list?start=<i>&stop=<j> (LRANGE)
+// The whole list without start and stop.
+func (h *HTTPServer) handleListRange(w http.ResponseWriter, r *http.Request, c *client) {
+	key := r.PathValue("key")
+	start, err := queryInt(r, "start", 0)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	stop, err := queryInt(r, "stop", -1)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	values, err := h.run(c, "LRANGE", key, strconv.Itoa(start), strconv.Itoa(stop))
+	if err != nil {
+		replyFailed(w, err, http.StatusInternalServerError)
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]any{"key": key, "values": values})
+}
+
+// GET /keys/{key}/list/len (LLEN)
+func (h *HTTPServer) handleListLen(w http.ResponseWriter, r *http.Request, c *client) {
+	key := r.PathValue("key")
+	n, err := h.run(c, "LLEN", key)
+	if err != nil {
+		replyFailed(w, err, http.StatusInternalServerError)
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]any{"key": key, "length": n})
+}
+
+// GET /keys/{key}/list/{index} (LINDEX)
+func (h *HTTPServer) handleListIndex(w http.ResponseWriter, r *http.Request, c *client) {
+	key := r.PathValue("key")
+	index, ok := parseIndex(r.PathValue("index"))
+	if !ok {
+		jsonError(w, http.StatusBadRequest, "index must be an integer")
+		return
+	}
+	v, err := h.run(c, "LINDEX", key, strconv.Itoa(index))
+	if err != nil {
+		replyFailed(w, err, http.StatusInternalServerError)
+		return
+	}
+	if v == nil {
+		jsonError(w, http.StatusNotFound, fmt.Sprintf("no element %d in '%s'", index, key))
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]any{"key": key, "index": index, "value": v})
+}
+
+// PUT /keys/{key}/list/{index} (LSET)
+// Body: {"value": "..."}
+func (h *HTTPServer) handleListSet(w http.ResponseWriter, r *http.Request, c *client) {
+	key := r.PathValue("key")
+	index, ok := parseIndex(r.PathValue("index"))
+	if !ok {
+		jsonError(w, http.StatusBadRequest, "index must be an integer")
+		return
+	}
+	var body struct {
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	_, err := h.run(c, "LSET", key, strconv.Itoa(index), body.Value)
+	switch {
+	case replyErrorIs(err, "no such key"):
+		jsonError(w, http.StatusNotFound, fmt.Sprintf("key '%s' not found", key))
+		return
+	case replyErrorIs(err, store.ErrIndexOutOfRange.Error()):
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	case err != nil:
+		replyFailed(w, err, http.StatusInternalServerError)
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "OK", "key": key})
+}
+
+// POST /keys/{key}/list/left (LPUSH)
+// POST /keys/{key}/list/right (RPUSH)
+// Body: {"values": ["...", ...]}
+func (h *HTTPServer) handleListPush(w http.ResponseWriter, r *http.Request, c *client) {
+	key := r.PathValue("key")
+	var body struct {
+		Values []string `json:"values"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.Values) == 0 {
+		jsonError(w, http.StatusBadRequest, "body must hold a non-empty values array")
+		return
+	}
+	name := "RPUSH"
+	if strings.HasSuffix(r.URL.Path, "/left") {
+		name = "LPUSH"
+	}
+	n, err := h.run(c, append([]string{name, key}, body.Values...)...)
+	if err != nil {
+		replyFailed(w, err, http.StatusInternalServerError)
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]any{"key": key, "length": n})
+}
+
+// DELETE /keys/{key}/list/left?count=<n> (LPOP)
+// DELETE /keys/{key}/list/right?count=<n> (RPOP)
+// Pops one element without count.
+func (h *HTTPServer) handleListPop(w http.ResponseWriter, r *http.Request, c *client) {
+	key := r.PathValue("key")
+	count, err := queryInt(r, "count", 1)
+	if err == nil && count < 0 {
+		err = errors.New("count must be positive")
+	}
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	name := "RPOP"
+	if strings.HasSuffix(r.URL.Path, "/left") {
+		name = "LPOP"
+	}
+	// Always with a count, so that the reply is an array
+	values, err := h.run(c, name, key, strconv.Itoa(count))
+	if err != nil {
+		replyFailed(w, err, http.StatusInternalServerError)
+		return
+	}
+	if values == nil {
+		jsonError(w, http.StatusNotFound, fmt.Sprintf("key '%s' not found", key))
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]any{"key": key, "values": values})
+}
+
+// DELETE /keys/{key}/list?value=<v>&count=<n> (LREM)
+// Removes every element equal to value without count.
+func (h *HTTPServer) handleListRemove(w http.ResponseWriter, r *http.Request, c *client) {
+	key := r.PathValue("key")
+	if !r.URL.Query().Has("value") {
+		jsonError(w, http.StatusBadRequest, "value is required")
+		return
+	}
+	count, err := queryInt(r, "count", 0)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	n, err := h.run(c, "LREM", key, strconv.Itoa(count), r.URL.Query().Get("value"))
+	if err != nil {
+		replyFailed(w, err, http.StatusInternalServerError)
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]any{"key": key, "removed": n})
+}
+
+// POST /keys/{key}/list/trim (LTRIM)
+// Body: {"start": <i>, "stop": <j>}
+func (h *HTTPServer) handleListTrim(w http.ResponseWriter, r *http.Request, c *client) {
+	key := r.PathValue("key")
+	var body struct {
+		Start *int `json:"start"`
+		Stop  *int `json:"stop"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Start == nil || body.Stop == nil {
+		jsonError(w, http.StatusBadRequest, "body must hold start and stop")
+		return
+	}
+	if _, err := h.run(c, "LTRIM", key, strconv.Itoa(*body.Start), strconv.Itoa(*body.Stop)); err != nil {
+		replyFailed(w, err, http.StatusInternalServerError)
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "OK", "key": key})
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"fmt"
+	"memstash/internal/protocol"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// monitorBuffer is the number of lines buffered per MONITOR connection.
+// Lines that do not fit are dropped rather than slowing down the commands
+// being monitored.
+const monitorBuffer = 1024
+
+// monitorCommands are the only commands a client may send after MONITOR.
+var monitorCommands = map[string]bool{
+	"QUIT": true,
+}
+
+// handleMonitor turns the connection into a monitor: from now on it
+// receives one line per command run by any client of the server.
+func (srv *Server) handleMonitor(c *client, args []string) string {
+	if c.monitor != nil {
+		return protocol.FormatOK()
+	}
+	c.monitor = make(chan string, monitorBuffer)
+	srv.monitorsMu.Lock()
+	srv.monitors[c] = c.monitor
+	srv.monitorCount.Store(int64(len(srv.monitors)))
+	srv.monitorsMu.Unlock()
+	go srv.deliverMonitor(c, c.monitor)
+	return protocol.FormatOK()
+}
+
+// removeMonitor stops feeding c, ending its delivery goroutine.
+func (srv *Server) removeMonitor(c *client) {
+	srv.monitorsMu.Lock()
+	defer srv.monitorsMu.Unlock()
+	if ch, ok := srv.monitors[c]; ok {
+		delete(srv.monitors, c)
+		srv.monitorCount.Store(int64(len(srv.monitors)))
+		close(ch)
+	}
+}
+
+// deliverMonitor writes monitor lines to c until it disconnects.
+func (srv *Server) deliverMonitor(c *client, lines <-chan string) {
+	for line := range lines {
+		c.wmu.Lock()
+		c.w.WriteString(line)
+		var err error
+		if len(lines) == 0 {
+			err = c.w.Flush()
+		}
+		c.wmu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// FeedMonitors shows a command to every MONITOR connection. addr names the
+// client that ran it and args holds the command name and its arguments.
+// Commands flagged skip_monitor, whose arguments hold passwords, are left
+// out. It never blocks: a monitor that cannot keep up loses lines.
+func (srv *Server) FeedMonitors(addr string, db int, args []string) {
+	if srv.monitorCount.Load() == 0 || len(args) == 0 {
+		return
+	}
+	if cmd := lookupCommand(args[0]); cmd != nil && cmd.hasFlag("skip_monitor") {
+		return
+	}
+	line := formatMonitorLine(time.Now(), db, addr, args)
+
+	srv.monitorsMu.RLock()
+	defer srv.monitorsMu.RUnlock()
+	for _, ch := range srv.monitors {
+		select {
+		case ch <- line:
+		default:
+		}
+	}
+}
+
+// formatMonitorLine formats a command like Redis does:
+// +1339518083.107412 [0 127.0.0.1:60866] "SET" "key" "value"
+func formatMonitorLine(t time.Time, db int, addr string, args []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "+%d.%06d [%d %s]", t.Unix(), t.Nanosecond()/1000, db, addr)
+	for _, arg := range args {
+		b.WriteByte(' ')
+		b.WriteString(strconv.Quote(arg))
+	}
+	b.WriteString("\r\n")
+	return b.String()
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"memstash/internal/protocol"
+	"memstash/internal/store"
+)
+
+// txControlCommands run immediately even inside MULTI instead of being queued.
+var txControlCommands = map[string]bool{
+	"MULTI":   true,
+	"EXEC":    true,
+	"DISCARD": true,
+	"WATCH":   true,
+	"UNWATCH": true,
+	"QUIT":    true,
+}
+
+func (srv *Server) queueCommand(c *client, cmd string, args []string) string {
+	if commands[cmd].hasFlag("no_multi") {
+		c.multiErr = true
+		return protocol.FormatError("Command not allowed inside a transaction")
+	}
+	c.queue = append(c.queue, append([]string{cmd}, args...))
+	return protocol.FormatSimpleString("QUEUED")
+}
+
+func (srv *Server) handleMulti(c *client, args []string) string {
+	if c.inMulti {
+		return protocol.FormatError("MULTI calls can not be nested")
+	}
+	c.inMulti = true
+	return protocol.FormatOK()
+}
+
+func (srv *Server) handleDiscard(c *client, args []string) string {
+	if !c.inMulti {
+		return protocol.FormatError("DISCARD without MULTI")
+	}
+	c.resetMulti()
+	c.watched = nil
+	return protocol.FormatOK()
+}
+
+// handleExec runs the queued commands in a single store critical section.
+// The transaction is aborted (null reply) if a WATCHed key was modified
+// since WATCH, and refused (EXECABORT) if a command failed to queue.
+func (srv *Server) handleExec(c *client, args []string) string {
+	if !c.inMulti {
+		return protocol.FormatError("EXEC without MULTI")
+	}
+	queue, failed, watched := c.queue, c.multiErr, c.watched
+	c.resetMulti()
+	c.watched = nil
+
+	if failed {
+		return protocol.FormatErrorCode("EXECABORT", "Transaction discarded because of previous errors.")
+	}
+
+	var replies []string
+	srv.store.Atomic(func(tx *store.Store) {
+		for wk, version := range watched {
+			if tx.DB(wk.db).Version(wk.key) != version {
+				return
+			}
+		}
+
+		c.tx = tx
+		defer func() { c.tx = nil }()
+		replies = make([]string, 0, len(queue))
+		for _, parts := range queue {
+			replies = append(replies, srv.executeCommand(c, parts[0], parts[1:]))
+		}
+	})
+
+	if replies == nil {
+		return c.formatNullArray()
+	}
+	return protocol.FormatArray(replies)
+}
+
+func (srv *Server) handleWatch(c *client, args []string) string {
+	if c.inMulti {
+		return protocol.FormatError("WATCH inside MULTI is not allowed")
+	}
+	if c.watched == nil {
+		c.watched = make(map[watchedKey]uint64, len(args))
+	}
+	db := srv.db(c)
+	for _, key := range args {
+		wk := watchedKey{db: c.db, key: key}
+		if _, ok := c.watched[wk]; !ok {
+			c.watched[wk] = db.Version(key)
+		}
+	}
+	return protocol.FormatOK()
+}
+
+func (srv *Server) handleUnwatch(c *client, args []string) string {
+	c.watched = nil
+	return protocol.FormatOK()
+}
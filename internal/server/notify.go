@@ -0,0 +1,34 @@
+package server
+
+import (
+	"memstash/internal/store"
+	"strconv"
+)
+
+// keyEventBuffer is how many store events may queue up before the store
+// starts dropping them for the pub/sub bridge.
+const keyEventBuffer = 4096
+
+// startKeyEvents forwards the store's key events to pub/sub as Redis-style
+// keyspace (__keyspace@<db>__:<key>) and keyevent (__keyevent@<db>__:<event>)
+// notifications, and its invalidations to CLIENT TRACKING connections.
+func (srv *Server) startKeyEvents() {
+	events, cancel := srv.store.Notifications(keyEventBuffer)
+	stopInvalidations := srv.store.OnInvalidate(srv.invalidate)
+	srv.stopEvents = func() {
+		cancel()
+		stopInvalidations()
+	}
+	go func() {
+		for ev := range events {
+			flags := srv.store.NotifyKeyspaceEvents()
+			db := strconv.Itoa(ev.DB)
+			if flags&store.NotifyKeyspace != 0 {
+				srv.pubsub.Publish("__keyspace@"+db+"__:"+ev.Key, ev.Event)
+			}
+			if flags&store.NotifyKeyevent != 0 {
+				srv.pubsub.Publish("__keyevent@"+db+"__:"+ev.Event, ev.Key)
+			}
+		}
+	}()
+}
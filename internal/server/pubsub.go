@@ -0,0 +1,184 @@
+package server
+
+import (
+	"fmt"
+	"memstash/internal/protocol"
+	"memstash/internal/pubsub"
+	"strings"
+)
+
+// subscriberCommands are the only commands a RESP2 client may send while it
+// has active subscriptions.
+var subscriberCommands = map[string]bool{
+	"SUBSCRIBE":    true,
+	"UNSUBSCRIBE":  true,
+	"PSUBSCRIBE":   true,
+	"PUNSUBSCRIBE": true,
+	"PING":         true,
+	"QUIT":         true,
+}
+
+// subscriber returns the client's pub/sub subscriber, creating it and its
+// delivery goroutine on first use.
+func (srv *Server) subscriber(c *client) *pubsub.Subscriber {
+	if c.sub == nil {
+		c.sub = srv.pubsub.NewSubscriber()
+		go srv.deliverMessages(c, c.sub)
+	}
+	return c.sub
+}
+
+// deliverMessages writes published messages to the client until the
+// subscriber is removed. A subscriber that overflowed under the disconnect
+// policy gets its connection closed.
+func (srv *Server) deliverMessages(c *client, sub *pubsub.Subscriber) {
+	for {
+		select {
+		case msg := <-sub.Messages():
+			c.wmu.Lock()
+			c.w.WriteString(c.formatMessage(msg))
+			var err error
+			if len(sub.Messages()) == 0 {
+				err = c.w.Flush()
+			}
+			c.wmu.Unlock()
+			if err != nil {
+				return
+			}
+		case <-sub.Done():
+			if sub.Overflowed() {
+				c.conn.Close()
+			}
+			return
+		}
+	}
+}
+
+func (c *client) formatMessage(msg pubsub.Message) string {
+	if msg.Pattern != "" {
+		return c.formatPush([]string{
+			protocol.FormatBulkString("pmessage"),
+			protocol.FormatBulkString(msg.Pattern),
+			protocol.FormatBulkString(msg.Channel),
+			protocol.FormatBulkString(msg.Payload),
+		})
+	}
+	return c.formatPush([]string{
+		protocol.FormatBulkString("message"),
+		protocol.FormatBulkString(msg.Channel),
+		protocol.FormatBulkString(msg.Payload),
+	})
+}
+
+// subscriptionReply formats the confirmation sent for every channel or
+// pattern a (un)subscribe command touches: [kind, name, count].
+func (c *client) subscriptionReply(kind, name string, count int) string {
+	return c.formatPush([]string{
+		protocol.FormatBulkString(kind),
+		protocol.FormatBulkString(name),
+		protocol.FormatInteger(int64(count)),
+	})
+}
+
+func (srv *Server) handleSubscribe(c *client, args []string) string {
+	sub := srv.subscriber(c)
+	var b strings.Builder
+	for _, channel := range args {
+		c.subCount = srv.pubsub.Subscribe(sub, channel)
+		b.WriteString(c.subscriptionReply("subscribe", channel, c.subCount))
+	}
+	return b.String()
+}
+
+func (srv *Server) handlePSubscribe(c *client, args []string) string {
+	sub := srv.subscriber(c)
+	var b strings.Builder
+	for _, pattern := range args {
+		c.subCount = srv.pubsub.PSubscribe(sub, pattern)
+		b.WriteString(c.subscriptionReply("psubscribe", pattern, c.subCount))
+	}
+	return b.String()
+}
+
+// handleUnsubscribe removes the given channels, or all of them when called
+// without arguments.
+func (srv *Server) handleUnsubscribe(c *client, args []string) string {
+	if c.sub != nil && len(args) == 0 {
+		args = srv.pubsub.SubscribedChannels(c.sub)
+	}
+	if len(args) == 0 {
+		return c.formatPush([]string{
+			protocol.FormatBulkString("unsubscribe"),
+			c.formatNull(),
+			protocol.FormatInteger(int64(c.subCount)),
+		})
+	}
+	var b strings.Builder
+	for _, channel := range args {
+		if c.sub != nil {
+			c.subCount = srv.pubsub.Unsubscribe(c.sub, channel)
+		}
+		b.WriteString(c.subscriptionReply("unsubscribe", channel, c.subCount))
+	}
+	return b.String()
+}
+
+func (srv *Server) handlePUnsubscribe(c *client, args []string) string {
+	if c.sub != nil && len(args) == 0 {
+		args = srv.pubsub.SubscribedPatterns(c.sub)
+	}
+	if len(args) == 0 {
+		return c.formatPush([]string{
+			protocol.FormatBulkString("punsubscribe"),
+			c.formatNull(),
+			protocol.FormatInteger(int64(c.subCount)),
+		})
+	}
+	var b strings.Builder
+	for _, pattern := range args {
+		if c.sub != nil {
+			c.subCount = srv.pubsub.PUnsubscribe(c.sub, pattern)
+		}
+		b.WriteString(c.subscriptionReply("punsubscribe", pattern, c.subCount))
+	}
+	return b.String()
+}
+
+func (srv *Server) handlePublish(c *client, args []string) string {
+	receivers := srv.pubsub.Publish(args[0], args[1])
+	return protocol.FormatInteger(int64(receivers))
+}
+
+// handlePubSub serves the introspection subcommands:
+// PUBSUB CHANNELS [pattern] | NUMSUB [channel ...] | NUMPAT
+func (srv *Server) handlePubSub(c *client, args []string) string {
+	switch strings.ToUpper(args[0]) {
+	case "CHANNELS":
+		if len(args) > 2 {
+			return protocol.FormatError("wrong number of arguments for 'PUBSUB|CHANNELS' command")
+		}
+		pattern := ""
+		if len(args) == 2 {
+			pattern = args[1]
+		}
+		return protocol.FormatBulkStrings(srv.pubsub.Channels(pattern))
+
+	case "NUMSUB":
+		pairs := make([]string, 0, 2*(len(args)-1))
+		for _, channel := range args[1:] {
+			pairs = append(pairs,
+				protocol.FormatBulkString(channel),
+				protocol.FormatInteger(int64(srv.pubsub.NumSub(channel))))
+		}
+		return c.formatMap(pairs)
+
+	case "NUMPAT":
+		if len(args) != 1 {
+			return protocol.FormatError("wrong number of arguments for 'PUBSUB|NUMPAT' command")
+		}
+		return protocol.FormatInteger(int64(srv.pubsub.NumPat()))
+
+	default:
+		return protocol.FormatError(fmt.Sprintf("unknown subcommand '%s'. Try PUBSUB HELP.", args[0]))
+	}
+}
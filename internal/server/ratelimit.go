@@ -0,0 +1,47 @@
+package server
+
+import (
+	"context"
+	"memstash/internal/ratelimit"
+	"net"
+	"net/http"
+)
+
+// RateLimiter returns the limiter applied to TCP commands and HTTP
+// requests; CONFIG SET ratelimit-* changes its rates.
+func (srv *Server) RateLimiter() *ratelimit.Limiter {
+	return srv.limiter
+}
+
+// allow charges a command received on c (name first) to the rate limits
+// of c and of its user. Its size is the total length of its arguments.
+func (srv *Server) allow(c *client, parts []string) bool {
+	size := 0
+	for _, part := range parts {
+		size += len(part)
+	}
+	return srv.limiter.Allow(&c.limits, c.user, size)
+}
+
+// connBucketsKey is the context key of the rate limit buckets of an HTTP
+// connection.
+type connBucketsKey struct{}
+
+// withConnBuckets gives every HTTP connection its own buckets, so that
+// requests sent over one keep-alive connection share a budget like the
+// commands of one TCP connection.
+func withConnBuckets(ctx context.Context, _ net.Conn) context.Context {
+	return context.WithValue(ctx, connBucketsKey{}, &ratelimit.Buckets{})
+}
+
+// allowRequest charges r to the limits of its connection and of user ("" if
+// the request is not authenticated). Its size is the length of the request
+// URI plus the declared body length.
+func (h *HTTPServer) allowRequest(r *http.Request, user string) bool {
+	conn, _ := r.Context().Value(connBucketsKey{}).(*ratelimit.Buckets)
+	size := len(r.URL.RequestURI())
+	if r.ContentLength > 0 {
+		size += int(r.ContentLength)
+	}
+	return h.srv.limiter.Allow(conn, user, size)
+}
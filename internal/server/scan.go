@@ -0,0 +1,66 @@
+package server
+
+import (
+	"memstash/internal/protocol"
+	"strconv"
+	"strings"
+)
+
+// scanOptions holds the MATCH, COUNT and TYPE options of SCAN.
+type scanOptions struct {
+	match string
+	count int
+	typ   string
+}
+
+// parseScanOptions parses [MATCH pattern] [COUNT count] [TYPE type].
+func parseScanOptions(args []string) (scanOptions, string) {
+	opts := scanOptions{count: 10}
+	for i := 0; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return opts, protocol.FormatError("syntax error")
+		}
+		value := args[i+1]
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			opts.match = value
+		case "COUNT":
+			count, err := strconv.Atoi(value)
+			if err != nil {
+				return opts, protocol.FormatError("value is not an integer or out of range")
+			}
+			if count < 1 {
+				return opts, protocol.FormatError("syntax error")
+			}
+			opts.count = count
+		case "TYPE":
+			opts.typ = strings.ToLower(value)
+		default:
+			return opts, protocol.FormatError("syntax error")
+		}
+	}
+	return opts, ""
+}
+
+// handleScan: SCAN <cursor> [MATCH pattern] [COUNT count] [TYPE type]
+func (srv *Server) handleScan(c *client, args []string) string {
+	cursor, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return protocol.FormatError("invalid cursor")
+	}
+	opts, errReply := parseScanOptions(args[1:])
+	if errReply != "" {
+		return errReply
+	}
+
+	next, keys := srv.db(c).Scan(cursor, opts.match, opts.count, opts.typ)
+	return protocol.FormatArray([]string{
+		protocol.FormatBulkString(strconv.FormatUint(next, 10)),
+		protocol.FormatBulkStrings(keys),
+	})
+}
+
+// handleType: TYPE <key>
+func (srv *Server) handleType(c *client, args []string) string {
+	return protocol.FormatSimpleString(srv.db(c).Type(args[0]))
+}
@@ -1,41 +1,185 @@
 package server
 
 import (
-	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"log"
+	"memstash/internal/acl"
+	"memstash/internal/config"
+	"memstash/internal/lifecycle"
 	"memstash/internal/protocol"
+	"memstash/internal/pubsub"
+	"memstash/internal/ratelimit"
+	"memstash/internal/slowlog"
 	"memstash/internal/store"
 	"net"
+	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// Version is the server version reported by HELLO.
+const Version = "1.0.0"
+
 type Server struct {
 	store        *store.Store
 	listener     net.Listener
 	port         int
-	snapshotPath string
+	noTCP        bool // set by DisableTCP
+	unixListener net.Listener
+	unixPath     string
+	unixPerm     os.FileMode
+	nextClientID atomic.Int64
+	pubsub       *pubsub.Hub
+	acl          *acl.ACL
+	config       *config.Registry
+	lifecycle    *lifecycle.Coordinator
+	closing      atomic.Bool // set by Shutdown
+	ctx          context.Context
+	stopBlocked  context.CancelFunc // wakes the clients in blocking commands
+	stopEvents   func()
+	tlsConfig    *tls.Config
+
+	clientsMu sync.Mutex
+	clients   map[int64]*client
+
+	monitorsMu   sync.RWMutex
+	monitors     map[*client]chan string
+	monitorCount atomic.Int64 // len(monitors), read without the lock
+
+	tracking *trackingTable // CLIENT TRACKING state
+
+	started       time.Time
+	totalCommands atomic.Int64
+	cmdStats      map[string]*commandStat
+	slowlog       *slowlog.Log
+	limiter       *ratelimit.Limiter
 }
 
 func NewServer(s *store.Store, port int) *Server {
-	return &Server{
-		store:        s,
-		port:         port,
-		snapshotPath: "memstash_data.json",
+	srv := &Server{
+		store:    s,
+		port:     port,
+		unixPerm: 0700,
+		pubsub:   pubsub.NewHub(pubsub.DefaultBufferSize, pubsub.DropMessages),
+		acl:      acl.New(),
+		clients:  make(map[int64]*client),
+		monitors: make(map[*client]chan string),
+		tracking: newTrackingTable(),
+		started:  time.Now(),
+		cmdStats: newCommandStats(),
+		slowlog:  slowlog.New(slowlog.DefaultThreshold, slowlog.DefaultMaxLen),
+		limiter:  ratelimit.New(),
 	}
+	srv.ctx, srv.stopBlocked = context.WithCancel(context.Background())
+	srv.config = srv.newConfig()
+	return srv
+}
+
+// SetPubSub replaces the server's pub/sub hub, e.g. with one configured
+// with a different buffer size or overflow policy. Call before Start.
+func (srv *Server) SetPubSub(h *pubsub.Hub) {
+	srv.pubsub = h
 }
 
-// Start binds to the configured TCP port and accepts connections.
+// Start binds to the configured TCP port and Unix socket and accepts
+// connections on both.
 func (srv *Server) Start() {
-	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", srv.port))
+	listeners := srv.listen()
+	srv.startKeyEvents()
+	srv.serveAll(listeners)
+}
+
+// SetUnixSocket makes the server also accept connections on a Unix socket
+// at path, created with permissions perm. A stale socket file left by a
+// previous run is removed. Call before Start.
+func (srv *Server) SetUnixSocket(path string, perm os.FileMode) {
+	srv.unixPath = path
+	srv.unixPerm = perm
+}
+
+// DisableTCP makes the server listen on its Unix socket only. Call before
+// Start, after SetUnixSocket.
+func (srv *Server) DisableTCP() {
+	srv.noTCP = true
+}
+
+// SetTLS makes the server accept only TLS connections, configured by cfg
+// (see tlsconf.Reloader.Config). Call before Start.
+func (srv *Server) SetTLS(cfg *tls.Config) {
+	srv.tlsConfig = cfg
+}
+
+// listen binds the TCP port, wrapping it in TLS when configured, and the
+// Unix socket if one is set.
+func (srv *Server) listen() []net.Listener {
+	var listeners []net.Listener
+	if !srv.noTCP {
+		ln, err := net.Listen("tcp", fmt.Sprintf(":%d", srv.port))
+		if err != nil {
+			log.Fatalf("TCP server failed to start: %v", err)
+		}
+		if srv.tlsConfig != nil {
+			ln = tls.NewListener(ln, srv.tlsConfig)
+			log.Printf("TCP server listening on %s (TLS)", ln.Addr().String())
+		} else {
+			log.Printf("TCP server listening on %s", ln.Addr().String())
+		}
+		srv.listener = ln
+		listeners = append(listeners, ln)
+	}
+	if srv.unixPath != "" {
+		ln, err := listenUnix(srv.unixPath, srv.unixPerm)
+		if err != nil {
+			log.Fatalf("Unix socket failed to start: %v", err)
+		}
+		log.Printf("TCP server listening on unix socket %s", srv.unixPath)
+		srv.unixListener = ln
+		listeners = append(listeners, ln)
+	}
+	return listeners
+}
+
+// listenUnix binds a Unix socket at path with permissions perm. The socket
+// is never wrapped in TLS: it cannot be reached from another host.
+func listenUnix(path string, perm os.FileMode) (net.Listener, error) {
+	// Redis does the same: a socket file that outlived its server would
+	// make bind fail.
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	ln, err := net.Listen("unix", path)
 	if err != nil {
-		log.Fatalf("TCP server failed to start: %v", err)
+		return nil, err
 	}
-	srv.listener = ln
-	log.Printf("TCP server listening on :%d", srv.port)
+	if err := os.Chmod(path, perm); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
 
+// serveAll serves every listener until they are all closed. TCP and Unix
+// socket connections go through the same handleConnection and registry.
+func (srv *Server) serveAll(listeners []net.Listener) {
+	var wg sync.WaitGroup
+	for _, ln := range listeners {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			srv.serve(ln)
+		}()
+	}
+	wg.Wait()
+}
+
+// serve accepts connections until the listener is closed.
+func (srv *Server) serve(ln net.Listener) {
 	for {
 		conn, err := ln.Accept()
 		if err != nil {
@@ -50,11 +194,24 @@ func (srv *Server) Start() {
 	}
 }
 
-// Stop closes the listener for graceful shutdown.
+// Stop closes the listeners for graceful shutdown.
 func (srv *Server) Stop() {
+	srv.closeListeners()
+	srv.stopBlocked()
+	if srv.stopEvents != nil {
+		srv.stopEvents()
+	}
+}
+
+// closeListeners stops accepting connections. Closing the Unix listener
+// also removes its socket file.
+func (srv *Server) closeListeners() {
 	if srv.listener != nil {
 		srv.listener.Close()
 	}
+	if srv.unixListener != nil {
+		srv.unixListener.Close()
+	}
 }
 
 // Addr returns the listener's address (useful for tests with port 0).
@@ -65,163 +222,233 @@ func (srv *Server) Addr() net.Addr {
 	return nil
 }
 
+// UnixAddr returns the Unix socket's address, or nil if there is none.
+func (srv *Server) UnixAddr() net.Addr {
+	if srv.unixListener != nil {
+		return srv.unixListener.Addr()
+	}
+	return nil
+}
+
 // StartAndReady starts the server and signals via the returned channel
 // once the listener is bound (useful for tests).
 func (srv *Server) StartAndReady() <-chan struct{} {
 	ready := make(chan struct{})
 	go func() {
-		ln, err := net.Listen("tcp", fmt.Sprintf(":%d", srv.port))
-		if err != nil {
-			log.Fatalf("TCP server failed to start: %v", err)
-		}
-		srv.listener = ln
-		log.Printf("TCP server listening on %s", ln.Addr().String())
+		listeners := srv.listen()
+		srv.startKeyEvents()
 		close(ready)
-
-		for {
-			conn, err := ln.Accept()
-			if err != nil {
-				if strings.Contains(err.Error(), "use of closed network connection") {
-					return
-				}
-				log.Printf("Accept error: %v", err)
-				continue
-			}
-			go srv.handleConnection(conn)
-		}
+		srv.serveAll(listeners)
 	}()
 	return ready
 }
 
+// handleConnection serves one client. Every command already sitting in the
+// read buffer is executed before the replies are flushed, so a pipelined
+// batch costs one write syscall instead of one per command.
 func (srv *Server) handleConnection(conn net.Conn) {
 	defer conn.Close()
-	scanner := bufio.NewScanner(conn)
+	c := newClient(srv.nextClientID.Add(1), conn)
+	srv.registerClient(c)
+	defer srv.closeClient(c)
+	// Accepted just before Shutdown: it never saw this client.
+	if srv.closing.Load() {
+		return
+	}
+	reader := protocol.NewReader(conn)
+	c.reader = reader
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
+	for {
+		parts, err := reader.ReadCommand()
+		if err != nil {
+			if errors.Is(err, protocol.ErrProtocol) {
+				c.wmu.Lock()
+				c.w.WriteString(protocol.FormatError(err.Error()))
+				c.w.Flush()
+				c.wmu.Unlock()
+			}
+			return
 		}
 
-		parts := strings.Fields(line)
 		cmd := strings.ToUpper(parts[0])
 		args := parts[1:]
 
-		response := srv.executeCommand(cmd, args)
-		conn.Write([]byte(response))
+		// Hold the writer while the command runs so that pub/sub messages
+		// cannot overtake the reply (e.g. a SUBSCRIBE confirmation).
+		c.wmu.Lock()
+		response := protocol.FormatError("rate limited")
+		if srv.allow(c, parts) {
+			response = srv.executeCommand(c, cmd, args)
+		}
+		c.w.WriteString(response)
+
+		// End of the batch: nothing left to parse without blocking.
+		closing := cmd == "QUIT" || c.closeAfterReply
+		if closing || reader.Buffered() == 0 {
+			err = c.w.Flush()
+		}
+		c.touch(cmd, reader.Buffered())
+		c.wmu.Unlock()
 
-		if cmd == "QUIT" {
+		if closing || err != nil {
 			return
 		}
 	}
 }
 
-func (srv *Server) executeCommand(cmd string, args []string) string {
-	switch cmd {
-	case "PING":
-		return protocol.FormatPong()
-
-	case "SET":
-		return srv.handleSet(args)
-
-	case "GET":
-		return srv.handleGet(args)
-
-	case "DEL", "DELETE":
-		return srv.handleDelete(args)
-
-	case "EXISTS":
-		return srv.handleExists(args)
-
-	case "SETEX":
-		return srv.handleSetEx(args)
-
-	case "TTL":
-		return srv.handleTTL(args)
-
-	case "KEYS":
-		return srv.handleKeys()
-
-	case "SAVE":
-		return srv.handleSave()
-
-	case "LOAD":
-		return srv.handleLoad()
+// closeClient releases everything a disconnected client holds.
+func (srv *Server) closeClient(c *client) {
+	srv.unregisterClient(c)
+	if c.monitor != nil {
+		srv.removeMonitor(c)
+	}
+	if c.sub != nil {
+		srv.pubsub.Remove(c.sub)
+	}
+	srv.closeTracking(c)
+}
 
-	case "CLEAR":
-		srv.store.Clear()
-		return protocol.FormatOK()
+// executeCommand runs one command for c, after checking its arity, the
+// permissions of c's user and the restrictions of c's current mode
+// (subscriber, monitor, MULTI).
+func (srv *Server) executeCommand(c *client, name string, args []string) string {
+	cmd, ok := commands[name]
+	if !ok {
+		if c.inMulti {
+			c.multiErr = true
+		}
+		return protocol.FormatError(fmt.Sprintf("unknown command '%s'", name))
+	}
+	if !cmd.checkArity(len(args)) {
+		if c.inMulti {
+			c.multiErr = true
+		}
+		return protocol.FormatError(fmt.Sprintf("wrong number of arguments for '%s' command", name))
+	}
+	if !c.local {
+		if err := checkPermission(srv.user(c), cmd, args); err != nil {
+			if c.inMulti {
+				c.multiErr = true
+			}
+			return protocol.FormatErrorCode(err.code, err.msg)
+		}
+	}
+	if c.subscribed() && !subscriberCommands[name] {
+		return protocol.FormatError(fmt.Sprintf(
+			"Can't execute '%s': only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT are allowed in this context",
+			strings.ToLower(name)))
+	}
+	if c.monitor != nil && !monitorCommands[name] {
+		return protocol.FormatError("only QUIT is allowed after MONITOR")
+	}
+	if c.inMulti && !txControlCommands[name] {
+		return srv.queueCommand(c, name, args)
+	}
+	if c.tracking != nil && cmd.hasFlag("readonly") {
+		srv.trackReads(c, cmd.keys(args))
+	}
+	var reply string
+	srv.call(cmd, args, c.addr, c.db, c, func() {
+		reply = cmd.handler(srv, c, args)
+	})
+	return reply
+}
 
-	case "EXPIRE":
-		return srv.handleExpire(args)
+// call runs one command through run, whichever front end received it: it
+// shows the command to monitors, times it, and accounts it in the command
+// statistics and the slow log. c names the client in the slow log. The
+// time a blocking command spends waiting for a push is not counted.
+func (srv *Server) call(cmd *Command, args []string, addr string, db int, c *client, run func()) {
+	fullArgs := append([]string{cmd.Name}, args...)
+	if !cmd.hasFlag("skip_monitor") {
+		srv.FeedMonitors(addr, db, fullArgs)
+	}
+	start, waited := time.Now(), c.waited
+	run()
+	d := time.Since(start) - (c.waited - waited)
+	srv.record(cmd.Name, d)
+	if srv.slowlog.Exceeds(d) && !cmd.hasFlag("skip_slowlog") {
+		c.mu.Lock()
+		name := c.name
+		c.mu.Unlock()
+		srv.slowlog.Add(d, fullArgs, addr, name)
+	}
+}
 
-	case "STATS":
-		return srv.handleStats()
+// db returns the database commands from c operate on: the one c selected,
+// seen through the transaction view while EXEC runs.
+func (srv *Server) db(c *client) *store.Store {
+	if c.tx != nil {
+		return c.tx.DB(c.db)
+	}
+	return srv.store.DB(c.db)
+}
 
-	case "HELP":
-		return srv.handleHelp()
+func (srv *Server) handlePing(c *client, args []string) string {
+	if c.subscribed() {
+		message := ""
+		if len(args) > 0 {
+			message = args[0]
+		}
+		return protocol.FormatBulkStrings([]string{"pong", message})
+	}
+	return protocol.FormatPong()
+}
 
-	case "QUIT":
-		return protocol.FormatOK()
+func (srv *Server) handleQuit(c *client, args []string) string {
+	return protocol.FormatOK()
+}
 
-	default:
-		return protocol.FormatError(fmt.Sprintf("unknown command '%s'", cmd))
-	}
+func (srv *Server) handleClear(c *client, args []string) string {
+	srv.db(c).Clear()
+	return protocol.FormatOK()
 }
 
-func (srv *Server) handleSet(args []string) string {
-	if len(args) < 2 {
-		return protocol.FormatError("wrong number of arguments for 'SET' command")
-	}
+func (srv *Server) handleSet(c *client, args []string) string {
 	key := args[0]
 	value := strings.Join(args[1:], " ")
 
-	err := srv.store.Set(key, value)
+	err := srv.db(c).Set(key, value)
 	if err != nil {
-		return protocol.FormatError(err.Error())
+		return storeError(err)
 	}
 	return protocol.FormatOK()
 }
 
-func (srv *Server) handleGet(args []string) string {
-	if len(args) < 1 {
-		return protocol.FormatError("wrong number of arguments for 'GET' command")
-	}
+func (srv *Server) handleGet(c *client, args []string) string {
 	key := args[0]
-	value, err := srv.store.Get(key)
+	value, err := srv.db(c).Get(key)
+	if errors.Is(err, store.ErrWrongType) {
+		return storeError(err)
+	}
 	if err != nil {
-		return protocol.FormatNull()
+		return c.formatNull()
 	}
 	return protocol.FormatBulkString(value)
 }
 
-func (srv *Server) handleDelete(args []string) string {
-	if len(args) < 1 {
-		return protocol.FormatError("wrong number of arguments for 'DEL' command")
-	}
-	key := args[0]
-	err := srv.store.Delete(key)
-	if err != nil {
-		return protocol.FormatInteger(0)
+// handleDelete: DEL key [key ...]
+// Replies with the number of keys that existed and were removed.
+func (srv *Server) handleDelete(c *client, args []string) string {
+	db := srv.db(c)
+	deleted := 0
+	for _, key := range args {
+		if db.Delete(key) == nil {
+			deleted++
+		}
 	}
-	return protocol.FormatInteger(1)
+	return protocol.FormatInteger(int64(deleted))
 }
 
-func (srv *Server) handleExists(args []string) string {
-	if len(args) < 1 {
-		return protocol.FormatError("wrong number of arguments for 'EXISTS' command")
-	}
+func (srv *Server) handleExists(c *client, args []string) string {
 	key := args[0]
-	if srv.store.Exists(key) {
+	if srv.db(c).Exists(key) {
 		return protocol.FormatInteger(1)
 	}
 	return protocol.FormatInteger(0)
 }
 
-func (srv *Server) handleSetEx(args []string) string {
-	if len(args) < 3 {
-		return protocol.FormatError("wrong number of arguments for 'SETEX' command")
-	}
+func (srv *Server) handleSetEx(c *client, args []string) string {
 	key := args[0]
 	seconds, err := strconv.Atoi(args[1])
 	if err != nil {
@@ -230,99 +457,140 @@ func (srv *Server) handleSetEx(args []string) string {
 	value := strings.Join(args[2:], " ")
 	ttl := time.Duration(seconds) * time.Second
 
-	err = srv.store.SetWithTTL(key, value, ttl)
+	err = srv.db(c).SetWithTTL(key, value, ttl)
 	if err != nil {
-		return protocol.FormatError(err.Error())
+		return storeError(err)
 	}
 	return protocol.FormatOK()
 }
 
-func (srv *Server) handleTTL(args []string) string {
-	if len(args) < 1 {
-		return protocol.FormatError("wrong number of arguments for 'TTL' command")
-	}
+func (srv *Server) handleTTL(c *client, args []string) string {
 	key := args[0]
-	ttl, err := srv.store.GetTTL(key)
+	ttl, err := srv.db(c).GetTTL(key)
 	if err != nil {
 		return protocol.FormatInteger(-2) // key does not exist
 	}
 	return protocol.FormatInteger(int64(ttl.Seconds()))
 }
 
-func (srv *Server) handleKeys() string {
-	keys := srv.store.Keys()
-	if len(keys) == 0 {
-		return protocol.FormatNull()
+// handleKeys: KEYS [pattern]
+// Without a pattern an empty database still replies with a null, as it
+// always has; with a pattern the reply is always an array.
+func (srv *Server) handleKeys(c *client, args []string) string {
+	if len(args) > 1 {
+		return protocol.FormatError("wrong number of arguments for 'KEYS' command")
 	}
-	// Return as multiple bulk strings (simplified array)
-	var b strings.Builder
-	b.WriteString(fmt.Sprintf("*%d\r\n", len(keys)))
-	for _, k := range keys {
-		b.WriteString(protocol.FormatBulkString(k))
+	if len(args) == 1 {
+		return protocol.FormatBulkStrings(srv.db(c).KeysMatching(args[0]))
 	}
-	return b.String()
+	keys := srv.db(c).Keys()
+	if len(keys) == 0 {
+		return c.formatNull()
+	}
+	return protocol.FormatBulkStrings(keys)
 }
 
-func (srv *Server) handleSave() string {
-	err := srv.store.SaveSnapshot(srv.snapshotPath)
+func (srv *Server) handleSave(c *client, args []string) string {
+	db := srv.db(c)
+	err := db.SaveSnapshot(db.SnapshotPath())
 	if err != nil {
 		return protocol.FormatError(err.Error())
 	}
 	return protocol.FormatOK()
 }
 
-func (srv *Server) handleLoad() string {
-	err := srv.store.LoadSnapshot(srv.snapshotPath)
+func (srv *Server) handleLoad(c *client, args []string) string {
+	db := srv.db(c)
+	err := db.LoadSnapshot(db.SnapshotPath())
 	if err != nil {
 		return protocol.FormatError(err.Error())
 	}
 	return protocol.FormatOK()
 }
 
-func (srv *Server) handleExpire(args []string) string {
-	if len(args) < 2 {
-		return protocol.FormatError("wrong number of arguments for 'EXPIRE' command")
-	}
+func (srv *Server) handleExpire(c *client, args []string) string {
 	key := args[0]
 	seconds, err := strconv.Atoi(args[1])
 	if err != nil {
 		return protocol.FormatError("value is not an integer or out of range")
 	}
 	ttl := time.Duration(seconds) * time.Second
-	err = srv.store.SetExpiry(key, ttl)
+	err = srv.db(c).SetExpiry(key, ttl)
 	if err != nil {
 		return protocol.FormatInteger(0)
 	}
 	return protocol.FormatInteger(1)
 }
 
-func (srv *Server) handleStats() string {
-	stats := srv.store.Stats()
+func (srv *Server) handleStats(c *client, args []string) string {
+	stats := srv.db(c).Stats()
+	if c.resp3() {
+		return protocol.FormatMap([]string{
+			protocol.FormatBulkString("keys"), protocol.FormatInteger(int64(stats.Keys)),
+			protocol.FormatBulkString("capacity"), protocol.FormatInteger(int64(stats.Capacity)),
+			protocol.FormatBulkString("hits"), protocol.FormatInteger(stats.Hits),
+			protocol.FormatBulkString("misses"), protocol.FormatInteger(stats.Misses),
+			protocol.FormatBulkString("evictions"), protocol.FormatInteger(stats.Evictions),
+			protocol.FormatBulkString("throttled_by_connection"), protocol.FormatInteger(srv.limiter.Throttled(ratelimit.Connection)),
+			protocol.FormatBulkString("throttled_by_user"), protocol.FormatInteger(srv.limiter.Throttled(ratelimit.User)),
+		})
+	}
 	var b strings.Builder
 	b.WriteString(fmt.Sprintf("keys:%d\r\n", stats.Keys))
 	b.WriteString(fmt.Sprintf("capacity:%d\r\n", stats.Capacity))
 	b.WriteString(fmt.Sprintf("hits:%d\r\n", stats.Hits))
 	b.WriteString(fmt.Sprintf("misses:%d\r\n", stats.Misses))
 	b.WriteString(fmt.Sprintf("evictions:%d\r\n", stats.Evictions))
+	b.WriteString(fmt.Sprintf("throttled_by_connection:%d\r\n", srv.limiter.Throttled(ratelimit.Connection)))
+	b.WriteString(fmt.Sprintf("throttled_by_user:%d\r\n", srv.limiter.Throttled(ratelimit.User)))
 	return protocol.FormatBulkString(b.String())
 }
 
-func (srv *Server) handleHelp() string {
-	help := `Commands:
-  PING                        - Test connection
-  SET <key> <value>           - Set a key-value pair
-  GET <key>                   - Get value by key
-  DEL <key>                   - Delete a key
-  EXISTS <key>                - Check if key exists (1/0)
-  SETEX <key> <sec> <value>   - Set with expiration
-  TTL <key>                   - Get time to live
-  EXPIRE <key> <seconds>      - Set expiration on key
-  KEYS                        - List all keys
-  SAVE                        - Save snapshot to disk
-  LOAD                        - Load snapshot from disk
-  CLEAR                       - Remove all keys
-  STATS                       - Show statistics
-  HELP                        - Show this help
-  QUIT                        - Close connection`
-	return protocol.FormatBulkString(help)
+// handleHello switches the connection's protocol version, optionally
+// authenticating first, and replies with a map describing the server:
+// HELLO [protover [AUTH username password]]
+func (srv *Server) handleHello(c *client, args []string) string {
+	version := c.proto
+	if len(args) > 0 {
+		v, err := strconv.Atoi(args[0])
+		if err != nil {
+			return protocol.FormatError("Protocol version is not an integer or out of range")
+		}
+		if v != protocol.RESP2 && v != protocol.RESP3 {
+			return protocol.FormatErrorCode("NOPROTO", "unsupported protocol version")
+		}
+		version = v
+	}
+
+	for i := 1; i < len(args); i++ {
+		switch {
+		case strings.EqualFold(args[i], "AUTH") && i+2 < len(args):
+			if errReply := srv.authenticate(c, args[i+1], args[i+2]); errReply != "" {
+				return errReply
+			}
+			i += 2
+		default:
+			return protocol.FormatError(fmt.Sprintf("syntax error in HELLO option '%s'", args[i]))
+		}
+	}
+	if srv.user(c) == nil {
+		return protocol.FormatErrorCode("NOAUTH", "HELLO must be called with the client already authenticated, otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client and select the RESP protocol version at the same time")
+	}
+	c.mu.Lock()
+	c.proto = version
+	c.mu.Unlock()
+
+	return c.formatMap([]string{
+		protocol.FormatBulkString("server"), protocol.FormatBulkString("memstash"),
+		protocol.FormatBulkString("version"), protocol.FormatBulkString(Version),
+		protocol.FormatBulkString("proto"), protocol.FormatInteger(int64(c.proto)),
+		protocol.FormatBulkString("id"), protocol.FormatInteger(c.id),
+		protocol.FormatBulkString("mode"), protocol.FormatBulkString("standalone"),
+		protocol.FormatBulkString("role"), protocol.FormatBulkString("master"),
+		protocol.FormatBulkString("modules"), protocol.FormatArray(nil),
+	})
+}
+
+func (srv *Server) handleHelp(c *client, args []string) string {
+	return protocol.FormatBulkString(helpText())
 }
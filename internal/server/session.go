@@ -0,0 +1,66 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"memstash/internal/protocol"
+	"strings"
+	"time"
+)
+
+// connectionCommands need a connection of their own to push data to or to
+// negotiate, so in-process sessions cannot run them.
+var connectionCommands = map[string]bool{
+	"HELLO":        true,
+	"MONITOR":      true,
+	"SUBSCRIBE":    true,
+	"PSUBSCRIBE":   true,
+	"UNSUBSCRIBE":  true,
+	"PUNSUBSCRIBE": true,
+}
+
+// Session runs commands in-process, for front ends without a connection
+// such as the CLI. Commands go through the same table, statistics, MONITOR
+// feed and slow log as those of TCP clients. A session is not in the client
+// registry and skips ACL checks: whoever runs the process already controls
+// its data.
+type Session struct {
+	srv *Server
+	c   *client
+}
+
+// NewSession creates a session whose commands are reported as coming
+// from addr, e.g. "cli".
+func (srv *Server) NewSession(addr string) *Session {
+	now := time.Now()
+	return &Session{srv: srv, c: &client{
+		addr:       addr,
+		laddr:      addr,
+		local:      true,
+		created:    now,
+		lastActive: now,
+		flags:      "N",
+		proto:      protocol.RESP2,
+		w:          bufio.NewWriter(io.Discard),
+	}}
+}
+
+// Do runs one command (name first) and returns its RESP2 reply.
+func (s *Session) Do(args []string) string {
+	if len(args) == 0 {
+		return protocol.FormatError("empty command")
+	}
+	name := strings.ToUpper(args[0])
+	if connectionCommands[name] {
+		return protocol.FormatError(fmt.Sprintf("'%s' needs a network connection", name))
+	}
+	return s.srv.executeCommand(s.c, name, args[1:])
+}
+
+// DB returns the database the session has selected.
+func (s *Session) DB() int {
+	s.c.mu.Lock()
+	defer s.c.mu.Unlock()
+	return s.c.db
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"memstash/internal/protocol"
+	"strconv"
+)
+
+// formatMembers replies with set members: a set for RESP3 clients, an
+// array otherwise.
+func (c *client) formatMembers(members []string) string {
+	items := make([]string, len(members))
+	for i, m := range members {
+		items[i] = protocol.FormatBulkString(m)
+	}
+	return c.formatSet(items)
+}
+
+// handleSAdd: SADD key member [member ...]
+func (srv *Server) handleSAdd(c *client, args []string) string {
+	n, err := srv.db(c).SAdd(args[0], args[1:]...)
+	if err != nil {
+		return storeError(err)
+	}
+	return protocol.FormatInteger(int64(n))
+}
+
+// handleSRem: SREM key member [member ...]
+func (srv *Server) handleSRem(c *client, args []string) string {
+	n, err := srv.db(c).SRem(args[0], args[1:]...)
+	if err != nil {
+		return storeError(err)
+	}
+	return protocol.FormatInteger(int64(n))
+}
+
+// handleSMembers: SMEMBERS key
+func (srv *Server) handleSMembers(c *client, args []string) string {
+	members, err := srv.db(c).SMembers(args[0])
+	if err != nil {
+		return storeError(err)
+	}
+	return c.formatMembers(members)
+}
+
+// handleSIsMember: SISMEMBER key member
+func (srv *Server) handleSIsMember(c *client, args []string) string {
+	ok, err := srv.db(c).SIsMember(args[0], args[1])
+	switch {
+	case err != nil:
+		return storeError(err)
+	case ok:
+		return protocol.FormatInteger(1)
+	}
+	return protocol.FormatInteger(0)
+}
+
+// handleSCard: SCARD key
+func (srv *Server) handleSCard(c *client, args []string) string {
+	n, err := srv.db(c).SCard(args[0])
+	if err != nil {
+		return storeError(err)
+	}
+	return protocol.FormatInteger(int64(n))
+}
+
+// handleSInter: SINTER key [key ...]
+func (srv *Server) handleSInter(c *client, args []string) string {
+	return srv.combine(c, args, srv.db(c).SInter)
+}
+
+// handleSUnion: SUNION key [key ...]
+func (srv *Server) handleSUnion(c *client, args []string) string {
+	return srv.combine(c, args, srv.db(c).SUnion)
+}
+
+// handleSDiff: SDIFF key [key ...]
+func (srv *Server) handleSDiff(c *client, args []string) string {
+	return srv.combine(c, args, srv.db(c).SDiff)
+}
+
+func (srv *Server) combine(c *client, keys []string, op func(...string) ([]string, error)) string {
+	members, err := op(keys...)
+	if err != nil {
+		return storeError(err)
+	}
+	return c.formatMembers(members)
+}
+
+// handleSInterStore: SINTERSTORE destination key [key ...]
+func (srv *Server) handleSInterStore(c *client, args []string) string {
+	return combineStore(args, srv.db(c).SInterStore)
+}
+
+// handleSUnionStore: SUNIONSTORE destination key [key ...]
+func (srv *Server) handleSUnionStore(c *client, args []string) string {
+	return combineStore(args, srv.db(c).SUnionStore)
+}
+
+// handleSDiffStore: SDIFFSTORE destination key [key ...]
+func (srv *Server) handleSDiffStore(c *client, args []string) string {
+	return combineStore(args, srv.db(c).SDiffStore)
+}
+
+func combineStore(args []string, op func(string, ...string) (int, error)) string {
+	n, err := op(args[0], args[1:]...)
+	if err != nil {
+		return storeError(err)
+	}
+	return protocol.FormatInteger(int64(n))
+}
+
+// handleSRandMember: SRANDMEMBER key [count]
+// Without count it replies with one member; with a negative count members
+// may repeat.
+func (srv *Server) handleSRandMember(c *client, args []string) string {
+	if len(args) > 2 {
+		return protocol.FormatError("syntax error")
+	}
+	count := 1
+	if len(args) == 2 {
+		n, ok := parseIndex(args[1])
+		if !ok {
+			return protocol.FormatError("value is not an integer or out of range")
+		}
+		count = n
+	}
+	members, err := srv.db(c).SRandMember(args[0], count)
+	switch {
+	case err != nil:
+		return storeError(err)
+	case len(args) == 2:
+		return protocol.FormatBulkStrings(members)
+	case len(members) == 0:
+		return c.formatNull()
+	}
+	return protocol.FormatBulkString(members[0])
+}
+
+// handleSPop: SPOP key [count]
+func (srv *Server) handleSPop(c *client, args []string) string {
+	if len(args) > 2 {
+		return protocol.FormatError("syntax error")
+	}
+	count := 1
+	if len(args) == 2 {
+		n, ok := parseIndex(args[1])
+		if !ok || n < 0 {
+			return protocol.FormatError("value is out of range, must be positive")
+		}
+		count = n
+	}
+	popped, err := srv.db(c).SPop(args[0], count)
+	switch {
+	case err != nil:
+		return storeError(err)
+	case len(args) == 2:
+		return c.formatMembers(popped)
+	case len(popped) == 0:
+		return c.formatNull()
+	}
+	return protocol.FormatBulkString(popped[0])
+}
+
+// handleSScan: SSCAN key cursor [MATCH pattern] [COUNT count]
+func (srv *Server) handleSScan(c *client, args []string) string {
+	cursor, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		return protocol.FormatError("invalid cursor")
+	}
+	opts, errReply := parseScanOptions(args[2:])
+	if errReply != "" {
+		return errReply
+	}
+	if opts.typ != "" {
+		return protocol.FormatError("syntax error")
+	}
+	next, members, err := srv.db(c).SScan(args[0], cursor, opts.match, opts.count)
+	if err != nil {
+		return storeError(err)
+	}
+	return protocol.FormatArray([]string{
+		protocol.FormatBulkString(strconv.FormatUint(next, 10)),
+		protocol.FormatBulkStrings(members),
+	})
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"memstash/internal/lifecycle"
+	"memstash/internal/protocol"
+	"time"
+)
+
+// drainPollInterval is how often Shutdown checks whether every connection
+// has finished, like http.Server.Shutdown does.
+const drainPollInterval = 10 * time.Millisecond
+
+// SetLifecycle lets SHUTDOWN (and POST /shutdown) stop the process through
+// c. Without it, SHUTDOWN is refused.
+func (srv *Server) SetLifecycle(c *lifecycle.Coordinator) {
+	srv.lifecycle = c
+}
+
+// Shutdown stops accepting connections and waits for every client to
+// finish the command it is running. Idle connections are closed at once;
+// if ctx expires first, the remaining ones are closed forcibly.
+func (srv *Server) Shutdown(ctx context.Context) error {
+	srv.closing.Store(true)
+	srv.closeListeners()
+	// Keyspace events keep flowing to subscribers until the commands being
+	// drained are done: stopping them takes the store lock those commands
+	// may hold.
+	stopEvents := func() {
+		if srv.stopEvents != nil {
+			srv.stopEvents()
+		}
+	}
+
+	// A connection blocked reading its next command wakes up with an
+	// error; one running a command finishes it, replies, then hits the
+	// deadline on its next read. Blocking commands give up at once.
+	srv.stopBlocked()
+	for _, c := range srv.connectedClients() {
+		c.interruptRead()
+	}
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for srv.ClientCount() > 0 {
+		select {
+		case <-ctx.Done():
+			for _, c := range srv.connectedClients() {
+				c.conn.Close()
+			}
+			go stopEvents()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	stopEvents()
+	return nil
+}
+
+// handleShutdown stops the server: SHUTDOWN [NOSAVE|SAVE]
+// The reply is sent before the connection closes; the shutdown itself runs
+// in the background, draining the other clients first.
+func (srv *Server) handleShutdown(c *client, args []string) string {
+	if len(args) > 1 {
+		return protocol.FormatError("wrong number of arguments for 'SHUTDOWN' command")
+	}
+	if srv.lifecycle == nil {
+		return protocol.FormatError("SHUTDOWN is not enabled on this server")
+	}
+	arg := ""
+	if len(args) == 1 {
+		arg = args[0]
+	}
+	mode, err := lifecycle.ParseMode(arg)
+	if err != nil {
+		return protocol.FormatError("syntax error")
+	}
+	c.closeAfterReply = true
+	go srv.lifecycle.Shutdown(mode)
+	return protocol.FormatOK()
+}
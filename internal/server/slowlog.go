@@ -0,0 +1,56 @@
+package server
+
+import (
+	"fmt"
+	"memstash/internal/protocol"
+	"memstash/internal/slowlog"
+	"strconv"
+	"strings"
+)
+
+// SlowLog returns the log of commands that exceeded the latency threshold.
+func (srv *Server) SlowLog() *slowlog.Log {
+	return srv.slowlog
+}
+
+// handleSlowLog reads and clears the slow log:
+// SLOWLOG GET [count] | LEN | RESET
+func (srv *Server) handleSlowLog(c *client, args []string) string {
+	switch strings.ToUpper(args[0]) {
+	case "GET":
+		count := 10
+		if len(args) > 2 {
+			return protocol.FormatError("wrong number of arguments for 'SLOWLOG|GET' command")
+		}
+		if len(args) == 2 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < -1 {
+				return protocol.FormatError("count should be greater than or equal to -1")
+			}
+			count = n
+		}
+		entries := srv.slowlog.Get(count)
+		replies := make([]string, 0, len(entries))
+		for _, e := range entries {
+			replies = append(replies, protocol.FormatArray([]string{
+				protocol.FormatInteger(e.ID),
+				protocol.FormatInteger(e.Timestamp),
+				protocol.FormatInteger(e.Micros),
+				protocol.FormatBulkStrings(e.Args),
+				protocol.FormatBulkString(e.Client),
+				protocol.FormatBulkString(e.ClientName),
+			}))
+		}
+		return protocol.FormatArray(replies)
+
+	case "LEN":
+		return protocol.FormatInteger(int64(srv.slowlog.Len()))
+
+	case "RESET":
+		srv.slowlog.Reset()
+		return protocol.FormatOK()
+
+	default:
+		return protocol.FormatError(fmt.Sprintf("unknown subcommand '%s'. Try SLOWLOG HELP.", args[0]))
+	}
+}
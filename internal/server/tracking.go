@@ -0,0 +1,349 @@
+package server
+
+import (
+	"errors"
+	"memstash/internal/protocol"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// trackingBuffer is the number of invalidation messages queued per
+// receiving connection. Unlike monitor lines they are never dropped: a
+// client that misses one would keep serving a stale value, so a
+// connection that falls this far behind is disconnected instead.
+const trackingBuffer = 1024
+
+// invalidateChannel is the channel RESP2 connections subscribe to when
+// other connections redirect their invalidation messages to them.
+const invalidateChannel = "__redis__:invalidate"
+
+// tracker is the CLIENT TRACKING state of one connection. It is not
+// changed once created: CLIENT TRACKING ON replaces it.
+type tracker struct {
+	c        *client
+	redirect *client  // receives the invalidation messages: c unless REDIRECT was given
+	bcast    bool     // broadcast mode: follow prefixes instead of the keys c read
+	prefixes []string // BCAST prefixes; none means every key
+}
+
+// follows reports whether a broadcast tracker is told about key.
+func (tr *tracker) follows(key string) bool {
+	if len(tr.prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range tr.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// trackingTable remembers which connections read which keys and which
+// connections follow key prefixes. Like in Redis, keys are tracked by name
+// whatever database they were read from.
+type trackingTable struct {
+	count atomic.Int64 // len(trackers), read without the lock on every write
+
+	mu       sync.Mutex
+	trackers map[*client]*tracker
+	readers  map[string]map[*client]struct{} // default mode: key -> connections that read it
+	outboxes map[*client]chan []string       // receiving connection -> queued invalidations
+}
+
+func newTrackingTable() *trackingTable {
+	return &trackingTable{
+		trackers: make(map[*client]*tracker),
+		readers:  make(map[string]map[*client]struct{}),
+		outboxes: make(map[*client]chan []string),
+	}
+}
+
+// startTracking enables tr, replacing the connection's previous tracking
+// state, and makes sure its redirect target has an outbox.
+func (srv *Server) startTracking(tr *tracker) error {
+	t := srv.tracking
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// The target may disconnect at any time; closeTracking runs after it
+	// left the registry, so a target still registered here gets cleaned up.
+	if tr.redirect != tr.c {
+		srv.clientsMu.Lock()
+		alive := srv.clients[tr.redirect.id] == tr.redirect
+		srv.clientsMu.Unlock()
+		if !alive {
+			return errors.New("The client ID you want redirect to does not exist")
+		}
+	}
+	t.forget(tr.c)
+	t.trackers[tr.c] = tr
+	t.count.Store(int64(len(t.trackers)))
+	if _, ok := t.outboxes[tr.redirect]; !ok && tr.redirect.conn != nil {
+		ch := make(chan []string, trackingBuffer)
+		t.outboxes[tr.redirect] = ch
+		go deliverInvalidations(tr.redirect, ch)
+	}
+	tr.c.tracking = tr
+	return nil
+}
+
+// stopTracking disables tracking for c (CLIENT TRACKING OFF).
+func (srv *Server) stopTracking(c *client) {
+	t := srv.tracking
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.forget(c)
+	c.tracking = nil
+}
+
+// closeTracking releases the tracking state of a disconnected client,
+// including the outbox of invalidations redirected to it.
+func (srv *Server) closeTracking(c *client) {
+	t := srv.tracking
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.forget(c)
+	if ch, ok := t.outboxes[c]; ok {
+		delete(t.outboxes, c)
+		close(ch)
+	}
+}
+
+// forget drops c's tracker and the keys it read. Callers hold mu.
+func (t *trackingTable) forget(c *client) {
+	tr, ok := t.trackers[c]
+	if !ok {
+		return
+	}
+	delete(t.trackers, c)
+	t.count.Store(int64(len(t.trackers)))
+	if tr.bcast {
+		return
+	}
+	for key, readers := range t.readers {
+		delete(readers, c)
+		if len(readers) == 0 {
+			delete(t.readers, key)
+		}
+	}
+}
+
+// trackReads remembers that c is about to read keys, so that it is told
+// when they change. Recording them before the read means a concurrent
+// write can only cause a spurious invalidation, never a missed one.
+func (srv *Server) trackReads(c *client, keys []string) {
+	if c.tracking == nil || c.tracking.bcast || len(keys) == 0 {
+		return
+	}
+	t := srv.tracking
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, key := range keys {
+		readers, ok := t.readers[key]
+		if !ok {
+			readers = make(map[*client]struct{})
+			t.readers[key] = readers
+		}
+		readers[c] = struct{}{}
+	}
+}
+
+// invalidate is the store's invalidation hook: it queues a message for
+// every connection that read key, or follows a prefix of it. An empty key
+// (a flushed database) is sent to every tracking connection as a null
+// invalidation. Default mode connections must read a key again to hear
+// about its next change. It runs with the store locked and never blocks.
+func (srv *Server) invalidate(db int, key string) {
+	t := srv.tracking
+	if t.count.Load() == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	targets := make(map[*client]struct{})
+	if key == "" {
+		clear(t.readers)
+		for _, tr := range t.trackers {
+			targets[tr.redirect] = struct{}{}
+		}
+	} else {
+		for c := range t.readers[key] {
+			if tr, ok := t.trackers[c]; ok {
+				targets[tr.redirect] = struct{}{}
+			}
+		}
+		delete(t.readers, key)
+		for _, tr := range t.trackers {
+			if tr.bcast && tr.follows(key) {
+				targets[tr.redirect] = struct{}{}
+			}
+		}
+	}
+
+	var keys []string
+	if key != "" {
+		keys = []string{key}
+	}
+	for target := range targets {
+		t.send(target, keys)
+	}
+}
+
+// send queues an invalidation for target, disconnecting it if its outbox
+// is full. Callers hold mu.
+func (t *trackingTable) send(target *client, keys []string) {
+	ch, ok := t.outboxes[target]
+	if !ok {
+		return
+	}
+	select {
+	case ch <- keys:
+	default:
+		delete(t.outboxes, target)
+		close(ch)
+		target.conn.Close()
+	}
+}
+
+// deliverInvalidations writes queued invalidations to c until it
+// disconnects.
+func deliverInvalidations(c *client, outbox <-chan []string) {
+	for keys := range outbox {
+		c.wmu.Lock()
+		c.w.WriteString(formatInvalidation(c, keys))
+		var err error
+		if len(outbox) == 0 {
+			err = c.w.Flush()
+		}
+		c.wmu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// formatInvalidation formats an invalidation message: a push to RESP3
+// connections and a pub/sub message on __redis__:invalidate to RESP2 ones.
+// A nil keys means every key was invalidated. Called with c.wmu held,
+// which keeps c's protocol from changing underneath.
+func formatInvalidation(c *client, keys []string) string {
+	payload := c.formatNullArray()
+	if keys != nil {
+		payload = protocol.FormatBulkStrings(keys)
+	}
+	if c.resp3() {
+		return protocol.FormatPush([]string{protocol.FormatBulkString("invalidate"), payload})
+	}
+	return protocol.FormatArray([]string{
+		protocol.FormatBulkString("message"),
+		protocol.FormatBulkString(invalidateChannel),
+		payload,
+	})
+}
+
+// handleTracking serves
+// CLIENT TRACKING ON|OFF [REDIRECT id] [BCAST] [PREFIX prefix ...]
+func (srv *Server) handleTracking(c *client, args []string) string {
+	if len(args) < 1 {
+		return protocol.FormatError("wrong number of arguments for 'CLIENT|TRACKING' command")
+	}
+	var on bool
+	switch strings.ToUpper(args[0]) {
+	case "ON":
+		on = true
+	case "OFF":
+	default:
+		return protocol.FormatError("syntax error")
+	}
+
+	tr := &tracker{c: c, redirect: c}
+	var redirect int64
+	for i := 1; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "BCAST":
+			tr.bcast = true
+		case "REDIRECT", "PREFIX":
+			if i+1 >= len(args) {
+				return protocol.FormatError("syntax error")
+			}
+			if strings.EqualFold(args[i], "PREFIX") {
+				tr.prefixes = append(tr.prefixes, args[i+1])
+			} else {
+				id, err := strconv.ParseInt(args[i+1], 10, 64)
+				if err != nil || id <= 0 {
+					return protocol.FormatError("Invalid client ID")
+				}
+				redirect = id
+			}
+			i++
+		default:
+			return protocol.FormatError("syntax error")
+		}
+	}
+
+	if !on {
+		srv.stopTracking(c)
+		return protocol.FormatOK()
+	}
+	if len(tr.prefixes) > 0 && !tr.bcast {
+		return protocol.FormatError("PREFIX option requires BCAST mode to be enabled")
+	}
+	if c.tracking != nil && c.tracking.bcast != tr.bcast {
+		return protocol.FormatError("You can't switch BCAST mode on/off before disabling tracking for this client, and then re-enabling it with a different mode.")
+	}
+	if redirect != 0 {
+		srv.clientsMu.Lock()
+		target, ok := srv.clients[redirect]
+		srv.clientsMu.Unlock()
+		if !ok {
+			return protocol.FormatError("The client ID you want redirect to does not exist")
+		}
+		tr.redirect = target
+	} else if !c.resp3() {
+		return protocol.FormatError("Tracking without REDIRECT needs RESP3 push messages: switch with HELLO 3")
+	}
+	if err := srv.startTracking(tr); err != nil {
+		return protocol.FormatError(err.Error())
+	}
+	return protocol.FormatOK()
+}
+
+// handleTrackingInfo serves CLIENT TRACKINGINFO: the tracking flags, the
+// redirect target and the broadcast prefixes of the calling connection.
+func (srv *Server) handleTrackingInfo(c *client) string {
+	tr := c.tracking
+	flags := []string{"off"}
+	redirect := int64(-1)
+	var prefixes []string
+	if tr != nil {
+		flags = []string{"on"}
+		if tr.bcast {
+			flags = append(flags, "bcast")
+		}
+		redirect = 0
+		if tr.redirect != c {
+			redirect = tr.redirect.id
+		}
+		prefixes = tr.prefixes
+	}
+	return c.formatMap([]string{
+		protocol.FormatBulkString("flags"), protocol.FormatBulkStrings(flags),
+		protocol.FormatBulkString("redirect"), protocol.FormatInteger(redirect),
+		protocol.FormatBulkString("prefixes"), protocol.FormatBulkStrings(prefixes),
+	})
+}
+
+// handleGetRedir serves CLIENT GETREDIR: -1 when tracking is off, 0 when
+// invalidations go to the connection itself, else the target's id.
+func (srv *Server) handleGetRedir(c *client) string {
+	switch {
+	case c.tracking == nil:
+		return protocol.FormatInteger(-1)
+	case c.tracking.redirect == c:
+		return protocol.FormatInteger(0)
+	}
+	return protocol.FormatInteger(c.tracking.redirect.id)
+}
@@ -0,0 +1,182 @@
+package slowlog
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultThreshold is the duration above which commands are logged.
+	DefaultThreshold = 10 * time.Millisecond
+	// DefaultMaxLen is the number of entries kept before the oldest is
+	// overwritten.
+	DefaultMaxLen = 128
+
+	// Like Redis, long commands are shortened so that the log stays small.
+	maxArgs     = 32
+	maxArgBytes = 128
+)
+
+// Entry is one logged command.
+type Entry struct {
+	ID         int64         `json:"id"`
+	Time       time.Time     `json:"-"`
+	Timestamp  int64         `json:"timestamp"` // Unix seconds
+	Duration   time.Duration `json:"-"`
+	Micros     int64         `json:"duration"` // microseconds
+	Args       []string      `json:"args"`
+	Client     string        `json:"client"`
+	ClientName string        `json:"client_name"`
+}
+
+// Log keeps the most recent commands that ran longer than a threshold in a
+// ring buffer.
+type Log struct {
+	threshold atomic.Int64 // nanoseconds; negative disables the log
+
+	mu      sync.Mutex
+	entries []Entry // ring buffer of up to maxLen entries
+	next    int     // slot the next entry is written to once full
+	maxLen  int
+	nextID  int64
+}
+
+// New creates a log that records commands slower than threshold, keeping at
+// most maxLen of them. A negative threshold disables it; zero logs every
+// command.
+func New(threshold time.Duration, maxLen int) *Log {
+	l := &Log{maxLen: maxLen}
+	l.threshold.Store(int64(threshold))
+	return l
+}
+
+// Threshold returns the current threshold.
+func (l *Log) Threshold() time.Duration {
+	return time.Duration(l.threshold.Load())
+}
+
+// SetThreshold changes the threshold for commands that finish from now on.
+func (l *Log) SetThreshold(d time.Duration) {
+	l.threshold.Store(int64(d))
+}
+
+// Exceeds reports whether a command that took d should be logged. It is
+// cheap enough to call after every command.
+func (l *Log) Exceeds(d time.Duration) bool {
+	threshold := l.threshold.Load()
+	return threshold >= 0 && int64(d) >= threshold
+}
+
+// MaxLen returns the number of entries kept.
+func (l *Log) MaxLen() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.maxLen
+}
+
+// SetMaxLen changes the number of entries kept, dropping the oldest ones if
+// the log shrinks.
+func (l *Log) SetMaxLen(n int) error {
+	if n < 0 {
+		return fmt.Errorf("max length must not be negative")
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries := l.newestFirst(n)
+	l.entries = make([]Entry, 0, n)
+	for i := len(entries) - 1; i >= 0; i-- {
+		l.entries = append(l.entries, entries[i])
+	}
+	l.next = 0
+	l.maxLen = n
+	return nil
+}
+
+// Add records a command that took d; args holds the command name first.
+func (l *Log) Add(d time.Duration, args []string, client, clientName string) {
+	now := time.Now()
+	entry := Entry{
+		Time:       now,
+		Timestamp:  now.Unix(),
+		Duration:   d,
+		Micros:     d.Microseconds(),
+		Args:       truncate(args),
+		Client:     client,
+		ClientName: clientName,
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry.ID = l.nextID
+	l.nextID++
+	if l.maxLen == 0 {
+		return
+	}
+	if len(l.entries) < l.maxLen {
+		l.entries = append(l.entries, entry)
+		return
+	}
+	l.entries[l.next] = entry
+	l.next = (l.next + 1) % l.maxLen
+}
+
+// Get returns up to n entries, newest first. A negative n returns all.
+func (l *Log) Get(n int) []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.newestFirst(n)
+}
+
+// newestFirst copies up to n entries, newest first. Callers hold mu.
+func (l *Log) newestFirst(n int) []Entry {
+	if n < 0 || n > len(l.entries) {
+		n = len(l.entries)
+	}
+	out := make([]Entry, 0, n)
+	if len(l.entries) == 0 {
+		return out
+	}
+	// Once the buffer is full the newest entry sits just before next.
+	newest := len(l.entries) - 1
+	if len(l.entries) == l.maxLen {
+		newest = (l.next - 1 + len(l.entries)) % len(l.entries)
+	}
+	for i := 0; i < n; i++ {
+		out = append(out, l.entries[(newest-i+len(l.entries))%len(l.entries)])
+	}
+	return out
+}
+
+// Len returns the number of entries in the log.
+func (l *Log) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.entries)
+}
+
+// Reset empties the log. Entry ids keep increasing.
+func (l *Log) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = nil
+	l.next = 0
+}
+
+// truncate copies args, keeping at most maxArgs arguments of at most
+// maxArgBytes bytes each, and says how much was left out.
+func truncate(args []string) []string {
+	out := make([]string, 0, min(len(args), maxArgs))
+	for i, arg := range args {
+		if i == maxArgs-1 && len(args) > maxArgs {
+			out = append(out, fmt.Sprintf("... (%d more arguments)", len(args)-i))
+			break
+		}
+		if len(arg) > maxArgBytes {
+			arg = fmt.Sprintf("%s... (%d more bytes)", arg[:maxArgBytes], len(arg)-maxArgBytes)
+		}
+		out = append(out, arg)
+	}
+	return out
+}
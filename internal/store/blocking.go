@@ -0,0 +1,184 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrTimeout is returned by the blocking pops when no element arrived in
+// time.
+var ErrTimeout = errors.New("timeout")
+
+// waiter is a caller of BLPop, BRPop or BLMove waiting for a push. It is
+// queued on every key it waits for, in the keyspace of its database, and
+// served by the first push to any of them.
+type waiter struct {
+	keys     []string
+	front    bool   // pop from the head
+	dst      string // BLMove destination; "" for BLPop and BRPop
+	dstFront bool
+	done     bool // served or given up; written under mu
+	result   chan popResult
+}
+
+type popResult struct {
+	key, value string
+	err        error
+}
+
+// BLPop pops the first element of the first non-empty list among keys. If
+// they are all empty it waits up to timeout (0 means forever) for a push,
+// behind the callers that started waiting earlier. It returns the key and
+// the element, ErrTimeout once timeout passes, or ctx's error if ctx is
+// done first. Transaction views never wait.
+func (str *Store) BLPop(ctx context.Context, timeout time.Duration, keys ...string) (string, string, error) {
+	r := str.block(ctx, timeout, &waiter{keys: keys, front: true})
+	return r.key, r.value, r.err
+}
+
+// BRPop is BLPop popping the last element.
+func (str *Store) BRPop(ctx context.Context, timeout time.Duration, keys ...string) (string, string, error) {
+	r := str.block(ctx, timeout, &waiter{keys: keys})
+	return r.key, r.value, r.err
+}
+
+// BLMove pops an element from the head (srcFront) or tail of the list at
+// src and pushes it to the head (dstFront) or tail of the list at dst,
+// waiting for src like BLPop. It returns the element moved.
+func (str *Store) BLMove(ctx context.Context, src, dst string, srcFront, dstFront bool, timeout time.Duration) (string, error) {
+	if dst == "" {
+		return "", ErrInvalidKey
+	}
+	r := str.block(ctx, timeout, &waiter{keys: []string{src}, front: srcFront, dst: dst, dstFront: dstFront})
+	return r.value, r.err
+}
+
+func (str *Store) block(ctx context.Context, timeout time.Duration, w *waiter) popResult {
+	str.lock()
+	for _, key := range w.keys {
+		if r, ok := str.popFor(w, key); ok {
+			str.unlock()
+			return r
+		}
+	}
+	// A transaction view holds mu: waiting would stop every push.
+	if str.held {
+		str.unlock()
+		return popResult{err: ErrTimeout}
+	}
+	w.result = make(chan popResult, 1)
+	if str.blocked == nil {
+		str.blocked = make(map[string][]*waiter)
+	}
+	for _, key := range w.keys {
+		str.blocked[key] = append(str.blocked[key], w)
+	}
+	str.unlock()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	var err error
+	select {
+	case r := <-w.result:
+		return r
+	case <-expired:
+		err = ErrTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	str.lock()
+	defer str.unlock()
+	// A push may have served w while it was giving up.
+	if w.done {
+		return <-w.result
+	}
+	str.unblock(w)
+	return popResult{err: err}
+}
+
+// popFor pops the element w waits for from the list at key, pushing it to
+// w's destination for BLMove. It reports false if there is no list at key.
+// Callers hold mu.
+func (str *Store) popFor(w *waiter, key string) (popResult, bool) {
+	node, list, err := str.writeList(key, false)
+	if err != nil {
+		return popResult{err: err}, true
+	}
+	if node == nil {
+		return popResult{}, false
+	}
+	if w.dst != "" {
+		if dst := str.lookup(w.dst); dst != nil {
+			if _, ok := dst.value.(*deque); !ok {
+				return popResult{err: ErrWrongType}, true
+			}
+		}
+	}
+
+	var v string
+	if w.front {
+		v = list.popFront()
+		str.listChanged(node, list, "lpop")
+	} else {
+		v = list.popBack()
+		str.listChanged(node, list, "rpop")
+	}
+	if w.dst != "" {
+		dst, dstList, _ := str.writeList(w.dst, true)
+		if w.dstFront {
+			dstList.pushFront(v)
+			str.listChanged(dst, dstList, "lpush")
+		} else {
+			dstList.pushBack(v)
+			str.listChanged(dst, dstList, "rpush")
+		}
+		str.serveBlocked(w.dst)
+	}
+	return popResult{key: key, value: v}, true
+}
+
+// serveBlocked hands elements of the list at key to its waiters, first
+// come first served, until the list or the queue is empty. Every push,
+// and every command that can bring a list to key, calls it. Callers hold
+// mu.
+func (str *Store) serveBlocked(key string) {
+	for len(str.blocked[key]) > 0 {
+		node := str.lookup(key)
+		if node == nil {
+			return
+		}
+		if _, ok := node.value.(*deque); !ok {
+			return
+		}
+		// Dequeued first: a BLMove from key to itself serves key again.
+		w := str.blocked[key][0]
+		str.unblock(w)
+		r, _ := str.popFor(w, key)
+		w.result <- r
+	}
+}
+
+// unblock removes w from the queues of all its keys. Callers hold mu.
+func (str *Store) unblock(w *waiter) {
+	w.done = true
+	for _, key := range w.keys {
+		queue := str.blocked[key]
+		kept := queue[:0]
+		for _, other := range queue {
+			if other != w {
+				kept = append(kept, other)
+			}
+		}
+		if len(kept) == 0 {
+			delete(str.blocked, key)
+		} else {
+			str.blocked[key] = kept
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+// DefaultDatabases is the number of databases NewStore creates, as in Redis.
+const DefaultDatabases = 16
+
+// DefaultSnapshotPath is the snapshot file used until SetSnapshotPath.
+const DefaultSnapshotPath = "memstash_data.json"
+
+var ErrInvalidDB = errors.New("DB index is out of range")
+
+// NewStoreWithDatabases creates n databases of the given capacity sharing
+// one lock and returns database 0.
+func NewStoreWithDatabases(capacity, n int) *Store {
+	if n < 1 {
+		n = 1
+	}
+	// Like Redis, count the store as saved when it is created.
+	g := &group{
+		dbs:          make([]*keyspace, n),
+		lastSave:     time.Now(),
+		snapshotPath: DefaultSnapshotPath,
+	}
+	for i := range g.dbs {
+		g.dbs[i] = &keyspace{group: g, index: i, capacity: capacity}
+		g.dbs[i].reset()
+	}
+	str := &Store{keyspace: g.dbs[0]}
+	g.autoSave = &periodic{fn: str.autoSaveSnapshot}
+	g.ttlCleaner = &periodic{fn: str.cleanExpiredKeys}
+	return str
+}
+
+// DB returns a handle on database index of the same store, or nil if the
+// index is out of range. Handles obtained from a transaction view are
+// transaction views too.
+func (str *Store) DB(index int) *Store {
+	if index < 0 || index >= len(str.dbs) {
+		return nil
+	}
+	return &Store{keyspace: str.dbs[index], held: str.held}
+}
+
+// Index returns the number of the database str refers to.
+func (str *Store) Index() int {
+	return str.index
+}
+
+// Databases returns how many databases the store has.
+func (str *Store) Databases() int {
+	return len(str.dbs)
+}
+
+// Move transfers key to database dst. It returns false if the key does not
+// exist here or already exists in dst.
+func (str *Store) Move(key string, dst int) (bool, error) {
+	if key == "" {
+		return false, ErrInvalidKey
+	}
+	if dst < 0 || dst >= len(str.dbs) {
+		return false, ErrInvalidDB
+	}
+	if dst == str.index {
+		return false, errors.New("source and destination objects are the same")
+	}
+	str.lock()
+	defer str.unlock()
+	node := str.lookup(key)
+	if node == nil {
+		return false, nil
+	}
+	target := &Store{keyspace: str.dbs[dst], held: true}
+	if target.lookup(key) != nil {
+		return false, nil
+	}
+
+	str.removeNode(node)
+	if len(target.data) >= target.capacity {
+		target.evictLeastUsed()
+	}
+	node.version = str.nextVersion()
+	target.insertNode(node)
+	target.lru.AddToHead(node)
+	str.invalidate(key)
+	target.invalidate(key)
+	str.notify(NotifyGeneric, "move_from", key)
+	target.notify(NotifyGeneric, "move_to", key)
+	target.serveBlocked(key)
+	return true, nil
+}
+
+// SwapDB exchanges the contents of two databases. Handles on either
+// database see the other one's keys afterwards.
+func (str *Store) SwapDB(a, b int) error {
+	if a < 0 || a >= len(str.dbs) || b < 0 || b >= len(str.dbs) {
+		return ErrInvalidDB
+	}
+	str.lock()
+	defer str.unlock()
+	x, y := str.dbs[a], str.dbs[b]
+	x.data, y.data = y.data, x.data
+	x.lru, y.lru = y.lru, x.lru
+	x.table, y.table = y.table, x.table
+	x.markRemoved()
+	y.markRemoved()
+	x.invalidate("")
+	y.invalidate("")
+	// Clients blocked on either database may find their lists there now.
+	for _, ks := range []*keyspace{x, y} {
+		db := &Store{keyspace: ks, held: true}
+		for key := range ks.blocked {
+			db.serveBlocked(key)
+		}
+	}
+	return nil
+}
+
+// FlushAll removes every key from every database.
+func (str *Store) FlushAll() {
+	str.lock()
+	defer str.unlock()
+	for _, db := range str.dbs {
+		db.reset()
+	}
+}
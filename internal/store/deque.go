@@ -0,0 +1,169 @@
+package store
+
+import "fmt"
+
+// minDequeSize is the smallest ring a deque allocates.
+const minDequeSize = 8
+
+// elemOverhead approximates the bytes an element costs besides its
+// contents: its string header in the ring.
+const elemOverhead = 16
+
+// deque is a double-ended queue of strings kept in a ring buffer whose size
+// is a power of two. It backs list values: pushes and pops at either end
+// are amortised O(1), indexing is O(1), and the ring shrinks again when a
+// list is emptied so that popped lists give their memory back.
+type deque struct {
+	buf   []string
+	head  int   // ring position of element 0
+	n     int   // number of elements
+	bytes int64 // total length of the elements
+}
+
+func (d *deque) typeName() string { return "list" }
+
+func (d *deque) memory() int64 { return d.bytes + int64(len(d.buf))*elemOverhead }
+
+func (d *deque) String() string { return fmt.Sprint(d.slice(0, d.n-1)) }
+
+// Len returns the number of elements.
+func (d *deque) Len() int { return d.n }
+
+func (d *deque) pos(i int) int { return (d.head + i) & (len(d.buf) - 1) }
+
+// at returns element i, counted from the front; 0 <= i < Len.
+func (d *deque) at(i int) string { return d.buf[d.pos(i)] }
+
+// set replaces element i; 0 <= i < Len.
+func (d *deque) set(i int, v string) {
+	p := d.pos(i)
+	d.bytes += int64(len(v) - len(d.buf[p]))
+	d.buf[p] = v
+}
+
+func (d *deque) pushFront(v string) {
+	d.grow()
+	d.head = (d.head - 1) & (len(d.buf) - 1)
+	d.buf[d.head] = v
+	d.n++
+	d.bytes += int64(len(v))
+}
+
+func (d *deque) pushBack(v string) {
+	d.grow()
+	d.buf[d.pos(d.n)] = v
+	d.n++
+	d.bytes += int64(len(v))
+}
+
+// popFront removes and returns the first element; the deque must not be
+// empty.
+func (d *deque) popFront() string {
+	v := d.buf[d.head]
+	d.buf[d.head] = ""
+	d.head = d.pos(1)
+	d.n--
+	d.bytes -= int64(len(v))
+	d.shrink()
+	return v
+}
+
+// popBack removes and returns the last element; the deque must not be
+// empty.
+func (d *deque) popBack() string {
+	p := d.pos(d.n - 1)
+	v := d.buf[p]
+	d.buf[p] = ""
+	d.n--
+	d.bytes -= int64(len(v))
+	d.shrink()
+	return v
+}
+
+// slice copies elements start..stop inclusive; out of order bounds give an
+// empty slice.
+func (d *deque) slice(start, stop int) []string {
+	if start > stop {
+		return []string{}
+	}
+	out := make([]string, 0, stop-start+1)
+	for i := start; i <= stop; i++ {
+		out = append(out, d.at(i))
+	}
+	return out
+}
+
+// trim keeps elements start..stop inclusive and drops the rest; out of
+// order bounds empty the deque.
+func (d *deque) trim(start, stop int) {
+	if start > stop {
+		start, stop = d.n, d.n-1
+	}
+	for i := d.n - 1; i > stop; i-- {
+		d.popBack()
+	}
+	for i := 0; i < start; i++ {
+		d.popFront()
+	}
+}
+
+// remove deletes elements equal to v: the first count of them from the
+// front when count > 0, the last -count from the back when count < 0, and
+// all of them when count is 0. It returns how many were removed.
+func (d *deque) remove(count int, v string) int {
+	items := d.slice(0, d.n-1)
+	keep := make([]bool, len(items))
+	removed := 0
+	for k := range items {
+		i := k
+		if count < 0 {
+			i = len(items) - 1 - k
+		}
+		if items[i] == v && (count == 0 || removed < abs(count)) {
+			removed++
+			continue
+		}
+		keep[i] = true
+	}
+	if removed == 0 {
+		return 0
+	}
+	*d = deque{}
+	for i, item := range items {
+		if keep[i] {
+			d.pushBack(item)
+		}
+	}
+	return removed
+}
+
+// grow doubles the ring when it is full.
+func (d *deque) grow() {
+	if d.n < len(d.buf) {
+		return
+	}
+	d.resize(max(minDequeSize, 2*len(d.buf)))
+}
+
+// shrink halves the ring when it is at most a quarter full.
+func (d *deque) shrink() {
+	if len(d.buf) > minDequeSize && d.n <= len(d.buf)/4 {
+		d.resize(len(d.buf) / 2)
+	}
+}
+
+func (d *deque) resize(size int) {
+	buf := make([]string, size)
+	for i := 0; i < d.n; i++ {
+		buf[i] = d.at(i)
+	}
+	d.buf = buf
+	d.head = 0
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,452 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrHashValueNotInteger = errors.New("hash value is not an integer")
+	ErrIncrOverflow        = errors.New("increment or decrement would overflow")
+)
+
+// hash maps fields to values. Each field is kept in a Node of its own, so
+// that fields share the TTL helpers of keys and HSCAN walks them with the
+// same resize-stable keyIndex as SCAN. Fields with a TTL are also listed
+// in expiring, so that expired fields are found without visiting the
+// others.
+type hash struct {
+	fields   map[string]*Node
+	index    *keyIndex
+	expiring map[string]*Node
+	bytes    int64 // total length of the field names and values
+}
+
+func newHash() *hash {
+	return &hash{
+		fields:   make(map[string]*Node),
+		index:    newKeyIndex(),
+		expiring: make(map[string]*Node),
+	}
+}
+
+func (h *hash) typeName() string { return "hash" }
+
+// memory counts every field like a key, since each one has a Node, a map
+// entry and an index slot.
+func (h *hash) memory() int64 { return h.bytes + int64(len(h.fields))*nodeOverhead }
+
+func (h *hash) String() string { return fmt.Sprint(h.all()) }
+
+// Len returns the number of fields.
+func (h *hash) Len() int { return len(h.fields) }
+
+func (h *hash) all() map[string]string {
+	all := make(map[string]string, len(h.fields))
+	for name, f := range h.fields {
+		all[name] = string(f.value.(stringValue))
+	}
+	return all
+}
+
+// set stores v in field and clears its TTL, like HSET. It reports whether
+// the field is new.
+func (h *hash) set(field, v string) bool {
+	if f, ok := h.fields[field]; ok {
+		h.replace(f, v)
+		h.setExpiry(f, nil)
+		return false
+	}
+	f := &Node{key: field, value: stringValue(v)}
+	h.fields[field] = f
+	h.index.add(f)
+	h.bytes += int64(len(field) + len(v))
+	return true
+}
+
+// replace changes the value of field f and keeps its TTL.
+func (h *hash) replace(f *Node, v string) {
+	h.bytes += int64(len(v) - len(f.value.(stringValue)))
+	f.value = stringValue(v)
+}
+
+func (h *hash) del(field string) bool {
+	f, ok := h.fields[field]
+	if !ok {
+		return false
+	}
+	delete(h.fields, field)
+	delete(h.expiring, field)
+	h.index.remove(f)
+	h.bytes -= int64(len(field) + len(f.value.(stringValue)))
+	return true
+}
+
+// setExpiry gives field f a TTL ending at at, or removes it when at is nil.
+func (h *hash) setExpiry(f *Node, at *time.Time) {
+	f.expireAt = at
+	if at == nil {
+		delete(h.expiring, f.key)
+	} else {
+		h.expiring[f.key] = f
+	}
+}
+
+// removeExpired deletes the fields whose TTL has passed and returns how
+// many there were.
+func (h *hash) removeExpired() int {
+	removed := 0
+	for name, f := range h.expiring {
+		if f.isExpired() {
+			h.del(name)
+			removed++
+		}
+	}
+	return removed
+}
+
+// allExpired reports whether every field has a TTL that has passed.
+func (h *hash) allExpired() bool {
+	if len(h.expiring) < len(h.fields) {
+		return false
+	}
+	for _, f := range h.expiring {
+		if !f.isExpired() {
+			return false
+		}
+	}
+	return true
+}
+
+// expireFields removes the expired fields of the hash at node and deletes
+// the key if no field is left. It reports whether the key still exists.
+// Callers hold mu.
+func (str *Store) expireFields(node *Node, h *hash) bool {
+	if len(h.expiring) == 0 || h.removeExpired() == 0 {
+		return true
+	}
+	node.version = str.nextVersion()
+	str.invalidate(node.key)
+	str.notify(NotifyHash, "hexpired", node.key)
+	if h.Len() == 0 {
+		str.removeNode(node)
+		str.notify(NotifyGeneric, "del", node.key)
+		return false
+	}
+	return true
+}
+
+// readHash returns the hash stored at key for a read, counting the lookup
+// as a hit or a miss. It returns nil if the key does not exist. Callers
+// hold mu.
+func (str *Store) readHash(key string) (*Node, *hash, error) {
+	node := str.lookup(key)
+	if node == nil {
+		str.misses++
+		return nil, nil, nil
+	}
+	h, ok := node.value.(*hash)
+	if !ok {
+		return nil, nil, ErrWrongType
+	}
+	str.hits++
+	str.lru.MoveToHead(node)
+	return node, h, nil
+}
+
+// writeHash returns the hash stored at key for a change. It returns nil if
+// the key does not exist, unless create is set, in which case an empty
+// hash is added. Callers hold mu.
+func (str *Store) writeHash(key string, create bool) (*Node, *hash, error) {
+	if key == "" {
+		return nil, nil, ErrInvalidKey
+	}
+	node := str.lookup(key)
+	if node != nil {
+		h, ok := node.value.(*hash)
+		if !ok {
+			return nil, nil, ErrWrongType
+		}
+		return node, h, nil
+	}
+	if !create {
+		return nil, nil, nil
+	}
+	h := newHash()
+	return str.insertValue(key, h), h, nil
+}
+
+// hashChanged records a change to a hash and deletes it once it has no
+// fields left. Callers hold mu.
+func (str *Store) hashChanged(node *Node, h *hash, event string) {
+	str.modified(node, NotifyHash, event)
+	if h.Len() == 0 {
+		str.removeNode(node)
+		str.notify(NotifyGeneric, "del", node.key)
+	}
+}
+
+// HSet sets fields of the hash stored at key, creating it if needed. A
+// field that is set loses its TTL. It returns how many fields were added.
+func (str *Store) HSet(key string, fields map[string]string) (int, error) {
+	str.lock()
+	defer str.unlock()
+	node, h, err := str.writeHash(key, true)
+	if err != nil {
+		return 0, err
+	}
+	added := 0
+	for field, v := range fields {
+		if h.set(field, v) {
+			added++
+		}
+	}
+	str.hashChanged(node, h, "hset")
+	return added, nil
+}
+
+// HGet returns the value of field in the hash stored at key. ok is false
+// if the key or the field does not exist.
+func (str *Store) HGet(key, field string) (v string, ok bool, err error) {
+	str.lock()
+	defer str.unlock()
+	_, h, err := str.readHash(key)
+	if h == nil {
+		return "", false, err
+	}
+	f, ok := h.fields[field]
+	if !ok {
+		return "", false, nil
+	}
+	return string(f.value.(stringValue)), true, nil
+}
+
+// HMGet returns the values of the fields of the hash stored at key that
+// exist.
+func (str *Store) HMGet(key string, fields ...string) (map[string]string, error) {
+	str.lock()
+	defer str.unlock()
+	values := make(map[string]string, len(fields))
+	_, h, err := str.readHash(key)
+	if h == nil {
+		return values, err
+	}
+	for _, field := range fields {
+		if f, ok := h.fields[field]; ok {
+			values[field] = string(f.value.(stringValue))
+		}
+	}
+	return values, nil
+}
+
+// HGetAll returns every field of the hash stored at key; a missing key is
+// an empty hash.
+func (str *Store) HGetAll(key string) (map[string]string, error) {
+	str.lock()
+	defer str.unlock()
+	_, h, err := str.readHash(key)
+	if h == nil {
+		return map[string]string{}, err
+	}
+	return h.all(), nil
+}
+
+// HDel removes fields from the hash stored at key and returns how many
+// existed. The key is deleted with its last field.
+func (str *Store) HDel(key string, fields ...string) (int, error) {
+	str.lock()
+	defer str.unlock()
+	node, h, err := str.writeHash(key, false)
+	if node == nil || err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, field := range fields {
+		if h.del(field) {
+			removed++
+		}
+	}
+	if removed > 0 {
+		str.hashChanged(node, h, "hdel")
+	}
+	return removed, nil
+}
+
+// HIncrBy adds delta to the integer stored in field of the hash at key,
+// starting from 0 if the key or the field does not exist, and returns the
+// new value. The field keeps its TTL.
+func (str *Store) HIncrBy(key, field string, delta int64) (int64, error) {
+	str.lock()
+	defer str.unlock()
+	node, h, err := str.writeHash(key, true)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	f, exists := h.fields[field]
+	if exists {
+		n, err = strconv.ParseInt(string(f.value.(stringValue)), 10, 64)
+		if err != nil {
+			return 0, ErrHashValueNotInteger
+		}
+	}
+	if (delta > 0 && n > math.MaxInt64-delta) || (delta < 0 && n < math.MinInt64-delta) {
+		return 0, ErrIncrOverflow
+	}
+	n += delta
+	if exists {
+		h.replace(f, strconv.FormatInt(n, 10))
+	} else {
+		h.set(field, strconv.FormatInt(n, 10))
+	}
+	str.hashChanged(node, h, "hincrby")
+	return n, nil
+}
+
+// HExists reports whether field exists in the hash stored at key.
+func (str *Store) HExists(key, field string) (bool, error) {
+	str.lock()
+	defer str.unlock()
+	_, h, err := str.readHash(key)
+	if h == nil {
+		return false, err
+	}
+	_, ok := h.fields[field]
+	return ok, nil
+}
+
+// HLen returns the number of fields of the hash stored at key, 0 if it
+// does not exist.
+func (str *Store) HLen(key string) (int, error) {
+	str.lock()
+	defer str.unlock()
+	_, h, err := str.readHash(key)
+	if h == nil {
+		return 0, err
+	}
+	return h.Len(), nil
+}
+
+// HScan is SCAN over the fields of the hash stored at key: it returns up
+// to about count fields matching match, as field, value pairs, and the
+// cursor to pass to the next call, 0 once the iteration is complete.
+func (str *Store) HScan(key string, cursor uint64, match string, count int) (uint64, []string, error) {
+	if count <= 0 {
+		count = 10
+	}
+	str.lock()
+	defer str.unlock()
+	_, h, err := str.readHash(key)
+	if h == nil {
+		return 0, []string{}, err
+	}
+	pairs := []string{}
+	cursor = h.index.scan(cursor, match, count, func(f *Node) bool {
+		pairs = append(pairs, f.key, string(f.value.(stringValue)))
+		return true
+	})
+	return cursor, pairs, nil
+}
+
+// Results of HExpire and HPersist for each field, as in Redis.
+const (
+	FieldMissing = -2 // the key or the field does not exist
+	FieldNoTTL   = -1 // HPersist: the field had no TTL
+	FieldUpdated = 1  // the TTL was set or removed
+	FieldDeleted = 2  // HExpire: a TTL <= 0 deleted the field
+)
+
+// HExpire gives fields of the hash stored at key a TTL. A ttl <= 0 deletes
+// the fields instead. It returns one FieldMissing, FieldUpdated or
+// FieldDeleted per field.
+func (str *Store) HExpire(key string, ttl time.Duration, fields ...string) ([]int, error) {
+	str.lock()
+	defer str.unlock()
+	results := make([]int, len(fields))
+	node, h, err := str.writeHash(key, false)
+	if node == nil || err != nil {
+		for i := range results {
+			results[i] = FieldMissing
+		}
+		return results, err
+	}
+	expireAt := time.Now().Add(ttl)
+	updated, deleted := false, false
+	for i, field := range fields {
+		f, ok := h.fields[field]
+		switch {
+		case !ok:
+			results[i] = FieldMissing
+		case ttl <= 0:
+			h.del(field)
+			results[i] = FieldDeleted
+			deleted = true
+		default:
+			h.setExpiry(f, &expireAt)
+			results[i] = FieldUpdated
+			updated = true
+		}
+	}
+	if updated {
+		str.hashChanged(node, h, "hexpire")
+	}
+	if deleted {
+		str.hashChanged(node, h, "hdel")
+	}
+	return results, nil
+}
+
+// HPersist removes the TTL of fields of the hash stored at key. It returns
+// one FieldMissing, FieldNoTTL or FieldUpdated per field.
+func (str *Store) HPersist(key string, fields ...string) ([]int, error) {
+	str.lock()
+	defer str.unlock()
+	results := make([]int, len(fields))
+	node, h, err := str.writeHash(key, false)
+	if node == nil || err != nil {
+		for i := range results {
+			results[i] = FieldMissing
+		}
+		return results, err
+	}
+	persisted := false
+	for i, field := range fields {
+		f, ok := h.fields[field]
+		switch {
+		case !ok:
+			results[i] = FieldMissing
+		case f.expireAt == nil:
+			results[i] = FieldNoTTL
+		default:
+			h.setExpiry(f, nil)
+			results[i] = FieldUpdated
+			persisted = true
+		}
+	}
+	if persisted {
+		str.hashChanged(node, h, "hpersist")
+	}
+	return results, nil
+}
+
+// HTTL returns the remaining TTL of fields of the hash stored at key: -1
+// for a field without TTL and -2 for a missing key or field, like GetTTL.
+func (str *Store) HTTL(key string, fields ...string) ([]time.Duration, error) {
+	str.lock()
+	defer str.unlock()
+	ttls := make([]time.Duration, len(fields))
+	_, h, err := str.readHash(key)
+	for i, field := range fields {
+		ttls[i] = FieldMissing
+		if h == nil {
+			continue
+		}
+		if f, ok := h.fields[field]; ok {
+			ttls[i] = f.remainingTTL()
+		}
+	}
+	return ttls, err
+}
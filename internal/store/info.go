@@ -0,0 +1,73 @@
+package store
+
+import "time"
+
+// nodeOverhead approximates the bytes a key costs besides its key and
+// value: the Node struct, its map entry and its SCAN index slot.
+const nodeOverhead = 112
+
+// DBInfo describes one non-empty database.
+type DBInfo struct {
+	Index   int
+	Keys    int
+	Expires int // keys with a TTL
+}
+
+// Info aggregates the counters of every database for the INFO command.
+type Info struct {
+	Keys         int
+	Expires      int
+	Hits         int64
+	Misses       int64
+	Expired      int64
+	Evictions    int64
+	DatasetBytes int64 // approximate memory held by keys and values
+	Capacity     int   // maximum number of keys of each database
+	Blocked      int   // callers waiting in BLPop, BRPop or BLMove
+	Databases    []DBInfo
+
+	ChangesSinceSave int64
+	LastSave         time.Time // last successful save or load, or creation
+	LastSaveErr      error
+}
+
+// Info returns counters for the whole store. It walks every key, so it
+// costs O(n) under the read lock.
+func (str *Store) Info() Info {
+	str.rlock()
+	defer str.runlock()
+
+	info := Info{
+		ChangesSinceSave: str.dirty,
+		LastSave:         str.lastSave,
+		LastSaveErr:      str.lastSaveErr,
+		Capacity:         str.capacity,
+	}
+	blocked := make(map[*waiter]struct{})
+	for _, db := range str.dbs {
+		for _, queue := range db.blocked {
+			for _, w := range queue {
+				blocked[w] = struct{}{}
+			}
+		}
+		info.Hits += db.hits
+		info.Misses += db.misses
+		info.Expired += db.expired
+		info.Evictions += db.evictions
+		if len(db.data) == 0 {
+			continue
+		}
+		dbInfo := DBInfo{Index: db.index, Keys: len(db.data)}
+		for key, node := range db.data {
+			if node.expireAt != nil {
+				dbInfo.Expires++
+			}
+			info.DatasetBytes += int64(len(key)) + node.value.memory() + nodeOverhead
+		}
+		info.Keys += dbInfo.Keys
+		info.Expires += dbInfo.Expires
+		info.Databases = append(info.Databases, dbInfo)
+	}
+	info.Blocked = len(blocked)
+	return info
+}
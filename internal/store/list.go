@@ -0,0 +1,244 @@
+package store
+
+import "errors"
+
+var ErrIndexOutOfRange = errors.New("index out of range")
+
+// listRange converts LRANGE/LTRIM style bounds, where negative indices
+// count from the end, into positions in a list of n elements. start > stop
+// means the range is empty.
+func listRange(start, stop, n int) (int, int) {
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	start = max(start, 0)
+	stop = min(stop, n-1)
+	return start, stop
+}
+
+// readList returns the list stored at key for a read, counting the lookup
+// as a hit or a miss. It returns nil if the key does not exist. Callers
+// hold mu.
+func (str *Store) readList(key string) (*Node, *deque, error) {
+	node := str.lookup(key)
+	if node == nil {
+		str.misses++
+		return nil, nil, nil
+	}
+	list, ok := node.value.(*deque)
+	if !ok {
+		return nil, nil, ErrWrongType
+	}
+	str.hits++
+	str.lru.MoveToHead(node)
+	return node, list, nil
+}
+
+// writeList returns the list stored at key for a change. It returns nil if
+// the key does not exist, unless create is set, in which case an empty
+// list is added. Callers hold mu.
+func (str *Store) writeList(key string, create bool) (*Node, *deque, error) {
+	if key == "" {
+		return nil, nil, ErrInvalidKey
+	}
+	node := str.lookup(key)
+	if node == nil {
+		if !create {
+			return nil, nil, nil
+		}
+		list := &deque{}
+		return str.insertValue(key, list), list, nil
+	}
+	list, ok := node.value.(*deque)
+	if !ok {
+		return nil, nil, ErrWrongType
+	}
+	return node, list, nil
+}
+
+// listChanged records a change to a list and deletes it once it is empty,
+// since Redis has no empty lists. Callers hold mu.
+func (str *Store) listChanged(node *Node, list *deque, event string) {
+	str.modified(node, NotifyList, event)
+	if list.Len() == 0 {
+		str.removeNode(node)
+		str.notify(NotifyGeneric, "del", node.key)
+	}
+}
+
+// LPush inserts values at the head of the list stored at key, one after
+// the other, creating the list if needed. It returns the new length.
+func (str *Store) LPush(key string, values ...string) (int, error) {
+	return str.push(key, values, true)
+}
+
+// RPush appends values to the list stored at key, creating it if needed.
+// It returns the new length.
+func (str *Store) RPush(key string, values ...string) (int, error) {
+	return str.push(key, values, false)
+}
+
+func (str *Store) push(key string, values []string, front bool) (int, error) {
+	str.lock()
+	defer str.unlock()
+	node, list, err := str.writeList(key, true)
+	if err != nil {
+		return 0, err
+	}
+	for _, v := range values {
+		if front {
+			list.pushFront(v)
+		} else {
+			list.pushBack(v)
+		}
+	}
+	event := "rpush"
+	if front {
+		event = "lpush"
+	}
+	str.listChanged(node, list, event)
+	n := list.Len()
+	str.serveBlocked(key)
+	return n, nil
+}
+
+// LPop removes and returns up to count elements from the head of the list
+// stored at key. It returns nil if the key does not exist.
+func (str *Store) LPop(key string, count int) ([]string, error) {
+	return str.pop(key, count, true)
+}
+
+// RPop removes and returns up to count elements from the tail of the list
+// stored at key. It returns nil if the key does not exist.
+func (str *Store) RPop(key string, count int) ([]string, error) {
+	return str.pop(key, count, false)
+}
+
+func (str *Store) pop(key string, count int, front bool) ([]string, error) {
+	str.lock()
+	defer str.unlock()
+	node, list, err := str.writeList(key, false)
+	if node == nil || err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return []string{}, nil
+	}
+	popped := make([]string, 0, min(count, list.Len()))
+	for len(popped) < count && list.Len() > 0 {
+		if front {
+			popped = append(popped, list.popFront())
+		} else {
+			popped = append(popped, list.popBack())
+		}
+	}
+	event := "rpop"
+	if front {
+		event = "lpop"
+	}
+	str.listChanged(node, list, event)
+	return popped, nil
+}
+
+// LRange returns the elements start..stop inclusive of the list stored at
+// key; negative indices count from the end. A missing key is an empty list.
+func (str *Store) LRange(key string, start, stop int) ([]string, error) {
+	str.lock()
+	defer str.unlock()
+	_, list, err := str.readList(key)
+	if list == nil {
+		return []string{}, err
+	}
+	start, stop = listRange(start, stop, list.Len())
+	return list.slice(start, stop), nil
+}
+
+// LLen returns the length of the list stored at key, 0 if it does not
+// exist.
+func (str *Store) LLen(key string) (int, error) {
+	str.lock()
+	defer str.unlock()
+	_, list, err := str.readList(key)
+	if list == nil {
+		return 0, err
+	}
+	return list.Len(), nil
+}
+
+// LIndex returns element index of the list stored at key; negative indices
+// count from the end. ok is false if the key or the element does not exist.
+func (str *Store) LIndex(key string, index int) (v string, ok bool, err error) {
+	str.lock()
+	defer str.unlock()
+	_, list, err := str.readList(key)
+	if list == nil {
+		return "", false, err
+	}
+	if index < 0 {
+		index += list.Len()
+	}
+	if index < 0 || index >= list.Len() {
+		return "", false, nil
+	}
+	return list.at(index), true, nil
+}
+
+// LSet replaces element index of the list stored at key. It returns
+// ErrKeyNotFound if the key does not exist and ErrIndexOutOfRange if the
+// list is too short.
+func (str *Store) LSet(key string, index int, v string) error {
+	str.lock()
+	defer str.unlock()
+	node, list, err := str.writeList(key, false)
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		return ErrKeyNotFound
+	}
+	if index < 0 {
+		index += list.Len()
+	}
+	if index < 0 || index >= list.Len() {
+		return ErrIndexOutOfRange
+	}
+	list.set(index, v)
+	str.listChanged(node, list, "lset")
+	return nil
+}
+
+// LRem removes elements equal to v from the list stored at key: the first
+// count of them when count > 0, the last -count when count < 0, and all of
+// them when count is 0. It returns how many were removed.
+func (str *Store) LRem(key string, count int, v string) (int, error) {
+	str.lock()
+	defer str.unlock()
+	node, list, err := str.writeList(key, false)
+	if node == nil || err != nil {
+		return 0, err
+	}
+	removed := list.remove(count, v)
+	if removed > 0 {
+		str.listChanged(node, list, "lrem")
+	}
+	return removed, nil
+}
+
+// LTrim keeps only elements start..stop inclusive of the list stored at
+// key; negative indices count from the end. A range that keeps nothing
+// deletes the key.
+func (str *Store) LTrim(key string, start, stop int) error {
+	str.lock()
+	defer str.unlock()
+	node, list, err := str.writeList(key, false)
+	if node == nil || err != nil {
+		return err
+	}
+	start, stop = listRange(start, stop, list.Len())
+	list.trim(start, stop)
+	str.listChanged(node, list, "ltrim")
+	return nil
+}
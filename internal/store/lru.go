@@ -12,10 +12,11 @@ var (
 
 type Node struct {
 	key      string
-	value    string
+	value    value
 	prev     *Node
 	next     *Node
 	expireAt *time.Time // nil  = no expiration
+	version  uint64     // bumped on every modification (used by WATCH)
 }
 type LruList struct {
 	Head *Node
@@ -82,33 +83,23 @@ func (st *LruList) RemoveLeastUsed() bool {
 	if st.Tail == nil {
 		return false
 	}
-	temp := st.Tail
-	st.Tail = temp.prev
-	if st.Tail != nil {
-		st.Tail.next = nil
-	}
-	temp = nil
+	st.RemoveNode(st.Tail)
 	return true
 }
 
 func (st *LruList) RemoveNode(nd *Node) {
-
-	if nd == st.Head {
+	if nd.prev != nil {
+		nd.prev.next = nd.next
+	} else {
 		st.Head = nd.next
-		if st.Head != nil {
-			st.Head.prev = nil
-		}
-		return
 	}
-	if nd == st.Tail {
+	if nd.next != nil {
+		nd.next.prev = nd.prev
+	} else {
 		st.Tail = nd.prev
-		if st.Tail != nil {
-			st.Tail.next = nil
-		}
-		return
 	}
-	nd.prev.next = nd.next
-	nd.next.prev = nd.prev
+	nd.prev = nil
+	nd.next = nil
 }
 
 func (st *LruList) PrintList() {
@@ -120,7 +111,7 @@ func (st *LruList) PrintList() {
 	curr := st.Head
 	i := 1
 	for curr != nil {
-		fmt.Printf("  [%d] key=%q value=%q\n", i, curr.key, curr.value)
+		fmt.Printf("  [%d] key=%q value=%q\n", i, curr.key, fmt.Sprint(curr.value))
 		curr = curr.next
 		i++
 	}
@@ -0,0 +1,171 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NotifyFlags selects which keyspace notifications are emitted, in the
+// style of Redis' notify-keyspace-events setting.
+type NotifyFlags uint
+
+const (
+	NotifyKeyspace NotifyFlags = 1 << iota // K: publish on __keyspace@<db>__:<key>
+	NotifyKeyevent                         // E: publish on __keyevent@<db>__:<event>
+	NotifyGeneric                          // g: del, expire, persist, move_from, move_to
+	NotifyString                           // $: set
+	NotifyExpired                          // x: key expired
+	NotifyEvicted                          // e: key evicted by the LRU
+	NotifyList                             // l: lpush, rpush, lpop, rpop, lset, lrem, ltrim
+	NotifyHash                             // h: hset, hdel, hincrby, hexpire, hpersist, hexpired
+	NotifySet                              // s: sadd, srem, spop, sinterstore, sunionstore, sdiffstore
+
+	// NotifyAll is the "A" alias for every event class.
+	NotifyAll = NotifyGeneric | NotifyString | NotifyList | NotifySet | NotifyHash | NotifyExpired | NotifyEvicted
+)
+
+var notifyFlagChars = []struct {
+	char byte
+	flag NotifyFlags
+}{
+	{'K', NotifyKeyspace},
+	{'E', NotifyKeyevent},
+	{'g', NotifyGeneric},
+	{'$', NotifyString},
+	{'l', NotifyList},
+	{'s', NotifySet},
+	{'h', NotifyHash},
+	{'x', NotifyExpired},
+	{'e', NotifyEvicted},
+}
+
+// ParseNotifyFlags parses a notify-keyspace-events string such as "KEA"
+// or "Ex". The empty string disables notifications.
+func ParseNotifyFlags(s string) (NotifyFlags, error) {
+	var flags NotifyFlags
+	for i := 0; i < len(s); i++ {
+		if s[i] == 'A' {
+			flags |= NotifyAll
+			continue
+		}
+		found := false
+		for _, fc := range notifyFlagChars {
+			if fc.char == s[i] {
+				flags |= fc.flag
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("invalid notify-keyspace-events flag '%c'", s[i])
+		}
+	}
+	return flags, nil
+}
+
+// String renders flags back to notify-keyspace-events syntax.
+func (f NotifyFlags) String() string {
+	var b strings.Builder
+	for _, fc := range notifyFlagChars {
+		if fc.flag&NotifyAll != 0 && f&NotifyAll == NotifyAll {
+			continue
+		}
+		if f&fc.flag != 0 {
+			b.WriteByte(fc.char)
+		}
+	}
+	if f&NotifyAll == NotifyAll {
+		b.WriteByte('A')
+	}
+	return b.String()
+}
+
+// KeyEvent describes one change to a key.
+type KeyEvent struct {
+	DB    int // database the key lives in
+	Key   string
+	Event string // "set", "del", "expire", "persist", "expired", "evicted", "move_from", "move_to", or a list, set or hash command such as "lpush"
+}
+
+// SetNotifyKeyspaceEvents chooses which event classes are emitted by every
+// database. As in Redis, nothing is emitted unless K or E is set as well.
+func (str *Store) SetNotifyKeyspaceEvents(flags NotifyFlags) {
+	str.lock()
+	defer str.unlock()
+	str.notifyFlags = flags
+}
+
+// NotifyKeyspaceEvents returns the current notification flags.
+func (str *Store) NotifyKeyspaceEvents() NotifyFlags {
+	str.rlock()
+	defer str.runlock()
+	return str.notifyFlags
+}
+
+// Notifications registers a listener for key events of every database and
+// returns the channel they are delivered on, plus a function that
+// unregisters the listener and closes the channel. Delivery never blocks the store: when
+// the buffer is full, events are dropped for that listener.
+func (str *Store) Notifications(buffer int) (<-chan KeyEvent, func()) {
+	ch := make(chan KeyEvent, buffer)
+	str.lock()
+	if str.listeners == nil {
+		str.listeners = make(map[chan KeyEvent]struct{})
+	}
+	str.listeners[ch] = struct{}{}
+	str.unlock()
+
+	cancel := func() {
+		str.lock()
+		defer str.unlock()
+		if _, ok := str.listeners[ch]; ok {
+			delete(str.listeners, ch)
+			close(ch)
+		}
+	}
+	return ch, cancel
+}
+
+// OnInvalidate registers fn to be told about every key whose value or TTL
+// changes, or that is deleted, expires, is evicted or moves, whatever the
+// notify-keyspace-events flags say. An empty key means the whole database
+// was emptied (FLUSHDB, FLUSHALL, SWAPDB or a snapshot load). fn runs with
+// the store locked, so it must neither block nor use the store. The
+// returned function unregisters it.
+func (str *Store) OnInvalidate(fn func(db int, key string)) func() {
+	str.lock()
+	defer str.unlock()
+	if str.invalidators == nil {
+		str.invalidators = make(map[int]func(db int, key string))
+	}
+	id := str.nextInvalidate
+	str.nextInvalidate++
+	str.invalidators[id] = fn
+
+	return func() {
+		str.lock()
+		defer str.unlock()
+		delete(str.invalidators, id)
+	}
+}
+
+// invalidate runs the invalidation hooks for key, or for the whole
+// database when key is empty. Callers hold mu.
+func (ks *keyspace) invalidate(key string) {
+	for _, fn := range ks.invalidators {
+		fn(ks.index, key)
+	}
+}
+
+// notify emits an event of the given class if it is enabled. Callers hold mu.
+func (str *Store) notify(class NotifyFlags, event, key string) {
+	if str.notifyFlags&class == 0 || str.notifyFlags&(NotifyKeyspace|NotifyKeyevent) == 0 {
+		return
+	}
+	for ch := range str.listeners {
+		select {
+		case ch <- KeyEvent{DB: str.index, Key: key, Event: event}:
+		default:
+		}
+	}
+}
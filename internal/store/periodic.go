@@ -0,0 +1,77 @@
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+// periodic runs fn on a ticker whose interval can be changed while it
+// runs. It has its own lock so that retuning never waits for fn, which
+// usually takes the store lock.
+type periodic struct {
+	fn func()
+
+	mu       sync.Mutex
+	interval time.Duration
+	ticker   *time.Ticker
+	done     chan struct{} // closed by stop
+	stopped  bool
+}
+
+// setInterval starts the ticker, retunes it, or pauses it when d <= 0.
+// It does nothing once the task is stopped.
+func (p *periodic) setInterval(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		return
+	}
+	p.interval = d
+	switch {
+	case d <= 0:
+		if p.ticker != nil {
+			p.ticker.Stop()
+		}
+	case p.ticker == nil:
+		p.ticker = time.NewTicker(d)
+		p.done = make(chan struct{})
+		go p.run(p.ticker, p.done)
+	default:
+		p.ticker.Reset(d)
+	}
+}
+
+// getInterval returns the current interval, 0 when paused or not started.
+func (p *periodic) getInterval() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped || p.interval < 0 {
+		return 0
+	}
+	return p.interval
+}
+
+// stop ends the task for good.
+func (p *periodic) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		return
+	}
+	p.stopped = true
+	if p.ticker != nil {
+		p.ticker.Stop()
+		close(p.done)
+	}
+}
+
+func (p *periodic) run(ticker *time.Ticker, done <-chan struct{}) {
+	for {
+		select {
+		case <-ticker.C:
+			p.fn()
+		case <-done:
+			return
+		}
+	}
+}
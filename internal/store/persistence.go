@@ -4,46 +4,146 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"os/signal"
-	"syscall"
 	"time"
 )
 
-// SnapshotEntry represents a single key-value pair with metadata
+// SnapshotEntry represents a single key-value pair with metadata. Type is
+// empty for strings, which keep their value in Value; a list keeps its
+// elements in List, a set its members in Set and a hash its fields in
+// Hash, with the TTLs of its fields in FieldExpireAt.
 type SnapshotEntry struct {
-	Key      string     `json:"key"`
-	Value    string     `json:"value"`
-	ExpireAt *time.Time `json:"expire_at,omitempty"`
+	Key           string               `json:"key"`
+	Type          string               `json:"type,omitempty"`
+	Value         string               `json:"value,omitempty"`
+	List          []string             `json:"list,omitempty"`
+	Set           []string             `json:"set,omitempty"`
+	Hash          map[string]string    `json:"hash,omitempty"`
+	FieldExpireAt map[string]time.Time `json:"field_expire_at,omitempty"`
+	ExpireAt      *time.Time           `json:"expire_at,omitempty"`
 }
 
-// Snapshot represents entire store state
+// snapshotEntry converts node for a snapshot.
+func snapshotEntry(node *Node) SnapshotEntry {
+	entry := SnapshotEntry{Key: node.key, ExpireAt: node.expireAt}
+	switch v := node.value.(type) {
+	case stringValue:
+		entry.Value = string(v)
+	case *deque:
+		entry.Type = v.typeName()
+		entry.List = v.slice(0, v.Len()-1)
+	case *set:
+		entry.Type = v.typeName()
+		entry.Set = v.sorted()
+	case *hash:
+		entry.Type = v.typeName()
+		entry.Hash = make(map[string]string, v.Len())
+		for name, f := range v.fields {
+			if f.isExpired() {
+				continue
+			}
+			entry.Hash[name] = string(f.value.(stringValue))
+			if f.expireAt != nil {
+				if entry.FieldExpireAt == nil {
+					entry.FieldExpireAt = make(map[string]time.Time)
+				}
+				entry.FieldExpireAt[name] = *f.expireAt
+			}
+		}
+	}
+	return entry
+}
+
+// check rejects entries of a type this version does not know.
+func (entry SnapshotEntry) check() error {
+	switch entry.Type {
+	case "", "string", "list", "set", "hash":
+		return nil
+	}
+	return fmt.Errorf("key %q has unknown type %q", entry.Key, entry.Type)
+}
+
+// value rebuilds the value of a checked snapshot entry. It returns nil
+// for a hash whose fields have all expired.
+func (entry SnapshotEntry) value() value {
+	switch entry.Type {
+	case "list":
+		list := &deque{}
+		for _, v := range entry.List {
+			list.pushBack(v)
+		}
+		return list
+	case "set":
+		s := newSet()
+		for _, m := range entry.Set {
+			s.add(m)
+		}
+		return s
+	case "hash":
+		h := newHash()
+		for name, v := range entry.Hash {
+			h.set(name, v)
+			if at, ok := entry.FieldExpireAt[name]; ok {
+				h.setExpiry(h.fields[name], &at)
+			}
+		}
+		h.removeExpired()
+		if h.Len() == 0 {
+			return nil
+		}
+		return h
+	}
+	return stringValue(entry.Value)
+}
+
+// SnapshotDatabase holds the keys of one database
+type SnapshotDatabase struct {
+	DB      int             `json:"db"`
+	Entries []SnapshotEntry `json:"entries"`
+}
+
+// Snapshot represents entire store state. Version 1.0 files only have
+// Entries (database 0); version 2.0 files list every non-empty database.
 type Snapshot struct {
-	Version  string          `json:"version"`
-	Capacity int             `json:"capacity"`
-	Entries  []SnapshotEntry `json:"entries"`
+	Version   string             `json:"version"`
+	Capacity  int                `json:"capacity"`
+	Entries   []SnapshotEntry    `json:"entries,omitempty"`
+	Databases []SnapshotDatabase `json:"databases,omitempty"`
 }
 
+// SaveSnapshot writes every database of the store to filepath and records
+// the outcome for LastSave.
 func (str *Store) SaveSnapshot(filepath string) error {
-	str.mu.Lock()
-	defer str.mu.Unlock()
+	str.lock()
+	defer str.unlock()
+	err := str.writeSnapshot(filepath)
+	str.lastSaveErr = err
+	if err == nil {
+		str.lastSave = time.Now()
+		str.dirty = 0
+	}
+	return err
+}
+
+// writeSnapshot does the work of SaveSnapshot. Callers hold mu.
+func (str *Store) writeSnapshot(filepath string) error {
 	snapshot := Snapshot{
-		Version:  "1.0",
-		Capacity: str.capacity,
-		Entries:  make([]SnapshotEntry, 0, len(str.data)),
+		Version:   "2.0",
+		Capacity:  str.capacity,
+		Databases: []SnapshotDatabase{},
 	}
-	count := 0
 
-	for node := str.lru.Head; node != nil; node = node.next {
-		if node.isExpired() {
+	for _, db := range str.dbs {
+		if len(db.data) == 0 {
 			continue
 		}
-		snapshot.Entries = append(snapshot.Entries, SnapshotEntry{
-
-			Key:      node.key,
-			Value:    node.value,
-			ExpireAt: node.expireAt,
-		})
-		count++
+		entries := make([]SnapshotEntry, 0, len(db.data))
+		for node := db.lru.Head; node != nil; node = node.next {
+			if node.isExpired() {
+				continue
+			}
+			entries = append(entries, snapshotEntry(node))
+		}
+		snapshot.Databases = append(snapshot.Databases, SnapshotDatabase{DB: db.index, Entries: entries})
 	}
 
 	backup, readErr := os.ReadFile(filepath)
@@ -79,11 +179,10 @@ func (str *Store) SaveSnapshot(filepath string) error {
 
 }
 
+// LoadSnapshot replaces the contents of every database with the snapshot
+// in filepath. A missing file leaves the store untouched.
 func (str *Store) LoadSnapshot(filepath string) error {
 	// Check if file exists
-	str.lru.Head = nil
-	str.lru.Tail = nil
-
 	data, err := os.ReadFile(filepath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -98,70 +197,111 @@ func (str *Store) LoadSnapshot(filepath string) error {
 	if err != nil {
 		return fmt.Errorf("unmarshal failed: %w", err)
 	}
+	databases := snapshot.Databases
+	if len(databases) == 0 && len(snapshot.Entries) > 0 {
+		databases = []SnapshotDatabase{{DB: 0, Entries: snapshot.Entries}}
+	}
+	for _, sdb := range databases {
+		if sdb.DB < 0 || sdb.DB >= len(str.dbs) {
+			return fmt.Errorf("snapshot has database %d but only %d are configured", sdb.DB, len(str.dbs))
+		}
+		for _, entry := range sdb.Entries {
+			if err := entry.check(); err != nil {
+				return err
+			}
+		}
+	}
 
-	str.mu.Lock()
-	defer str.mu.Unlock()
+	str.lock()
+	defer str.unlock()
+	for _, db := range str.dbs {
+		db.reset()
+	}
 
 	// Load entries (skip expired)
 	now := time.Now()
-	for _, entry := range snapshot.Entries {
-		// Skip if expired
-		if entry.ExpireAt != nil && now.After(*entry.ExpireAt) {
-			continue
-		}
+	for _, sdb := range databases {
+		db := &Store{keyspace: str.dbs[sdb.DB], held: true}
+		for _, entry := range sdb.Entries {
+			// Skip if expired or duplicated
+			if entry.ExpireAt != nil && now.After(*entry.ExpireAt) {
+				continue
+			}
+			if _, dup := db.data[entry.Key]; dup {
+				continue
+			}
 
-		node := &Node{
-			key:      entry.Key,
-			value:    entry.Value,
-			expireAt: entry.ExpireAt,
-		}
+			v := entry.value()
+			if v == nil {
+				continue
+			}
+			node := &Node{
+				key:      entry.Key,
+				value:    v,
+				expireAt: entry.ExpireAt,
+				version:  str.nextVersion(),
+			}
 
-		str.lru.AddToTail(node)
-		str.data[entry.Key] = node
+			db.lru.AddToTail(node)
+			db.insertNode(node)
 
-		// Stop if capacity reached
-		if len(str.data) >= str.capacity {
-			break
+			// Stop if capacity reached
+			if len(db.data) >= db.capacity {
+				break
+			}
 		}
 	}
 
+	str.dirty = 0
+	str.lastSave = time.Now()
 	return nil
 }
 
 // EnableAutoSave starts background goroutine to save periodically
 func (str *Store) EnableAutoSave(filepath string, interval time.Duration) {
-	ticker := time.NewTicker(interval)
-	go func() {
-		for range ticker.C {
-			err := str.SaveSnapshot(filepath)
-			if err != nil {
-				fmt.Printf("\nAuto-save failed: %v\n", err)
-			} else {
-				fmt.Printf("\nAuto-save complete (%s)\n", filepath)
-			}
-			fmt.Print("memstash> ")
-		}
-	}()
-}
-
-// SaveOnShutdown saves before program exits and returns a channel
-func (s *Store) SaveOnShutdown(filepath string) <-chan struct{} {
-	done := make(chan struct{})
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-
-	go func() {
-		<-sigChan
-		fmt.Println("\nShutting down, saving data...")
-		err := s.SaveSnapshot(filepath)
-		if err != nil {
-			fmt.Printf("Save failed: %v\n", err)
-		} else {
-			fmt.Println("Data saved successfully")
-		}
-		close(done)
-		os.Exit(0)
-	}()
+	str.SetSnapshotPath(filepath)
+	str.SetAutoSaveInterval(interval)
+}
+
+// SetAutoSaveInterval changes how often the store is saved to its snapshot
+// path, starting auto-save if needed. An interval <= 0 pauses it.
+func (str *Store) SetAutoSaveInterval(interval time.Duration) {
+	str.autoSave.setInterval(interval)
+}
+
+// AutoSaveInterval returns the auto-save interval, 0 when it is not running.
+func (str *Store) AutoSaveInterval() time.Duration {
+	return str.autoSave.getInterval()
+}
+
+// autoSaveSnapshot is run by the auto-save ticker.
+func (str *Store) autoSaveSnapshot() {
+	filepath := str.SnapshotPath()
+	err := str.SaveSnapshot(filepath)
+	if err != nil {
+		fmt.Printf("\nAuto-save failed: %v\n", err)
+	} else {
+		fmt.Printf("\nAuto-save complete (%s)\n", filepath)
+	}
+	fmt.Print("memstash> ")
+}
+
+// SnapshotPath returns the file SAVE, LOAD and auto-save use.
+func (str *Store) SnapshotPath() string {
+	str.rlock()
+	defer str.runlock()
+	return str.snapshotPath
+}
+
+// SetSnapshotPath changes the file SAVE, LOAD and auto-save use.
+func (str *Store) SetSnapshotPath(filepath string) {
+	str.lock()
+	defer str.unlock()
+	str.snapshotPath = filepath
+}
 
-	return done
+// Close stops auto-save and the TTL cleaner. The store stays usable.
+func (str *Store) Close() {
+	str.autoSave.stop()
+	str.ttlCleaner.stop()
 }
@@ -0,0 +1,163 @@
+package store
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"memstash/internal/protocol"
+)
+
+// minBuckets is the smallest size of a keyIndex table.
+const minBuckets = 16
+
+// keyIndex is a chained hash table over the nodes of one database. It
+// exists only to support SCAN: unlike a Go map, its layout is known, so a
+// cursor can name a position in it that survives resizes.
+//
+// The table size is always a power of two and a key lives in bucket
+// hash & (size-1). Cursors walk the buckets in reverse-binary order (the
+// high bits of the bucket number are incremented first, as in Redis'
+// dictScan). When the table doubles or halves, every bucket maps onto
+// buckets whose reversed numbers are contiguous, so a scan in progress
+// neither skips keys that exist for its whole duration nor loops forever;
+// it may return some keys more than once.
+type keyIndex struct {
+	buckets [][]*Node
+	count   int
+}
+
+func newKeyIndex() *keyIndex {
+	return &keyIndex{buckets: make([][]*Node, minBuckets)}
+}
+
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+func (ix *keyIndex) mask() uint64 {
+	return uint64(len(ix.buckets) - 1)
+}
+
+func (ix *keyIndex) add(node *Node) {
+	b := hashKey(node.key) & ix.mask()
+	ix.buckets[b] = append(ix.buckets[b], node)
+	ix.count++
+	if ix.count > len(ix.buckets) {
+		ix.resize(len(ix.buckets) * 2)
+	}
+}
+
+func (ix *keyIndex) remove(node *Node) {
+	b := hashKey(node.key) & ix.mask()
+	bucket := ix.buckets[b]
+	for i, n := range bucket {
+		if n == node {
+			last := len(bucket) - 1
+			bucket[i] = bucket[last]
+			bucket[last] = nil
+			ix.buckets[b] = bucket[:last]
+			ix.count--
+			break
+		}
+	}
+	if len(ix.buckets) > minBuckets && ix.count < len(ix.buckets)/8 {
+		ix.resize(len(ix.buckets) / 2)
+	}
+}
+
+func (ix *keyIndex) resize(size int) {
+	old := ix.buckets
+	ix.buckets = make([][]*Node, size)
+	mask := ix.mask()
+	for _, bucket := range old {
+		for _, node := range bucket {
+			b := hashKey(node.key) & mask
+			ix.buckets[b] = append(ix.buckets[b], node)
+		}
+	}
+}
+
+// nextCursor advances cursor to the next bucket in reverse-binary order.
+// It returns 0 once every bucket has been visited.
+func (ix *keyIndex) nextCursor(cursor uint64) uint64 {
+	cursor |= ^ix.mask()
+	cursor = bits.Reverse64(cursor)
+	cursor++
+	return bits.Reverse64(cursor)
+}
+
+// Scan returns up to about count keys starting at cursor, and the cursor
+// to pass to the next call; a returned cursor of 0 means the iteration is
+// complete. Start with cursor 0. Keys that exist for the whole iteration
+// are returned at least once, even if the database grows or shrinks in
+// between. match is a glob pattern ("" or "*" for every key) and typ a
+// value type such as "string" ("" for every type). Both filters are
+// applied after the keys are fetched, so a call may return fewer than
+// count keys (or none) while the cursor is not yet 0.
+func (str *Store) Scan(cursor uint64, match string, count int, typ string) (uint64, []string) {
+	if count <= 0 {
+		count = 10
+	}
+	str.rlock()
+	defer str.runlock()
+
+	var keys []string
+	cursor = str.table.scan(cursor, match, count, func(node *Node) bool {
+		if typ != "" && typ != node.typeName() {
+			return false
+		}
+		keys = append(keys, node.key)
+		return true
+	})
+	return cursor, keys
+}
+
+// scan calls fn for the unexpired nodes matching match in the buckets
+// from cursor on, until fn accepted about count of them or every bucket
+// was visited, and returns the cursor to resume from.
+func (ix *keyIndex) scan(cursor uint64, match string, count int, fn func(*Node) bool) uint64 {
+	accepted := 0
+	// Bound the work done on a sparse table, like Redis does. Visiting
+	// every bucket once is a full pass, so a large count never needs more
+	// (and never overflows the budget).
+	visits := len(ix.buckets)
+	if count < visits/10 {
+		visits = count * 10
+	}
+	for ; visits > 0; visits-- {
+		for _, node := range ix.buckets[cursor&ix.mask()] {
+			if node.isGone() {
+				continue
+			}
+			if match != "" && match != "*" && !protocol.Match(match, node.key) {
+				continue
+			}
+			if fn(node) {
+				accepted++
+			}
+		}
+		cursor = ix.nextCursor(cursor)
+		if cursor == 0 || accepted >= count {
+			break
+		}
+	}
+	return cursor
+}
+
+// Type returns the type of the value stored at key, or "none" if the key
+// does not exist.
+func (str *Store) Type(key string) string {
+	str.rlock()
+	defer str.runlock()
+	node, ok := str.data[key]
+	if !ok || node.isGone() {
+		return "none"
+	}
+	return node.typeName()
+}
+
+// typeName is the name TYPE and SCAN TYPE use for the node's value.
+func (n *Node) typeName() string {
+	return n.value.typeName()
+}
@@ -0,0 +1,451 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+)
+
+// ErrNegativeCount is returned by SPop for a count below 0.
+var ErrNegativeCount = errors.New("value is out of range, must be positive")
+
+// set is a collection of distinct strings. Like hash fields, members are
+// kept in Nodes of their own so that SSCAN walks them with the keyIndex of
+// SCAN; the index also lets SRANDMEMBER and SPOP pick a random member
+// without copying the set.
+type set struct {
+	members map[string]*Node
+	index   *keyIndex
+	bytes   int64 // total length of the members
+}
+
+func newSet() *set {
+	return &set{members: make(map[string]*Node), index: newKeyIndex()}
+}
+
+func (s *set) typeName() string { return "set" }
+
+// memory counts every member like a key, since each one has a Node, a map
+// entry and an index slot.
+func (s *set) memory() int64 { return s.bytes + int64(len(s.members))*nodeOverhead }
+
+func (s *set) String() string { return fmt.Sprint(s.sorted()) }
+
+// Len returns the number of members.
+func (s *set) Len() int { return len(s.members) }
+
+func (s *set) has(member string) bool {
+	_, ok := s.members[member]
+	return ok
+}
+
+// add reports whether member is new.
+func (s *set) add(member string) bool {
+	if s.has(member) {
+		return false
+	}
+	m := &Node{key: member}
+	s.members[member] = m
+	s.index.add(m)
+	s.bytes += int64(len(member))
+	return true
+}
+
+func (s *set) remove(member string) bool {
+	m, ok := s.members[member]
+	if !ok {
+		return false
+	}
+	delete(s.members, member)
+	s.index.remove(m)
+	s.bytes -= int64(len(member))
+	return true
+}
+
+// sorted returns the members in order.
+func (s *set) sorted() []string {
+	members := make([]string, 0, len(s.members))
+	for m := range s.members {
+		members = append(members, m)
+	}
+	sort.Strings(members)
+	return members
+}
+
+// random returns a random member; the set must not be empty. It picks a
+// random non-empty bucket, then a member in it, like Redis' dictGetRandomKey:
+// members sharing a bucket are a little less likely to be picked.
+func (s *set) random() string {
+	for {
+		bucket := s.index.buckets[rand.IntN(len(s.index.buckets))]
+		if len(bucket) > 0 {
+			return bucket[rand.IntN(len(bucket))].key
+		}
+	}
+}
+
+// sample returns count distinct random members, or all of them if the set
+// is not larger than count.
+func (s *set) sample(count int) []string {
+	if count >= s.Len() {
+		return s.sorted()
+	}
+	// Close to the whole set, repeated picks would mostly hit members
+	// already taken: shuffle a copy instead.
+	if count*3 > s.Len() {
+		members := s.sorted()
+		rand.Shuffle(len(members), func(i, j int) { members[i], members[j] = members[j], members[i] })
+		return members[:count]
+	}
+	picked := make(map[string]struct{}, count)
+	out := make([]string, 0, count)
+	for len(out) < count {
+		m := s.random()
+		if _, dup := picked[m]; !dup {
+			picked[m] = struct{}{}
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// readSet returns the set stored at key for a read, counting the lookup
+// as a hit or a miss. It returns nil if the key does not exist. Callers
+// hold mu.
+func (str *Store) readSet(key string) (*Node, *set, error) {
+	node := str.lookup(key)
+	if node == nil {
+		str.misses++
+		return nil, nil, nil
+	}
+	s, ok := node.value.(*set)
+	if !ok {
+		return nil, nil, ErrWrongType
+	}
+	str.hits++
+	str.lru.MoveToHead(node)
+	return node, s, nil
+}
+
+// writeSet returns the set stored at key for a change. It returns nil if
+// the key does not exist, unless create is set, in which case an empty set
+// is added. Callers hold mu.
+func (str *Store) writeSet(key string, create bool) (*Node, *set, error) {
+	if key == "" {
+		return nil, nil, ErrInvalidKey
+	}
+	node := str.lookup(key)
+	if node == nil {
+		if !create {
+			return nil, nil, nil
+		}
+		s := newSet()
+		return str.insertValue(key, s), s, nil
+	}
+	s, ok := node.value.(*set)
+	if !ok {
+		return nil, nil, ErrWrongType
+	}
+	return node, s, nil
+}
+
+// setChanged records a change to a set and deletes it once it is empty.
+// Callers hold mu.
+func (str *Store) setChanged(node *Node, s *set, event string) {
+	str.modified(node, NotifySet, event)
+	if s.Len() == 0 {
+		str.removeNode(node)
+		str.notify(NotifyGeneric, "del", node.key)
+	}
+}
+
+// SAdd adds members to the set stored at key, creating it if needed, and
+// returns how many were not already in it.
+func (str *Store) SAdd(key string, members ...string) (int, error) {
+	str.lock()
+	defer str.unlock()
+	node, s, err := str.writeSet(key, true)
+	if err != nil {
+		return 0, err
+	}
+	added := 0
+	for _, m := range members {
+		if s.add(m) {
+			added++
+		}
+	}
+	str.setChanged(node, s, "sadd")
+	return added, nil
+}
+
+// SRem removes members from the set stored at key and returns how many
+// were in it. The key is deleted with its last member.
+func (str *Store) SRem(key string, members ...string) (int, error) {
+	str.lock()
+	defer str.unlock()
+	node, s, err := str.writeSet(key, false)
+	if node == nil || err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, m := range members {
+		if s.remove(m) {
+			removed++
+		}
+	}
+	if removed > 0 {
+		str.setChanged(node, s, "srem")
+	}
+	return removed, nil
+}
+
+// SMembers returns the members of the set stored at key in order; a
+// missing key is an empty set.
+func (str *Store) SMembers(key string) ([]string, error) {
+	str.lock()
+	defer str.unlock()
+	_, s, err := str.readSet(key)
+	if s == nil {
+		return []string{}, err
+	}
+	return s.sorted(), nil
+}
+
+// SIsMember reports whether member is in the set stored at key.
+func (str *Store) SIsMember(key, member string) (bool, error) {
+	str.lock()
+	defer str.unlock()
+	_, s, err := str.readSet(key)
+	if s == nil {
+		return false, err
+	}
+	return s.has(member), nil
+}
+
+// SCard returns the number of members of the set stored at key, 0 if it
+// does not exist.
+func (str *Store) SCard(key string) (int, error) {
+	str.lock()
+	defer str.unlock()
+	_, s, err := str.readSet(key)
+	if s == nil {
+		return 0, err
+	}
+	return s.Len(), nil
+}
+
+// SRandMember returns random members of the set stored at key without
+// removing them: count distinct ones when count > 0 (fewer if the set is
+// smaller), and -count possibly repeated ones when count < 0. It returns
+// nil if the key does not exist.
+func (str *Store) SRandMember(key string, count int) ([]string, error) {
+	str.lock()
+	defer str.unlock()
+	_, s, err := str.readSet(key)
+	if s == nil {
+		return nil, err
+	}
+	if count >= 0 {
+		return s.sample(count), nil
+	}
+	out := make([]string, -count)
+	for i := range out {
+		out[i] = s.random()
+	}
+	return out, nil
+}
+
+// SPop removes and returns up to count random members of the set stored
+// at key. It returns nil if the key does not exist, and ErrNegativeCount if
+// count < 0.
+func (str *Store) SPop(key string, count int) ([]string, error) {
+	if count < 0 {
+		return nil, ErrNegativeCount
+	}
+	str.lock()
+	defer str.unlock()
+	node, s, err := str.writeSet(key, false)
+	if node == nil || err != nil {
+		return nil, err
+	}
+	popped := s.sample(count)
+	if len(popped) == 0 {
+		return popped, nil
+	}
+	for _, m := range popped {
+		s.remove(m)
+	}
+	str.setChanged(node, s, "spop")
+	return popped, nil
+}
+
+// SScan is SCAN over the members of the set stored at key: it returns up
+// to about count members matching match and the cursor to pass to the
+// next call, 0 once the iteration is complete.
+func (str *Store) SScan(key string, cursor uint64, match string, count int) (uint64, []string, error) {
+	if count <= 0 {
+		count = 10
+	}
+	str.lock()
+	defer str.unlock()
+	_, s, err := str.readSet(key)
+	if s == nil {
+		return 0, []string{}, err
+	}
+	members := []string{}
+	cursor = s.index.scan(cursor, match, count, func(m *Node) bool {
+		members = append(members, m.key)
+		return true
+	})
+	return cursor, members, nil
+}
+
+// SInter returns the members common to the sets stored at keys, in
+// order. A missing key is an empty set.
+func (str *Store) SInter(keys ...string) ([]string, error) {
+	str.lock()
+	defer str.unlock()
+	return str.combine(keys, inter)
+}
+
+// SUnion returns the members of any of the sets stored at keys, in order.
+func (str *Store) SUnion(keys ...string) ([]string, error) {
+	str.lock()
+	defer str.unlock()
+	return str.combine(keys, union)
+}
+
+// SDiff returns the members of the set stored at the first key that are
+// in none of the others, in order.
+func (str *Store) SDiff(keys ...string) ([]string, error) {
+	str.lock()
+	defer str.unlock()
+	return str.combine(keys, diff)
+}
+
+// SInterStore stores the result of SInter in the set at dst, replacing
+// whatever dst held, and returns its size. An empty result deletes dst.
+func (str *Store) SInterStore(dst string, keys ...string) (int, error) {
+	return str.combineStore(dst, keys, inter, "sinterstore")
+}
+
+// SUnionStore stores the result of SUnion in the set at dst, like
+// SInterStore.
+func (str *Store) SUnionStore(dst string, keys ...string) (int, error) {
+	return str.combineStore(dst, keys, union, "sunionstore")
+}
+
+// SDiffStore stores the result of SDiff in the set at dst, like
+// SInterStore.
+func (str *Store) SDiffStore(dst string, keys ...string) (int, error) {
+	return str.combineStore(dst, keys, diff, "sdiffstore")
+}
+
+// setOp is one of the set algebra operations. Missing sets are nil.
+type setOp func(sets []*set) []string
+
+func inter(sets []*set) []string {
+	out := []string{}
+	for _, s := range sets {
+		if s == nil {
+			return out
+		}
+	}
+	// Walk the smallest set and probe the others
+	smallest := sets[0]
+	for _, s := range sets[1:] {
+		if s.Len() < smallest.Len() {
+			smallest = s
+		}
+	}
+	for _, m := range smallest.sorted() {
+		inAll := true
+		for _, s := range sets {
+			if !s.has(m) {
+				inAll = false
+				break
+			}
+		}
+		if inAll {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+func union(sets []*set) []string {
+	seen := make(map[string]struct{})
+	out := []string{}
+	for _, s := range sets {
+		if s == nil {
+			continue
+		}
+		for m := range s.members {
+			if _, dup := seen[m]; !dup {
+				seen[m] = struct{}{}
+				out = append(out, m)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func diff(sets []*set) []string {
+	out := []string{}
+	if sets[0] == nil {
+		return out
+	}
+	for _, m := range sets[0].sorted() {
+		inOther := false
+		for _, s := range sets[1:] {
+			if s != nil && s.has(m) {
+				inOther = true
+				break
+			}
+		}
+		if !inOther {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// combine applies op to the sets stored at keys. Callers hold mu.
+func (str *Store) combine(keys []string, op setOp) ([]string, error) {
+	sets := make([]*set, len(keys))
+	for i, key := range keys {
+		_, s, err := str.readSet(key)
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = s
+	}
+	return op(sets), nil
+}
+
+func (str *Store) combineStore(dst string, keys []string, op setOp, event string) (int, error) {
+	if dst == "" {
+		return 0, ErrInvalidKey
+	}
+	str.lock()
+	defer str.unlock()
+	members, err := str.combine(keys, op)
+	if err != nil {
+		return 0, err
+	}
+	if len(members) == 0 {
+		str.deleteInternal(dst)
+		return 0, nil
+	}
+	if node := str.lookup(dst); node != nil {
+		str.removeNode(node)
+	}
+	s := newSet()
+	for _, m := range members {
+		s.add(m)
+	}
+	str.setChanged(str.insertValue(dst, s), s, event)
+	return s.Len(), nil
+}
@@ -2,7 +2,9 @@ package store
 
 import (
 	"errors"
+	"memstash/internal/protocol"
 	"sync"
+	"time"
 )
 
 var (
@@ -18,96 +20,121 @@ type StoreStats struct {
 	Hits      int64
 	Misses    int64
 	Evictions int64
+	Expired   int64
 }
+
+// Store is a handle on one numbered database. Handles for the other
+// databases of the same server are obtained with DB.
 type Store struct {
-	mu        sync.RWMutex
+	*keyspace
+	held bool // true for transaction views: the caller already holds mu
+}
+
+// keyspace is one database: the state shared by a Store and its
+// transaction views.
+type keyspace struct {
+	*group
+	index     int
 	data      map[string]*Node
 	capacity  int
 	lru       *LruList
+	table     *keyIndex // bucket layout for SCAN cursors
 	hits      int64
 	misses    int64
 	evictions int64
+	expired   int64
+	removed   uint64 // clock tick of the last key removal; the version of absent keys
+
+	// blocked queues the BLPop, BRPop and BLMove callers waiting for
+	// each key, oldest first. It stays with the database number: SWAPDB
+	// does not move it.
+	blocked map[string][]*waiter
+}
+
+// group is the state shared by all databases of a store. A single lock
+// covers every database so that MOVE, SWAPDB and transactions touching
+// several databases are atomic.
+type group struct {
+	mu    sync.RWMutex
+	dbs   []*keyspace
+	clock uint64 // last version handed out to a modified key
+	dirty int64  // changes since the last successful save
+
+	lastSave     time.Time // last successful save or load, or creation
+	lastSaveErr  error     // result of the last save attempt
+	snapshotPath string
+
+	autoSave   *periodic
+	ttlCleaner *periodic
+
+	notifyFlags NotifyFlags
+	listeners   map[chan KeyEvent]struct{}
+
+	invalidators   map[int]func(db int, key string)
+	nextInvalidate int // id of the next invalidation hook
 }
 
+// NewStore creates a store with DefaultDatabases databases, each holding
+// up to capacity keys, and returns database 0.
 func NewStore(capacity int) *Store {
-	return &Store{data: make(map[string]*Node),
-		capacity: capacity,
-		lru:      NewLru(),
-	}
+	return NewStoreWithDatabases(capacity, DefaultDatabases)
 }
 func (str *Store) Set(key string, value string) error {
 	if key == "" {
 		return ErrInvalidKey
 	}
-	str.mu.Lock()
-	defer str.mu.Unlock()
-	temp := &Node{
-		value: value,
-		key:   key,
-	}
-	if node, ok := str.data[key]; ok {
-		node.value = value
+	str.lock()
+	defer str.unlock()
+	if node := str.lookup(key); node != nil {
+		if _, isString := node.value.(stringValue); !isString {
+			return ErrWrongType
+		}
+		node.value = stringValue(value)
+		node.version = str.nextVersion()
 		str.lru.MoveToHead(node)
+		str.invalidate(key)
+		str.notify(NotifyString, "set", key)
 		return nil
 	}
 
-	if len(str.data) < str.capacity {
-
-		if str.lru.Head == nil && str.lru.Tail == nil {
-			str.data[key] = temp
-			str.lru.Head = temp
-			str.lru.Tail = temp
-
-			return nil
-		}
-
-		str.data[key] = temp
-		temp.next = str.lru.Head
-		str.lru.Head.prev = temp
-		str.lru.Head = temp
-
-		return nil
-	} else {
-
-		if str.lru.Tail != nil {
-			keyToRemove := str.lru.Tail.key
-			str.lru.RemoveLeastUsed()
-			delete(str.data, keyToRemove)
-		}
-
-		str.data[key] = temp
-		if str.lru.Head != nil {
-			temp.next = str.lru.Head
-			str.lru.Head.prev = temp
-		}
-		str.lru.Head = temp
-		if str.lru.Tail == nil {
-			str.lru.Tail = temp
-		}
-
-		return nil
+	if len(str.data) >= str.capacity {
+		str.evictLeastUsed()
+	}
+	node := &Node{
+		value:   stringValue(value),
+		key:     key,
+		version: str.nextVersion(),
 	}
+	str.insertNode(node)
+	str.lru.AddToHead(node)
+	str.invalidate(key)
+	str.notify(NotifyString, "set", key)
+	return nil
 }
 func (str *Store) Get(key string) (string, error) {
 	if key == "" {
 		return "", ErrInvalidKey
 	}
-	str.mu.Lock()
-	defer str.mu.Unlock()
-	node, ok := str.data[key]
-	if !ok {
+	str.lock()
+	defer str.unlock()
+	if node, ok := str.data[key]; ok && node.isExpired() {
+		str.expireNode(node)
+		return "", ErrKeyExpired
+	}
+	// lookup also removes a hash whose fields have all expired.
+	node := str.lookup(key)
+	if node == nil {
 		str.misses++
 		return "", ErrKeyNotFound
 	}
-	if node.isExpired() {
-		str.lru.RemoveNode(node)
-		delete(str.data, key)
-		return "", ErrKeyExpired
+	v, ok := node.value.(stringValue)
+	if !ok {
+		return "", ErrWrongType
 	}
 	str.hits++
 	str.lru.MoveToHead(node)
 
-	return node.value, nil
+	return string(v), nil
 }
 
 // deleteInternal removes a key without locking (for internal use only)
@@ -115,46 +142,94 @@ func (str *Store) deleteInternal(key string) error {
 	if key == "" {
 		return ErrInvalidKey
 	}
-	if _, ok := str.data[key]; !ok {
+	node := str.lookup(key)
+	if node == nil {
 		return ErrKeyNotFound
 	}
-	// Case 1: Deleting middle Node
-	if str.data[key] == str.lru.Head && str.data[key] == str.lru.Tail {
-		str.lru.Head = nil
-		str.lru.Tail = nil
+	str.removeNode(node)
+	str.invalidate(key)
+	str.notify(NotifyGeneric, "del", key)
+	return nil
+}
 
-		// Case 2: Deleting Head
-	} else if str.lru.Head == str.data[key] {
-		str.lru.Head = str.lru.Head.next
-		if str.lru.Head != nil {
-			str.lru.Head.prev = nil
-		}
+// insertNode adds a new node to the map and the SCAN index; the caller
+// links it into the LRU list. Callers hold mu.
+func (str *Store) insertNode(node *Node) {
+	str.data[node.key] = node
+	str.table.add(node)
+}
 
-		// Case 3: Deleting Tail
-	} else if str.lru.Tail == str.data[key] {
-		str.lru.Tail = str.lru.Tail.prev
-		if str.lru.Tail != nil {
-			str.lru.Tail.next = nil
-		}
-		// Case 4: Node is Both Head and Tail
-	} else {
-		str.data[key].prev.next = str.data[key].next
-		str.data[key].next.prev = str.data[key].prev
-	}
+// removeNode unlinks node from the LRU list, the map and the SCAN index.
+// Callers hold mu.
+func (str *Store) removeNode(node *Node) {
+	str.lru.RemoveNode(node)
+	delete(str.data, node.key)
+	str.table.remove(node)
+	str.dirty++
+	str.markRemoved()
+}
 
-	delete(str.data, key)
-	return nil
+// markRemoved gives the absent keys of the database a new version, so that
+// a key created and removed again since WATCH still reads as changed.
+// Callers hold mu.
+func (ks *keyspace) markRemoved() {
+	ks.clock++
+	ks.removed = ks.clock
+}
+
+// reset empties the database. Callers hold mu.
+func (ks *keyspace) reset() {
+	ks.dirty += int64(len(ks.data))
+	ks.data = make(map[string]*Node)
+	ks.lru = NewLru()
+	ks.table = newKeyIndex()
+	ks.markRemoved()
+	ks.invalidate("")
+}
+
+// evictLeastUsed drops the least recently used key to make room for a new
+// one. Callers hold mu.
+func (str *Store) evictLeastUsed() {
+	node := str.lru.Tail
+	if node == nil {
+		return
+	}
+	str.removeNode(node)
+	str.evictions++
+	str.invalidate(node.key)
+	str.notify(NotifyEvicted, "evicted", node.key)
 }
 
 func (str *Store) Delete(key string) error {
-	str.mu.Lock()
-	defer str.mu.Unlock()
+	str.lock()
+	defer str.unlock()
 	return str.deleteInternal(key)
 }
 
+// SetCapacity changes the number of keys each database may hold. Databases
+// over the new limit evict their least recently used keys at once; the
+// number of evicted keys is returned.
+func (str *Store) SetCapacity(capacity int) (int, error) {
+	if capacity < 1 {
+		return 0, errors.New("capacity must be at least 1")
+	}
+	str.lock()
+	defer str.unlock()
+	evicted := 0
+	for _, ks := range str.dbs {
+		ks.capacity = capacity
+		db := &Store{keyspace: ks, held: true}
+		for len(db.data) > capacity {
+			db.evictLeastUsed()
+			evicted++
+		}
+	}
+	return evicted, nil
+}
+
 func (s *Store) Stats() StoreStats {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.rlock()
+	defer s.runlock()
 
 	return StoreStats{
 		Keys:      len(s.data),
@@ -162,35 +237,74 @@ func (s *Store) Stats() StoreStats {
 		Hits:      s.hits,
 		Misses:    s.misses,
 		Evictions: s.evictions,
+		Expired:   s.expired,
 	}
 }
 
 func (str *Store) Keys() []string {
-	str.mu.RLock()
-	defer str.mu.RUnlock()
+	str.rlock()
+	defer str.runlock()
 	keys := make([]string, 0, len(str.data))
-	for key := range str.data {
+	for key, node := range str.data {
+		if !node.isGone() {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// KeysMatching returns the keys that match the glob pattern (see
+// protocol.Match), skipping expired ones.
+func (str *Store) KeysMatching(pattern string) []string {
+	str.rlock()
+	defer str.runlock()
+	var keys []string
+	for key, node := range str.data {
+		if node.isGone() || !protocol.Match(pattern, key) {
+			continue
+		}
 		keys = append(keys, key)
 	}
 	return keys
 }
 
+// DeleteMatching removes every key that matches the glob pattern and
+// returns how many were removed.
+func (str *Store) DeleteMatching(pattern string) int {
+	str.lock()
+	defer str.unlock()
+	var matched []string
+	for key := range str.data {
+		if protocol.Match(pattern, key) {
+			matched = append(matched, key)
+		}
+	}
+	// Expired keys match too, but only count as expired, not deleted.
+	deleted := 0
+	for _, key := range matched {
+		if str.deleteInternal(key) == nil {
+			deleted++
+		}
+	}
+	return deleted
+}
+
 func (str *Store) PrintList() {
-	str.mu.RLock()
-	defer str.mu.RUnlock()
+	str.rlock()
+	defer str.runlock()
 	str.lru.PrintList()
 }
 
 func (str *Store) Exists(key string) bool {
-	str.mu.RLock()
-	defer str.mu.RUnlock()
-	_, ok := str.data[key]
-	return ok
+	str.rlock()
+	defer str.runlock()
+	node, ok := str.data[key]
+	return ok && !node.isGone()
 }
-func (str *Store) Clear() {
-	str.mu.Lock()
-	defer str.mu.Unlock()
-	str.data = make(map[string]*Node)
-	str.lru = NewLru()
 
+// Clear removes every key of this database (FLUSHDB).
+func (str *Store) Clear() {
+	str.lock()
+	defer str.unlock()
+	str.reset()
 }
@@ -38,80 +38,112 @@ func (st *Store) SetWithTTL(key, value string, ttl time.Duration) error {
 	if ttl == 0 {
 		return errors.New("TTL must be greater than 0")
 	}
-	st.mu.Lock()
-	defer st.mu.Unlock()
+	st.lock()
+	defer st.unlock()
 	expiresAt := time.Now().Add(ttl)
 	// check if node exists
-	if node, exists := st.data[key]; exists {
-		node.value = value
+	if node := st.lookup(key); node != nil {
+		if _, isString := node.value.(stringValue); !isString {
+			return ErrWrongType
+		}
+		node.value = stringValue(value)
 		node.expireAt = &expiresAt
+		node.version = st.nextVersion()
 		st.lru.MoveToHead(node)
+		st.invalidate(key)
+		st.notify(NotifyString, "set", key)
+		st.notify(NotifyGeneric, "expire", key)
 		return nil
 
 	}
 	if len(st.data) >= st.capacity {
-		keyToRemove := st.lru.Tail.key
-		st.lru.RemoveLeastUsed()
-		delete(st.data, keyToRemove)
+		st.evictLeastUsed()
 	}
 	node := &Node{
 		key:      key,
-		value:    value,
+		value:    stringValue(value),
 		expireAt: &expiresAt,
+		version:  st.nextVersion(),
 	}
-	st.data[key] = node
-	st.lru.MoveToHead(node)
+	st.insertNode(node)
+	st.lru.AddToHead(node)
+	st.invalidate(key)
+	st.notify(NotifyString, "set", key)
+	st.notify(NotifyGeneric, "expire", key)
 	return nil
 
 }
 
 // Update key expiry
 func (st *Store) SetExpiry(key string, ttl time.Duration) error {
-	st.mu.Lock()
-	defer st.mu.Unlock()
-	node, exists := st.data[key]
-	if !exists {
+	st.lock()
+	defer st.unlock()
+	node := st.lookup(key)
+	if node == nil {
 		return ErrKeyNotFound
 	}
 	if ttl <= 0 {
 		node.expireAt = nil
+		st.notify(NotifyGeneric, "persist", key)
 	} else {
 		expiresAt := time.Now().Add(ttl)
 		node.expireAt = &expiresAt
+		st.notify(NotifyGeneric, "expire", key)
 	}
+	node.version = st.nextVersion()
+	st.invalidate(key)
 	return nil
 }
 
 // StartTTLCleaner starts background goroutine to clean expired keys
 func (st *Store) StartTTLCleaner(interval time.Duration) {
-	ticker := time.NewTicker(interval)
-	go func() {
-		for range ticker.C {
-			st.cleanExpiredKeys()
-		}
-	}()
+	st.SetTTLCleanerInterval(interval)
+}
+
+// SetTTLCleanerInterval changes how often expired keys are swept, starting
+// the cleaner if needed. An interval <= 0 pauses it; expired keys are then
+// only removed when they are accessed.
+func (st *Store) SetTTLCleanerInterval(interval time.Duration) {
+	st.ttlCleaner.setInterval(interval)
 }
 
-// clean Expired Keys
+// TTLCleanerInterval returns the cleaner interval, 0 when it is not running.
+func (st *Store) TTLCleanerInterval() time.Duration {
+	return st.ttlCleaner.getInterval()
+}
+
+// clean Expired Keys, and expired hash fields, of every database
 func (st *Store) cleanExpiredKeys() {
-	st.mu.Lock()
-	defer st.mu.Unlock()
-	node := st.lru.Head
-	for node != nil {
-		next := node.next // save next before potential removal
-		if node.isExpired() {
-			st.lru.RemoveNode(node)
-			delete(st.data, node.key)
+	st.lock()
+	defer st.unlock()
+	for _, ks := range st.dbs {
+		db := &Store{keyspace: ks, held: true}
+		node := db.lru.Head
+		for node != nil {
+			next := node.next // save next before potential removal
+			if node.isExpired() {
+				db.expireNode(node)
+			} else if h, ok := node.value.(*hash); ok {
+				db.expireFields(node, h)
+			}
+			node = next
 		}
-		node = next
 	}
 }
 
+// expireNode removes a key whose TTL has passed. Callers hold mu.
+func (st *Store) expireNode(node *Node) {
+	st.removeNode(node)
+	st.expired++
+	st.invalidate(node.key)
+	st.notify(NotifyExpired, "expired", node.key)
+}
+
 func (st *Store) GetTTL(key string) (time.Duration, error) {
-	st.mu.Lock()
-	defer st.mu.Unlock()
-	node, exists := st.data[key]
-	if !exists {
+	st.lock()
+	defer st.unlock()
+	node := st.lookup(key)
+	if node == nil {
 		return 0, ErrKeyNotFound
 	}
 	return node.remainingTTL(), nil
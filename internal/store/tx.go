@@ -0,0 +1,60 @@
+package store
+
+// lock helpers: transaction views skip locking because the goroutine that
+// created them already holds mu for the whole transaction.
+
+func (str *Store) lock() {
+	if !str.held {
+		str.mu.Lock()
+	}
+}
+
+func (str *Store) unlock() {
+	if !str.held {
+		str.mu.Unlock()
+	}
+}
+
+func (str *Store) rlock() {
+	if !str.held {
+		str.mu.RLock()
+	}
+}
+
+func (str *Store) runlock() {
+	if !str.held {
+		str.mu.RUnlock()
+	}
+}
+
+// Atomic runs fn while holding the store's write lock, so everything fn
+// does is one critical section (this is how EXEC runs a transaction).
+// fn receives a view of the store whose methods do not lock; the view must
+// not be used once fn has returned.
+func (str *Store) Atomic(fn func(tx *Store)) {
+	str.lock()
+	defer str.unlock()
+	fn(&Store{keyspace: str.keyspace, held: true})
+}
+
+// Version returns the modification version of key. Every write to a key
+// gives it a new, larger version, so comparing versions tells whether a
+// key changed (see WATCH). A missing key has the version of the last
+// removal in its database: creating and deleting it moves that on too.
+func (str *Store) Version(key string) uint64 {
+	str.rlock()
+	defer str.runlock()
+	node, ok := str.data[key]
+	if !ok || node.isGone() {
+		return str.removed
+	}
+	return node.version
+}
+
+// nextVersion advances the keyspace clock and counts the change for
+// persistence. Callers must hold mu.
+func (str *Store) nextVersion() uint64 {
+	str.clock++
+	str.dirty++
+	return str.clock
+}
@@ -0,0 +1,73 @@
+package store
+
+import "errors"
+
+// ErrWrongType is returned when a command meant for one value type is used
+// on a key holding another, e.g. GET on a list.
+var ErrWrongType = errors.New("Operation against a key holding the wrong kind of value")
+
+// value is what a key holds. Each type reports the name TYPE and SCAN TYPE
+// use for it and roughly how many bytes it takes, for INFO.
+type value interface {
+	typeName() string
+	memory() int64
+}
+
+// stringValue is the value set by SET and SETEX.
+type stringValue string
+
+func (v stringValue) typeName() string { return "string" }
+func (v stringValue) memory() int64    { return int64(len(v)) }
+
+// lookup returns the node of key, or nil if it does not exist. An expired
+// key is removed on the way, as if it had already been swept, and so are
+// the expired fields of a hash, with the hash itself once none is left.
+// Callers hold mu.
+func (str *Store) lookup(key string) *Node {
+	node, ok := str.data[key]
+	if !ok {
+		return nil
+	}
+	if node.isExpired() {
+		str.expireNode(node)
+		return nil
+	}
+	if h, ok := node.value.(*hash); ok && !str.expireFields(node, h) {
+		return nil
+	}
+	return node
+}
+
+// isGone reports whether node no longer exists for readers under the read
+// lock, which cannot remove it: its TTL has passed, or it is a hash whose
+// fields have all expired.
+func (n *Node) isGone() bool {
+	if n.isExpired() {
+		return true
+	}
+	h, ok := n.value.(*hash)
+	return ok && h.allExpired()
+}
+
+// modified records a change to node's value: the key gets a new version
+// and becomes the most recently used one, its trackers are invalidated and
+// event is emitted in class. Callers hold mu.
+func (str *Store) modified(node *Node, class NotifyFlags, event string) {
+	node.version = str.nextVersion()
+	str.lru.MoveToHead(node)
+	str.invalidate(node.key)
+	str.notify(class, event, node.key)
+}
+
+// insertValue adds key with v as a new most recently used key, evicting
+// the least recently used one first if the database is full. Callers hold
+// mu and have checked that key does not exist.
+func (str *Store) insertValue(key string, v value) *Node {
+	if len(str.data) >= str.capacity {
+		str.evictLeastUsed()
+	}
+	node := &Node{key: key, value: v}
+	str.insertNode(node)
+	str.lru.AddToHead(node)
+	return node
+}
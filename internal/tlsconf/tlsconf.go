@@ -0,0 +1,187 @@
+package tlsconf
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ClientAuth says whether clients must present a certificate signed by the
+// configured CA (mutual TLS).
+type ClientAuth int
+
+const (
+	ClientAuthNo       ClientAuth = iota // never ask for a client certificate
+	ClientAuthOptional                   // verify a certificate if one is sent
+	ClientAuthRequired                   // refuse clients without a valid certificate
+)
+
+// ParseClientAuth converts "no", "optional" or "yes" into a ClientAuth.
+func ParseClientAuth(s string) (ClientAuth, error) {
+	switch strings.ToLower(s) {
+	case "no":
+		return ClientAuthNo, nil
+	case "optional":
+		return ClientAuthOptional, nil
+	case "yes":
+		return ClientAuthRequired, nil
+	}
+	return ClientAuthNo, fmt.Errorf("invalid client auth %q: must be no, optional or yes", s)
+}
+
+// Options locates the PEM files for a TLS listener.
+type Options struct {
+	CertFile   string
+	KeyFile    string
+	CAFile     string // CA bundle used to verify client certificates
+	ClientAuth ClientAuth
+}
+
+// Reloader owns the certificate, key and client CA of a TLS listener and
+// swaps them in place when the files change, so a renewed certificate is
+// picked up without restarting the server. Connections that are already
+// established keep the certificate they were accepted with.
+type Reloader struct {
+	opts Options
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	pool    *x509.CertPool
+	modTime time.Time // newest modification time of the loaded files
+}
+
+// New loads the files described by opts.
+func New(opts Options) (*Reloader, error) {
+	if opts.CertFile == "" || opts.KeyFile == "" {
+		return nil, errors.New("tls: both a certificate and a key file are required")
+	}
+	if opts.ClientAuth != ClientAuthNo && opts.CAFile == "" {
+		return nil, errors.New("tls: client authentication needs a CA file")
+	}
+	r := &Reloader{opts: opts}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload rereads the certificate, key and CA files. On error the
+// previously loaded ones stay in use.
+func (r *Reloader) Reload() error {
+	modTime, err := r.filesModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+	if err != nil {
+		return fmt.Errorf("tls: load key pair: %w", err)
+	}
+	var pool *x509.CertPool
+	if r.opts.CAFile != "" {
+		pem, err := os.ReadFile(r.opts.CAFile)
+		if err != nil {
+			return fmt.Errorf("tls: read CA file: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("tls: no certificates found in %s", r.opts.CAFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.pool = pool
+	r.modTime = modTime
+	r.mu.Unlock()
+	return nil
+}
+
+// Changed reports whether any of the files was modified since the last
+// successful load.
+func (r *Reloader) Changed() bool {
+	modTime, err := r.filesModTime()
+	if err != nil {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return modTime.After(r.modTime)
+}
+
+// Watch checks the files every interval and reloads them when they change.
+// Call the returned function to stop watching.
+func (r *Reloader) Watch(interval time.Duration, onError func(error)) func() {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if !r.Changed() {
+					continue
+				}
+				if err := r.Reload(); err != nil && onError != nil {
+					onError(err)
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// Certificate returns the certificate currently served.
+func (r *Reloader) Certificate() *tls.Certificate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert
+}
+
+// Config returns a server tls.Config that always uses the most recently
+// loaded certificate and client CA.
+func (r *Reloader) Config() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*r.cert},
+				ClientCAs:    r.pool,
+			}
+			switch r.opts.ClientAuth {
+			case ClientAuthOptional:
+				cfg.ClientAuth = tls.VerifyClientCertIfGiven
+			case ClientAuthRequired:
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+			return cfg, nil
+		},
+	}
+}
+
+func (r *Reloader) filesModTime() (time.Time, error) {
+	var newest time.Time
+	for _, path := range []string{r.opts.CertFile, r.opts.KeyFile, r.opts.CAFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("tls: %w", err)
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+	}
+	return newest, nil
+}
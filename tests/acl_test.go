@@ -0,0 +1,276 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"memstash/internal/acl"
+	"memstash/internal/server"
+	"memstash/internal/store"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestACLDefaultUserIsOpen(t *testing.T) {
+	users := acl.New()
+	u := users.DefaultUser()
+	if u == nil {
+		t.Fatal("Expected default user to be usable without a password")
+	}
+	if !u.CanRun("CLEAR", []string{"write", "dangerous"}) || !u.CanAccessKey("anything") {
+		t.Error("Expected default user to be allowed everything")
+	}
+}
+
+func TestACLRequirePass(t *testing.T) {
+	users := acl.New()
+	users.SetRequirePass("secret")
+
+	if users.DefaultUser() != nil {
+		t.Error("Expected default user to require a password")
+	}
+	if _, err := users.Authenticate("default", "wrong"); err != acl.ErrWrongPass {
+		t.Errorf("Expected ErrWrongPass, got %v", err)
+	}
+	if _, err := users.Authenticate("default", "secret"); err != nil {
+		t.Errorf("Expected successful auth, got %v", err)
+	}
+}
+
+func TestACLRulesApplyInOrder(t *testing.T) {
+	users := acl.New()
+	err := users.SetUser("reader", "on", ">pw", "~cache:*", "+@read", "-keys", "+set")
+	if err != nil {
+		t.Fatalf("SetUser: %v", err)
+	}
+	u, err := users.Authenticate("reader", "pw")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+
+	if !u.CanRun("GET", []string{"read"}) {
+		t.Error("Expected +@read to allow GET")
+	}
+	if u.CanRun("KEYS", []string{"read", "dangerous"}) {
+		t.Error("Expected -keys after +@read to deny KEYS")
+	}
+	if !u.CanRun("SET", []string{"write"}) {
+		t.Error("Expected +set to allow SET")
+	}
+	if u.CanRun("DEL", []string{"write"}) {
+		t.Error("Expected DEL to be denied")
+	}
+	if !u.CanAccessKey("cache:1") || u.CanAccessKey("session:1") {
+		t.Error("Expected only cache:* keys to be accessible")
+	}
+	if u.Describe() != "user reader on #"+sha256Hex("pw")+" ~cache:* +@read -keys +set" {
+		t.Errorf("Unexpected Describe(): %q", u.Describe())
+	}
+}
+
+func TestACLSetUserIsAtomic(t *testing.T) {
+	users := acl.New()
+	users.SetUser("bob", "on", "nopass", "+@all")
+	if err := users.SetUser("bob", "off", "+@nosuchcategory"); err == nil {
+		t.Fatal("Expected error for unknown category")
+	}
+	if !users.User("bob").Enabled() {
+		t.Error("Expected failed SetUser to leave the user unchanged")
+	}
+	if _, err := users.DelUser("default"); err == nil {
+		t.Error("Expected the default user to be protected")
+	}
+}
+
+func TestACLLoadAndSaveFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.acl")
+	content := "# users\nuser default on >adminpw ~* +@all\nuser app on >apppw ~app:* +@read +@write -@dangerous\n"
+	os.WriteFile(path, []byte(content), 0600)
+
+	users := acl.New()
+	if err := users.LoadFile(path); err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	if users.DefaultUser() != nil {
+		t.Error("Expected default user from file to require a password")
+	}
+	u, err := users.Authenticate("app", "apppw")
+	if err != nil {
+		t.Fatalf("Authenticate(app): %v", err)
+	}
+	if u.CanRun("CLEAR", []string{"write", "dangerous"}) {
+		t.Error("Expected -@dangerous to deny CLEAR")
+	}
+
+	users.SetUser("extra", "on", "nopass")
+	if err := users.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	reloaded := acl.New()
+	if err := reloaded.LoadFile(path); err != nil {
+		t.Fatalf("LoadFile after Save: %v", err)
+	}
+	if got := strings.Join(reloaded.Users(), ","); got != "app,default,extra" {
+		t.Errorf("Expected users app,default,extra after reload, got %s", got)
+	}
+
+	os.WriteFile(path, []byte("user broken on +@bogus\n"), 0600)
+	if err := users.Reload(); err == nil {
+		t.Error("Expected Reload to fail on an invalid file")
+	}
+}
+
+// helper: start a TCP server whose default user needs a password
+func startAuthServer(t *testing.T) (*server.Server, *acl.ACL, string) {
+	t.Helper()
+	users := acl.New()
+	users.SetRequirePass("secret")
+	srv := server.NewServer(store.NewStore(10), 0)
+	srv.SetACL(users)
+	<-srv.StartAndReady()
+	return srv, users, srv.Addr().String()
+}
+
+func TestServerAuth(t *testing.T) {
+	srv, _, addr := startAuthServer(t)
+	defer srv.Stop()
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+
+	if resp := sendCommand(conn, reader, "GET k"); !strings.HasPrefix(resp, "-NOAUTH") {
+		t.Errorf("GET before AUTH: expected -NOAUTH, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "AUTH wrong"); !strings.HasPrefix(resp, "-WRONGPASS") {
+		t.Errorf("AUTH wrong: expected -WRONGPASS, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "AUTH secret"); resp != "+OK\r\n" {
+		t.Errorf("AUTH secret: expected +OK\\r\\n, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "GET k"); resp != "$-1\r\n" {
+		t.Errorf("GET after AUTH: expected $-1\\r\\n, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "ACL WHOAMI"); resp != "$7\r\ndefault\r\n" {
+		t.Errorf("ACL WHOAMI: got %q", resp)
+	}
+}
+
+func TestServerHelloAuth(t *testing.T) {
+	srv, _, addr := startAuthServer(t)
+	defer srv.Stop()
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+
+	if resp := sendCommand(conn, reader, "HELLO 3"); !strings.HasPrefix(resp, "-NOAUTH") {
+		t.Errorf("HELLO without AUTH: expected -NOAUTH, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "HELLO 3 AUTH default secret"); !strings.HasPrefix(resp, "%") {
+		t.Errorf("HELLO 3 AUTH: expected map reply, got %q", resp)
+	}
+}
+
+func TestServerACLPermissions(t *testing.T) {
+	srv, _, addr := startAuthServer(t)
+	defer srv.Stop()
+
+	admin, adminReader := dialServer(t, addr)
+	defer admin.Close()
+	sendCommand(admin, adminReader, "AUTH secret")
+	resp := sendCommand(admin, adminReader, "ACL SETUSER app on >apppw ~app:* +@read +@write -@dangerous")
+	if resp != "+OK\r\n" {
+		t.Fatalf("ACL SETUSER: expected +OK\\r\\n, got %q", resp)
+	}
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+	if resp := sendCommand(conn, reader, "AUTH app apppw"); resp != "+OK\r\n" {
+		t.Fatalf("AUTH app: expected +OK\\r\\n, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "SET app:1 v"); resp != "+OK\r\n" {
+		t.Errorf("SET app:1: expected +OK\\r\\n, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "SET other:1 v"); !strings.HasPrefix(resp, "-NOPERM No permissions to access a key") {
+		t.Errorf("SET other:1: expected key NOPERM, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "CLEAR"); !strings.HasPrefix(resp, "-NOPERM User app has no permissions to run the 'clear' command") {
+		t.Errorf("CLEAR: expected command NOPERM, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "ACL LIST"); !strings.HasPrefix(resp, "-NOPERM") {
+		t.Errorf("ACL LIST as app: expected NOPERM, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "ACL WHOAMI"); resp != "$3\r\napp\r\n" {
+		t.Errorf("ACL WHOAMI: got %q", resp)
+	}
+
+	// Rule changes apply to connections that are already authenticated
+	sendCommand(admin, adminReader, "ACL SETUSER app -set")
+	if resp := sendCommand(conn, reader, "SET app:1 v"); !strings.HasPrefix(resp, "-NOPERM") {
+		t.Errorf("SET after -set: expected NOPERM, got %q", resp)
+	}
+
+	resp = sendCommand(admin, adminReader, "ACL LIST")
+	if !strings.Contains(resp, "user app on #") || !strings.Contains(resp, "user default on #") {
+		t.Errorf("ACL LIST: unexpected reply %q", resp)
+	}
+}
+
+func TestHTTPAuth(t *testing.T) {
+	users := acl.New()
+	users.SetRequirePass("secret")
+	users.SetUser("reader", "on", ">readpw", "~*", "+@read")
+
+	s := store.NewStore(10)
+	tcp := server.NewServer(s, 0)
+	tcp.SetACL(users)
+	h := server.NewHTTPServer(s, 0)
+	h.Attach(tcp)
+	<-h.StartAndReady()
+	defer h.Stop()
+	baseURL := "http://" + h.Addr().String()
+
+	// No credentials
+	resp, err := http.Get(baseURL + "/keys")
+	if err != nil {
+		t.Fatalf("GET /keys failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("GET /keys without auth: expected 401, got %d", resp.StatusCode)
+	}
+
+	do := func(method, path, user, pass string, body string) int {
+		req, _ := http.NewRequest(method, baseURL+path, bytes.NewBufferString(body))
+		req.SetBasicAuth(user, pass)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := do("GET", "/keys", "default", "wrong", ""); code != http.StatusUnauthorized {
+		t.Errorf("Wrong password: expected 401, got %d", code)
+	}
+	if code := do("POST", "/keys/k", "default", "secret", `{"value":"v"}`); code != http.StatusCreated {
+		t.Errorf("POST as default: expected 201, got %d", code)
+	}
+	if code := do("GET", "/keys/k", "reader", "readpw", ""); code != http.StatusOK {
+		t.Errorf("GET as reader: expected 200, got %d", code)
+	}
+	if code := do("POST", "/keys/k", "reader", "readpw", `{"value":"v"}`); code != http.StatusForbidden {
+		t.Errorf("POST as reader: expected 403, got %d", code)
+	}
+	if code := do("POST", "/load", "reader", "readpw", ""); code != http.StatusForbidden {
+		t.Errorf("POST /load as reader: expected 403, got %d", code)
+	}
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
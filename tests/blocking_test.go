@@ -0,0 +1,306 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"memstash/internal/store"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStoreBlockingPopImmediate(t *testing.T) {
+	s := store.NewStore(10)
+	s.RPush("b", "1", "2")
+
+	// The first non-empty list wins
+	key, v, err := s.BLPop(context.Background(), time.Second, "a", "b")
+	if err != nil || key != "b" || v != "1" {
+		t.Errorf("BLPop: got %q, %q, %v", key, v, err)
+	}
+	if key, v, _ := s.BRPop(context.Background(), time.Second, "b"); key != "b" || v != "2" {
+		t.Errorf("BRPop: got %q, %q", key, v)
+	}
+	if s.Exists("b") {
+		t.Error("List emptied by blocking pops still exists")
+	}
+
+	s.Set("str", "v")
+	if _, _, err := s.BLPop(context.Background(), time.Second, "missing", "str"); !errors.Is(err, store.ErrWrongType) {
+		t.Errorf("BLPop on a string: expected ErrWrongType, got %v", err)
+	}
+	if _, _, err := s.BLPop(context.Background(), 20*time.Millisecond, "missing"); !errors.Is(err, store.ErrTimeout) {
+		t.Errorf("BLPop on a missing key: expected ErrTimeout, got %v", err)
+	}
+}
+
+func TestStoreBlockingPopWaitsInOrder(t *testing.T) {
+	s := store.NewStore(10)
+	type result struct{ name, v string }
+	results := make(chan result, 3)
+	for i, name := range []string{"first", "second", "third"} {
+		go func() {
+			_, v, err := s.BLPop(context.Background(), 0, "queue")
+			if err != nil {
+				t.Errorf("%s BLPop: %v", name, err)
+			}
+			results <- result{name, v}
+		}()
+		// Let each waiter queue up before the next one
+		waitBlocked(t, s, i+1)
+	}
+
+	// One push of two elements serves the two oldest waiters
+	if n, _ := s.RPush("queue", "a", "b"); n != 2 {
+		t.Errorf("RPush: expected the length before serving waiters, got %d", n)
+	}
+	got := map[string]string{}
+	for range 2 {
+		r := <-results
+		got[r.name] = r.v
+	}
+	if got["first"] != "a" || got["second"] != "b" {
+		t.Errorf("Expected first to get a and second b, got %v", got)
+	}
+	if s.Exists("queue") {
+		t.Error("Expected the waiters to drain the list")
+	}
+	s.LPush("queue", "c")
+	if got := <-results; got != (result{"third", "c"}) {
+		t.Errorf("Expected the third waiter to get c, got %v", got)
+	}
+	if blockedCount(s) != 0 {
+		t.Errorf("Expected no blocked callers, got %d", blockedCount(s))
+	}
+}
+
+func TestStoreBlockingPopTimeoutAndCancel(t *testing.T) {
+	s := store.NewStore(10)
+	start := time.Now()
+	if _, _, err := s.BRPop(context.Background(), 50*time.Millisecond, "q"); !errors.Is(err, store.ErrTimeout) {
+		t.Errorf("Expected ErrTimeout, got %v", err)
+	}
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("BRPop returned after %v, before its timeout", d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, _, err := s.BLPop(ctx, 0, "q")
+		done <- err
+	}()
+	waitBlocked(t, s, 1)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	// A cancelled waiter takes nothing
+	s.RPush("q", "kept")
+	if n, _ := s.LLen("q"); n != 1 || blockedCount(s) != 0 {
+		t.Errorf("Expected the element to stay and no waiters, got %d and %d", n, blockedCount(s))
+	}
+}
+
+func TestStoreBLMove(t *testing.T) {
+	s := store.NewStore(10)
+	s.RPush("src", "1", "2")
+	if v, err := s.BLMove(context.Background(), "src", "dst", false, true, time.Second); err != nil || v != "2" {
+		t.Fatalf("BLMove: got %q, %v", v, err)
+	}
+	if dst, _ := s.LRange("dst", 0, -1); strings.Join(dst, ",") != "2" {
+		t.Errorf("dst after BLMove: got %v", dst)
+	}
+
+	// A waiter on dst is served by the element BLMove pushes there
+	done := make(chan string)
+	go func() {
+		_, v, _ := s.BLPop(context.Background(), 0, "other")
+		done <- v
+	}()
+	waitBlocked(t, s, 1)
+	go s.BLMove(context.Background(), "empty", "other", true, true, 0)
+	waitBlocked(t, s, 2)
+	s.RPush("empty", "moved")
+	if v := <-done; v != "moved" {
+		t.Errorf("Expected the BLPop on other to get moved, got %q", v)
+	}
+
+	// The destination type is checked before anything is popped
+	s.Set("str", "v")
+	if _, err := s.BLMove(context.Background(), "src", "str", true, true, time.Second); !errors.Is(err, store.ErrWrongType) {
+		t.Errorf("BLMove to a string: expected ErrWrongType, got %v", err)
+	}
+	if n, _ := s.LLen("src"); n != 1 {
+		t.Errorf("Expected src to keep its element, got length %d", n)
+	}
+}
+
+func TestStoreBlockingPopServedByMove(t *testing.T) {
+	s := store.NewStore(10)
+	done := make(chan string)
+	go func() {
+		_, v, _ := s.BLPop(context.Background(), 0, "q")
+		done <- v
+	}()
+	waitBlocked(t, s, 1)
+	db1 := s.DB(1)
+	db1.RPush("q", "from db1")
+	db1.Move("q", 0)
+	if v := <-done; v != "from db1" {
+		t.Errorf("Expected the moved list to serve the waiter, got %q", v)
+	}
+}
+
+// blockedCount returns how many callers wait in a blocking pop.
+func blockedCount(s *store.Store) int {
+	return s.Info().Blocked
+}
+
+// waitBlocked waits until n callers wait in a blocking pop.
+func waitBlocked(t *testing.T, s *store.Store, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for blockedCount(s) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d blocked callers, got %d", n, blockedCount(s))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestServerBlockingPop(t *testing.T) {
+	s := store.NewStore(10)
+	srv := startServerWithStore(t, s)
+	defer srv.Stop()
+	addr := srv.Addr().String()
+
+	consumer, consumerReader := dialServer(t, addr)
+	defer consumer.Close()
+	producer, producerReader := dialServer(t, addr)
+	defer producer.Close()
+	consumer.SetReadDeadline(time.Now().Add(5 * time.Second))
+	producer.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	for _, tc := range []struct{ cmd, expected string }{
+		{"BLPOP q 0.05", "*-1\r\n"},
+		{"BLPOP q -1", "-ERR timeout is negative\r\n"},
+		{"BLPOP q soon", "-ERR timeout is not a float or out of range\r\n"},
+		{"BLMOVE q d UP LEFT 0", "-ERR syntax error\r\n"},
+		{"SET str v", "+OK\r\n"},
+		{"BRPOP str 1", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+	} {
+		if resp := sendCommand(consumer, consumerReader, tc.cmd); resp != tc.expected {
+			t.Errorf("%s: expected %q, got %q", tc.cmd, tc.expected, resp)
+		}
+	}
+
+	// A push from another connection wakes the consumer
+	sendCommand(consumer, consumerReader, "CLIENT SETNAME consumer")
+	consumer.Write([]byte("BRPOP q1 q2 0\r\n"))
+	waitBlocked(t, s, 1)
+	if resp := sendCommand(producer, producerReader, "INFO clients"); !strings.Contains(resp, "blocked_clients:1") {
+		t.Errorf("INFO clients: expected a blocked client, got %q", resp)
+	}
+	if resp := sendCommand(producer, producerReader, "LPUSH q2 job"); resp != ":1\r\n" {
+		t.Errorf("LPUSH: got %q", resp)
+	}
+	if resp := readResponse(consumerReader); resp != encodeCommand("q2", "job") {
+		t.Errorf("BRPOP: got %q", resp)
+	}
+
+	consumer.Write([]byte("BLMOVE q1 done RIGHT LEFT 0\r\n"))
+	waitBlocked(t, s, 1)
+	sendCommand(producer, producerReader, "RPUSH q1 next")
+	if resp := readResponse(consumerReader); resp != "$4\r\nnext\r\n" {
+		t.Errorf("BLMOVE: got %q", resp)
+	}
+	if resp := sendCommand(producer, producerReader, "LRANGE done 0 -1"); resp != encodeCommand("next") {
+		t.Errorf("LRANGE done: got %q", resp)
+	}
+
+	// Inside MULTI blocking pops do not wait
+	sendCommand(consumer, consumerReader, "MULTI")
+	sendCommand(consumer, consumerReader, "BLPOP q 0")
+	if resp := sendCommand(consumer, consumerReader, "EXEC"); resp != "*1\r\n*-1\r\n" {
+		t.Errorf("EXEC with BLPOP: got %q", resp)
+	}
+	// The connection still works after blocking
+	if resp := sendCommand(consumer, consumerReader, "PING"); resp != "+PONG\r\n" {
+		t.Errorf("PING: got %q", resp)
+	}
+}
+
+func TestServerBlockingPopDisconnect(t *testing.T) {
+	s := store.NewStore(10)
+	srv := startServerWithStore(t, s)
+	defer srv.Stop()
+	addr := srv.Addr().String()
+
+	consumer, _ := dialServer(t, addr)
+	consumer.Write([]byte("BLPOP q 0\r\n"))
+	waitBlocked(t, s, 1)
+	consumer.Close()
+
+	// The waiter goes away with its connection and takes nothing
+	deadline := time.Now().Add(2 * time.Second)
+	for blockedCount(s) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if blockedCount(s) != 0 {
+		t.Fatal("Disconnected client is still blocked")
+	}
+	s.RPush("q", "kept")
+	if n, _ := s.LLen("q"); n != 1 {
+		t.Errorf("Expected the element to stay, got length %d", n)
+	}
+}
+
+func TestServerBlockingWaitNotTimed(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	// The wait is well over the default 10ms threshold
+	if resp := sendCommand(conn, reader, "BLPOP nolist 0.2"); resp != "*-1\r\n" {
+		t.Fatalf("BLPOP: expected a timeout, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "SLOWLOG LEN"); resp != ":0\r\n" {
+		t.Errorf("SLOWLOG LEN after a timed-out BLPOP: expected :0, got %q", resp)
+	}
+	resp := sendCommand(conn, reader, "INFO commandstats")
+	m := regexp.MustCompile(`cmdstat_blpop:calls=1,usec=(\d+)`).FindStringSubmatch(resp)
+	if m == nil {
+		t.Fatalf("INFO commandstats: no blpop entry in %q", resp)
+	}
+	if usec, _ := strconv.Atoi(m[1]); usec >= 200000 {
+		t.Errorf("Expected the wait to be left out of commandstats, got %dus", usec)
+	}
+}
+
+func TestServerShutdownWakesBlockedClients(t *testing.T) {
+	s := store.NewStore(10)
+	srv := startServerWithStore(t, s)
+	defer srv.Stop()
+	addr := srv.Addr().String()
+
+	consumer, reader := dialServer(t, addr)
+	defer consumer.Close()
+	consumer.SetReadDeadline(time.Now().Add(5 * time.Second))
+	consumer.Write([]byte("BLPOP q 0\r\n"))
+	waitBlocked(t, s, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown with a blocked client: %v", err)
+	}
+	if resp := readResponse(reader); resp != "*-1\r\n" {
+		t.Errorf("Blocked client at shutdown: expected a null reply, got %q", resp)
+	}
+}
@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"fmt"
+	"io"
+	"memstash/internal/server"
+	"memstash/internal/store"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServerClientIDAndName(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+
+	if resp := sendCommand(conn, reader, "CLIENT ID"); !strings.HasPrefix(resp, ":") {
+		t.Errorf("CLIENT ID: expected integer, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "CLIENT GETNAME"); resp != "$-1\r\n" {
+		t.Errorf("CLIENT GETNAME before SETNAME: expected $-1\\r\\n, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "CLIENT SETNAME worker-1"); resp != "+OK\r\n" {
+		t.Errorf("CLIENT SETNAME: expected +OK\\r\\n, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "CLIENT GETNAME"); resp != "$8\r\nworker-1\r\n" {
+		t.Errorf("CLIENT GETNAME: got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, `CLIENT SETNAME "bad name"`); !strings.HasPrefix(resp, "-ERR Client names cannot contain spaces") {
+		t.Errorf("CLIENT SETNAME with space: got %q", resp)
+	}
+}
+
+func TestServerClientList(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+
+	conn1, reader1 := dialServer(t, addr)
+	defer conn1.Close()
+	conn2, reader2 := dialServer(t, addr)
+	defer conn2.Close()
+
+	sendCommand(conn1, reader1, "CLIENT SETNAME first")
+	sendCommand(conn2, reader2, "SET k v")
+
+	resp := sendCommand(conn1, reader1, "CLIENT LIST")
+	lines := strings.Split(strings.TrimSpace(strings.SplitN(resp, "\r\n", 2)[1]), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("CLIENT LIST: expected 2 clients, got %q", resp)
+	}
+	byAddr := map[string]string{}
+	for _, line := range lines {
+		for _, field := range strings.Fields(line) {
+			if strings.HasPrefix(field, "addr=") {
+				byAddr[strings.TrimPrefix(field, "addr=")] = line
+			}
+		}
+	}
+	first := byAddr[conn1.LocalAddr().String()]
+	if !strings.Contains(first, " name=first ") || !strings.Contains(first, " cmd=client ") {
+		t.Errorf("CLIENT LIST: unexpected line for first client %q", first)
+	}
+	second := byAddr[conn2.LocalAddr().String()]
+	if !strings.Contains(second, " cmd=set ") || !strings.Contains(second, " user=default ") {
+		t.Errorf("CLIENT LIST: unexpected line for second client %q", second)
+	}
+	if srv.ClientCount() != 2 {
+		t.Errorf("Expected ClientCount 2, got %d", srv.ClientCount())
+	}
+}
+
+func TestServerClientKill(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+
+	admin, adminReader := dialServer(t, addr)
+	defer admin.Close()
+	victim, victimReader := dialServer(t, addr)
+	defer victim.Close()
+
+	id := strings.TrimSpace(strings.TrimPrefix(sendCommand(victim, victimReader, "CLIENT ID"), ":"))
+	if resp := sendCommand(admin, adminReader, "CLIENT KILL ID "+id); resp != ":1\r\n" {
+		t.Errorf("CLIENT KILL ID: expected :1\\r\\n, got %q", resp)
+	}
+	victim.SetReadDeadline(time.Now().Add(2 * time.Second))
+	victim.Write([]byte("PING\r\n"))
+	if _, err := victimReader.ReadString('\n'); err != io.EOF {
+		t.Errorf("Expected killed connection to be closed, got %v", err)
+	}
+
+	// Old form by address
+	other, _ := dialServer(t, addr)
+	defer other.Close()
+	if resp := sendCommand(admin, adminReader, "CLIENT KILL "+other.LocalAddr().String()); resp != "+OK\r\n" {
+		t.Errorf("CLIENT KILL addr: expected +OK\\r\\n, got %q", resp)
+	}
+	if resp := sendCommand(admin, adminReader, "CLIENT KILL 1.2.3.4:5"); resp != "-ERR No such client\r\n" {
+		t.Errorf("CLIENT KILL unknown addr: got %q", resp)
+	}
+
+	// By user, skipping the caller by default
+	if resp := sendCommand(admin, adminReader, "CLIENT KILL USER default"); resp != ":0\r\n" {
+		t.Errorf("CLIENT KILL USER: expected :0\\r\\n (caller skipped), got %q", resp)
+	}
+	// SKIPME no kills the caller after the reply is sent
+	if resp := sendCommand(admin, adminReader, "CLIENT KILL USER default SKIPME no"); resp != ":1\r\n" {
+		t.Errorf("CLIENT KILL SKIPME no: expected :1\\r\\n, got %q", resp)
+	}
+	admin.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := adminReader.ReadString('\n'); err != io.EOF {
+		t.Errorf("Expected caller connection to be closed, got %v", err)
+	}
+}
+
+func TestHTTPListClients(t *testing.T) {
+	s := store.NewStore(10)
+	tcp := server.NewServer(s, 0)
+	<-tcp.StartAndReady()
+	defer tcp.Stop()
+	h := server.NewHTTPServer(s, 0)
+	h.Attach(tcp)
+	<-h.StartAndReady()
+	defer h.Stop()
+
+	conn, reader := dialServer(t, tcp.Addr().String())
+	defer conn.Close()
+	sendCommand(conn, reader, "CLIENT SETNAME api")
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/clients", h.Addr().String()))
+	if err != nil {
+		t.Fatalf("GET /clients failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+	body := decodeJSON(t, resp.Body)
+	if body["count"] != float64(1) {
+		t.Fatalf("Expected count 1, got %v", body["count"])
+	}
+	client := body["clients"].([]any)[0].(map[string]any)
+	if client["name"] != "api" || client["addr"] != conn.LocalAddr().String() || client["cmd"] != "client" {
+		t.Errorf("Unexpected client entry: %v", client)
+	}
+}
@@ -0,0 +1,212 @@
+package tests
+
+import (
+	"memstash/internal/acl"
+	"memstash/internal/cli"
+	"memstash/internal/server"
+	"memstash/internal/store"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServerCommandInfo(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+
+	resp := sendCommand(conn, reader, "COMMAND COUNT")
+	count, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(resp, ":")))
+	if err != nil || count < 40 {
+		t.Fatalf("COMMAND COUNT: got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "COMMAND"); !strings.HasPrefix(resp, "*"+strconv.Itoa(count)+"\r\n") {
+		t.Errorf("COMMAND: expected %d entries, got %q", count, resp[:min(len(resp), 20)])
+	}
+
+	want := "*2\r\n" +
+		"*7\r\n$3\r\nget\r\n:2\r\n*2\r\n+readonly\r\n+fast\r\n:1\r\n:1\r\n:1\r\n*1\r\n+@read\r\n" +
+		"*-1\r\n"
+	if resp := sendCommand(conn, reader, "COMMAND INFO get nosuch"); resp != want {
+		t.Errorf("COMMAND INFO get nosuch:\n got %q\nwant %q", resp, want)
+	}
+	resp = sendCommand(conn, reader, "COMMAND INFO watch")
+	if !strings.Contains(resp, ":-2\r\n") || !strings.Contains(resp, ":1\r\n:-1\r\n:1\r\n") {
+		t.Errorf("COMMAND INFO watch: expected arity -2 and keys 1,-1,1, got %q", resp)
+	}
+
+	resp = sendCommand(conn, reader, "COMMAND DOCS set nosuch")
+	want = "*2\r\n$3\r\nset\r\n*6\r\n" +
+		"$7\r\nsummary\r\n$20\r\nSet a key-value pair\r\n" +
+		"$5\r\ngroup\r\n$6\r\nstring\r\n" +
+		"$6\r\nsyntax\r\n$17\r\nSET <key> <value>\r\n"
+	if resp != want {
+		t.Errorf("COMMAND DOCS set nosuch:\n got %q\nwant %q", resp, want)
+	}
+	if resp := sendCommand(conn, reader, "COMMAND NOPE"); !strings.HasPrefix(resp, "-ERR unknown subcommand") {
+		t.Errorf("COMMAND NOPE: got %q", resp)
+	}
+}
+
+func TestServerCommandArity(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+
+	for _, cmd := range []string{"GET", "GET a b", "SETEX k 10", "EXPIRE k", "SELECT", "MOVE k", "SAVE now", "PUBLISH ch"} {
+		name := strings.Fields(cmd)[0]
+		want := "-ERR wrong number of arguments for '" + name + "' command\r\n"
+		if resp := sendCommand(conn, reader, cmd); resp != want {
+			t.Errorf("%s: expected %q, got %q", cmd, want, resp)
+		}
+	}
+
+	// A wrong arity aborts a transaction like an unknown command does
+	sendCommand(conn, reader, "MULTI")
+	if resp := sendCommand(conn, reader, "TTL"); !strings.HasPrefix(resp, "-ERR wrong number") {
+		t.Errorf("TTL in MULTI: got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "EXEC"); !strings.HasPrefix(resp, "-EXECABORT") {
+		t.Errorf("EXEC: expected EXECABORT, got %q", resp)
+	}
+}
+
+func TestServerCommandKeyPositionsDriveACL(t *testing.T) {
+	srv, _, addr := startAuthServer(t)
+	defer srv.Stop()
+
+	admin, adminReader := dialServer(t, addr)
+	defer admin.Close()
+	sendCommand(admin, adminReader, "AUTH secret")
+	sendCommand(admin, adminReader, "ACL SETUSER app on >apppw ~app:* +@all")
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+	sendCommand(conn, reader, "AUTH app apppw")
+	if resp := sendCommand(conn, reader, "WATCH app:1 app:2"); resp != "+OK\r\n" {
+		t.Errorf("WATCH app:1 app:2: got %q", resp)
+	}
+	// Every argument of WATCH is a key, so the last one is checked too
+	if resp := sendCommand(conn, reader, "WATCH app:1 other"); !strings.HasPrefix(resp, "-NOPERM No permissions to access a key") {
+		t.Errorf("WATCH app:1 other: expected key NOPERM, got %q", resp)
+	}
+	// The database index of MOVE is not a key
+	if resp := sendCommand(conn, reader, "MOVE app:1 1"); resp != ":0\r\n" {
+		t.Errorf("MOVE app:1 1: got %q", resp)
+	}
+}
+
+func TestServerHelpListsTheTable(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+
+	help := sendCommand(conn, reader, "HELP")
+	for _, line := range []string{"SAVE ", "COMMAND [COUNT|INFO|DOCS ...]", "SHUTDOWN [NOSAVE|SAVE]", "SETEX <key> <sec> <value>"} {
+		if !strings.Contains(help, "\n  "+line) {
+			t.Errorf("HELP does not list %q", line)
+		}
+	}
+	if strings.Contains(help, "SAVE [file]") || strings.Contains(help, "PRINT") {
+		t.Errorf("HELP lists commands that do not exist: %q", help)
+	}
+}
+
+func TestSessionDispatchesThroughTheTable(t *testing.T) {
+	s := store.NewStoreWithDatabases(10, 4)
+	srv := server.NewServer(s, 0)
+	users := acl.New()
+	users.SetRequirePass("secret")
+	srv.SetACL(users)
+	<-srv.StartAndReady()
+	defer srv.Stop()
+
+	mon, monReader := dialServer(t, srv.Addr().String())
+	defer mon.Close()
+	sendCommand(mon, monReader, "AUTH secret")
+	sendCommand(mon, monReader, "MONITOR")
+
+	// The CLI is trusted: it needs no password
+	session := srv.NewSession("cli")
+	if resp := session.Do([]string{"SET", "greeting", "hello", "world"}); resp != "+OK\r\n" {
+		t.Errorf("SET: got %q", resp)
+	}
+	if resp := session.Do([]string{"get", "greeting"}); resp != "$11\r\nhello world\r\n" {
+		t.Errorf("GET: got %q", resp)
+	}
+	if resp := session.Do([]string{"SELECT", "2"}); resp != "+OK\r\n" || session.DB() != 2 {
+		t.Errorf("SELECT 2: got %q, db %d", resp, session.DB())
+	}
+	if resp := session.Do([]string{"GET", "greeting"}); resp != "$-1\r\n" {
+		t.Errorf("GET in db 2: got %q", resp)
+	}
+
+	// The CLI used to disagree with the server on these
+	if resp := session.Do([]string{"PRINT"}); !strings.HasPrefix(resp, "-ERR unknown command 'PRINT'") {
+		t.Errorf("PRINT: got %q", resp)
+	}
+	if resp := session.Do([]string{"SAVE", "backup.json"}); !strings.HasPrefix(resp, "-ERR wrong number of arguments") {
+		t.Errorf("SAVE backup.json: got %q", resp)
+	}
+	if resp := session.Do([]string{"SUBSCRIBE", "news"}); !strings.HasPrefix(resp, "-ERR 'SUBSCRIBE' needs a network connection") {
+		t.Errorf("SUBSCRIBE: got %q", resp)
+	}
+
+	mon.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, client, args := readMonitorLine(t, monReader)
+	if client != "cli" || args != `"SET" "greeting" "hello" "world"` {
+		t.Errorf("Expected the CLI SET in MONITOR, got %s %s", client, args)
+	}
+
+	if srv.ClientCount() != 1 {
+		t.Errorf("Expected the session to stay out of the client registry, got %d clients", srv.ClientCount())
+	}
+}
+
+func TestCLIFormatReply(t *testing.T) {
+	tests := []struct {
+		reply, want string
+	}{
+		{"+OK\r\n", "OK"},
+		{"-ERR syntax error\r\n", "(error) ERR syntax error"},
+		{":42\r\n", "(integer) 42"},
+		{"$-1\r\n", "(nil)"},
+		{"$5\r\nhello\r\n", `"hello"`},
+		{"$13\r\nkeys:1\r\nx:2\r\n\r\n", "keys:1\nx:2"},
+		{"*0\r\n", "(empty array)"},
+		{"*2\r\n$1\r\na\r\n*2\r\n:1\r\n$1\r\nb\r\n", "1) \"a\"\n2) 1) (integer) 1\n   2) \"b\""},
+	}
+	for _, tt := range tests {
+		if got := cli.FormatReply(tt.reply); got != tt.want {
+			t.Errorf("FormatReply(%q):\n got %q\nwant %q", tt.reply, got, tt.want)
+		}
+	}
+}
+
+func TestHTTPDispatchesThroughTheTable(t *testing.T) {
+	s := store.NewStore(10)
+	tcp := server.NewServer(s, 0)
+	<-tcp.StartAndReady()
+	defer tcp.Stop()
+	h := server.NewHTTPServer(s, 0)
+	h.Attach(tcp)
+	<-h.StartAndReady()
+	defer h.Stop()
+
+	resp, err := http.Get("http://" + h.Addr().String() + "/keys/k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// HTTP requests are accounted like the command they stand for
+	_, fields := infoFields(t, "INFO commandstats", tcp.Addr().String())
+	if !strings.HasPrefix(fields["cmdstat_get"], "calls=1,") {
+		t.Errorf("Expected GET /keys/k in cmdstat_get, got %q", fields["cmdstat_get"])
+	}
+}
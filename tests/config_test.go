@@ -0,0 +1,237 @@
+package tests
+
+import (
+	"errors"
+	"memstash/internal/config"
+	"memstash/internal/store"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStoreSetCapacityEvicts(t *testing.T) {
+	s := store.NewStore(5)
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		s.Set(k, "v")
+	}
+	s.DB(1).Set("x", "v")
+	s.Get("a") // a becomes most recently used
+
+	evicted, err := s.SetCapacity(2)
+	if err != nil || evicted != 3 {
+		t.Fatalf("SetCapacity: expected 3 evictions, got %d, %v", evicted, err)
+	}
+	if !s.Exists("a") || !s.Exists("e") || s.Exists("b") {
+		t.Errorf("Expected a and e to survive, got %v", s.Keys())
+	}
+	if stats := s.DB(1).Stats(); stats.Capacity != 2 || stats.Keys != 1 {
+		t.Errorf("DB 1: expected capacity 2 and 1 key, got %+v", stats)
+	}
+	s.Set("f", "v")
+	if len(s.Keys()) != 2 {
+		t.Errorf("Expected the new capacity to hold, got %v", s.Keys())
+	}
+	if _, err := s.SetCapacity(0); err == nil {
+		t.Error("Expected capacity 0 to be rejected")
+	}
+}
+
+func TestStoreRetuneTTLCleaner(t *testing.T) {
+	s := store.NewStore(10)
+	s.StartTTLCleaner(time.Hour)
+	s.SetWithTTL("k", "v", 10*time.Millisecond)
+
+	s.SetTTLCleanerInterval(10 * time.Millisecond)
+	if s.TTLCleanerInterval() != 10*time.Millisecond {
+		t.Errorf("Expected 10ms, got %v", s.TTLCleanerInterval())
+	}
+	deadline := time.Now().Add(time.Second)
+	for s.Exists("k") && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if s.Exists("k") {
+		t.Error("Expected the retuned cleaner to remove the expired key")
+	}
+
+	s.SetTTLCleanerInterval(0)
+	if s.TTLCleanerInterval() != 0 {
+		t.Errorf("Expected a paused cleaner to report 0, got %v", s.TTLCleanerInterval())
+	}
+}
+
+func TestStoreRetuneAutoSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auto.json")
+	s := store.NewStore(10)
+	s.EnableAutoSave(path, time.Hour)
+	s.Set("k", "v")
+
+	s.SetAutoSaveInterval(10 * time.Millisecond)
+	defer s.SetAutoSaveInterval(0)
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(path); err == nil {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Error("Expected the retuned auto-save to write the snapshot")
+}
+
+func TestConfigRegistry(t *testing.T) {
+	value := "1"
+	r := config.New("")
+	r.Register(config.Param{Name: "alpha", Get: func() string { return value }, Set: func(v string) error {
+		if v == "bad" {
+			return errors.New("bad value")
+		}
+		value = v
+		return nil
+	}})
+	r.Register(config.Param{Name: "beta", Get: func() string { return "fixed" }})
+
+	entries := r.Get("*")
+	if len(entries) != 2 || entries[0].Name != "alpha" || entries[1].Value != "fixed" {
+		t.Errorf("Get *: got %+v", entries)
+	}
+	if entries := r.Get("b*"); len(entries) != 1 || entries[0].Name != "beta" {
+		t.Errorf("Get b*: got %+v", entries)
+	}
+	if err := r.Set("ALPHA", "2"); err != nil || value != "2" {
+		t.Errorf("Set: got %v, value %s", err, value)
+	}
+	if err := r.Set("alpha", "bad"); err == nil || value != "2" {
+		t.Errorf("Expected the setter's error, got %v", err)
+	}
+	if err := r.Set("beta", "x"); !errors.Is(err, config.ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly, got %v", err)
+	}
+	if err := r.Set("gamma", "x"); !errors.Is(err, config.ErrUnknownParam) {
+		t.Errorf("Expected ErrUnknownParam, got %v", err)
+	}
+	if err := r.Rewrite(); !errors.Is(err, config.ErrNoFile) {
+		t.Errorf("Expected ErrNoFile, got %v", err)
+	}
+}
+
+func TestConfigRewrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	original := "# settings\nCAPACITY=10\nOTHER=keep\n"
+	if err := os.WriteFile(path, []byte(original), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	values := map[string]string{"capacity": "10", "dbfilename": "data.json", "port": "6379"}
+	r := config.New(path)
+	for name, env := range map[string]string{"capacity": "CAPACITY", "dbfilename": "SNAPSHOT_FILE", "port": "TCP_PORT"} {
+		name := name
+		r.Register(config.Param{Name: name, Env: env,
+			Get: func() string { return values[name] },
+			Set: func(v string) error { values[name] = v; return nil },
+		})
+	}
+	r.Set("capacity", "20")
+	r.Set("dbfilename", "my data.json")
+	if err := r.Rewrite(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(path)
+	want := "# settings\nCAPACITY=20\nOTHER=keep\nSNAPSHOT_FILE=\"my data.json\"\n"
+	if string(data) != want {
+		t.Errorf("Rewrite: expected %q, got %q", want, data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the file mode to be kept, got %v", info.Mode().Perm())
+	}
+}
+
+func TestServerConfigGetSet(t *testing.T) {
+	s := store.NewStore(10)
+	srv := startServerWithStore(t, s)
+	defer srv.Stop()
+
+	conn, reader := dialServer(t, srv.Addr().String())
+	defer conn.Close()
+
+	resp := sendCommand(conn, reader, "CONFIG GET capacity")
+	if resp != "*2\r\n$8\r\ncapacity\r\n$2\r\n10\r\n" {
+		t.Errorf("CONFIG GET capacity: got %q", resp)
+	}
+	resp = sendCommand(conn, reader, "CONFIG GET *interval")
+	if !strings.Contains(resp, "autosave-interval") || !strings.Contains(resp, "ttl-cleaner-interval") {
+		t.Errorf("CONFIG GET *interval: got %q", resp)
+	}
+
+	for _, k := range []string{"a", "b", "c"} {
+		sendCommand(conn, reader, "SET "+k+" v")
+	}
+	if resp := sendCommand(conn, reader, "CONFIG SET capacity 1"); resp != "+OK\r\n" {
+		t.Fatalf("CONFIG SET capacity: got %q", resp)
+	}
+	if keys := s.Keys(); len(keys) != 1 || keys[0] != "c" {
+		t.Errorf("Expected capacity change to evict down to c, got %v", keys)
+	}
+	if resp := sendCommand(conn, reader, "CONFIG SET ttl-cleaner-interval 5 dbfilename other.json"); resp != "+OK\r\n" {
+		t.Errorf("CONFIG SET two params: got %q", resp)
+	}
+	if s.TTLCleanerInterval() != 5*time.Second || s.SnapshotPath() != "other.json" {
+		t.Errorf("Expected retuned cleaner and snapshot path, got %v, %s", s.TTLCleanerInterval(), s.SnapshotPath())
+	}
+	s.SetTTLCleanerInterval(0)
+
+	if resp := sendCommand(conn, reader, "CONFIG SET databases 4"); !strings.Contains(resp, "can't set immutable config") {
+		t.Errorf("CONFIG SET databases: got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "CONFIG SET nosuch 1"); !strings.HasPrefix(resp, "-ERR Unknown option") {
+		t.Errorf("CONFIG SET unknown: got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "CONFIG SET capacity zero"); !strings.Contains(resp, "positive integer") {
+		t.Errorf("CONFIG SET invalid: got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "CONFIG REWRITE"); !strings.Contains(resp, "without a config file") {
+		t.Errorf("CONFIG REWRITE without file: got %q", resp)
+	}
+}
+
+func TestServerConfigRefusedInTransaction(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	// CONFIG takes the store lock that EXEC holds, so it cannot be queued
+	for _, cmd := range []string{"CONFIG GET capacity", "CONFIG SET capacity 5", "CONFIG SET notify-keyspace-events KEA"} {
+		sendCommand(conn, reader, "MULTI")
+		if resp := sendCommand(conn, reader, cmd); resp != "-ERR Command not allowed inside a transaction\r\n" {
+			t.Errorf("%s in MULTI: got %q", cmd, resp)
+		}
+		if resp := sendCommand(conn, reader, "EXEC"); !strings.HasPrefix(resp, "-EXECABORT") {
+			t.Errorf("EXEC after %s: expected -EXECABORT, got %q", cmd, resp)
+		}
+	}
+	if resp := sendCommand(conn, reader, "CONFIG GET capacity"); resp != "*2\r\n$8\r\ncapacity\r\n$2\r\n10\r\n" {
+		t.Errorf("CONFIG GET after the transactions: got %q", resp)
+	}
+}
+
+func TestServerConfigRewrite(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+	path := filepath.Join(t.TempDir(), ".env")
+	os.WriteFile(path, []byte("CAPACITY=10\n"), 0644)
+	srv.Config().SetFile(path)
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+	sendCommand(conn, reader, "CONFIG SET capacity 50")
+	if resp := sendCommand(conn, reader, "CONFIG REWRITE"); resp != "+OK\r\n" {
+		t.Fatalf("CONFIG REWRITE: got %q", resp)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "CAPACITY=50\n" {
+		t.Errorf("Expected CAPACITY=50, got %q", data)
+	}
+}
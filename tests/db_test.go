@@ -0,0 +1,323 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"memstash/internal/store"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDatabasesAreIsolated(t *testing.T) {
+	db0 := store.NewStore(2)
+	db1 := db0.DB(1)
+
+	if db0.Databases() != store.DefaultDatabases {
+		t.Errorf("Expected %d databases, got %d", store.DefaultDatabases, db0.Databases())
+	}
+	if db0.DB(store.DefaultDatabases) != nil || db0.DB(-1) != nil {
+		t.Error("Expected out of range DB to return nil")
+	}
+
+	db0.Set("key", "zero")
+	db1.Set("key", "one")
+	if v, _ := db0.Get("key"); v != "zero" {
+		t.Errorf("DB 0: expected zero, got %s", v)
+	}
+	if v, _ := db1.Get("key"); v != "one" {
+		t.Errorf("DB 1: expected one, got %s", v)
+	}
+
+	// Each database has its own capacity and LRU
+	db1.Set("a", "1")
+	db1.Set("b", "2")
+	if db1.Exists("key") {
+		t.Error("Expected DB 1 to evict its own least recently used key")
+	}
+	if !db0.Exists("key") {
+		t.Error("Expected DB 0 to be unaffected by eviction in DB 1")
+	}
+}
+
+func TestMoveKey(t *testing.T) {
+	db0 := store.NewStore(10)
+	db2 := db0.DB(2)
+
+	db0.SetWithTTL("k", "v", time.Minute)
+	moved, err := db0.Move("k", 2)
+	if err != nil || !moved {
+		t.Fatalf("Move: expected true, got %v, %v", moved, err)
+	}
+	if db0.Exists("k") {
+		t.Error("Expected key to be gone from the source database")
+	}
+	if v, _ := db2.Get("k"); v != "v" {
+		t.Errorf("Expected moved value v, got %s", v)
+	}
+	if ttl, _ := db2.GetTTL("k"); ttl <= 0 {
+		t.Error("Expected TTL to move with the key")
+	}
+
+	db0.Set("k", "other")
+	if moved, _ := db0.Move("k", 2); moved {
+		t.Error("Expected Move to refuse an existing destination key")
+	}
+	if moved, _ := db0.Move("missing", 2); moved {
+		t.Error("Expected Move of a missing key to return false")
+	}
+	if _, err := db0.Move("k", 0); err == nil {
+		t.Error("Expected Move to the same database to fail")
+	}
+	if _, err := db0.Move("k", 99); err != store.ErrInvalidDB {
+		t.Errorf("Expected ErrInvalidDB, got %v", err)
+	}
+}
+
+func TestSwapDBAndFlushAll(t *testing.T) {
+	db0 := store.NewStore(10)
+	db1 := db0.DB(1)
+	db0.Set("a", "from0")
+	db1.Set("b", "from1")
+
+	if err := db0.SwapDB(0, 1); err != nil {
+		t.Fatalf("SwapDB: %v", err)
+	}
+	if !db0.Exists("b") || db0.Exists("a") || !db1.Exists("a") {
+		t.Error("Expected handles to see the swapped contents")
+	}
+	if err := db0.SwapDB(0, 42); err != store.ErrInvalidDB {
+		t.Errorf("Expected ErrInvalidDB, got %v", err)
+	}
+
+	db0.Clear()
+	if db0.Exists("b") || !db1.Exists("a") {
+		t.Error("Expected Clear to only flush one database")
+	}
+	db0.FlushAll()
+	if db1.Exists("a") {
+		t.Error("Expected FlushAll to flush every database")
+	}
+}
+
+func TestSnapshotSavesAllDatabases(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	s1 := store.NewStore(10)
+	s1.Set("zero", "0")
+	s1.DB(3).Set("three", "3")
+	if err := s1.SaveSnapshot(path); err != nil {
+		t.Fatalf("SaveSnapshot: %v", err)
+	}
+
+	s2 := store.NewStore(10)
+	s2.Set("stale", "x")
+	if err := s2.DB(5).LoadSnapshot(path); err != nil {
+		t.Fatalf("LoadSnapshot: %v", err)
+	}
+	if s2.Exists("stale") {
+		t.Error("Expected LoadSnapshot to replace existing keys")
+	}
+	if v, _ := s2.Get("zero"); v != "0" {
+		t.Errorf("DB 0: expected 0, got %s", v)
+	}
+	if v, _ := s2.DB(3).Get("three"); v != "3" {
+		t.Errorf("DB 3: expected 3, got %s", v)
+	}
+
+	small := store.NewStoreWithDatabases(10, 2)
+	if err := small.LoadSnapshot(path); err == nil {
+		t.Error("Expected loading DB 3 into a 2-database store to fail")
+	}
+}
+
+func TestSnapshotLoadsVersion1Format(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "v1.json")
+	os.WriteFile(path, []byte(`{"version":"1.0","capacity":5,"entries":[{"key":"old","value":"v"}]}`), 0644)
+
+	s := store.NewStore(5)
+	if err := s.LoadSnapshot(path); err != nil {
+		t.Fatalf("LoadSnapshot: %v", err)
+	}
+	if v, _ := s.Get("old"); v != "v" {
+		t.Errorf("Expected old=v in DB 0, got %q", v)
+	}
+}
+
+func TestServerSelectAndMove(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+
+	sendCommand(conn, reader, "SET k zero")
+	if resp := sendCommand(conn, reader, "SELECT 1"); resp != "+OK\r\n" {
+		t.Fatalf("SELECT 1: expected +OK\\r\\n, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "GET k"); resp != "$-1\r\n" {
+		t.Errorf("GET in DB 1: expected $-1\\r\\n, got %q", resp)
+	}
+	sendCommand(conn, reader, "SET m moved")
+	if resp := sendCommand(conn, reader, "MOVE m 0"); resp != ":1\r\n" {
+		t.Errorf("MOVE: expected :1\\r\\n, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "SELECT 16"); resp != "-ERR DB index is out of range\r\n" {
+		t.Errorf("SELECT 16: got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "CLIENT INFO"); !strings.Contains(resp, " db=1 ") {
+		t.Errorf("CLIENT INFO: expected db=1, got %q", resp)
+	}
+
+	// Another connection starts in DB 0
+	other, otherReader := dialServer(t, addr)
+	defer other.Close()
+	if resp := sendCommand(other, otherReader, "GET m"); resp != "$5\r\nmoved\r\n" {
+		t.Errorf("GET m in DB 0: got %q", resp)
+	}
+}
+
+func TestServerSwapDBAndFlush(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+
+	sendCommand(conn, reader, "SET a 1")
+	if resp := sendCommand(conn, reader, "SWAPDB 0 1"); resp != "+OK\r\n" {
+		t.Fatalf("SWAPDB: expected +OK\\r\\n, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "EXISTS a"); resp != ":0\r\n" {
+		t.Errorf("EXISTS a after SWAPDB: expected :0\\r\\n, got %q", resp)
+	}
+	sendCommand(conn, reader, "SELECT 1")
+	if resp := sendCommand(conn, reader, "EXISTS a"); resp != ":1\r\n" {
+		t.Errorf("EXISTS a in DB 1: expected :1\\r\\n, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "FLUSHDB"); resp != "+OK\r\n" {
+		t.Errorf("FLUSHDB: got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "EXISTS a"); resp != ":0\r\n" {
+		t.Errorf("EXISTS a after FLUSHDB: expected :0\\r\\n, got %q", resp)
+	}
+	sendCommand(conn, reader, "SET b 2")
+	sendCommand(conn, reader, "SELECT 0")
+	sendCommand(conn, reader, "SET c 3")
+	if resp := sendCommand(conn, reader, "FLUSHALL"); resp != "+OK\r\n" {
+		t.Errorf("FLUSHALL: got %q", resp)
+	}
+	sendCommand(conn, reader, "SELECT 1")
+	if resp := sendCommand(conn, reader, "EXISTS b"); resp != ":0\r\n" {
+		t.Errorf("EXISTS b after FLUSHALL: expected :0\\r\\n, got %q", resp)
+	}
+}
+
+func TestServerWatchAcrossDatabases(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+	other, otherReader := dialServer(t, addr)
+	defer other.Close()
+
+	sendCommand(conn, reader, "SELECT 2")
+	sendCommand(conn, reader, "WATCH k")
+	sendCommand(conn, reader, "SELECT 0")
+	sendCommand(conn, reader, "MULTI")
+	sendCommand(conn, reader, "SET k v")
+
+	// A write to k in DB 0 must not abort; the watched key lives in DB 2
+	sendCommand(other, otherReader, "SET k x")
+	if resp := sendCommand(conn, reader, "EXEC"); resp != "*1\r\n+OK\r\n" {
+		t.Errorf("EXEC: expected *1\\r\\n+OK\\r\\n, got %q", resp)
+	}
+
+	sendCommand(conn, reader, "SELECT 2")
+	sendCommand(conn, reader, "WATCH k")
+	sendCommand(conn, reader, "MULTI")
+	sendCommand(conn, reader, "SET k v")
+	sendCommand(other, otherReader, "SELECT 2")
+	sendCommand(other, otherReader, "SET k x")
+	if resp := sendCommand(conn, reader, "EXEC"); resp != "*-1\r\n" {
+		t.Errorf("EXEC after change in DB 2: expected *-1\\r\\n, got %q", resp)
+	}
+}
+
+func TestServerKeyspaceNotificationsUseDB(t *testing.T) {
+	s := store.NewStore(10)
+	s.SetNotifyKeyspaceEvents(store.NotifyKeyspace | store.NotifyAll)
+	srv := startServerWithStore(t, s)
+	defer srv.Stop()
+
+	sub, subReader := dialServer(t, srv.Addr().String())
+	defer sub.Close()
+	sendCommand(sub, subReader, "SUBSCRIBE __keyspace@3__:k")
+
+	conn, reader := dialServer(t, srv.Addr().String())
+	defer conn.Close()
+	sendCommand(conn, reader, "SET k v") // DB 0: not delivered
+	sendCommand(conn, reader, "SELECT 3")
+	sendCommand(conn, reader, "SET k v")
+
+	sub.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if msg := readResponse(subReader); msg != "*3\r\n$7\r\nmessage\r\n$16\r\n__keyspace@3__:k\r\n$3\r\nset\r\n" {
+		t.Errorf("Expected set notification for DB 3, got %q", msg)
+	}
+}
+
+func TestHTTPDatabaseParameter(t *testing.T) {
+	h, baseURL := startTestHTTPServer(t, 10)
+	defer h.Stop()
+
+	resp, err := http.Post(baseURL+"/keys/k?db=2", "application/json", bytes.NewBufferString(`{"value":"two"}`))
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	resp.Body.Close()
+
+	resp, _ = http.Get(baseURL + "/keys/k")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET without db: expected 404, got %d", resp.StatusCode)
+	}
+
+	resp, _ = http.Get(baseURL + "/keys/k?db=2")
+	body := decodeJSON(t, resp.Body)
+	resp.Body.Close()
+	if body["value"] != "two" {
+		t.Errorf("GET ?db=2: expected two, got %v", body["value"])
+	}
+
+	for _, db := range []string{"abc", "16", "-1"} {
+		resp, _ = http.Get(fmt.Sprintf("%s/keys?db=%s", baseURL, db))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("GET /keys?db=%s: expected 400, got %d", db, resp.StatusCode)
+		}
+	}
+}
+
+func TestTTLCleanerSweepsAllDatabases(t *testing.T) {
+	db0 := store.NewStore(10)
+	db4 := db0.DB(4)
+	events, cancel := db0.Notifications(4)
+	defer cancel()
+	db0.SetNotifyKeyspaceEvents(store.NotifyKeyevent | store.NotifyExpired)
+
+	db4.SetWithTTL("temp", "v", 20*time.Millisecond)
+	db0.StartTTLCleaner(10 * time.Millisecond)
+
+	select {
+	case ev := <-events:
+		if ev.DB != 4 || ev.Key != "temp" || ev.Event != "expired" {
+			t.Errorf("Unexpected event %+v", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the cleaner to expire a key in DB 4")
+	}
+}
@@ -0,0 +1,386 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"memstash/internal/store"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHashSetGetDelete(t *testing.T) {
+	s := store.NewStore(10)
+
+	if n, err := s.HSet("h", map[string]string{"name": "ada", "lang": "go"}); err != nil || n != 2 {
+		t.Fatalf("HSet: expected 2 new fields, got %d, %v", n, err)
+	}
+	if n, _ := s.HSet("h", map[string]string{"name": "grace", "city": "nyc"}); n != 1 {
+		t.Errorf("HSet with one new field: expected 1, got %d", n)
+	}
+	if v, ok, _ := s.HGet("h", "name"); !ok || v != "grace" {
+		t.Errorf("HGet name: got %q, %v", v, ok)
+	}
+	if _, ok, _ := s.HGet("h", "missing"); ok {
+		t.Error("HGet on a missing field found it")
+	}
+	values, _ := s.HMGet("h", "lang", "missing")
+	if len(values) != 1 || values["lang"] != "go" {
+		t.Errorf("HMGet: got %v", values)
+	}
+	if n, _ := s.HLen("h"); n != 3 {
+		t.Errorf("HLen: expected 3, got %d", n)
+	}
+	if ok, _ := s.HExists("h", "city"); !ok {
+		t.Error("HExists city: expected true")
+	}
+
+	if n, _ := s.HDel("h", "city", "missing"); n != 1 {
+		t.Errorf("HDel: expected 1, got %d", n)
+	}
+	all, _ := s.HGetAll("h")
+	if fmt.Sprint(all) != "map[lang:go name:grace]" {
+		t.Errorf("HGetAll: got %v", all)
+	}
+
+	// Deleting the last field deletes the key
+	s.HDel("h", "lang", "name")
+	if s.Exists("h") {
+		t.Error("Empty hash still exists")
+	}
+}
+
+func TestHashIncrBy(t *testing.T) {
+	s := store.NewStore(10)
+
+	if n, err := s.HIncrBy("h", "visits", 5); err != nil || n != 5 {
+		t.Fatalf("HIncrBy on a missing key: got %d, %v", n, err)
+	}
+	if n, _ := s.HIncrBy("h", "visits", -7); n != -2 {
+		t.Errorf("HIncrBy -7: expected -2, got %d", n)
+	}
+	s.HSet("h", map[string]string{"name": "ada", "big": "9223372036854775807"})
+	if _, err := s.HIncrBy("h", "name", 1); !errors.Is(err, store.ErrHashValueNotInteger) {
+		t.Errorf("HIncrBy on a string: expected ErrHashValueNotInteger, got %v", err)
+	}
+	if _, err := s.HIncrBy("h", "big", 1); !errors.Is(err, store.ErrIncrOverflow) {
+		t.Errorf("HIncrBy past MaxInt64: expected ErrIncrOverflow, got %v", err)
+	}
+}
+
+func TestHashFieldTTL(t *testing.T) {
+	s := store.NewStore(10)
+	flags, _ := store.ParseNotifyFlags("KEA")
+	s.SetNotifyKeyspaceEvents(flags)
+	s.HSet("session", map[string]string{"user": "ada", "token": "t1", "csrf": "c1"})
+
+	results, _ := s.HExpire("session", 50*time.Millisecond, "token", "csrf", "missing")
+	if fmt.Sprint(results) != "[1 1 -2]" {
+		t.Errorf("HExpire: got %v", results)
+	}
+	if results, _ := s.HPersist("session", "csrf", "user"); fmt.Sprint(results) != "[1 -1]" {
+		t.Errorf("HPersist: got %v", results)
+	}
+	ttls, _ := s.HTTL("session", "token", "user", "missing")
+	if ttls[0] <= 0 || ttls[0] > 50*time.Millisecond || ttls[1] != -1 || ttls[2] != -2 {
+		t.Errorf("HTTL: got %v", ttls)
+	}
+
+	events, cancel := s.Notifications(16)
+	defer cancel()
+	time.Sleep(100 * time.Millisecond)
+
+	// The expired field is gone; the key and the other fields are not
+	if _, ok, _ := s.HGet("session", "token"); ok {
+		t.Error("Expired field still readable")
+	}
+	if ev := nextEvent(t, events); ev.Key != "session" || ev.Event != "hexpired" {
+		t.Errorf("Expected hexpired event, got %+v", ev)
+	}
+	if n, _ := s.HLen("session"); n != 2 {
+		t.Errorf("HLen after expiry: expected 2, got %d", n)
+	}
+
+	// Setting a field clears its TTL
+	s.HExpire("session", time.Minute, "user")
+	s.HSet("session", map[string]string{"user": "grace"})
+	if ttls, _ := s.HTTL("session", "user"); ttls[0] != -1 {
+		t.Errorf("HTTL after HSet: expected -1, got %v", ttls[0])
+	}
+
+	// A TTL <= 0 deletes the field, and the key with its last field
+	if results, _ := s.HExpire("session", 0, "user", "csrf"); fmt.Sprint(results) != "[2 2]" {
+		t.Errorf("HExpire 0: got %v", results)
+	}
+	if s.Exists("session") {
+		t.Error("Hash without fields still exists")
+	}
+}
+
+func TestHashFieldsSweptByCleaner(t *testing.T) {
+	s := store.NewStore(10)
+	s.HSet("h", map[string]string{"a": "1"})
+	s.HExpire("h", 20*time.Millisecond, "a")
+	s.StartTTLCleaner(10 * time.Millisecond)
+	defer s.SetTTLCleanerInterval(0)
+
+	// Exists hides the hash at once; Info counts it until it is swept
+	deadline := time.Now().Add(time.Second)
+	for s.Info().Keys > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if s.Info().Keys > 0 {
+		t.Error("Cleaner did not delete the hash whose only field expired")
+	}
+}
+
+func TestHashAllFieldsExpiredHidesKey(t *testing.T) {
+	s := store.NewStore(10)
+	s.HSet("h", map[string]string{"a": "1", "b": "2"})
+	s.HExpire("h", 20*time.Millisecond, "a", "b")
+	s.Set("other", "v")
+	time.Sleep(40 * time.Millisecond)
+
+	// Nothing swept the hash yet, but no reader sees it
+	if s.Exists("h") {
+		t.Error("Exists: hash whose fields all expired still exists")
+	}
+	if typ := s.Type("h"); typ != "none" {
+		t.Errorf("Type: expected none, got %q", typ)
+	}
+	if keys := s.Keys(); fmt.Sprint(keys) != "[other]" {
+		t.Errorf("Keys: expected [other], got %v", keys)
+	}
+	if keys := s.KeysMatching("*"); fmt.Sprint(keys) != "[other]" {
+		t.Errorf("KeysMatching: expected [other], got %v", keys)
+	}
+	if _, keys := s.Scan(0, "", 100, ""); fmt.Sprint(keys) != "[other]" {
+		t.Errorf("Scan: expected [other], got %v", keys)
+	}
+
+	// Any write lookup removes it for good
+	if err := s.SetExpiry("h", time.Minute); !errors.Is(err, store.ErrKeyNotFound) {
+		t.Errorf("SetExpiry: expected ErrKeyNotFound, got %v", err)
+	}
+	if n := s.Info().Keys; n != 1 {
+		t.Errorf("Expected the lookup to delete the hash, got %d keys", n)
+	}
+}
+
+func TestHashAllFieldsExpiredGet(t *testing.T) {
+	s := store.NewStore(10)
+	s.HSet("h", map[string]string{"a": "1"})
+	s.HExpire("h", 10*time.Millisecond, "a")
+	time.Sleep(30 * time.Millisecond)
+
+	// GET sees no key rather than a hash of the wrong type
+	if _, err := s.Get("h"); !errors.Is(err, store.ErrKeyNotFound) {
+		t.Errorf("Get: expected ErrKeyNotFound, got %v", err)
+	}
+	if n := s.Info().Keys; n != 0 {
+		t.Errorf("Expected Get to delete the hash, got %d keys", n)
+	}
+}
+
+func TestHashScan(t *testing.T) {
+	s := store.NewStore(10)
+	fields := make(map[string]string)
+	for i := 0; i < 100; i++ {
+		fields[fmt.Sprintf("f%d", i)] = fmt.Sprint(i)
+	}
+	s.HSet("h", fields)
+
+	seen := make(map[string]string)
+	var cursor uint64
+	for {
+		next, pairs, err := s.HScan("h", cursor, "", 7)
+		if err != nil {
+			t.Fatalf("HScan: %v", err)
+		}
+		for i := 0; i < len(pairs); i += 2 {
+			seen[pairs[i]] = pairs[i+1]
+		}
+		// Remove fields as the scan goes; the others must still be seen
+		s.HDel("h", "f0", "f1", "f2")
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+	for i := 3; i < 100; i++ {
+		if seen[fmt.Sprintf("f%d", i)] != fmt.Sprint(i) {
+			t.Fatalf("HScan missed f%d", i)
+		}
+	}
+
+	_, pairs, _ := s.HScan("h", 0, "f9?", 100)
+	if len(pairs) != 20 {
+		t.Errorf("HScan MATCH f9?: expected 10 fields, got %v", pairs)
+	}
+}
+
+func TestHashWrongTypeAndEviction(t *testing.T) {
+	s := store.NewStore(2)
+	s.HSet("h", map[string]string{"a": "1"})
+	s.RPush("l", "x")
+
+	if _, err := s.HSet("l", map[string]string{"a": "1"}); !errors.Is(err, store.ErrWrongType) {
+		t.Errorf("HSet on a list: expected ErrWrongType, got %v", err)
+	}
+	if _, err := s.Get("h"); !errors.Is(err, store.ErrWrongType) {
+		t.Errorf("Get on a hash: expected ErrWrongType, got %v", err)
+	}
+	if _, err := s.LLen("h"); !errors.Is(err, store.ErrWrongType) {
+		t.Errorf("LLen on a hash: expected ErrWrongType, got %v", err)
+	}
+	if s.Type("h") != "hash" {
+		t.Errorf("Type: expected hash, got %q", s.Type("h"))
+	}
+
+	// Reading a field counts as a use of the key
+	s.HGet("h", "a")
+	s.Set("new", "v")
+	if !s.Exists("h") || s.Exists("l") {
+		t.Error("Expected the list, not the hash, to be evicted")
+	}
+
+	// Every field is counted in the dataset size
+	before := s.Info().DatasetBytes
+	s.HSet("h", map[string]string{"b": strings.Repeat("x", 1000)})
+	if grown := s.Info().DatasetBytes - before; grown < 1000 {
+		t.Errorf("DatasetBytes grew by %d after adding a 1000 byte field", grown)
+	}
+}
+
+func TestHashSnapshot(t *testing.T) {
+	path := "/tmp/test_memstash_hash_snapshot.json"
+	defer os.Remove(path)
+
+	s1 := store.NewStore(10)
+	s1.HSet("h", map[string]string{"a": "1", "b": "2", "c": "3"})
+	s1.HExpire("h", time.Hour, "a")
+	s1.HExpire("h", 30*time.Millisecond, "c")
+	s1.HSet("gone", map[string]string{"x": "1"})
+	s1.HExpire("gone", 30*time.Millisecond, "x")
+	if err := s1.SaveSnapshot(path); err != nil {
+		t.Fatalf("SaveSnapshot: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	s2 := store.NewStore(10)
+	if err := s2.LoadSnapshot(path); err != nil {
+		t.Fatalf("LoadSnapshot: %v", err)
+	}
+	if all, _ := s2.HGetAll("h"); fmt.Sprint(all) != "map[a:1 b:2]" {
+		t.Errorf("Loaded hash: got %v", all)
+	}
+	if ttls, _ := s2.HTTL("h", "a", "b"); ttls[0] < 59*time.Minute || ttls[1] != -1 {
+		t.Errorf("Loaded field TTLs: got %v", ttls)
+	}
+	if s2.Exists("gone") {
+		t.Error("Hash whose fields all expired was loaded")
+	}
+}
+
+func TestServerHashCommands(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+
+	for _, tc := range []struct{ cmd, expected string }{
+		{"HSET user:1 name ada lang go", ":2\r\n"},
+		{"HSET user:1 name", "-ERR wrong number of arguments for 'HSET' command\r\n"},
+		{"HGET user:1 name", "$3\r\nada\r\n"},
+		{"HGET user:1 missing", "$-1\r\n"},
+		{"HMGET user:1 lang missing", "*2\r\n$2\r\ngo\r\n$-1\r\n"},
+		{"HGETALL user:1", encodeCommand("lang", "go", "name", "ada")},
+		{"HINCRBY user:1 visits 3", ":3\r\n"},
+		{"HINCRBY user:1 name 1", "-ERR hash value is not an integer\r\n"},
+		{"HINCRBY user:1 visits x", "-ERR value is not an integer or out of range\r\n"},
+		{"HEXISTS user:1 visits", ":1\r\n"},
+		{"HLEN user:1", ":3\r\n"},
+		{"HDEL user:1 visits missing", ":1\r\n"},
+		{"HEXPIRE user:1 100 FIELDS 2 lang missing", "*2\r\n:1\r\n:-2\r\n"},
+		{"HEXPIRE user:1 100 FIELDS 3 lang", "-ERR The `numfields` parameter must match the number of arguments\r\n"},
+		{"HTTL user:1 FIELDS 2 lang name", "*2\r\n:99\r\n:-1\r\n"},
+		{"HPERSIST user:1 FIELDS 1 lang", "*1\r\n:1\r\n"},
+		{"HSCAN user:1 0 MATCH n*", "*2\r\n$1\r\n0\r\n" + encodeCommand("name", "ada")},
+		{"TYPE user:1", "+hash\r\n"},
+		{"GET user:1", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{"HGETALL missing", "*0\r\n"},
+	} {
+		if resp := sendCommand(conn, reader, tc.cmd); resp != tc.expected {
+			t.Errorf("%s: expected %q, got %q", tc.cmd, tc.expected, resp)
+		}
+	}
+
+	// RESP3 clients get HGETALL as a map
+	sendCommand(conn, reader, "HELLO 3")
+	if resp := sendCommand(conn, reader, "HGETALL user:1"); !strings.HasPrefix(resp, "%2\r\n") {
+		t.Errorf("HGETALL over RESP3: expected a map, got %q", resp)
+	}
+}
+
+func TestHTTPHashEndpoints(t *testing.T) {
+	h, baseURL := startTestHTTPServer(t, 10)
+	defer h.Stop()
+
+	do := func(method, path, body string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(method, baseURL+path, bytes.NewBufferString(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		return resp
+	}
+	expect := func(resp *http.Response, status int) map[string]any {
+		t.Helper()
+		defer resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Fatalf("%s %s: expected %d, got %d", resp.Request.Method, resp.Request.URL.Path, status, resp.StatusCode)
+		}
+		return decodeJSON(t, resp.Body)
+	}
+
+	expect(do("PUT", "/keys/s/hash/user", `{"value":"ada"}`), http.StatusCreated)
+	expect(do("PUT", "/keys/s/hash/user", `{"value":"grace"}`), http.StatusOK)
+	expect(do("PUT", "/keys/s/hash/token", `{"value":"t1","ttl":60}`), http.StatusCreated)
+
+	body := expect(do("GET", "/keys/s/hash/token", ""), http.StatusOK)
+	if body["value"] != "t1" || body["ttl"] != float64(59) && body["ttl"] != float64(60) {
+		t.Errorf("GET token: got %v", body)
+	}
+	body = expect(do("GET", "/keys/s/hash/user", ""), http.StatusOK)
+	if body["value"] != "grace" || body["ttl"] != float64(-1) {
+		t.Errorf("GET user: got %v", body)
+	}
+	expect(do("GET", "/keys/s/hash/missing", ""), http.StatusNotFound)
+
+	body = expect(do("POST", "/keys/s/hash/visits/incr", `{"by":2}`), http.StatusOK)
+	if body["value"] != float64(2) {
+		t.Errorf("incr: expected 2, got %v", body["value"])
+	}
+	expect(do("POST", "/keys/s/hash/user/incr", `{"by":1}`), http.StatusConflict)
+
+	body = expect(do("GET", "/keys/s/hash", ""), http.StatusOK)
+	fields, _ := body["fields"].(map[string]any)
+	var names []string
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "token,user,visits" {
+		t.Errorf("GET hash: got %v", body)
+	}
+
+	expect(do("DELETE", "/keys/s/hash/user", ""), http.StatusOK)
+	expect(do("DELETE", "/keys/s/hash/user", ""), http.StatusNotFound)
+
+	expect(do("GET", "/keys/s", ""), http.StatusConflict)
+	expect(do("GET", "/keys/s/list", ""), http.StatusConflict)
+}
@@ -328,3 +328,98 @@ func TestHTTPConcurrentClients(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestHTTPKeysPattern(t *testing.T) {
+	h, baseURL := startTestHTTPServer(t, 10)
+	defer h.Stop()
+
+	for _, k := range []string{"user:1", "user:2", "order:1"} {
+		resp, _ := http.Post(baseURL+"/keys/"+k, "application/json", bytes.NewBufferString(`{"value":"v"}`))
+		resp.Body.Close()
+	}
+
+	resp, err := http.Get(baseURL + "/keys?pattern=user:*")
+	if err != nil {
+		t.Fatalf("GET /keys?pattern failed: %v", err)
+	}
+	body := decodeJSON(t, resp.Body)
+	resp.Body.Close()
+	if body["count"] != float64(2) {
+		t.Errorf("Expected 2 keys matching user:*, got %v", body["keys"])
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, baseURL+"/keys?pattern=user:*", nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("DELETE /keys?pattern failed: %v", err)
+	}
+	body = decodeJSON(t, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || body["deleted"] != float64(2) {
+		t.Errorf("Expected 200 with 2 deleted, got %d %v", resp.StatusCode, body)
+	}
+
+	resp, _ = http.Get(baseURL + "/keys")
+	body = decodeJSON(t, resp.Body)
+	resp.Body.Close()
+	if body["count"] != float64(1) {
+		t.Errorf("Expected only order:1 to remain, got %v", body["keys"])
+	}
+
+	req, _ = http.NewRequest(http.MethodDelete, baseURL+"/keys", nil)
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("DELETE /keys without pattern: expected 400, got %d", resp.StatusCode)
+	}
+}
+
+func TestHTTPRunsCommandHandlers(t *testing.T) {
+	s := store.NewStore(10)
+	tcp := server.NewServer(s, 0)
+	<-tcp.StartAndReady()
+	defer tcp.Stop()
+	h := server.NewHTTPServer(s, 0)
+	h.Attach(tcp)
+	<-h.StartAndReady()
+	defer h.Stop()
+	baseURL := "http://" + h.Addr().String()
+
+	mon, monReader := dialServer(t, tcp.Addr().String())
+	defer mon.Close()
+	sendCommand(mon, monReader, "MONITOR")
+	mon.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	// The endpoint runs the command with the arguments built from the body
+	resp, err := http.Post(baseURL+"/keys/k", "application/json", bytes.NewBufferString(`{"value": "v", "ttl": 60}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if _, _, args := readMonitorLine(t, monReader); args != `"SETEX" "k" "60" "v"` {
+		t.Errorf("Expected SETEX with every argument, got %s", args)
+	}
+
+	// Error replies map to statuses
+	s.RPush("l", "x")
+	resp, _ = http.Get(baseURL + "/keys/l")
+	body := decodeJSON(t, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict || body["error"] != store.ErrWrongType.Error() {
+		t.Errorf("GET on a list: expected 409 WRONGTYPE, got %d %v", resp.StatusCode, body)
+	}
+
+	// Typed replies become JSON values
+	resp, _ = http.Get(baseURL + "/keys/l/list/len")
+	body = decodeJSON(t, resp.Body)
+	resp.Body.Close()
+	if body["length"] != float64(1) {
+		t.Errorf("GET list/len: expected length 1, got %v", body)
+	}
+	resp, _ = http.Get(baseURL + "/stats")
+	body = decodeJSON(t, resp.Body)
+	resp.Body.Close()
+	if body["keys"] != float64(2) || body["capacity"] != float64(10) {
+		t.Errorf("GET /stats: got %v", body)
+	}
+}
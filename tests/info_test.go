@@ -0,0 +1,192 @@
+package tests
+
+import (
+	"memstash/internal/store"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// infoFields runs INFO with the given arguments and returns its sections
+// and their fields.
+func infoFields(t *testing.T, cmd string, addr string) (map[string]bool, map[string]string) {
+	t.Helper()
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+
+	resp := sendCommand(conn, reader, cmd)
+	if !strings.HasPrefix(resp, "$") {
+		t.Fatalf("%s: expected bulk string, got %q", cmd, resp)
+	}
+	sections := map[string]bool{}
+	fields := map[string]string{}
+	for _, line := range strings.Split(strings.SplitN(resp, "\r\n", 2)[1], "\r\n") {
+		if strings.HasPrefix(line, "# ") {
+			sections[strings.TrimPrefix(line, "# ")] = true
+		} else if k, v, ok := strings.Cut(line, ":"); ok {
+			fields[k] = v
+		}
+	}
+	return sections, fields
+}
+
+func TestStoreCountsEvictionsAndExpirations(t *testing.T) {
+	s := store.NewStore(2)
+	s.Set("a", "1")
+	s.Set("b", "2")
+	s.Set("c", "3") // evicts a
+	if stats := s.Stats(); stats.Evictions != 1 {
+		t.Errorf("Expected 1 eviction, got %d", stats.Evictions)
+	}
+
+	s.SetWithTTL("b", "2", 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	s.Get("b")
+	if stats := s.Stats(); stats.Expired != 1 {
+		t.Errorf("Expected 1 expired key, got %d", stats.Expired)
+	}
+
+	info := s.Info()
+	if info.Evictions != 1 || info.Expired != 1 || info.Keys != 1 {
+		t.Errorf("Info: expected 1 key, 1 eviction, 1 expiration, got %+v", info)
+	}
+}
+
+func TestStoreInfoDatabasesAndSaves(t *testing.T) {
+	s := store.NewStore(10)
+	s.Set("a", "1")
+	s.DB(3).SetWithTTL("b", "2", time.Minute)
+
+	info := s.Info()
+	if len(info.Databases) != 2 {
+		t.Fatalf("Expected 2 non-empty databases, got %+v", info.Databases)
+	}
+	if db := info.Databases[1]; db.Index != 3 || db.Keys != 1 || db.Expires != 1 {
+		t.Errorf("Expected db3 with 1 key and 1 expire, got %+v", db)
+	}
+	if info.ChangesSinceSave != 2 {
+		t.Errorf("Expected 2 changes since save, got %d", info.ChangesSinceSave)
+	}
+	if info.DatasetBytes <= 0 {
+		t.Errorf("Expected a positive dataset size, got %d", info.DatasetBytes)
+	}
+
+	path := filepath.Join(t.TempDir(), "dump.json")
+	if err := s.SaveSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	info = s.Info()
+	if info.ChangesSinceSave != 0 || info.LastSaveErr != nil || time.Since(info.LastSave) > time.Minute {
+		t.Errorf("After save: got %+v", info)
+	}
+
+	if err := s.SaveSnapshot(filepath.Join(t.TempDir(), "missing", "dump.json")); err == nil {
+		t.Fatal("Expected saving into a missing directory to fail")
+	}
+	if s.Info().LastSaveErr == nil {
+		t.Error("Expected the failed save to be recorded")
+	}
+}
+
+func TestServerInfoDefaultSections(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+	sendCommand(conn, reader, "SET a 1")
+	sendCommand(conn, reader, "SELECT 2")
+	sendCommand(conn, reader, "SETEX b 60 2")
+	sendCommand(conn, reader, "GET b")
+	sendCommand(conn, reader, "GET missing")
+
+	sections, fields := infoFields(t, "INFO", addr)
+	for _, name := range []string{"Server", "Clients", "Memory", "Persistence", "Stats", "Keyspace"} {
+		if !sections[name] {
+			t.Errorf("Expected section %s, got %v", name, sections)
+		}
+	}
+	if sections["Commandstats"] {
+		t.Error("Expected commandstats to be left out by default")
+	}
+
+	if fields["process_id"] != strconv.Itoa(os.Getpid()) {
+		t.Errorf("process_id: got %q", fields["process_id"])
+	}
+	if fields["connected_clients"] != "2" {
+		t.Errorf("connected_clients: expected 2, got %q", fields["connected_clients"])
+	}
+	if n, _ := strconv.Atoi(fields["total_commands_processed"]); n < 5 {
+		t.Errorf("total_commands_processed: expected at least 5, got %q", fields["total_commands_processed"])
+	}
+	if fields["keyspace_hits"] != "1" || fields["keyspace_misses"] != "1" {
+		t.Errorf("Expected 1 hit and 1 miss, got %q / %q", fields["keyspace_hits"], fields["keyspace_misses"])
+	}
+	if fields["db0"] != "keys=1,expires=0" || fields["db2"] != "keys=1,expires=1" {
+		t.Errorf("keyspace: got db0=%q db2=%q", fields["db0"], fields["db2"])
+	}
+	if fields["rdb_changes_since_last_save"] != "2" || fields["rdb_last_bgsave_status"] != "ok" {
+		t.Errorf("persistence: got %q / %q", fields["rdb_changes_since_last_save"], fields["rdb_last_bgsave_status"])
+	}
+	if n, _ := strconv.Atoi(fields["used_memory"]); n <= 0 {
+		t.Errorf("used_memory: got %q", fields["used_memory"])
+	}
+}
+
+func TestServerInfoSelectedSections(t *testing.T) {
+	srv, addr := startTestServer(t, 1)
+	defer srv.Stop()
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+	sendCommand(conn, reader, "SET a 1")
+	sendCommand(conn, reader, "SET b 2")
+	sendCommand(conn, reader, "GET b")
+
+	sections, fields := infoFields(t, "INFO stats commandstats", addr)
+	if len(sections) != 2 || !sections["Stats"] || !sections["Commandstats"] {
+		t.Fatalf("Expected only Stats and Commandstats, got %v", sections)
+	}
+	if fields["evicted_keys"] != "1" {
+		t.Errorf("evicted_keys: expected 1, got %q", fields["evicted_keys"])
+	}
+	if !strings.HasPrefix(fields["cmdstat_set"], "calls=2,") {
+		t.Errorf("cmdstat_set: got %q", fields["cmdstat_set"])
+	}
+	if !strings.HasPrefix(fields["cmdstat_get"], "calls=1,") {
+		t.Errorf("cmdstat_get: got %q", fields["cmdstat_get"])
+	}
+
+	sections, _ = infoFields(t, "INFO everything", addr)
+	if !sections["Commandstats"] || !sections["Server"] {
+		t.Errorf("INFO everything: got %v", sections)
+	}
+}
+
+func TestServerInfoInsideTransaction(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+	// EXEC holds the store lock; INFO must not try to take it again
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	sendCommand(conn, reader, "SET a 1")
+	sendCommand(conn, reader, "MULTI")
+	sendCommand(conn, reader, "INFO keyspace")
+	resp := sendCommand(conn, reader, "EXEC")
+	if !strings.Contains(resp, "db0:keys=1") {
+		t.Fatalf("EXEC with INFO: got %q", resp)
+	}
+
+	// The store is still usable by other clients
+	other, otherReader := dialServer(t, addr)
+	defer other.Close()
+	other.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if resp := sendCommand(other, otherReader, "GET a"); resp != "$1\r\n1\r\n" {
+		t.Errorf("GET after EXEC: got %q", resp)
+	}
+}
@@ -0,0 +1,362 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"memstash/internal/server"
+	"memstash/internal/store"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListPushPopAndRange(t *testing.T) {
+	s := store.NewStore(10)
+
+	if n, err := s.RPush("l", "b", "c"); err != nil || n != 2 {
+		t.Fatalf("RPush: expected 2, got %d, %v", n, err)
+	}
+	if n, _ := s.LPush("l", "a", "z"); n != 4 {
+		t.Fatalf("LPush: expected 4, got %d", n)
+	}
+	values, _ := s.LRange("l", 0, -1)
+	if strings.Join(values, ",") != "z,a,b,c" {
+		t.Errorf("LRange 0 -1: got %v", values)
+	}
+	values, _ = s.LRange("l", -3, 1)
+	if strings.Join(values, ",") != "a" {
+		t.Errorf("LRange -3 1: got %v", values)
+	}
+	if values, _ = s.LRange("l", 5, 10); len(values) != 0 {
+		t.Errorf("LRange past the end: got %v", values)
+	}
+
+	if popped, _ := s.LPop("l", 1); strings.Join(popped, ",") != "z" {
+		t.Errorf("LPop: got %v", popped)
+	}
+	if popped, _ := s.RPop("l", 5); strings.Join(popped, ",") != "c,b,a" {
+		t.Errorf("RPop 5: got %v", popped)
+	}
+
+	// Popping the last element deletes the key
+	if s.Exists("l") {
+		t.Error("Empty list still exists")
+	}
+	if popped, err := s.LPop("l", 1); popped != nil || err != nil {
+		t.Errorf("LPop on a missing key: got %v, %v", popped, err)
+	}
+}
+
+func TestListGrowsAndShrinks(t *testing.T) {
+	s := store.NewStore(10)
+
+	// Mix both ends so the ring wraps around while it grows
+	for i := 0; i < 100; i++ {
+		s.RPush("l", fmt.Sprint(i))
+		s.LPush("l", fmt.Sprint(-i-1))
+	}
+	if n, _ := s.LLen("l"); n != 200 {
+		t.Fatalf("LLen: expected 200, got %d", n)
+	}
+	for _, tc := range []struct {
+		index int
+		value string
+	}{{0, "-100"}, {99, "-1"}, {100, "0"}, {-1, "99"}} {
+		if v, ok, _ := s.LIndex("l", tc.index); !ok || v != tc.value {
+			t.Errorf("LIndex %d: expected %s, got %q, %v", tc.index, tc.value, v, ok)
+		}
+	}
+	before := s.Info().DatasetBytes
+	s.LTrim("l", 95, 104)
+	if values, _ := s.LRange("l", 0, -1); strings.Join(values, ",") != "-5,-4,-3,-2,-1,0,1,2,3,4" {
+		t.Errorf("LTrim 95 104: got %v", values)
+	}
+	if after := s.Info().DatasetBytes; after >= before {
+		t.Errorf("Memory did not shrink after LTRIM: %d before, %d after", before, after)
+	}
+}
+
+func TestListSetRemoveAndTrim(t *testing.T) {
+	s := store.NewStore(10)
+	s.RPush("l", "a", "x", "b", "x", "c", "x")
+
+	if err := s.LSet("l", -1, "y"); err != nil {
+		t.Fatalf("LSet: %v", err)
+	}
+	if err := s.LSet("l", 6, "y"); !errors.Is(err, store.ErrIndexOutOfRange) {
+		t.Errorf("LSet past the end: expected ErrIndexOutOfRange, got %v", err)
+	}
+	if err := s.LSet("missing", 0, "y"); !errors.Is(err, store.ErrKeyNotFound) {
+		t.Errorf("LSet on a missing key: expected ErrKeyNotFound, got %v", err)
+	}
+
+	if n, _ := s.LRem("l", -1, "x"); n != 1 {
+		t.Errorf("LRem -1: expected 1, got %d", n)
+	}
+	if values, _ := s.LRange("l", 0, -1); strings.Join(values, ",") != "a,x,b,c,y" {
+		t.Errorf("After LRem -1: got %v", values)
+	}
+	if n, _ := s.LRem("l", 0, "x"); n != 1 {
+		t.Errorf("LRem 0: expected 1, got %d", n)
+	}
+
+	// A range that keeps nothing deletes the key
+	s.LTrim("l", 3, 1)
+	if s.Exists("l") {
+		t.Error("LTrim with an empty range kept the key")
+	}
+}
+
+func TestListWrongType(t *testing.T) {
+	s := store.NewStore(10)
+	s.Set("str", "v")
+	s.RPush("list", "a")
+
+	if _, err := s.LPush("str", "a"); !errors.Is(err, store.ErrWrongType) {
+		t.Errorf("LPush on a string: expected ErrWrongType, got %v", err)
+	}
+	if _, err := s.LRange("str", 0, -1); !errors.Is(err, store.ErrWrongType) {
+		t.Errorf("LRange on a string: expected ErrWrongType, got %v", err)
+	}
+	if _, err := s.Get("list"); !errors.Is(err, store.ErrWrongType) {
+		t.Errorf("Get on a list: expected ErrWrongType, got %v", err)
+	}
+	if err := s.Set("list", "v"); !errors.Is(err, store.ErrWrongType) {
+		t.Errorf("Set on a list: expected ErrWrongType, got %v", err)
+	}
+	if err := s.SetWithTTL("list", "v", time.Minute); !errors.Is(err, store.ErrWrongType) {
+		t.Errorf("SetWithTTL on a list: expected ErrWrongType, got %v", err)
+	}
+
+	// Generic commands work on any type
+	if s.Type("list") != "list" {
+		t.Errorf("Type: expected list, got %q", s.Type("list"))
+	}
+	if _, keys := s.Scan(0, "", 100, "list"); strings.Join(keys, ",") != "list" {
+		t.Errorf("Scan TYPE list: got %v", keys)
+	}
+	if err := s.Delete("list"); err != nil {
+		t.Errorf("Delete: %v", err)
+	}
+	if _, err := s.RPush("list", "b"); err != nil {
+		t.Errorf("RPush after Delete: %v", err)
+	}
+}
+
+func TestListEvictionAndExpiry(t *testing.T) {
+	s := store.NewStore(2)
+	s.RPush("old", "a")
+	s.Set("k", "v")
+
+	// Pushing to a list counts as a use
+	s.RPush("old", "b")
+	s.Set("new", "v")
+	if !s.Exists("old") || s.Exists("k") {
+		t.Error("Expected the string, not the list, to be evicted")
+	}
+
+	s.SetExpiry("old", 50*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	if n, _ := s.LLen("old"); n != 0 {
+		t.Errorf("LLen on an expired list: expected 0, got %d", n)
+	}
+}
+
+func TestListSnapshot(t *testing.T) {
+	path := "/tmp/test_memstash_list_snapshot.json"
+	defer os.Remove(path)
+
+	s1 := store.NewStore(10)
+	s1.RPush("l", "a", "b", "c")
+	s1.Set("s", "v")
+	if err := s1.SaveSnapshot(path); err != nil {
+		t.Fatalf("SaveSnapshot: %v", err)
+	}
+
+	s2 := store.NewStore(10)
+	if err := s2.LoadSnapshot(path); err != nil {
+		t.Fatalf("LoadSnapshot: %v", err)
+	}
+	if values, _ := s2.LRange("l", 0, -1); strings.Join(values, ",") != "a,b,c" {
+		t.Errorf("Loaded list: got %v", values)
+	}
+	if v, _ := s2.Get("s"); v != "v" {
+		t.Errorf("Loaded string: got %q", v)
+	}
+}
+
+func TestServerListCommands(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+
+	for _, tc := range []struct{ cmd, expected string }{
+		{"RPUSH l a b c", ":3\r\n"},
+		{"LPUSH l z", ":4\r\n"},
+		{"LRANGE l 0 -1", encodeCommand("z", "a", "b", "c")},
+		{"LLEN l", ":4\r\n"},
+		{"LINDEX l -1", "$1\r\nc\r\n"},
+		{"LINDEX l 9", "$-1\r\n"},
+		{"LSET l 0 y", "+OK\r\n"},
+		{"LSET l 9 y", "-ERR index out of range\r\n"},
+		{"LSET missing 0 y", "-ERR no such key\r\n"},
+		{"LREM l 0 a", ":1\r\n"},
+		{"LTRIM l 0 1", "+OK\r\n"},
+		{"LPOP l", "$1\r\ny\r\n"},
+		{"RPOP l 5", encodeCommand("b")},
+		{"LPOP l", "$-1\r\n"},
+		{"LPOP l 2", "*-1\r\n"},
+		{"LPOP l -1", "-ERR value is out of range, must be positive\r\n"},
+		{"LRANGE l x 1", "-ERR value is not an integer or out of range\r\n"},
+		{"TYPE l", "+none\r\n"},
+	} {
+		if resp := sendCommand(conn, reader, tc.cmd); resp != tc.expected {
+			t.Errorf("%s: expected %q, got %q", tc.cmd, tc.expected, resp)
+		}
+	}
+}
+
+func TestServerListWrongType(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+
+	sendCommand(conn, reader, "RPUSH l a")
+	sendCommand(conn, reader, "SET s v")
+	for _, cmd := range []string{"GET l", "SET l v", "SETEX l 10 v", "LPUSH s a", "LRANGE s 0 -1", "LPOP s"} {
+		if resp := sendCommand(conn, reader, cmd); !strings.HasPrefix(resp, "-WRONGTYPE ") {
+			t.Errorf("%s: expected WRONGTYPE, got %q", cmd, resp)
+		}
+	}
+	if resp := sendCommand(conn, reader, "TYPE l"); resp != "+list\r\n" {
+		t.Errorf("TYPE l: expected +list, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "DEL l"); resp != ":1\r\n" {
+		t.Errorf("DEL l: expected :1, got %q", resp)
+	}
+}
+
+func TestCLIListCommands(t *testing.T) {
+	srv, _ := startTestServer(t, 10)
+	defer srv.Stop()
+	session := srv.NewSession("cli")
+
+	if resp := session.Do([]string{"rpush", "queue", "job 1", "job 2"}); resp != ":2\r\n" {
+		t.Errorf("RPUSH: got %q", resp)
+	}
+	if resp := session.Do([]string{"LPOP", "queue"}); resp != "$5\r\njob 1\r\n" {
+		t.Errorf("LPOP: got %q", resp)
+	}
+	if resp := session.Do([]string{"LLEN"}); !strings.HasPrefix(resp, "-ERR wrong number of arguments") {
+		t.Errorf("LLEN without a key: got %q", resp)
+	}
+}
+
+func TestHTTPListEndpoints(t *testing.T) {
+	h, baseURL := startTestHTTPServer(t, 10)
+	defer h.Stop()
+
+	do := func(method, path, body string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(method, baseURL+path, bytes.NewBufferString(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		return resp
+	}
+	expect := func(resp *http.Response, status int) map[string]any {
+		t.Helper()
+		defer resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Fatalf("%s %s: expected %d, got %d", resp.Request.Method, resp.Request.URL.Path, status, resp.StatusCode)
+		}
+		return decodeJSON(t, resp.Body)
+	}
+
+	body := expect(do("POST", "/keys/l/list/right", `{"values":["a","b","c"]}`), http.StatusOK)
+	if body["length"] != float64(3) {
+		t.Errorf("RPUSH: expected length 3, got %v", body["length"])
+	}
+	expect(do("POST", "/keys/l/list/left", `{"values":["z"]}`), http.StatusOK)
+
+	body = expect(do("GET", "/keys/l/list?start=1", ""), http.StatusOK)
+	if fmt.Sprint(body["values"]) != "[a b c]" {
+		t.Errorf("LRANGE 1 -1: got %v", body["values"])
+	}
+	body = expect(do("GET", "/keys/l/list/len", ""), http.StatusOK)
+	if body["length"] != float64(4) {
+		t.Errorf("LLEN: expected 4, got %v", body["length"])
+	}
+	body = expect(do("GET", "/keys/l/list/-1", ""), http.StatusOK)
+	if body["value"] != "c" {
+		t.Errorf("LINDEX -1: expected c, got %v", body["value"])
+	}
+	expect(do("GET", "/keys/l/list/9", ""), http.StatusNotFound)
+
+	expect(do("PUT", "/keys/l/list/0", `{"value":"y"}`), http.StatusOK)
+	expect(do("PUT", "/keys/l/list/9", `{"value":"y"}`), http.StatusBadRequest)
+
+	body = expect(do("DELETE", "/keys/l/list?value=a", ""), http.StatusOK)
+	if body["removed"] != float64(1) {
+		t.Errorf("LREM: expected 1 removed, got %v", body["removed"])
+	}
+	expect(do("POST", "/keys/l/list/trim", `{"start":0,"stop":1}`), http.StatusOK)
+
+	body = expect(do("DELETE", "/keys/l/list/right?count=5", ""), http.StatusOK)
+	if fmt.Sprint(body["values"]) != "[b y]" {
+		t.Errorf("RPOP 5: got %v", body["values"])
+	}
+	expect(do("DELETE", "/keys/l/list/left", ""), http.StatusNotFound)
+
+	// String endpoints refuse lists and list endpoints refuse strings
+	expect(do("POST", "/keys/l/list/right", `{"values":["a"]}`), http.StatusOK)
+	expect(do("GET", "/keys/l", ""), http.StatusConflict)
+	expect(do("POST", "/keys/l", `{"value":"v"}`), http.StatusConflict)
+	expect(do("POST", "/keys/s", `{"value":"v"}`), http.StatusCreated)
+	expect(do("GET", "/keys/s/list", ""), http.StatusConflict)
+}
+
+func TestHTTPListSharesTCPState(t *testing.T) {
+	s := store.NewStore(10)
+	tcp := server.NewServer(s, 0)
+	<-tcp.StartAndReady()
+	defer tcp.Stop()
+	h := server.NewHTTPServer(s, 0)
+	h.Attach(tcp)
+	<-h.StartAndReady()
+	defer h.Stop()
+
+	conn, reader := dialServer(t, tcp.Addr().String())
+	defer conn.Close()
+	sendCommand(conn, reader, "RPUSH shared a b")
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/keys/shared/list", h.Addr()))
+	if err != nil {
+		t.Fatalf("GET /keys/shared/list: %v", err)
+	}
+	defer resp.Body.Close()
+	if body := decodeJSON(t, resp.Body); fmt.Sprint(body["values"]) != "[a b]" {
+		t.Errorf("GET /keys/shared/list: got %v", body)
+	}
+}
+
+func TestSetReplacesExpiredList(t *testing.T) {
+	s := store.NewStore(10)
+	s.RPush("l", "a")
+	s.SetExpiry("l", 20*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+
+	// The list is gone even though the cleaner has not swept it yet
+	if err := s.Set("l", "v"); err != nil {
+		t.Fatalf("Set on an expired list: %v", err)
+	}
+	if v, _ := s.Get("l"); v != "v" {
+		t.Errorf("Get: expected v, got %q", v)
+	}
+}
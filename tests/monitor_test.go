@@ -0,0 +1,171 @@
+package tests
+
+import (
+	"bufio"
+	"fmt"
+	"memstash/internal/server"
+	"memstash/internal/store"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var monitorLine = regexp.MustCompile(`^\+\d+\.\d{6} \[(\d+) (\S+)\] (.*)\r\n$`)
+
+// readMonitorLine reads one MONITOR line and returns its database, client
+// address and quoted arguments.
+func readMonitorLine(t *testing.T, reader *bufio.Reader) (string, string, string) {
+	t.Helper()
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("read monitor line: %v", err)
+	}
+	m := monitorLine.FindStringSubmatch(line)
+	if m == nil {
+		t.Fatalf("unexpected monitor line %q", line)
+	}
+	return m[1], m[2], m[3]
+}
+
+func TestServerMonitor(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+
+	mon, monReader := dialServer(t, addr)
+	defer mon.Close()
+	if resp := sendCommand(mon, monReader, "MONITOR"); resp != "+OK\r\n" {
+		t.Fatalf("MONITOR: got %q", resp)
+	}
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+	sendCommand(conn, reader, "AUTH nobody secret")
+	sendCommand(conn, reader, "SELECT 3")
+	fmt.Fprint(conn, encodeCommand("SET", "greeting", `say "hi"`))
+	readResponse(reader)
+
+	mon.SetReadDeadline(time.Now().Add(2 * time.Second))
+	db, client, args := readMonitorLine(t, monReader)
+	if db != "0" || args != `"SELECT" "3"` {
+		t.Errorf("Expected SELECT in db 0 (AUTH hidden), got db %s args %s", db, args)
+	}
+	if client != conn.LocalAddr().String() {
+		t.Errorf("Expected client address %s, got %s", conn.LocalAddr(), client)
+	}
+	db, _, args = readMonitorLine(t, monReader)
+	if db != "3" || args != `"SET" "greeting" "say \"hi\""` {
+		t.Errorf("Expected quoted SET in db 3, got db %s args %s", db, args)
+	}
+
+	if resp := sendCommand(mon, monReader, "GET greeting"); !strings.HasPrefix(resp, "-ERR only QUIT") {
+		t.Errorf("Expected commands to be refused after MONITOR, got %q", resp)
+	}
+}
+
+func TestServerMonitorShowsTransactions(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+
+	mon, monReader := dialServer(t, addr)
+	defer mon.Close()
+	sendCommand(mon, monReader, "MONITOR")
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+	sendCommand(conn, reader, "MULTI")
+	sendCommand(conn, reader, "SET a 1")
+	sendCommand(conn, reader, "EXEC")
+
+	mon.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var got []string
+	for i := 0; i < 3; i++ {
+		_, _, args := readMonitorLine(t, monReader)
+		got = append(got, args)
+	}
+	want := []string{`"MULTI"`, `"EXEC"`, `"SET" "a" "1"`}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestServerMonitorRefusedInTransaction(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	sendCommand(conn, reader, "MULTI")
+	if resp := sendCommand(conn, reader, "MONITOR"); resp != "-ERR Command not allowed inside a transaction\r\n" {
+		t.Errorf("MONITOR in MULTI: got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "EXEC"); !strings.HasPrefix(resp, "-EXECABORT") {
+		t.Errorf("EXEC after MONITOR: expected -EXECABORT, got %q", resp)
+	}
+	// The connection did not switch to monitor mode
+	if resp := sendCommand(conn, reader, "PING"); resp != "+PONG\r\n" {
+		t.Errorf("PING after the transaction: got %q", resp)
+	}
+}
+
+func TestServerMonitorSlowConsumerDoesNotBlock(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+
+	// A monitor that never reads
+	mon, monReader := dialServer(t, addr)
+	defer mon.Close()
+	sendCommand(mon, monReader, "MONITOR")
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+	value := strings.Repeat("x", 1024)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 5000; i++ {
+			sendCommand(conn, reader, "SET k "+value)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Commands were blocked by a slow monitor")
+	}
+}
+
+func TestHTTPMonitor(t *testing.T) {
+	s := store.NewStore(10)
+	tcp := server.NewServer(s, 0)
+	<-tcp.StartAndReady()
+	defer tcp.Stop()
+	h := server.NewHTTPServer(s, 0)
+	h.Attach(tcp)
+	<-h.StartAndReady()
+	defer h.Stop()
+
+	mon, monReader := dialServer(t, tcp.Addr().String())
+	defer mon.Close()
+	sendCommand(mon, monReader, "MONITOR")
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/keys/user:1?db=2", h.Addr().String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	mon.SetReadDeadline(time.Now().Add(2 * time.Second))
+	db, _, args := readMonitorLine(t, monReader)
+	if db != "2" || args != `"GET" "user:1"` {
+		t.Errorf("Expected GET user:1 in db 2, got db %s args %s", db, args)
+	}
+
+	tcp.FeedMonitors("cli", 0, []string{"KEYS", "*"})
+	_, client, args := readMonitorLine(t, monReader)
+	if client != "cli" || args != `"KEYS" "*"` {
+		t.Errorf("Expected CLI command, got %s %s", client, args)
+	}
+}
@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"memstash/internal/store"
+	"strings"
+	"testing"
+	"time"
+)
+
+func nextEvent(t *testing.T, events <-chan store.KeyEvent) store.KeyEvent {
+	t.Helper()
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for key event")
+		return store.KeyEvent{}
+	}
+}
+
+func expectNoEvent(t *testing.T, events <-chan store.KeyEvent) {
+	t.Helper()
+	select {
+	case ev := <-events:
+		t.Errorf("Expected no event, got %+v", ev)
+	default:
+	}
+}
+
+func TestParseNotifyFlags(t *testing.T) {
+	flags, err := store.ParseNotifyFlags("KEA")
+	if err != nil {
+		t.Fatalf("ParseNotifyFlags(KEA): %v", err)
+	}
+	if flags != store.NotifyKeyspace|store.NotifyKeyevent|store.NotifyAll {
+		t.Errorf("ParseNotifyFlags(KEA): got %b", flags)
+	}
+	if flags.String() != "KEA" {
+		t.Errorf("String(): expected KEA, got %q", flags.String())
+	}
+
+	flags, _ = store.ParseNotifyFlags("Ex$")
+	if flags.String() != "E$x" {
+		t.Errorf("String(): expected E$x, got %q", flags.String())
+	}
+
+	if _, err := store.ParseNotifyFlags("KQ"); err == nil {
+		t.Error("Expected error for unknown flag")
+	}
+}
+
+func TestNotificationsForWrites(t *testing.T) {
+	myStore := store.NewStore(10)
+	flags, _ := store.ParseNotifyFlags("KEA")
+	myStore.SetNotifyKeyspaceEvents(flags)
+	events, cancel := myStore.Notifications(16)
+	defer cancel()
+
+	myStore.Set("k", "v")
+	if ev := nextEvent(t, events); ev != (store.KeyEvent{Key: "k", Event: "set"}) {
+		t.Errorf("Set: unexpected event %+v", ev)
+	}
+
+	myStore.SetExpiry("k", time.Minute)
+	if ev := nextEvent(t, events); ev.Event != "expire" {
+		t.Errorf("SetExpiry: expected expire, got %+v", ev)
+	}
+
+	myStore.Delete("k")
+	if ev := nextEvent(t, events); ev.Event != "del" {
+		t.Errorf("Delete: expected del, got %+v", ev)
+	}
+
+	// Failed writes emit nothing
+	myStore.Delete("k")
+	expectNoEvent(t, events)
+}
+
+func TestNotificationsForExpiryAndEviction(t *testing.T) {
+	myStore := store.NewStore(1)
+	flags, _ := store.ParseNotifyFlags("Exe")
+	myStore.SetNotifyKeyspaceEvents(flags)
+	events, cancel := myStore.Notifications(16)
+	defer cancel()
+
+	// Only the enabled classes (expired, evicted) are emitted
+	myStore.SetWithTTL("short", "v", 20*time.Millisecond)
+	expectNoEvent(t, events)
+
+	time.Sleep(40 * time.Millisecond)
+	myStore.Get("short")
+	if ev := nextEvent(t, events); ev != (store.KeyEvent{Key: "short", Event: "expired"}) {
+		t.Errorf("lazy expiry: unexpected event %+v", ev)
+	}
+
+	myStore.Set("a", "1")
+	myStore.Set("b", "2") // capacity 1: evicts a
+	if ev := nextEvent(t, events); ev != (store.KeyEvent{Key: "a", Event: "evicted"}) {
+		t.Errorf("eviction: unexpected event %+v", ev)
+	}
+}
+
+func TestNotificationsDisabledByDefault(t *testing.T) {
+	myStore := store.NewStore(10)
+	events, cancel := myStore.Notifications(16)
+	myStore.Set("k", "v")
+	expectNoEvent(t, events)
+
+	// Class flags without K or E emit nothing either, as in Redis
+	myStore.SetNotifyKeyspaceEvents(store.NotifyAll)
+	myStore.Set("k", "v2")
+	expectNoEvent(t, events)
+
+	cancel()
+	if _, ok := <-events; ok {
+		t.Error("Expected channel to be closed after cancel")
+	}
+}
+
+func TestServerKeyspaceNotifications(t *testing.T) {
+	s := store.NewStore(10)
+	flags, _ := store.ParseNotifyFlags("KEA")
+	s.SetNotifyKeyspaceEvents(flags)
+	srv := startServerWithStore(t, s)
+	defer srv.Stop()
+
+	conn, reader := dialServer(t, srv.Addr().String())
+	defer conn.Close()
+	sendCommand(conn, reader, "SUBSCRIBE __keyspace@0__:user __keyevent@0__:del")
+	readResponse(reader) // second subscribe confirmation
+
+	s.Set("user", "alice")
+	resp := readResponse(reader)
+	if !strings.Contains(resp, "__keyspace@0__:user") || !strings.HasSuffix(resp, "$3\r\nset\r\n") {
+		t.Errorf("Expected keyspace set notification, got %q", resp)
+	}
+
+	s.Delete("user")
+	resp = readResponse(reader)
+	if !strings.Contains(resp, "__keyspace@0__:user") || !strings.HasSuffix(resp, "$3\r\ndel\r\n") {
+		t.Errorf("Expected keyspace del notification, got %q", resp)
+	}
+	resp = readResponse(reader)
+	if !strings.Contains(resp, "__keyevent@0__:del") || !strings.HasSuffix(resp, "$4\r\nuser\r\n") {
+		t.Errorf("Expected keyevent del notification, got %q", resp)
+	}
+}
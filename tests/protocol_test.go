@@ -0,0 +1,195 @@
+package tests
+
+import (
+	"errors"
+	"io"
+	"math"
+	"math/big"
+	"memstash/internal/protocol"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func readAll(t *testing.T, input string) [][]string {
+	t.Helper()
+	r := protocol.NewReader(strings.NewReader(input))
+	var cmds [][]string
+	for {
+		args, err := r.ReadCommand()
+		if err == io.EOF {
+			return cmds
+		}
+		if err != nil {
+			t.Fatalf("ReadCommand(%q): unexpected error %v", input, err)
+		}
+		cmds = append(cmds, args)
+	}
+}
+
+func TestProtocolReadMultiBulk(t *testing.T) {
+	cmds := readAll(t, "*3\r\n$3\r\nSET\r\n$3\r\nfoo\r\n$3\r\nbar\r\n")
+	expected := [][]string{{"SET", "foo", "bar"}}
+	if !reflect.DeepEqual(cmds, expected) {
+		t.Errorf("Expected %q, got %q", expected, cmds)
+	}
+}
+
+func TestProtocolReadBinarySafeBulk(t *testing.T) {
+	value := "hello world\r\nwith\x00nul"
+	input := "*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n"
+	cmds := readAll(t, input)
+	if len(cmds) != 1 || cmds[0][2] != value {
+		t.Errorf("Expected binary value to round-trip, got %q", cmds)
+	}
+}
+
+func TestProtocolReadInline(t *testing.T) {
+	cmds := readAll(t, "PING\r\nSET k \"a b\\n\" 'c d'\n\r\n")
+	expected := [][]string{{"PING"}, {"SET", "k", "a b\n", "c d"}}
+	if !reflect.DeepEqual(cmds, expected) {
+		t.Errorf("Expected %q, got %q", expected, cmds)
+	}
+}
+
+func TestProtocolReadMixedStream(t *testing.T) {
+	cmds := readAll(t, "*1\r\n$4\r\nPING\r\nGET foo\r\n*0\r\n*2\r\n$3\r\nGET\r\n$0\r\n\r\n")
+	expected := [][]string{{"PING"}, {"GET", "foo"}, {"GET", ""}}
+	if !reflect.DeepEqual(cmds, expected) {
+		t.Errorf("Expected %q, got %q", expected, cmds)
+	}
+}
+
+func TestProtocolReadErrors(t *testing.T) {
+	inputs := []string{
+		"*x\r\n",
+		"*1\r\n+PING\r\n",
+		"*1\r\n$-5\r\n",
+		"*1\r\n$4\r\nPINGXX",
+		"SET k \"unterminated\r\n",
+	}
+	for _, input := range inputs {
+		r := protocol.NewReader(strings.NewReader(input))
+		_, err := r.ReadCommand()
+		if !errors.Is(err, protocol.ErrProtocol) {
+			t.Errorf("ReadCommand(%q): expected protocol error, got %v", input, err)
+		}
+	}
+}
+
+func TestProtocolReadTruncated(t *testing.T) {
+	r := protocol.NewReader(strings.NewReader("*2\r\n$3\r\nGET\r\n$3\r\nfo"))
+	_, err := r.ReadCommand()
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("Expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+// endless is a client that never sends a newline; it counts what is read.
+type endless struct{ n int }
+
+func (e *endless) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 'a'
+	}
+	e.n += len(p)
+	return len(p), nil
+}
+
+func TestProtocolReadLineLimit(t *testing.T) {
+	for _, prefix := range []string{"", "*", "*1\r\n$"} {
+		src := &endless{}
+		_, err := protocol.NewReader(io.MultiReader(strings.NewReader(prefix), src)).ReadCommand()
+		if !errors.Is(err, protocol.ErrProtocol) {
+			t.Errorf("Line after %q with no newline: expected ErrProtocol, got %v", prefix, err)
+		}
+		// The line is refused once it passes the limit, not read forever
+		if src.n > 2*protocol.MaxInlineLen {
+			t.Errorf("Line after %q: read %d bytes before refusing it", prefix, src.n)
+		}
+	}
+}
+
+func TestProtocolReadBulkAllocation(t *testing.T) {
+	// A huge length with no data behind it must not allocate that length
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := protocol.NewReader(strings.NewReader("*1\r\n$536870911\r\nabc")).ReadCommand()
+	runtime.ReadMemStats(&after)
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("Expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	if grown := after.TotalAlloc - before.TotalAlloc; grown > 1<<20 {
+		t.Errorf("Reading a truncated bulk header allocated %d bytes", grown)
+	}
+
+	// Bulk strings larger than the preallocation still arrive whole
+	large := strings.Repeat("x", 300*1024)
+	args := readAll(t, "*2\r\n$3\r\nSET\r\n$"+strconv.Itoa(len(large))+"\r\n"+large+"\r\n")
+	if len(args) != 1 || args[0][1] != large {
+		t.Error("Large bulk string was not read back intact")
+	}
+}
+
+func TestProtocolRESP3Formatters(t *testing.T) {
+	bulk := protocol.FormatBulkString
+	n, _ := new(big.Int).SetString("3492890328409238509324850943850943825024385", 10)
+	cases := []struct {
+		name     string
+		got      string
+		expected string
+	}{
+		{"map", protocol.FormatMap([]string{bulk("a"), protocol.FormatInteger(1)}), "%1\r\n$1\r\na\r\n:1\r\n"},
+		{"set", protocol.FormatSet([]string{bulk("x"), bulk("y")}), "~2\r\n$1\r\nx\r\n$1\r\ny\r\n"},
+		{"push", protocol.FormatPush([]string{bulk("message")}), ">1\r\n$7\r\nmessage\r\n"},
+		{"double", protocol.FormatDouble(3.25), ",3.25\r\n"},
+		{"double inf", protocol.FormatDouble(math.Inf(-1)), ",-inf\r\n"},
+		{"boolean", protocol.FormatBoolean(true), "#t\r\n"},
+		{"big number", protocol.FormatBigNumber(n), "(3492890328409238509324850943850943825024385\r\n"},
+		{"verbatim", protocol.FormatVerbatim("txt", "Some string"), "=15\r\ntxt:Some string\r\n"},
+		{"null", protocol.FormatNull3(), "_\r\n"},
+		{"empty array", protocol.FormatArray(nil), "*0\r\n"},
+	}
+	for _, tc := range cases {
+		if tc.got != tc.expected {
+			t.Errorf("%s: expected %q, got %q", tc.name, tc.expected, tc.got)
+		}
+	}
+}
+
+func TestProtocolMatch(t *testing.T) {
+	cases := []struct {
+		pattern string
+		s       string
+		match   bool
+	}{
+		{"*", "", true},
+		{"*", "anything", true},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h*llo", "heeeello", true},
+		{"h*llo", "hello world", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[a-b]llo", "hcllo", false},
+		{"user:*:name", "user:42:name", true},
+		{"user:*:name", "user:42:email", false},
+		{"a/*", "a/b/c", true},
+		{`\*`, "*", true},
+		{`\*`, "x", false},
+		{`h\?llo`, "h?llo", true},
+		{`[\]]`, "]", true},
+		{"*a*b*", "xxaxxbxx", true},
+		{"*a*b", "xxaxxbxx", false},
+	}
+	for _, tc := range cases {
+		if got := protocol.Match(tc.pattern, tc.s); got != tc.match {
+			t.Errorf("Match(%q, %q): expected %v, got %v", tc.pattern, tc.s, tc.match, got)
+		}
+	}
+}
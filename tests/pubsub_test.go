@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"memstash/internal/pubsub"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func receive(t *testing.T, sub *pubsub.Subscriber) pubsub.Message {
+	t.Helper()
+	select {
+	case msg := <-sub.Messages():
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for message")
+		return pubsub.Message{}
+	}
+}
+
+func TestPubSubExactChannel(t *testing.T) {
+	hub := pubsub.NewHub(10, pubsub.DropMessages)
+	sub := hub.NewSubscriber()
+
+	if count := hub.Subscribe(sub, "news"); count != 1 {
+		t.Errorf("Expected 1 subscription, got %d", count)
+	}
+	if n := hub.Publish("news", "hello"); n != 1 {
+		t.Errorf("Expected 1 receiver, got %d", n)
+	}
+	if n := hub.Publish("other", "ignored"); n != 0 {
+		t.Errorf("Expected 0 receivers for other channel, got %d", n)
+	}
+
+	msg := receive(t, sub)
+	if msg.Channel != "news" || msg.Payload != "hello" || msg.Pattern != "" {
+		t.Errorf("Unexpected message %+v", msg)
+	}
+
+	hub.Unsubscribe(sub, "news")
+	if n := hub.Publish("news", "gone"); n != 0 {
+		t.Errorf("Expected 0 receivers after unsubscribe, got %d", n)
+	}
+}
+
+func TestPubSubPatterns(t *testing.T) {
+	hub := pubsub.NewHub(10, pubsub.DropMessages)
+	sub := hub.NewSubscriber()
+	hub.PSubscribe(sub, "user.*")
+
+	if n := hub.Publish("user.42", "login"); n != 1 {
+		t.Errorf("Expected 1 receiver, got %d", n)
+	}
+	if n := hub.Publish("order.42", "created"); n != 0 {
+		t.Errorf("Expected 0 receivers, got %d", n)
+	}
+	msg := receive(t, sub)
+	if msg.Pattern != "user.*" || msg.Channel != "user.42" || msg.Payload != "login" {
+		t.Errorf("Unexpected message %+v", msg)
+	}
+	if hub.NumPat() != 1 {
+		t.Errorf("Expected NumPat 1, got %d", hub.NumPat())
+	}
+}
+
+func TestPubSubIntrospection(t *testing.T) {
+	hub := pubsub.NewHub(10, pubsub.DropMessages)
+	a, b := hub.NewSubscriber(), hub.NewSubscriber()
+	hub.Subscribe(a, "news.tech")
+	hub.Subscribe(a, "weather")
+	hub.Subscribe(b, "news.tech")
+
+	if got := hub.Channels(""); !reflect.DeepEqual(got, []string{"news.tech", "weather"}) {
+		t.Errorf("Channels(\"\"): got %v", got)
+	}
+	if got := hub.Channels("news.*"); !reflect.DeepEqual(got, []string{"news.tech"}) {
+		t.Errorf("Channels(news.*): got %v", got)
+	}
+	if n := hub.NumSub("news.tech"); n != 2 {
+		t.Errorf("NumSub(news.tech): expected 2, got %d", n)
+	}
+
+	hub.Remove(a)
+	if n := hub.NumSub("news.tech"); n != 1 {
+		t.Errorf("NumSub after Remove: expected 1, got %d", n)
+	}
+	if got := hub.Channels(""); !reflect.DeepEqual(got, []string{"news.tech"}) {
+		t.Errorf("Channels after Remove: got %v", got)
+	}
+	select {
+	case <-a.Done():
+	default:
+		t.Error("Expected removed subscriber to be closed")
+	}
+}
+
+func TestPubSubSlowSubscriberDrop(t *testing.T) {
+	hub := pubsub.NewHub(2, pubsub.DropMessages)
+	sub := hub.NewSubscriber()
+	hub.Subscribe(sub, "ch")
+
+	for i := 0; i < 5; i++ {
+		hub.Publish("ch", "msg") // must not block
+	}
+	if sub.Dropped() != 3 {
+		t.Errorf("Expected 3 dropped messages, got %d", sub.Dropped())
+	}
+	select {
+	case <-sub.Done():
+		t.Error("Drop policy must not close the subscriber")
+	default:
+	}
+}
+
+func TestPubSubSlowSubscriberDisconnect(t *testing.T) {
+	hub := pubsub.NewHub(1, pubsub.Disconnect)
+	slow, fast := hub.NewSubscriber(), hub.NewSubscriber()
+	hub.Subscribe(slow, "ch")
+	hub.Subscribe(fast, "ch")
+
+	hub.Publish("ch", "one")
+	receive(t, fast)
+	hub.Publish("ch", "two") // slow is full now
+
+	select {
+	case <-slow.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Expected slow subscriber to be disconnected")
+	}
+	if !slow.Overflowed() {
+		t.Error("Expected Overflowed() to be true")
+	}
+	if n := hub.NumSub("ch"); n != 1 {
+		t.Errorf("Expected only the fast subscriber to remain, got %d", n)
+	}
+}
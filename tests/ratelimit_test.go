@@ -0,0 +1,213 @@
+package tests
+
+import (
+	"fmt"
+	"memstash/internal/ratelimit"
+	"memstash/internal/server"
+	"memstash/internal/store"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimiterCommands(t *testing.T) {
+	l := ratelimit.New()
+	var conn ratelimit.Buckets
+
+	// No limits by default
+	for i := 0; i < 100; i++ {
+		if !l.Allow(&conn, "", 10) {
+			t.Fatal("Unlimited limiter refused a command")
+		}
+	}
+
+	l.SetRates(ratelimit.Connection, ratelimit.Rates{Commands: 3})
+	conn = ratelimit.Buckets{}
+	for i := 0; i < 3; i++ {
+		if !l.Allow(&conn, "", 1) {
+			t.Fatalf("Command %d within the burst was refused", i+1)
+		}
+	}
+	if l.Allow(&conn, "", 1) {
+		t.Error("Fourth command in the same second was allowed")
+	}
+	if got := l.Throttled(ratelimit.Connection); got != 1 {
+		t.Errorf("Throttled(Connection): expected 1, got %d", got)
+	}
+
+	// Tokens come back at the configured rate
+	time.Sleep(400 * time.Millisecond)
+	if !l.Allow(&conn, "", 1) {
+		t.Error("Command after the bucket refilled was refused")
+	}
+
+	// Other connections have their own buckets
+	var other ratelimit.Buckets
+	if !l.Allow(&other, "", 1) {
+		t.Error("Another connection was refused")
+	}
+}
+
+func TestLimiterBytes(t *testing.T) {
+	l := ratelimit.New()
+	l.SetRates(ratelimit.Connection, ratelimit.Rates{Bytes: 10})
+	var conn ratelimit.Buckets
+
+	// A command larger than the bucket passes once, leaving it in debt
+	if !l.Allow(&conn, "", 25) {
+		t.Fatal("Oversized command on a full bucket was refused")
+	}
+	if l.Allow(&conn, "", 1) {
+		t.Error("Command while the bucket is in debt was allowed")
+	}
+}
+
+func TestLimiterUsers(t *testing.T) {
+	l := ratelimit.New()
+	l.SetRates(ratelimit.User, ratelimit.Rates{Commands: 2})
+	var a, b ratelimit.Buckets
+
+	// Connections of the same user share its budget
+	if !l.Allow(&a, "alice", 1) || !l.Allow(&b, "alice", 1) {
+		t.Fatal("Commands within alice's budget were refused")
+	}
+	if l.Allow(&a, "alice", 1) {
+		t.Error("Third command of alice was allowed")
+	}
+	if !l.Allow(&b, "bob", 1) {
+		t.Error("bob was refused because of alice")
+	}
+	// Unauthenticated connections have no user budget
+	for i := 0; i < 5; i++ {
+		if !l.Allow(nil, "", 1) {
+			t.Fatal("Unauthenticated command was refused")
+		}
+	}
+	if got := l.Throttled(ratelimit.User); got != 1 {
+		t.Errorf("Throttled(User): expected 1, got %d", got)
+	}
+	if got := l.Throttled(ratelimit.Connection); got != 0 {
+		t.Errorf("Throttled(Connection): expected 0, got %d", got)
+	}
+}
+
+func TestServerRateLimitPerConnection(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+	srv.RateLimiter().SetRates(ratelimit.Connection, ratelimit.Rates{Commands: 2})
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+	sendCommand(conn, reader, "PING")
+	sendCommand(conn, reader, "SET k v")
+	if resp := sendCommand(conn, reader, "GET k"); resp != "-ERR rate limited\r\n" {
+		t.Errorf("Third command: expected -ERR rate limited, got %q", resp)
+	}
+
+	// The refused command did not run, and other connections are unaffected
+	_, fields := infoFields(t, "INFO stats", addr)
+	if fields["throttled_by_connection"] != "1" || fields["throttled_by_user"] != "0" {
+		t.Errorf("INFO stats: expected 1 and 0 throttled commands, got %q and %q",
+			fields["throttled_by_connection"], fields["throttled_by_user"])
+	}
+
+	other, oreader := dialServer(t, addr)
+	defer other.Close()
+	if resp := sendCommand(other, oreader, "STATS"); !strings.Contains(resp, "throttled_by_connection:1\r\n") {
+		t.Errorf("STATS: expected throttled_by_connection:1, got %q", resp)
+	}
+}
+
+func TestServerRateLimitPerUser(t *testing.T) {
+	srv, _, addr := startAuthServer(t)
+	defer srv.Stop()
+	srv.RateLimiter().SetRates(ratelimit.User, ratelimit.Rates{Commands: 3})
+
+	first, freader := dialServer(t, addr)
+	defer first.Close()
+	second, sreader := dialServer(t, addr)
+	defer second.Close()
+
+	// AUTH itself runs before the connection has a user to charge
+	sendCommand(first, freader, "AUTH secret")
+	sendCommand(second, sreader, "AUTH secret")
+	sendCommand(first, freader, "PING")
+	sendCommand(second, sreader, "PING")
+	sendCommand(second, sreader, "PING")
+	if resp := sendCommand(first, freader, "PING"); resp != "-ERR rate limited\r\n" {
+		t.Errorf("Fourth command of the user: expected -ERR rate limited, got %q", resp)
+	}
+	if got := srv.RateLimiter().Throttled(ratelimit.User); got != 1 {
+		t.Errorf("Throttled(User): expected 1, got %d", got)
+	}
+}
+
+func TestServerRateLimitConfig(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+	if resp := sendCommand(conn, reader, "CONFIG SET ratelimit-user-bytes 1000 ratelimit-connection-commands 500"); resp != "+OK\r\n" {
+		t.Fatalf("CONFIG SET ratelimit-*: got %q", resp)
+	}
+	expected := ratelimit.Rates{Commands: 500}
+	if got := srv.RateLimiter().Rates(ratelimit.Connection); got != expected {
+		t.Errorf("Connection rates: expected %+v, got %+v", expected, got)
+	}
+	if resp := sendCommand(conn, reader, "CONFIG GET ratelimit-user-*"); resp != encodeCommand("ratelimit-user-bytes", "1000", "ratelimit-user-commands", "0") {
+		t.Errorf("CONFIG GET ratelimit-user-*: got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "CONFIG SET ratelimit-user-commands -1"); !strings.HasPrefix(resp, "-ERR CONFIG SET failed") {
+		t.Errorf("CONFIG SET with a negative rate: got %q", resp)
+	}
+}
+
+func TestHTTPRateLimit(t *testing.T) {
+	s := store.NewStore(10)
+	tcp := server.NewServer(s, 0)
+	tcp.RateLimiter().SetRates(ratelimit.Connection, ratelimit.Rates{Commands: 2})
+	h := server.NewHTTPServer(s, 0)
+	h.Attach(tcp)
+	<-h.StartAndReady()
+	defer h.Stop()
+	baseURL := fmt.Sprintf("http://%s", h.Addr().String())
+
+	// One keep-alive connection carries every request
+	client := &http.Client{Transport: &http.Transport{MaxConnsPerHost: 1}}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(baseURL + "/keys")
+		if err != nil {
+			t.Fatalf("GET /keys: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Request %d: expected 200, got %d", i+1, resp.StatusCode)
+		}
+	}
+	resp, err := client.Get(baseURL + "/keys")
+	if err != nil {
+		t.Fatalf("GET /keys: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Third request: expected 429, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("429 response without Retry-After")
+	}
+	if body := decodeJSON(t, resp.Body); body["error"] != "rate limited" {
+		t.Errorf("429 body: got %v", body)
+	}
+
+	// A new connection has a fresh budget and sees the counter
+	fresh, err := http.Get(baseURL + "/stats")
+	if err != nil {
+		t.Fatalf("GET /stats: %v", err)
+	}
+	defer fresh.Body.Close()
+	if body := decodeJSON(t, fresh.Body); body["throttled_by_connection"] != float64(1) {
+		t.Errorf("GET /stats: expected throttled_by_connection 1, got %v", body["throttled_by_connection"])
+	}
+}
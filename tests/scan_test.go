@@ -0,0 +1,225 @@
+package tests
+
+import (
+	"fmt"
+	"math"
+	"memstash/internal/store"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// scanAll iterates a whole SCAN, calling between(i) after every batch.
+func scanAll(s *store.Store, match string, count int, between func(i int)) map[string]int {
+	seen := map[string]int{}
+	var cursor uint64
+	for i := 0; ; i++ {
+		next, keys := s.Scan(cursor, match, count, "")
+		for _, k := range keys {
+			seen[k]++
+		}
+		if next == 0 {
+			return seen
+		}
+		cursor = next
+		if between != nil {
+			between(i)
+		}
+	}
+}
+
+func TestScanReturnsEveryKey(t *testing.T) {
+	s := store.NewStore(1000)
+	for i := 0; i < 500; i++ {
+		s.Set(fmt.Sprintf("key:%d", i), "v")
+	}
+
+	seen := scanAll(s, "", 10, nil)
+	if len(seen) != 500 {
+		t.Fatalf("Expected 500 distinct keys, got %d", len(seen))
+	}
+	for k, n := range seen {
+		if n != 1 {
+			t.Errorf("Key %s returned %d times without a resize", k, n)
+		}
+	}
+}
+
+func TestScanHugeCount(t *testing.T) {
+	s := store.NewStore(100)
+	fields := map[string]string{}
+	for i := 0; i < 50; i++ {
+		s.Set(fmt.Sprintf("key:%d", i), "v")
+		fields[fmt.Sprintf("f%d", i)] = "v"
+	}
+	s.HSet("h", fields)
+
+	// A count whose visit budget would overflow still covers everything
+	for _, count := range []int{1 << 62, math.MaxInt} {
+		if cursor, keys := s.Scan(0, "key:*", count, ""); cursor != 0 || len(keys) != 50 {
+			t.Errorf("Scan COUNT %d: expected 50 keys and cursor 0, got %d keys and cursor %d", count, len(keys), cursor)
+		}
+		if cursor, pairs, _ := s.HScan("h", 0, "", count); cursor != 0 || len(pairs) != 100 {
+			t.Errorf("HScan COUNT %d: expected 50 fields and cursor 0, got %d items and cursor %d", count, len(pairs), cursor)
+		}
+	}
+}
+
+func TestScanStableWhileGrowing(t *testing.T) {
+	s := store.NewStore(100000)
+	for i := 0; i < 100; i++ {
+		s.Set(fmt.Sprintf("orig:%d", i), "v")
+	}
+
+	// The first batches add enough keys to force several table resizes
+	seen := scanAll(s, "", 5, func(i int) {
+		if i >= 20 {
+			return
+		}
+		for j := 0; j < 200; j++ {
+			s.Set(fmt.Sprintf("new:%d:%d", i, j), "v")
+		}
+	})
+	for i := 0; i < 100; i++ {
+		if seen[fmt.Sprintf("orig:%d", i)] == 0 {
+			t.Errorf("Key orig:%d was skipped while the table grew", i)
+		}
+	}
+}
+
+func TestScanStableWhileShrinking(t *testing.T) {
+	s := store.NewStore(100000)
+	for i := 0; i < 2000; i++ {
+		s.Set(fmt.Sprintf("tmp:%d", i), "v")
+	}
+	for i := 0; i < 50; i++ {
+		s.Set(fmt.Sprintf("keep:%d", i), "v")
+	}
+
+	deleted := 0
+	seen := scanAll(s, "", 20, func(i int) {
+		for j := 0; j < 200 && deleted < 2000; j++ {
+			s.Delete(fmt.Sprintf("tmp:%d", deleted))
+			deleted++
+		}
+	})
+	for i := 0; i < 50; i++ {
+		if seen[fmt.Sprintf("keep:%d", i)] == 0 {
+			t.Errorf("Key keep:%d was skipped while the table shrank", i)
+		}
+	}
+}
+
+func TestScanMatchAndType(t *testing.T) {
+	s := store.NewStore(100)
+	s.Set("user:1", "a")
+	s.Set("user:2", "b")
+	s.Set("order:1", "c")
+
+	var users []string
+	for k := range scanAll(s, "user:*", 100, nil) {
+		users = append(users, k)
+	}
+	sort.Strings(users)
+	if strings.Join(users, ",") != "user:1,user:2" {
+		t.Errorf("MATCH user:*: got %v", users)
+	}
+
+	if _, keys := s.Scan(0, "", 100, "string"); len(keys) != 3 {
+		t.Errorf("TYPE string: expected 3 keys, got %v", keys)
+	}
+	if _, keys := s.Scan(0, "", 100, "list"); len(keys) != 0 {
+		t.Errorf("TYPE list: expected no keys, got %v", keys)
+	}
+	if s.Type("user:1") != "string" || s.Type("missing") != "none" {
+		t.Error("Unexpected TYPE result")
+	}
+}
+
+func TestServerScan(t *testing.T) {
+	srv, addr := startTestServer(t, 100)
+	defer srv.Stop()
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+	for i := 0; i < 30; i++ {
+		sendCommand(conn, reader, fmt.Sprintf("SET key:%d v", i))
+	}
+	sendCommand(conn, reader, "SET other v")
+
+	seen := map[string]bool{}
+	cursor := "0"
+	for {
+		resp := sendCommand(conn, reader, "SCAN "+cursor+" MATCH key:* COUNT 7")
+		lines := strings.Split(resp, "\r\n")
+		// *2, $n, cursor, *k, ($len, key)...
+		cursor = lines[2]
+		for i := 5; i < len(lines); i += 2 {
+			seen[lines[i]] = true
+		}
+		if cursor == "0" {
+			break
+		}
+	}
+	if len(seen) != 30 || seen["other"] {
+		t.Errorf("Expected the 30 key:* keys, got %d", len(seen))
+	}
+
+	resp := sendCommand(conn, reader, "SCAN 0 COUNT 4611686018427387904")
+	if !strings.HasPrefix(resp, "*2\r\n$1\r\n0\r\n*31\r\n") {
+		t.Errorf("SCAN with a huge COUNT: expected every key and cursor 0, got %q", resp)
+	}
+
+	if resp := sendCommand(conn, reader, "TYPE key:1"); resp != "+string\r\n" {
+		t.Errorf("TYPE key:1: expected +string\\r\\n, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "TYPE nope"); resp != "+none\r\n" {
+		t.Errorf("TYPE nope: expected +none\\r\\n, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "SCAN abc"); resp != "-ERR invalid cursor\r\n" {
+		t.Errorf("SCAN abc: got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "SCAN 0 COUNT 0"); resp != "-ERR syntax error\r\n" {
+		t.Errorf("SCAN 0 COUNT 0: got %q", resp)
+	}
+}
+
+func TestHTTPScanKeys(t *testing.T) {
+	h, baseURL := startTestHTTPServer(t, 100)
+	defer h.Stop()
+	for i := 0; i < 25; i++ {
+		http.Post(fmt.Sprintf("%s/keys/item:%d", baseURL, i), "application/json", strings.NewReader(`{"value":"v"}`))
+	}
+	http.Post(baseURL+"/keys/skip", "application/json", strings.NewReader(`{"value":"v"}`))
+
+	seen := map[string]bool{}
+	cursor := "0"
+	for pages := 0; ; pages++ {
+		resp, err := http.Get(fmt.Sprintf("%s/keys?cursor=%s&match=item:*&count=5", baseURL, cursor))
+		if err != nil {
+			t.Fatalf("GET /keys failed: %v", err)
+		}
+		body := decodeJSON(t, resp.Body)
+		resp.Body.Close()
+		for _, k := range body["keys"].([]any) {
+			seen[k.(string)] = true
+		}
+		cursor = body["cursor"].(string)
+		if cursor == "0" {
+			break
+		}
+		if pages > 100 {
+			t.Fatal("Scan did not terminate")
+		}
+	}
+	if len(seen) != 25 || seen["skip"] {
+		t.Errorf("Expected the 25 item:* keys, got %d", len(seen))
+	}
+
+	resp, _ := http.Get(baseURL + "/keys?cursor=nope")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Invalid cursor: expected 400, got %d", resp.StatusCode)
+	}
+}
@@ -3,6 +3,7 @@ package tests
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"memstash/internal/server"
 	"memstash/internal/store"
 	"net"
@@ -23,6 +24,14 @@ func startTestServer(t *testing.T, capacity int) (*server.Server, string) {
 	return srv, addr
 }
 
+// helper: start a server on port 0 around an existing store
+func startServerWithStore(t *testing.T, s *store.Store) *server.Server {
+	t.Helper()
+	srv := server.NewServer(s, 0)
+	<-srv.StartAndReady()
+	return srv
+}
+
 // helper: dial and return a reader + conn
 func dialServer(t *testing.T, addr string) (net.Conn, *bufio.Reader) {
 	t.Helper()
@@ -47,27 +56,34 @@ func readResponse(reader *bufio.Reader) string {
 		return ""
 	}
 
-	// Simple string (+), Error (-), Integer (:)
-	if len(line) > 0 && (line[0] == '+' || line[0] == '-' || line[0] == ':') {
+	// Simple string (+), Error (-), Integer (:), and the RESP3 null (_),
+	// double (,), boolean (#) and big number (()
+	if len(line) > 0 && strings.IndexByte("+-:_,#(", line[0]) >= 0 {
 		return line
 	}
 
-	// Bulk string ($)
-	if len(line) > 0 && line[0] == '$' {
+	// Bulk string ($), verbatim string (=)
+	if len(line) > 0 && (line[0] == '$' || line[0] == '=') {
 		sizeStr := strings.TrimSpace(line[1:])
 		if sizeStr == "-1" {
 			return line // null bulk string
 		}
-		// Read the data line
-		data, _ := reader.ReadString('\n')
-		return line + data
+		// Read the data by length; it may contain newlines
+		var size int
+		fmt.Sscanf(sizeStr, "%d", &size)
+		data := make([]byte, size+2)
+		io.ReadFull(reader, data)
+		return line + string(data)
 	}
 
-	// Array (*)
-	if len(line) > 0 && line[0] == '*' {
+	// Array (*), set (~), push (>) and map (%, two elements per entry)
+	if len(line) > 0 && strings.IndexByte("*~>%", line[0]) >= 0 {
 		countStr := strings.TrimSpace(line[1:])
 		var count int
 		fmt.Sscanf(countStr, "%d", &count)
+		if line[0] == '%' {
+			count *= 2
+		}
 		result := line
 		for i := 0; i < count; i++ {
 			result += readResponse(reader)
@@ -149,6 +165,16 @@ func TestServerDelete(t *testing.T) {
 	if resp != "$-1\r\n" {
 		t.Errorf("GET after DEL: expected $-1\\r\\n, got %q", resp)
 	}
+
+	// Every key is deleted, and only those that existed are counted
+	sendCommand(conn, reader, "SET a 1")
+	sendCommand(conn, reader, "RPUSH b 2")
+	if resp := sendCommand(conn, reader, "DEL a missing b"); resp != ":2\r\n" {
+		t.Errorf("DEL with several keys: expected :2, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "EXISTS b"); resp != ":0\r\n" {
+		t.Errorf("EXISTS after DEL: expected :0, got %q", resp)
+	}
 }
 
 func TestServerExists(t *testing.T) {
@@ -340,3 +366,408 @@ func TestServerSharedStore(t *testing.T) {
 		t.Errorf("Shared store: expected $10\\r\\nshared_val\\r\\n, got %q", resp)
 	}
 }
+
+// helper: encode args as a RESP multi-bulk request, the way client libraries do
+func encodeCommand(args ...string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return b.String()
+}
+
+func TestServerMultiBulkRequests(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+
+	value := "line one\r\nline two\x00 with spaces "
+	fmt.Fprint(conn, encodeCommand("SET", "bin key", value))
+	resp := readResponse(reader)
+	if resp != "+OK\r\n" {
+		t.Fatalf("SET: expected +OK\\r\\n, got %q", resp)
+	}
+
+	fmt.Fprint(conn, encodeCommand("GET", "bin key"))
+	line, _ := reader.ReadString('\n')
+	expectedHeader := fmt.Sprintf("$%d\r\n", len(value))
+	if line != expectedHeader {
+		t.Fatalf("GET: expected header %q, got %q", expectedHeader, line)
+	}
+	buf := make([]byte, len(value)+2)
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		t.Fatalf("GET: failed to read body: %v", err)
+	}
+	if string(buf) != value+"\r\n" {
+		t.Errorf("GET: expected %q, got %q", value, buf[:len(value)])
+	}
+}
+
+func TestServerProtocolError(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+
+	fmt.Fprint(conn, "*1\r\n$abc\r\n")
+	resp := readResponse(reader)
+	if !strings.HasPrefix(resp, "-ERR Protocol error") {
+		t.Errorf("Expected protocol error, got %q", resp)
+	}
+	if _, err := reader.ReadString('\n'); err == nil {
+		t.Error("Expected connection to be closed after protocol error")
+	}
+}
+
+func TestServerHelloNegotiatesRESP3(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+
+	resp := sendCommand(conn, reader, "HELLO 3")
+	if !strings.HasPrefix(resp, "%7\r\n") {
+		t.Fatalf("HELLO 3: expected a 7 entry map, got %q", resp)
+	}
+	if !strings.Contains(resp, "$5\r\nproto\r\n:3\r\n") {
+		t.Errorf("HELLO 3: expected proto 3 in reply, got %q", resp)
+	}
+
+	// Null replies use the RESP3 null type
+	resp = sendCommand(conn, reader, "GET missing")
+	if resp != "_\r\n" {
+		t.Errorf("GET missing (RESP3): expected _\\r\\n, got %q", resp)
+	}
+
+	// STATS comes back as a real map
+	sendCommand(conn, reader, "SET k v")
+	resp = sendCommand(conn, reader, "STATS")
+	if !strings.HasPrefix(resp, "%7\r\n") || !strings.Contains(resp, "$4\r\nkeys\r\n:1\r\n") {
+		t.Errorf("STATS (RESP3): expected map with keys=1, got %q", resp)
+	}
+
+	// Switching back to RESP2 restores the old reply shapes
+	resp = sendCommand(conn, reader, "HELLO 2")
+	if !strings.HasPrefix(resp, "*14\r\n") {
+		t.Errorf("HELLO 2: expected a flat 14 element array, got %q", resp)
+	}
+	resp = sendCommand(conn, reader, "GET missing")
+	if resp != "$-1\r\n" {
+		t.Errorf("GET missing (RESP2): expected $-1\\r\\n, got %q", resp)
+	}
+}
+
+func TestServerHelloUnsupportedVersion(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+
+	resp := sendCommand(conn, reader, "HELLO 4")
+	if !strings.HasPrefix(resp, "-NOPROTO") {
+		t.Errorf("HELLO 4: expected -NOPROTO error, got %q", resp)
+	}
+}
+
+func TestServerPipelining(t *testing.T) {
+	srv, addr := startTestServer(t, 2000)
+	defer srv.Stop()
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+
+	// Send the whole batch in one write, mixing multi-bulk and inline commands
+	const n = 1000
+	var batch strings.Builder
+	for i := 0; i < n; i++ {
+		batch.WriteString(encodeCommand("SET", fmt.Sprintf("key%d", i), fmt.Sprintf("value%d", i)))
+		fmt.Fprintf(&batch, "GET key%d\r\n", i)
+	}
+	if _, err := conn.Write([]byte(batch.String())); err != nil {
+		t.Fatalf("Failed to write batch: %v", err)
+	}
+
+	// Replies must come back in order
+	for i := 0; i < n; i++ {
+		if resp := readResponse(reader); resp != "+OK\r\n" {
+			t.Fatalf("Reply %d to SET: expected +OK\\r\\n, got %q", i, resp)
+		}
+		value := fmt.Sprintf("value%d", i)
+		expected := fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+		if resp := readResponse(reader); resp != expected {
+			t.Fatalf("Reply %d to GET: expected %q, got %q", i, expected, resp)
+		}
+	}
+}
+
+func BenchmarkServerPipelinedSet(b *testing.B) {
+	s := store.NewStore(b.N + 1)
+	srv := server.NewServer(s, 0)
+	<-srv.StartAndReady()
+	defer srv.Stop()
+
+	conn, err := net.Dial("tcp", srv.Addr().String())
+	if err != nil {
+		b.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	const batchSize = 1000
+	b.ResetTimer()
+	for sent := 0; sent < b.N; sent += batchSize {
+		count := min(batchSize, b.N-sent)
+		var batch strings.Builder
+		for i := 0; i < count; i++ {
+			batch.WriteString(encodeCommand("SET", fmt.Sprintf("key%d", sent+i), "value"))
+		}
+		conn.Write([]byte(batch.String()))
+		for i := 0; i < count; i++ {
+			readResponse(reader)
+		}
+	}
+}
+
+func TestServerMultiExec(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+
+	if resp := sendCommand(conn, reader, "MULTI"); resp != "+OK\r\n" {
+		t.Fatalf("MULTI: expected +OK\\r\\n, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "SET a 1"); resp != "+QUEUED\r\n" {
+		t.Fatalf("SET in MULTI: expected +QUEUED\\r\\n, got %q", resp)
+	}
+	sendCommand(conn, reader, "SET b 2")
+	sendCommand(conn, reader, "GET a")
+
+	// Nothing has run yet
+	conn2, reader2 := dialServer(t, addr)
+	defer conn2.Close()
+	if resp := sendCommand(conn2, reader2, "GET a"); resp != "$-1\r\n" {
+		t.Errorf("GET before EXEC: expected $-1\\r\\n, got %q", resp)
+	}
+
+	resp := sendCommand(conn, reader, "EXEC")
+	expected := "*3\r\n+OK\r\n+OK\r\n$1\r\n1\r\n"
+	if resp != expected {
+		t.Errorf("EXEC: expected %q, got %q", expected, resp)
+	}
+	if resp := sendCommand(conn2, reader2, "GET b"); resp != "$1\r\n2\r\n" {
+		t.Errorf("GET after EXEC: expected $1\\r\\n2\\r\\n, got %q", resp)
+	}
+}
+
+func TestServerMultiErrors(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+
+	if resp := sendCommand(conn, reader, "EXEC"); !strings.HasPrefix(resp, "-ERR EXEC without MULTI") {
+		t.Errorf("EXEC without MULTI: got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "DISCARD"); !strings.HasPrefix(resp, "-ERR DISCARD without MULTI") {
+		t.Errorf("DISCARD without MULTI: got %q", resp)
+	}
+
+	// DISCARD drops the queue
+	sendCommand(conn, reader, "MULTI")
+	if resp := sendCommand(conn, reader, "MULTI"); !strings.HasPrefix(resp, "-ERR") {
+		t.Errorf("Nested MULTI: expected error, got %q", resp)
+	}
+	sendCommand(conn, reader, "SET dropped 1")
+	if resp := sendCommand(conn, reader, "DISCARD"); resp != "+OK\r\n" {
+		t.Errorf("DISCARD: expected +OK\\r\\n, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "GET dropped"); resp != "$-1\r\n" {
+		t.Errorf("GET after DISCARD: expected $-1\\r\\n, got %q", resp)
+	}
+
+	// An unknown command aborts the whole transaction
+	sendCommand(conn, reader, "MULTI")
+	sendCommand(conn, reader, "SET kept 1")
+	if resp := sendCommand(conn, reader, "NOSUCHCMD"); !strings.HasPrefix(resp, "-ERR") {
+		t.Errorf("Unknown command in MULTI: expected error, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "EXEC"); !strings.HasPrefix(resp, "-EXECABORT") {
+		t.Errorf("EXEC after queue error: expected -EXECABORT, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "GET kept"); resp != "$-1\r\n" {
+		t.Errorf("GET after EXECABORT: expected $-1\\r\\n, got %q", resp)
+	}
+}
+
+func TestServerWatch(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+	other, otherReader := dialServer(t, addr)
+	defer other.Close()
+
+	sendCommand(conn, reader, "SET balance 100")
+
+	// Untouched watched key: EXEC runs
+	sendCommand(conn, reader, "WATCH balance")
+	sendCommand(conn, reader, "MULTI")
+	sendCommand(conn, reader, "SET balance 90")
+	if resp := sendCommand(conn, reader, "EXEC"); resp != "*1\r\n+OK\r\n" {
+		t.Errorf("EXEC with untouched WATCH: expected *1\\r\\n+OK\\r\\n, got %q", resp)
+	}
+
+	// Watched key modified by another client: EXEC aborts
+	sendCommand(conn, reader, "WATCH balance")
+	sendCommand(other, otherReader, "SET balance 50")
+	sendCommand(conn, reader, "MULTI")
+	sendCommand(conn, reader, "SET balance 80")
+	if resp := sendCommand(conn, reader, "EXEC"); resp != "*-1\r\n" {
+		t.Errorf("EXEC with modified WATCH: expected *-1\\r\\n, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "GET balance"); resp != "$2\r\n50\r\n" {
+		t.Errorf("GET after aborted EXEC: expected 50, got %q", resp)
+	}
+
+	// Watching a missing key that gets created also aborts
+	sendCommand(conn, reader, "WATCH fresh")
+	sendCommand(other, otherReader, "SET fresh 1")
+	sendCommand(conn, reader, "MULTI")
+	sendCommand(conn, reader, "SET fresh 2")
+	if resp := sendCommand(conn, reader, "EXEC"); resp != "*-1\r\n" {
+		t.Errorf("EXEC after watched key was created: expected *-1\\r\\n, got %q", resp)
+	}
+
+	// So does one created and deleted again in between
+	sendCommand(conn, reader, "WATCH gone")
+	sendCommand(other, otherReader, "SET gone 1")
+	sendCommand(other, otherReader, "DEL gone")
+	sendCommand(conn, reader, "MULTI")
+	sendCommand(conn, reader, "SET gone 2")
+	if resp := sendCommand(conn, reader, "EXEC"); resp != "*-1\r\n" {
+		t.Errorf("EXEC after watched key was created and deleted: expected *-1\\r\\n, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "EXISTS gone"); resp != ":0\r\n" {
+		t.Errorf("EXISTS after aborted EXEC: expected :0, got %q", resp)
+	}
+
+	// UNWATCH forgets the keys
+	sendCommand(conn, reader, "WATCH balance")
+	sendCommand(other, otherReader, "SET balance 10")
+	sendCommand(conn, reader, "UNWATCH")
+	sendCommand(conn, reader, "MULTI")
+	sendCommand(conn, reader, "SET balance 5")
+	if resp := sendCommand(conn, reader, "EXEC"); resp != "*1\r\n+OK\r\n" {
+		t.Errorf("EXEC after UNWATCH: expected *1\\r\\n+OK\\r\\n, got %q", resp)
+	}
+}
+
+func TestServerPubSub(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+
+	subConn, subReader := dialServer(t, addr)
+	defer subConn.Close()
+	pubConn, pubReader := dialServer(t, addr)
+	defer pubConn.Close()
+
+	resp := sendCommand(subConn, subReader, "SUBSCRIBE news")
+	if resp != "*3\r\n$9\r\nsubscribe\r\n$4\r\nnews\r\n:1\r\n" {
+		t.Fatalf("SUBSCRIBE: unexpected reply %q", resp)
+	}
+	resp = sendCommand(subConn, subReader, "PSUBSCRIBE user.*")
+	if resp != "*3\r\n$10\r\npsubscribe\r\n$6\r\nuser.*\r\n:2\r\n" {
+		t.Fatalf("PSUBSCRIBE: unexpected reply %q", resp)
+	}
+
+	// Regular commands are refused in subscriber mode
+	resp = sendCommand(subConn, subReader, "GET foo")
+	if !strings.HasPrefix(resp, "-ERR Can't execute 'get'") {
+		t.Errorf("GET in subscriber mode: expected error, got %q", resp)
+	}
+
+	if resp := sendCommand(pubConn, pubReader, "PUBLISH news hello"); resp != ":1\r\n" {
+		t.Errorf("PUBLISH news: expected :1\\r\\n, got %q", resp)
+	}
+	if resp := readResponse(subReader); resp != "*3\r\n$7\r\nmessage\r\n$4\r\nnews\r\n$5\r\nhello\r\n" {
+		t.Errorf("Expected message, got %q", resp)
+	}
+
+	sendCommand(pubConn, pubReader, "PUBLISH user.7 hi")
+	if resp := readResponse(subReader); resp != "*4\r\n$8\r\npmessage\r\n$6\r\nuser.*\r\n$6\r\nuser.7\r\n$2\r\nhi\r\n" {
+		t.Errorf("Expected pmessage, got %q", resp)
+	}
+
+	if resp := sendCommand(pubConn, pubReader, "PUBSUB CHANNELS"); resp != "*1\r\n$4\r\nnews\r\n" {
+		t.Errorf("PUBSUB CHANNELS: got %q", resp)
+	}
+	if resp := sendCommand(pubConn, pubReader, "PUBSUB NUMSUB news other"); resp != "*4\r\n$4\r\nnews\r\n:1\r\n$5\r\nother\r\n:0\r\n" {
+		t.Errorf("PUBSUB NUMSUB: got %q", resp)
+	}
+	if resp := sendCommand(pubConn, pubReader, "PUBSUB NUMPAT"); resp != ":1\r\n" {
+		t.Errorf("PUBSUB NUMPAT: got %q", resp)
+	}
+
+	// Leaving every subscription returns the client to normal mode
+	sendCommand(subConn, subReader, "UNSUBSCRIBE")
+	if resp := sendCommand(subConn, subReader, "PUNSUBSCRIBE"); resp != "*3\r\n$12\r\npunsubscribe\r\n$6\r\nuser.*\r\n:0\r\n" {
+		t.Errorf("PUNSUBSCRIBE: got %q", resp)
+	}
+	if resp := sendCommand(subConn, subReader, "GET foo"); resp != "$-1\r\n" {
+		t.Errorf("GET after unsubscribing: expected $-1\\r\\n, got %q", resp)
+	}
+}
+
+func TestServerPubSubRESP3Push(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+
+	sendCommand(conn, reader, "HELLO 3")
+	if resp := sendCommand(conn, reader, "SUBSCRIBE ch"); !strings.HasPrefix(resp, ">3\r\n") {
+		t.Fatalf("SUBSCRIBE (RESP3): expected push frame, got %q", resp)
+	}
+
+	// RESP3 clients may keep issuing commands while subscribed
+	if resp := sendCommand(conn, reader, "PUBLISH ch payload"); resp != ":1\r\n" {
+		t.Errorf("PUBLISH from subscriber: expected :1\\r\\n, got %q", resp)
+	}
+	if resp := readResponse(reader); resp != ">3\r\n$7\r\nmessage\r\n$2\r\nch\r\n$7\r\npayload\r\n" {
+		t.Errorf("Expected push message, got %q", resp)
+	}
+}
+
+func TestServerKeysPattern(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+
+	sendCommand(conn, reader, "SET user:1 a")
+	sendCommand(conn, reader, "SET user:2 b")
+	sendCommand(conn, reader, "SET order:1 c")
+
+	resp := sendCommand(conn, reader, "KEYS user:*")
+	if !strings.HasPrefix(resp, "*2\r\n") || !strings.Contains(resp, "user:1") || !strings.Contains(resp, "user:2") {
+		t.Errorf("KEYS user:*: expected user:1 and user:2, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "KEYS nomatch*"); resp != "*0\r\n" {
+		t.Errorf("KEYS nomatch*: expected *0\\r\\n, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "KEYS *"); !strings.HasPrefix(resp, "*3\r\n") {
+		t.Errorf("KEYS *: expected 3 keys, got %q", resp)
+	}
+}
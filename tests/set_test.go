@@ -0,0 +1,304 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"memstash/internal/store"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetAddRemoveMembers(t *testing.T) {
+	s := store.NewStore(10)
+
+	if n, err := s.SAdd("tags", "go", "redis", "go"); err != nil || n != 2 {
+		t.Fatalf("SAdd: expected 2 new members, got %d, %v", n, err)
+	}
+	if n, _ := s.SAdd("tags", "redis", "cache"); n != 1 {
+		t.Errorf("SAdd with one new member: expected 1, got %d", n)
+	}
+	if members, _ := s.SMembers("tags"); fmt.Sprint(members) != "[cache go redis]" {
+		t.Errorf("SMembers: got %v", members)
+	}
+	if ok, _ := s.SIsMember("tags", "go"); !ok {
+		t.Error("SIsMember go: expected true")
+	}
+	if ok, _ := s.SIsMember("tags", "java"); ok {
+		t.Error("SIsMember java: expected false")
+	}
+	if n, _ := s.SCard("tags"); n != 3 {
+		t.Errorf("SCard: expected 3, got %d", n)
+	}
+
+	if n, _ := s.SRem("tags", "go", "missing"); n != 1 {
+		t.Errorf("SRem: expected 1, got %d", n)
+	}
+	if members, _ := s.SMembers("missing"); len(members) != 0 {
+		t.Errorf("SMembers on a missing key: got %v", members)
+	}
+
+	// Removing the last member deletes the key
+	s.SRem("tags", "redis", "cache")
+	if s.Exists("tags") {
+		t.Error("Empty set still exists")
+	}
+}
+
+func TestSetAlgebra(t *testing.T) {
+	s := store.NewStore(10)
+	s.SAdd("a", "1", "2", "3", "4")
+	s.SAdd("b", "3", "4", "5")
+	s.SAdd("c", "4", "6")
+
+	for _, tc := range []struct {
+		name     string
+		op       func(...string) ([]string, error)
+		keys     []string
+		expected string
+	}{
+		{"SInter", s.SInter, []string{"a", "b", "c"}, "[4]"},
+		{"SInter with a missing key", s.SInter, []string{"a", "missing"}, "[]"},
+		{"SUnion", s.SUnion, []string{"a", "b", "c", "missing"}, "[1 2 3 4 5 6]"},
+		{"SDiff", s.SDiff, []string{"a", "b", "missing"}, "[1 2]"},
+		{"SDiff of a missing key", s.SDiff, []string{"missing", "a"}, "[]"},
+	} {
+		got, err := tc.op(tc.keys...)
+		if err != nil || fmt.Sprint(got) != tc.expected {
+			t.Errorf("%s: expected %s, got %v, %v", tc.name, tc.expected, got, err)
+		}
+	}
+
+	// STORE variants replace the destination whatever it held
+	s.Set("dst", "string")
+	if n, err := s.SUnionStore("dst", "b", "c"); err != nil || n != 4 {
+		t.Fatalf("SUnionStore: expected 4, got %d, %v", n, err)
+	}
+	if members, _ := s.SMembers("dst"); fmt.Sprint(members) != "[3 4 5 6]" {
+		t.Errorf("SUnionStore result: got %v", members)
+	}
+	if n, _ := s.SInterStore("dst", "dst", "a"); n != 2 {
+		t.Errorf("SInterStore with dst as a source: expected 2, got %d", n)
+	}
+	if n, _ := s.SDiffStore("dst", "b", "a"); n != 1 {
+		t.Errorf("SDiffStore: expected 1, got %d", n)
+	}
+	if members, _ := s.SMembers("dst"); fmt.Sprint(members) != "[5]" {
+		t.Errorf("SDiffStore result: got %v", members)
+	}
+
+	// An empty result deletes the destination
+	if n, _ := s.SInterStore("dst", "a", "missing"); n != 0 || s.Exists("dst") {
+		t.Errorf("SInterStore with an empty result: got %d, exists %v", n, s.Exists("dst"))
+	}
+}
+
+func TestSetRandomMembers(t *testing.T) {
+	s := store.NewStore(10)
+	for i := 0; i < 50; i++ {
+		s.SAdd("s", fmt.Sprint(i))
+	}
+
+	for _, count := range []int{1, 5, 20, 49, 50, 80} {
+		members, err := s.SRandMember("s", count)
+		if err != nil {
+			t.Fatalf("SRandMember %d: %v", count, err)
+		}
+		expected := min(count, 50)
+		if len(members) != expected || !distinct(members) {
+			t.Errorf("SRandMember %d: expected %d distinct members, got %v", count, expected, members)
+		}
+	}
+	if members, _ := s.SRandMember("s", -80); len(members) != 80 {
+		t.Errorf("SRandMember -80: expected 80 members, got %d", len(members))
+	}
+	if n, _ := s.SCard("s"); n != 50 {
+		t.Errorf("SRandMember removed members: SCard is %d", n)
+	}
+	if members, _ := s.SRandMember("missing", 3); members != nil {
+		t.Errorf("SRandMember on a missing key: got %v", members)
+	}
+
+	popped, _ := s.SPop("s", 10)
+	if len(popped) != 10 || !distinct(popped) {
+		t.Fatalf("SPop 10: got %v", popped)
+	}
+	for _, m := range popped {
+		if ok, _ := s.SIsMember("s", m); ok {
+			t.Errorf("Popped member %s is still in the set", m)
+		}
+	}
+	if _, err := s.SPop("s", -1); !errors.Is(err, store.ErrNegativeCount) {
+		t.Errorf("SPop -1: expected ErrNegativeCount, got %v", err)
+	}
+	if n, _ := s.SCard("s"); n != 40 {
+		t.Errorf("SPop -1 changed the set: SCard is %d", n)
+	}
+	if popped, _ := s.SPop("s", 100); len(popped) != 40 {
+		t.Errorf("SPop past the set size: expected 40 members, got %d", len(popped))
+	}
+	if s.Exists("s") {
+		t.Error("Set emptied by SPop still exists")
+	}
+}
+
+func distinct(members []string) bool {
+	sorted := append([]string(nil), members...)
+	sort.Strings(sorted)
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i] == sorted[i-1] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSetScan(t *testing.T) {
+	s := store.NewStore(10)
+	for i := 0; i < 100; i++ {
+		s.SAdd("s", fmt.Sprintf("m%d", i))
+	}
+
+	seen := make(map[string]bool)
+	var cursor uint64
+	for {
+		next, members, err := s.SScan("s", cursor, "", 7)
+		if err != nil {
+			t.Fatalf("SScan: %v", err)
+		}
+		for _, m := range members {
+			seen[m] = true
+		}
+		// Remove members as the scan goes; the others must still be seen
+		s.SRem("s", "m0", "m1", "m2")
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+	for i := 3; i < 100; i++ {
+		if !seen[fmt.Sprintf("m%d", i)] {
+			t.Fatalf("SScan missed m%d", i)
+		}
+	}
+
+	if _, members, _ := s.SScan("s", 0, "m9?", 100); len(members) != 10 {
+		t.Errorf("SScan MATCH m9?: expected 10 members, got %v", members)
+	}
+}
+
+func TestSetWrongTypeEvictionAndExpiry(t *testing.T) {
+	s := store.NewStore(2)
+	s.SAdd("s", "a")
+	s.RPush("l", "x")
+
+	if _, err := s.SAdd("l", "a"); !errors.Is(err, store.ErrWrongType) {
+		t.Errorf("SAdd on a list: expected ErrWrongType, got %v", err)
+	}
+	if _, err := s.SUnion("s", "l"); !errors.Is(err, store.ErrWrongType) {
+		t.Errorf("SUnion with a list: expected ErrWrongType, got %v", err)
+	}
+	if _, err := s.Get("s"); !errors.Is(err, store.ErrWrongType) {
+		t.Errorf("Get on a set: expected ErrWrongType, got %v", err)
+	}
+	if s.Type("s") != "set" {
+		t.Errorf("Type: expected set, got %q", s.Type("s"))
+	}
+
+	// Checking a member counts as a use of the key
+	s.SIsMember("s", "a")
+	s.Set("new", "v")
+	if !s.Exists("s") || s.Exists("l") {
+		t.Error("Expected the list, not the set, to be evicted")
+	}
+
+	// Every member is counted in the dataset size
+	before := s.Info().DatasetBytes
+	s.SAdd("s", strings.Repeat("x", 1000))
+	if grown := s.Info().DatasetBytes - before; grown < 1000 {
+		t.Errorf("DatasetBytes grew by %d after adding a 1000 byte member", grown)
+	}
+
+	// A set expires like any other key
+	s.SetExpiry("s", 30*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	if n, _ := s.SCard("s"); n != 0 || s.Exists("s") {
+		t.Error("Expired set still exists")
+	}
+	if n, err := s.SAdd("s", "b"); err != nil || n != 1 {
+		t.Errorf("SAdd after expiry: got %d, %v", n, err)
+	}
+}
+
+func TestSetSnapshot(t *testing.T) {
+	path := "/tmp/test_memstash_set_snapshot.json"
+	defer os.Remove(path)
+
+	s1 := store.NewStore(10)
+	s1.SAdd("flags", "dark-mode", "beta", "new-search")
+	if err := s1.SaveSnapshot(path); err != nil {
+		t.Fatalf("SaveSnapshot: %v", err)
+	}
+
+	s2 := store.NewStore(10)
+	if err := s2.LoadSnapshot(path); err != nil {
+		t.Fatalf("LoadSnapshot: %v", err)
+	}
+	if members, _ := s2.SMembers("flags"); fmt.Sprint(members) != "[beta dark-mode new-search]" {
+		t.Errorf("Loaded set: got %v", members)
+	}
+}
+
+func TestServerSetCommands(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+
+	for _, tc := range []struct{ cmd, expected string }{
+		{"SADD a x y z", ":3\r\n"},
+		{"SADD b y z w", ":3\r\n"},
+		{"SREM a z missing", ":1\r\n"},
+		{"SMEMBERS a", encodeCommand("x", "y")},
+		{"SISMEMBER a x", ":1\r\n"},
+		{"SISMEMBER a z", ":0\r\n"},
+		{"SCARD b", ":3\r\n"},
+		{"SINTER a b", encodeCommand("y")},
+		{"SUNION a b", encodeCommand("w", "x", "y", "z")},
+		{"SDIFF b a", encodeCommand("w", "z")},
+		{"SUNIONSTORE u a b", ":4\r\n"},
+		{"SINTERSTORE u a missing", ":0\r\n"},
+		{"EXISTS u", ":0\r\n"},
+		{"SDIFFSTORE d a b", ":1\r\n"},
+		{"SRANDMEMBER d", "$1\r\nx\r\n"},
+		{"SRANDMEMBER d -2", encodeCommand("x", "x")},
+		{"SRANDMEMBER missing", "$-1\r\n"},
+		{"SRANDMEMBER missing 2", "*0\r\n"},
+		{"SPOP d -1", "-ERR value is out of range, must be positive\r\n"},
+		{"SPOP d", "$1\r\nx\r\n"},
+		{"SPOP d", "$-1\r\n"},
+		{"SPOP d 2", "*0\r\n"},
+		{"SSCAN b 0 MATCH w", "*2\r\n$1\r\n0\r\n" + encodeCommand("w")},
+		{"SSCAN b 0 TYPE set", "-ERR syntax error\r\n"},
+		{"TYPE a", "+set\r\n"},
+		{"SET str v", "+OK\r\n"},
+		{"SADD str x", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{"SINTER a str", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+	} {
+		if resp := sendCommand(conn, reader, tc.cmd); resp != tc.expected {
+			t.Errorf("%s: expected %q, got %q", tc.cmd, tc.expected, resp)
+		}
+	}
+
+	// RESP3 clients get members as a set
+	sendCommand(conn, reader, "HELLO 3")
+	if resp := sendCommand(conn, reader, "SMEMBERS a"); !strings.HasPrefix(resp, "~2\r\n") {
+		t.Errorf("SMEMBERS over RESP3: expected a set, got %q", resp)
+	}
+
+	session := srv.NewSession("cli")
+	if resp := session.Do([]string{"SCARD", "b"}); resp != ":3\r\n" {
+		t.Errorf("SCARD from a session: got %q", resp)
+	}
+}
@@ -0,0 +1,268 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"io"
+	"memstash/internal/lifecycle"
+	"memstash/internal/server"
+	"memstash/internal/store"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeComponent records when it was shut down.
+type fakeComponent struct {
+	name  string
+	steps *[]string
+	mu    *sync.Mutex
+}
+
+func (f fakeComponent) Shutdown(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	*f.steps = append(*f.steps, f.name)
+	return nil
+}
+
+func TestLifecycleOrderAndOnce(t *testing.T) {
+	var mu sync.Mutex
+	var steps []string
+	record := func(step string) {
+		mu.Lock()
+		defer mu.Unlock()
+		steps = append(steps, step)
+	}
+
+	c := lifecycle.New(time.Second)
+	c.Add(fakeComponent{name: "listener", steps: &steps, mu: &mu})
+	c.OnSave(func() error { record("save"); return nil })
+	c.OnStop(func() { record("stop tickers") })
+
+	if err := c.Shutdown(lifecycle.Save); err != nil {
+		t.Fatal(err)
+	}
+	c.Shutdown(lifecycle.Save) // no-op
+	select {
+	case <-c.Done():
+	default:
+		t.Error("Expected Done to be closed")
+	}
+	if strings.Join(steps, ",") != "listener,save,stop tickers" {
+		t.Errorf("Unexpected order %v", steps)
+	}
+}
+
+func TestLifecycleNoSaveAndSaveError(t *testing.T) {
+	saved := false
+	c := lifecycle.New(time.Second)
+	c.OnSave(func() error { saved = true; return nil })
+	c.Shutdown(lifecycle.NoSave)
+	if saved {
+		t.Error("Expected NOSAVE to skip saving")
+	}
+
+	c = lifecycle.New(time.Second)
+	c.OnSave(func() error { return errors.New("disk full") })
+	stopped := false
+	c.OnStop(func() { stopped = true })
+	if err := c.Shutdown(lifecycle.Save); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("Expected the save error, got %v", err)
+	}
+	if !stopped {
+		t.Error("Expected stop hooks to run even if saving fails")
+	}
+
+	if _, err := lifecycle.ParseMode("later"); err == nil {
+		t.Error("Expected an invalid mode to be rejected")
+	}
+}
+
+func TestServerShutdownDrainsInFlightCommands(t *testing.T) {
+	s := store.NewStore(10)
+	s.Set("k", "v")
+	srv := startServerWithStore(t, s)
+	addr := srv.Addr().String()
+
+	busy, busyReader := dialServer(t, addr)
+	defer busy.Close()
+	idle, idleReader := dialServer(t, addr)
+	defer idle.Close()
+	sendCommand(idle, idleReader, "PING")
+
+	// Hold the store lock so that GET is still running when Shutdown starts
+	release := make(chan struct{})
+	locked := make(chan struct{})
+	go s.Atomic(func(tx *store.Store) {
+		close(locked)
+		<-release
+	})
+	<-locked
+	busy.Write([]byte("GET k\r\n"))
+	time.Sleep(50 * time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() { done <- srv.Shutdown(context.Background()) }()
+
+	// The idle client is disconnected right away
+	idle.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := idleReader.ReadByte(); err != io.EOF {
+		t.Errorf("Expected the idle connection to be closed, got %v", err)
+	}
+	if _, err := net.DialTimeout("tcp", addr, time.Second); err == nil {
+		t.Error("Expected new connections to be refused")
+	}
+	select {
+	case <-done:
+		t.Fatal("Shutdown returned while a command was still running")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	busy.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if resp := readResponse(busyReader); resp != "$1\r\nv\r\n" {
+		t.Errorf("Expected the in-flight GET to complete, got %q", resp)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+}
+
+func TestServerShutdownDeadline(t *testing.T) {
+	s := store.NewStore(10)
+	srv := startServerWithStore(t, s)
+
+	conn, reader := dialServer(t, srv.Addr().String())
+	defer conn.Close()
+	release := make(chan struct{})
+	defer close(release)
+	locked := make(chan struct{})
+	go s.Atomic(func(tx *store.Store) {
+		close(locked)
+		<-release
+	})
+	<-locked
+	conn.Write([]byte("GET k\r\n"))
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := srv.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := reader.ReadByte(); err == nil {
+		t.Error("Expected the connection to be closed forcibly")
+	}
+}
+
+func TestServerShutdownCommand(t *testing.T) {
+	for _, tc := range []struct {
+		cmd   string
+		saved bool
+	}{
+		{"SHUTDOWN", true},
+		{"SHUTDOWN NOSAVE", false},
+	} {
+		t.Run(tc.cmd, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "dump.json")
+			s := store.NewStore(10)
+			s.SetSnapshotPath(path)
+			srv := startServerWithStore(t, s)
+
+			coordinator := lifecycle.New(time.Second)
+			coordinator.Add(srv)
+			coordinator.OnSave(func() error { return s.SaveSnapshot(s.SnapshotPath()) })
+			coordinator.OnStop(s.Close)
+			srv.SetLifecycle(coordinator)
+
+			conn, reader := dialServer(t, srv.Addr().String())
+			defer conn.Close()
+			sendCommand(conn, reader, "SET k v")
+			if resp := sendCommand(conn, reader, "SHUTDOWN NOW"); !strings.HasPrefix(resp, "-ERR syntax error") {
+				t.Errorf("SHUTDOWN NOW: got %q", resp)
+			}
+			if resp := sendCommand(conn, reader, tc.cmd); resp != "+OK\r\n" {
+				t.Fatalf("%s: got %q", tc.cmd, resp)
+			}
+			select {
+			case <-coordinator.Done():
+			case <-time.After(2 * time.Second):
+				t.Fatal("Shutdown did not complete")
+			}
+			if _, err := os.Stat(path); (err == nil) != tc.saved {
+				t.Errorf("Expected saved=%v, stat error %v", tc.saved, err)
+			}
+		})
+	}
+}
+
+func TestServerShutdownWithoutLifecycle(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+	if resp := sendCommand(conn, reader, "SHUTDOWN"); !strings.HasPrefix(resp, "-ERR SHUTDOWN is not enabled") {
+		t.Errorf("SHUTDOWN: got %q", resp)
+	}
+}
+
+func TestHTTPShutdown(t *testing.T) {
+	s := store.NewStore(10)
+	tcp := server.NewServer(s, 0)
+	<-tcp.StartAndReady()
+	h := server.NewHTTPServer(s, 0)
+	h.Attach(tcp)
+	<-h.StartAndReady()
+
+	coordinator := lifecycle.New(time.Second)
+	coordinator.Add(tcp)
+	coordinator.Add(h)
+	tcp.SetLifecycle(coordinator)
+	baseURL := "http://" + h.Addr().String()
+
+	resp, err := http.Post(baseURL+"/shutdown?mode=later", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a bad mode, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Post(baseURL+"/shutdown?mode=nosave", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("Expected 202, got %d", resp.StatusCode)
+	}
+	select {
+	case <-coordinator.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("Shutdown did not complete")
+	}
+	if _, err := http.Get(baseURL + "/stats"); err == nil {
+		t.Error("Expected the HTTP server to be stopped")
+	}
+}
+
+func TestStoreCloseStopsTickers(t *testing.T) {
+	s := store.NewStore(10)
+	s.StartTTLCleaner(time.Minute)
+	s.Close()
+	if s.TTLCleanerInterval() != 0 {
+		t.Errorf("Expected a stopped cleaner, got %v", s.TTLCleanerInterval())
+	}
+	s.SetAutoSaveInterval(time.Minute)
+	if s.AutoSaveInterval() != 0 {
+		t.Error("Expected auto-save to stay stopped after Close")
+	}
+}
@@ -0,0 +1,193 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"memstash/internal/server"
+	"memstash/internal/slowlog"
+	"memstash/internal/store"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlowLogRingBuffer(t *testing.T) {
+	l := slowlog.New(time.Millisecond, 3)
+	if l.Exceeds(500*time.Microsecond) || !l.Exceeds(time.Millisecond) {
+		t.Error("Expected the threshold to be inclusive")
+	}
+	for i := 0; i < 5; i++ {
+		l.Add(2*time.Millisecond, []string{"SET", fmt.Sprint(i)}, "127.0.0.1:1", "")
+	}
+	if l.Len() != 3 {
+		t.Fatalf("Expected 3 entries, got %d", l.Len())
+	}
+	entries := l.Get(-1)
+	if entries[0].ID != 4 || entries[2].ID != 2 || entries[0].Args[1] != "4" {
+		t.Errorf("Expected ids 4, 3, 2 newest first, got %+v", entries)
+	}
+	if entries[0].Micros != 2000 {
+		t.Errorf("Expected 2000 microseconds, got %d", entries[0].Micros)
+	}
+	if got := l.Get(1); len(got) != 1 || got[0].ID != 4 {
+		t.Errorf("Get(1): got %+v", got)
+	}
+
+	l.SetMaxLen(2)
+	if entries := l.Get(-1); len(entries) != 2 || entries[0].ID != 4 || entries[1].ID != 3 {
+		t.Errorf("After shrinking: got %+v", entries)
+	}
+	l.Add(time.Second, []string{"GET", "x"}, "", "")
+	if entries := l.Get(-1); entries[0].ID != 5 || entries[1].ID != 4 {
+		t.Errorf("After shrinking and adding: got %+v", entries)
+	}
+
+	l.Reset()
+	l.Add(time.Second, []string{"GET", "x"}, "", "")
+	if entries := l.Get(-1); len(entries) != 1 || entries[0].ID != 6 {
+		t.Errorf("Expected ids to keep increasing after reset, got %+v", entries)
+	}
+
+	l.SetThreshold(-1)
+	if l.Exceeds(time.Hour) {
+		t.Error("Expected a negative threshold to disable the log")
+	}
+}
+
+func TestSlowLogZeroMaxLen(t *testing.T) {
+	l := slowlog.New(0, 10)
+	l.Add(time.Second, []string{"GET", "x"}, "", "")
+	if err := l.SetMaxLen(0); err != nil {
+		t.Fatalf("SetMaxLen(0): %v", err)
+	}
+	if entries := l.Get(10); len(entries) != 0 {
+		t.Errorf("Get with maxLen 0: got %+v", entries)
+	}
+	l.Add(time.Second, []string{"GET", "x"}, "", "")
+	if l.Len() != 0 || len(l.Get(-1)) != 0 {
+		t.Errorf("Expected a log with maxLen 0 to stay empty, got %d entries", l.Len())
+	}
+
+	// Over TCP too: SLOWLOG GET must not take the connection down
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+	sendCommand(conn, reader, "CONFIG SET slowlog-max-len 0")
+	if resp := sendCommand(conn, reader, "SLOWLOG GET"); resp != "*0\r\n" {
+		t.Errorf("SLOWLOG GET with max len 0: got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "PING"); resp != "+PONG\r\n" {
+		t.Errorf("PING after SLOWLOG GET: got %q", resp)
+	}
+}
+
+func TestSlowLogTruncatesArguments(t *testing.T) {
+	l := slowlog.New(0, 10)
+	args := []string{"SET", strings.Repeat("k", 200)}
+	for i := 0; i < 40; i++ {
+		args = append(args, "v")
+	}
+	l.Add(0, args, "", "")
+	got := l.Get(1)[0].Args
+	if len(got) != 32 || got[31] != "... (11 more arguments)" {
+		t.Errorf("Expected 32 arguments ending with a summary, got %d: %q", len(got), got[len(got)-1])
+	}
+	if got[1] != strings.Repeat("k", 128)+"... (72 more bytes)" {
+		t.Errorf("Expected a shortened argument, got %q", got[1])
+	}
+}
+
+func TestServerSlowLog(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+	sendCommand(conn, reader, "CLIENT SETNAME tester")
+	// Log everything from here on
+	if resp := sendCommand(conn, reader, "CONFIG SET slowlog-log-slower-than 0"); resp != "+OK\r\n" {
+		t.Fatalf("CONFIG SET: got %q", resp)
+	}
+	sendCommand(conn, reader, "SET k v")
+	sendCommand(conn, reader, "AUTH default nopass")
+
+	if resp := sendCommand(conn, reader, "SLOWLOG LEN"); resp != ":2\r\n" {
+		t.Errorf("SLOWLOG LEN: expected 2 (CONFIG and SET, not AUTH), got %q", resp)
+	}
+	// Newest first: SLOWLOG LEN, then SET
+	resp := sendCommand(conn, reader, "SLOWLOG GET 2")
+	if !strings.HasPrefix(resp, "*2\r\n*6\r\n") {
+		t.Fatalf("SLOWLOG GET 2: expected two entries of 6 fields, got %q", resp)
+	}
+	for _, want := range []string{"*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$1\r\nv\r\n", conn.LocalAddr().String(), "$6\r\ntester\r\n"} {
+		if !strings.Contains(resp, want) {
+			t.Errorf("SLOWLOG GET 2: expected %q in %q", want, resp)
+		}
+	}
+
+	if resp := sendCommand(conn, reader, "SLOWLOG RESET"); resp != "+OK\r\n" {
+		t.Errorf("SLOWLOG RESET: got %q", resp)
+	}
+	// The RESET itself is logged once it finishes
+	if resp := sendCommand(conn, reader, "SLOWLOG LEN"); resp != ":1\r\n" {
+		t.Errorf("SLOWLOG LEN after reset: got %q", resp)
+	}
+	sendCommand(conn, reader, "CONFIG SET slowlog-log-slower-than -1")
+	sendCommand(conn, reader, "SLOWLOG RESET")
+	sendCommand(conn, reader, "SET k v")
+	if resp := sendCommand(conn, reader, "SLOWLOG LEN"); resp != ":0\r\n" {
+		t.Errorf("Expected a disabled log to stay empty, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "SLOWLOG GET x"); !strings.HasPrefix(resp, "-ERR") {
+		t.Errorf("SLOWLOG GET x: expected error, got %q", resp)
+	}
+}
+
+func TestHTTPSlowLog(t *testing.T) {
+	s := store.NewStore(10)
+	tcp := server.NewServer(s, 0)
+	tcp.SlowLog().SetThreshold(0)
+	h := server.NewHTTPServer(s, 0)
+	h.Attach(tcp)
+	<-h.StartAndReady()
+	defer h.Stop()
+	baseURL := "http://" + h.Addr().String()
+
+	resp, err := http.Get(baseURL + "/keys/user:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Get(baseURL + "/slowlog?count=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Entries []struct {
+			ID       int64    `json:"id"`
+			Duration int64    `json:"duration"`
+			Args     []string `json:"args"`
+			Client   string   `json:"client"`
+		} `json:"entries"`
+		Len int `json:"len"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Len != 1 || len(body.Entries) != 1 {
+		t.Fatalf("Expected one entry, got %+v", body)
+	}
+	if e := body.Entries[0]; strings.Join(e.Args, " ") != "GET user:1" || e.Client == "" {
+		t.Errorf("Unexpected entry %+v", e)
+	}
+
+	resp2, _ := http.Get(baseURL + "/slowlog?count=x")
+	resp2.Body.Close()
+	if resp2.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a bad count, got %d", resp2.StatusCode)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"memstash/internal/store"
 	"os"
+	"sort"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -734,3 +736,133 @@ func TestStatsCounters(t *testing.T) {
 		t.Errorf("Expected capacity 5, got %d", stats.Capacity)
 	}
 }
+
+func TestVersionChangesOnWrite(t *testing.T) {
+	myStore := store.NewStore(10)
+
+	missing := myStore.Version("key")
+
+	myStore.Set("key", "v1")
+	v1 := myStore.Version("key")
+	if v1 == missing {
+		t.Fatal("Expected Set to change the version")
+	}
+
+	// Reads do not change the version
+	myStore.Get("key")
+	if v := myStore.Version("key"); v != v1 {
+		t.Errorf("Expected Get to keep version %d, got %d", v1, v)
+	}
+
+	myStore.Set("key", "v2")
+	v2 := myStore.Version("key")
+	if v2 <= v1 {
+		t.Errorf("Expected version to grow after update, got %d then %d", v1, v2)
+	}
+
+	myStore.SetExpiry("key", time.Minute)
+	if v := myStore.Version("key"); v <= v2 {
+		t.Errorf("Expected version to grow after SetExpiry, got %d then %d", v2, v)
+	}
+
+	myStore.Delete("key")
+	if v := myStore.Version("key"); v == missing || v <= v2 {
+		t.Errorf("Expected a new version after Delete, got %d", v)
+	}
+}
+
+func TestAtomicBlocksOtherWriters(t *testing.T) {
+	myStore := store.NewStore(10)
+	myStore.Set("counter", "0")
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		myStore.Atomic(func(tx *store.Store) {
+			tx.Set("counter", "1")
+			close(entered)
+			<-release
+			// The view can be used freely without deadlocking
+			if val, _ := tx.Get("counter"); val != "1" {
+				t.Errorf("Expected 1 inside transaction, got %s", val)
+			}
+		})
+	}()
+
+	<-entered
+	go func() {
+		myStore.Set("counter", "2")
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("Set completed while a transaction held the store")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-done
+
+	if val, _ := myStore.Get("counter"); val != "2" {
+		t.Errorf("Expected 2 after transaction, got %s", val)
+	}
+}
+
+func TestKeysMatching(t *testing.T) {
+	myStore := store.NewStore(10)
+	for _, k := range []string{"user:1", "user:2", "user:10", "order:1", "hello", "hallo", "hxllo"} {
+		myStore.Set(k, "v")
+	}
+
+	cases := []struct {
+		pattern string
+		want    string
+	}{
+		{"user:*", "user:1,user:10,user:2"},
+		{"user:?", "user:1,user:2"},
+		{"h[ae]llo", "hallo,hello"},
+		{"h[^e]llo", "hallo,hxllo"},
+		{"h[a-b]llo", "hallo"},
+		{"*:1", "order:1,user:1"},
+		{"nothing*", ""},
+	}
+	for _, tc := range cases {
+		keys := myStore.KeysMatching(tc.pattern)
+		sort.Strings(keys)
+		if got := strings.Join(keys, ","); got != tc.want {
+			t.Errorf("KeysMatching(%q): expected %q, got %q", tc.pattern, tc.want, got)
+		}
+	}
+
+	myStore.Set("a*b", "v")
+	if keys := myStore.KeysMatching(`a\*b`); len(keys) != 1 || keys[0] != "a*b" {
+		t.Errorf(`KeysMatching("a\*b"): expected [a*b], got %v`, keys)
+	}
+}
+
+func TestDeleteMatching(t *testing.T) {
+	myStore := store.NewStore(10)
+	myStore.Set("session:1", "a")
+	myStore.Set("session:2", "b")
+	myStore.Set("config", "c")
+
+	if n := myStore.DeleteMatching("session:*"); n != 2 {
+		t.Errorf("Expected 2 keys deleted, got %d", n)
+	}
+	if myStore.Exists("session:1") || myStore.Exists("session:2") || !myStore.Exists("config") {
+		t.Error("Expected only session:* keys to be deleted")
+	}
+	if n := myStore.DeleteMatching("session:*"); n != 0 {
+		t.Errorf("Expected 0 keys deleted on second call, got %d", n)
+	}
+
+	// An expired key that matches is not counted
+	myStore.SetWithTTL("session:3", "d", 10*time.Millisecond)
+	myStore.Set("session:4", "e")
+	time.Sleep(30 * time.Millisecond)
+	if n := myStore.DeleteMatching("session:*"); n != 1 {
+		t.Errorf("Expected 1 key deleted next to an expired one, got %d", n)
+	}
+}
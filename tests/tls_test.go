@@ -0,0 +1,250 @@
+package tests
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"memstash/internal/server"
+	"memstash/internal/store"
+	"memstash/internal/tlsconf"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA issues certificates for the TLS tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "memstash test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create CA: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns PEM encoded certificate and key signed by the CA.
+func (ca *testCA) issue(t *testing.T, serial int64, client bool) ([]byte, []byte) {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1"), net.IPv6loopback},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if client {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("issue certificate: %v", err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// writeTLSFiles writes a server certificate, key and the CA to dir.
+func writeTLSFiles(t *testing.T, ca *testCA, dir string, serial int64) tlsconf.Options {
+	t.Helper()
+	certPEM, keyPEM := ca.issue(t, serial, false)
+	opts := tlsconf.Options{
+		CertFile: filepath.Join(dir, "server.crt"),
+		KeyFile:  filepath.Join(dir, "server.key"),
+		CAFile:   filepath.Join(dir, "ca.crt"),
+	}
+	os.WriteFile(opts.CertFile, certPEM, 0600)
+	os.WriteFile(opts.KeyFile, keyPEM, 0600)
+	os.WriteFile(opts.CAFile, ca.pem, 0600)
+	return opts
+}
+
+func clientTLSConfig(ca *testCA) *tls.Config {
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca.pem)
+	return &tls.Config{RootCAs: pool, ServerName: "localhost"}
+}
+
+func TestTLSServer(t *testing.T) {
+	ca := newTestCA(t)
+	certs, err := tlsconf.New(writeTLSFiles(t, ca, t.TempDir(), 2))
+	if err != nil {
+		t.Fatalf("tlsconf.New: %v", err)
+	}
+
+	srv := server.NewServer(store.NewStore(10), 0)
+	srv.SetTLS(certs.Config())
+	<-srv.StartAndReady()
+	defer srv.Stop()
+
+	conn, err := tls.Dial("tcp", srv.Addr().String(), clientTLSConfig(ca))
+	if err != nil {
+		t.Fatalf("TLS dial failed: %v", err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	if resp := sendCommand(conn, reader, "PING"); resp != "+PONG\r\n" {
+		t.Errorf("PING over TLS: expected +PONG\\r\\n, got %q", resp)
+	}
+
+	// A plaintext client must not get a RESP reply
+	plain, err := net.Dial("tcp", srv.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer plain.Close()
+	plain.SetReadDeadline(time.Now().Add(2 * time.Second))
+	plain.Write([]byte("PING\r\n"))
+	buf := make([]byte, 64)
+	n, _ := plain.Read(buf)
+	if string(buf[:n]) == "+PONG\r\n" {
+		t.Error("Expected plaintext PING to be rejected")
+	}
+}
+
+func TestTLSHTTPServer(t *testing.T) {
+	ca := newTestCA(t)
+	certs, err := tlsconf.New(writeTLSFiles(t, ca, t.TempDir(), 2))
+	if err != nil {
+		t.Fatalf("tlsconf.New: %v", err)
+	}
+
+	h := server.NewHTTPServer(store.NewStore(10), 0)
+	h.SetTLS(certs.Config())
+	<-h.StartAndReady()
+	defer h.Stop()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientTLSConfig(ca)}}
+	resp, err := client.Get("https://" + h.Addr().String() + "/stats")
+	if err != nil {
+		t.Fatalf("GET /stats over TLS failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestTLSMutualAuth(t *testing.T) {
+	ca := newTestCA(t)
+	opts := writeTLSFiles(t, ca, t.TempDir(), 2)
+	opts.ClientAuth = tlsconf.ClientAuthRequired
+	certs, err := tlsconf.New(opts)
+	if err != nil {
+		t.Fatalf("tlsconf.New: %v", err)
+	}
+
+	srv := server.NewServer(store.NewStore(10), 0)
+	srv.SetTLS(certs.Config())
+	<-srv.StartAndReady()
+	defer srv.Stop()
+
+	// Without a client certificate the handshake fails
+	conn, err := tls.Dial("tcp", srv.Addr().String(), clientTLSConfig(ca))
+	if err == nil {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		reader := bufio.NewReader(conn)
+		conn.Write([]byte("PING\r\n"))
+		if _, err = reader.ReadString('\n'); err == nil {
+			t.Error("Expected connection without a client certificate to fail")
+		}
+		conn.Close()
+	}
+
+	certPEM, keyPEM := ca.issue(t, 3, true)
+	clientCert, _ := tls.X509KeyPair(certPEM, keyPEM)
+	cfg := clientTLSConfig(ca)
+	cfg.Certificates = []tls.Certificate{clientCert}
+	conn, err = tls.Dial("tcp", srv.Addr().String(), cfg)
+	if err != nil {
+		t.Fatalf("TLS dial with client certificate failed: %v", err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	if resp := sendCommand(conn, reader, "PING"); resp != "+PONG\r\n" {
+		t.Errorf("PING with client certificate: expected +PONG\\r\\n, got %q", resp)
+	}
+}
+
+func TestTLSCertificateReload(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certs, err := tlsconf.New(writeTLSFiles(t, ca, dir, 2))
+	if err != nil {
+		t.Fatalf("tlsconf.New: %v", err)
+	}
+
+	srv := server.NewServer(store.NewStore(10), 0)
+	srv.SetTLS(certs.Config())
+	<-srv.StartAndReady()
+	defer srv.Stop()
+
+	serial := func() int64 {
+		conn, err := tls.Dial("tcp", srv.Addr().String(), clientTLSConfig(ca))
+		if err != nil {
+			t.Fatalf("TLS dial failed: %v", err)
+		}
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+	}
+	if got := serial(); got != 2 {
+		t.Fatalf("Expected certificate serial 2, got %d", got)
+	}
+
+	// Renew the certificate on disk; the watcher swaps it in
+	stop := certs.Watch(10*time.Millisecond, nil)
+	defer stop()
+	future := time.Now().Add(time.Second)
+	writeTLSFiles(t, ca, dir, 4)
+	os.Chtimes(filepath.Join(dir, "server.crt"), future, future)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for certs.Certificate().Leaf == nil || certs.Certificate().Leaf.SerialNumber.Int64() != 4 {
+		if time.Now().After(deadline) {
+			t.Fatal("Certificate was not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := serial(); got != 4 {
+		t.Errorf("Expected renewed certificate serial 4, got %d", got)
+	}
+
+	// A broken file keeps the old certificate in use
+	os.WriteFile(filepath.Join(dir, "server.crt"), []byte("garbage"), 0600)
+	if err := certs.Reload(); err == nil {
+		t.Error("Expected Reload to fail on an invalid certificate")
+	}
+	if got := serial(); got != 4 {
+		t.Errorf("Expected serial 4 after failed reload, got %d", got)
+	}
+}
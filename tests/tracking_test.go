@@ -0,0 +1,247 @@
+package tests
+
+import (
+	"bufio"
+	"memstash/internal/store"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// invalidation formats the RESP3 push sent when key changes.
+func invalidation(key string) string {
+	return ">2\r\n$10\r\ninvalidate\r\n" + encodeCommand(key)
+}
+
+// expectNoMessage fails if anything arrives on conn within a short while.
+func expectNoMessage(t *testing.T, conn net.Conn, reader *bufio.Reader) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	defer conn.SetReadDeadline(time.Time{})
+	if resp := readResponse(reader); resp != "" {
+		t.Errorf("Expected no message, got %q", resp)
+	}
+}
+
+// dialTracking connects a RESP3 client with CLIENT TRACKING enabled using
+// the given options.
+func dialTracking(t *testing.T, addr, options string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, reader := dialServer(t, addr)
+	sendCommand(conn, reader, "HELLO 3")
+	if resp := sendCommand(conn, reader, strings.TrimSpace("CLIENT TRACKING ON "+options)); resp != "+OK\r\n" {
+		t.Fatalf("CLIENT TRACKING ON %s: got %q", options, resp)
+	}
+	return conn, reader
+}
+
+func TestTrackingDefaultMode(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+
+	tracked, treader := dialTracking(t, addr, "")
+	defer tracked.Close()
+	writer, wreader := dialServer(t, addr)
+	defer writer.Close()
+
+	sendCommand(writer, wreader, "SET k v1")
+	sendCommand(writer, wreader, "SET other v1")
+	if resp := sendCommand(tracked, treader, "GET k"); resp != "$2\r\nv1\r\n" {
+		t.Fatalf("GET k: got %q", resp)
+	}
+
+	// Keys that were not read are not reported
+	sendCommand(writer, wreader, "SET other v2")
+	expectNoMessage(t, tracked, treader)
+
+	sendCommand(writer, wreader, "SET k v2")
+	if resp := readResponse(treader); resp != invalidation("k") {
+		t.Errorf("After SET: expected invalidation of k, got %q", resp)
+	}
+
+	// The key has to be read again before the next change is reported
+	sendCommand(writer, wreader, "SET k v3")
+	expectNoMessage(t, tracked, treader)
+
+	sendCommand(tracked, treader, "EXISTS k")
+	sendCommand(writer, wreader, "DEL k")
+	if resp := readResponse(treader); resp != invalidation("k") {
+		t.Errorf("After DEL: expected invalidation of k, got %q", resp)
+	}
+
+	// Reads of missing keys are tracked too, since clients cache misses
+	sendCommand(tracked, treader, "GET missing")
+	sendCommand(writer, wreader, "SET missing v")
+	if resp := readResponse(treader); resp != invalidation("missing") {
+		t.Errorf("After SET of a cached miss: expected invalidation, got %q", resp)
+	}
+
+	// Writes are not tracked
+	sendCommand(tracked, treader, "SET mine v")
+	sendCommand(writer, wreader, "SET mine w")
+	expectNoMessage(t, tracked, treader)
+
+	if resp := sendCommand(tracked, treader, "CLIENT TRACKING OFF"); resp != "+OK\r\n" {
+		t.Fatalf("CLIENT TRACKING OFF: got %q", resp)
+	}
+	sendCommand(tracked, treader, "GET k")
+	sendCommand(writer, wreader, "SET k v4")
+	expectNoMessage(t, tracked, treader)
+}
+
+func TestTrackingRedirectToRESP2Subscriber(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+
+	sub, sreader := dialServer(t, addr)
+	defer sub.Close()
+	id := strings.TrimSpace(strings.TrimPrefix(sendCommand(sub, sreader, "CLIENT ID"), ":"))
+	sendCommand(sub, sreader, "SUBSCRIBE __redis__:invalidate")
+
+	tracked, treader := dialServer(t, addr)
+	defer tracked.Close()
+	resp := sendCommand(tracked, treader, "CLIENT TRACKING ON")
+	if !strings.Contains(resp, "REDIRECT") {
+		t.Errorf("RESP2 tracking without REDIRECT: expected error, got %q", resp)
+	}
+	if resp := sendCommand(tracked, treader, "CLIENT TRACKING ON REDIRECT 9999"); resp != "-ERR The client ID you want redirect to does not exist\r\n" {
+		t.Errorf("REDIRECT to unknown id: got %q", resp)
+	}
+	if resp := sendCommand(tracked, treader, "CLIENT TRACKING ON REDIRECT "+id); resp != "+OK\r\n" {
+		t.Fatalf("CLIENT TRACKING ON REDIRECT: got %q", resp)
+	}
+	if resp := sendCommand(tracked, treader, "CLIENT GETREDIR"); resp != ":"+id+"\r\n" {
+		t.Errorf("CLIENT GETREDIR: expected %s, got %q", id, resp)
+	}
+
+	sendCommand(tracked, treader, "GET k")
+	writer, wreader := dialServer(t, addr)
+	defer writer.Close()
+	sendCommand(writer, wreader, "SET k v")
+
+	expected := "*3\r\n$7\r\nmessage\r\n$20\r\n__redis__:invalidate\r\n*1\r\n$1\r\nk\r\n"
+	if resp := readResponse(sreader); resp != expected {
+		t.Errorf("Redirected invalidation: expected %q, got %q", expected, resp)
+	}
+	// Nothing goes to the tracked connection itself
+	expectNoMessage(t, tracked, treader)
+
+	// Once the target is gone, writes still succeed
+	sub.Close()
+	time.Sleep(50 * time.Millisecond)
+	sendCommand(tracked, treader, "GET k")
+	if resp := sendCommand(writer, wreader, "SET k w"); resp != "+OK\r\n" {
+		t.Errorf("SET after the redirect target left: got %q", resp)
+	}
+}
+
+func TestTrackingBroadcast(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+	sendCommand(conn, reader, "HELLO 3")
+	if resp := sendCommand(conn, reader, "CLIENT TRACKING ON PREFIX user:"); resp != "-ERR PREFIX option requires BCAST mode to be enabled\r\n" {
+		t.Errorf("PREFIX without BCAST: got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "CLIENT TRACKING ON BCAST PREFIX user: PREFIX order:"); resp != "+OK\r\n" {
+		t.Fatalf("CLIENT TRACKING ON BCAST: got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "CLIENT TRACKING ON"); !strings.Contains(resp, "BCAST mode") {
+		t.Errorf("Switching off BCAST while tracking: expected error, got %q", resp)
+	}
+
+	writer, wreader := dialServer(t, addr)
+	defer writer.Close()
+
+	// Broadcast clients hear about every matching key, read or not, and
+	// every time it changes.
+	sendCommand(writer, wreader, "SET user:1 a")
+	if resp := readResponse(reader); resp != invalidation("user:1") {
+		t.Errorf("SET user:1: expected invalidation, got %q", resp)
+	}
+	sendCommand(writer, wreader, "SET user:1 b")
+	if resp := readResponse(reader); resp != invalidation("user:1") {
+		t.Errorf("Second SET user:1: expected invalidation, got %q", resp)
+	}
+	sendCommand(writer, wreader, "SET order:7 x")
+	if resp := readResponse(reader); resp != invalidation("order:7") {
+		t.Errorf("SET order:7: expected invalidation, got %q", resp)
+	}
+	sendCommand(writer, wreader, "SET session:1 x")
+	expectNoMessage(t, conn, reader)
+
+	resp := sendCommand(conn, reader, "CLIENT TRACKINGINFO")
+	expected := "%3\r\n$5\r\nflags\r\n*2\r\n$2\r\non\r\n$5\r\nbcast\r\n" +
+		"$8\r\nredirect\r\n:0\r\n" +
+		"$8\r\nprefixes\r\n*2\r\n$5\r\nuser:\r\n$6\r\norder:\r\n"
+	if resp != expected {
+		t.Errorf("CLIENT TRACKINGINFO: expected %q, got %q", expected, resp)
+	}
+	if resp := sendCommand(conn, reader, "CLIENT INFO"); !strings.Contains(resp, "flags=tB ") {
+		t.Errorf("CLIENT INFO: expected flags=tB, got %q", resp)
+	}
+}
+
+func TestTrackingExpiryEvictionAndFlush(t *testing.T) {
+	s := store.NewStore(2)
+	srv := startServerWithStore(t, s)
+	defer srv.Stop()
+	addr := srv.Addr().String()
+
+	conn, reader := dialTracking(t, addr, "")
+	defer conn.Close()
+
+	// Eviction
+	s.Set("a", "1")
+	s.Set("b", "2")
+	sendCommand(conn, reader, "GET a")
+	s.Get("b") // a is now the least recently used key
+	s.Set("c", "3")
+	if resp := readResponse(reader); resp != invalidation("a") {
+		t.Errorf("Eviction: expected invalidation of a, got %q", resp)
+	}
+
+	// A TTL change, then the expiry itself, found by the next read
+	sendCommand(conn, reader, "GET b")
+	s.SetExpiry("b", 50*time.Millisecond)
+	if resp := readResponse(reader); resp != invalidation("b") {
+		t.Errorf("SetExpiry: expected invalidation of b, got %q", resp)
+	}
+	sendCommand(conn, reader, "GET b")
+	time.Sleep(80 * time.Millisecond)
+	if resp := sendCommand(conn, reader, "GET b"); resp != "_\r\n" {
+		t.Errorf("GET expired b: got %q", resp)
+	}
+	if resp := readResponse(reader); resp != invalidation("b") {
+		t.Errorf("Expiry: expected invalidation of b, got %q", resp)
+	}
+
+	// A flush invalidates everything with a null key list
+	sendCommand(conn, reader, "GET c")
+	s.FlushAll()
+	if resp := readResponse(reader); resp != ">2\r\n$10\r\ninvalidate\r\n_\r\n" {
+		t.Errorf("FlushAll: expected null invalidation, got %q", resp)
+	}
+}
+
+func TestTrackingInfoWhenOff(t *testing.T) {
+	srv, addr := startTestServer(t, 10)
+	defer srv.Stop()
+
+	conn, reader := dialServer(t, addr)
+	defer conn.Close()
+
+	expected := "*6\r\n$5\r\nflags\r\n*1\r\n$3\r\noff\r\n$8\r\nredirect\r\n:-1\r\n$8\r\nprefixes\r\n*0\r\n"
+	if resp := sendCommand(conn, reader, "CLIENT TRACKINGINFO"); resp != expected {
+		t.Errorf("CLIENT TRACKINGINFO: expected %q, got %q", expected, resp)
+	}
+	if resp := sendCommand(conn, reader, "CLIENT GETREDIR"); resp != ":-1\r\n" {
+		t.Errorf("CLIENT GETREDIR: expected -1, got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "CLIENT TRACKING MAYBE"); resp != "-ERR syntax error\r\n" {
+		t.Errorf("CLIENT TRACKING MAYBE: got %q", resp)
+	}
+}
@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"memstash/internal/server"
+	"memstash/internal/store"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startUnixServer starts a server listening on a Unix socket in a temporary
+// directory, and on TCP unless tcp is false.
+func startUnixServer(t *testing.T, s *store.Store, tcp bool) (*server.Server, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "memstash.sock")
+	srv := server.NewServer(s, 0)
+	srv.SetUnixSocket(path, 0770)
+	if !tcp {
+		srv.DisableTCP()
+	}
+	<-srv.StartAndReady()
+	return srv, path
+}
+
+func dialUnix(t *testing.T, path string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	return conn, bufio.NewReader(conn)
+}
+
+func TestUnixSocketAlongsideTCP(t *testing.T) {
+	srv, path := startUnixServer(t, store.NewStore(10), true)
+	defer srv.Stop()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != 0770 {
+		t.Errorf("Expected a socket with mode 0770, got %v", info.Mode())
+	}
+	if srv.UnixAddr() == nil || srv.UnixAddr().String() != path {
+		t.Errorf("UnixAddr: got %v", srv.UnixAddr())
+	}
+
+	unixConn, unixReader := dialUnix(t, path)
+	defer unixConn.Close()
+	tcpConn, tcpReader := dialServer(t, srv.Addr().String())
+	defer tcpConn.Close()
+
+	// Both listeners serve the same store
+	if resp := sendCommand(unixConn, unixReader, "SET k v"); resp != "+OK\r\n" {
+		t.Fatalf("SET over the socket: got %q", resp)
+	}
+	if resp := sendCommand(tcpConn, tcpReader, "GET k"); resp != "$1\r\nv\r\n" {
+		t.Errorf("GET over TCP: got %q", resp)
+	}
+
+	// ... and register their clients in the same registry
+	sendCommand(unixConn, unixReader, "CLIENT SETNAME sidecar")
+	resp := sendCommand(tcpConn, tcpReader, "CLIENT LIST")
+	var line string
+	for _, l := range strings.Split(resp, "\n") {
+		if strings.Contains(l, "name=sidecar") {
+			line = l
+		}
+	}
+	if line == "" {
+		t.Fatalf("Expected the Unix client in CLIENT LIST, got %q", resp)
+	}
+	if !strings.Contains(line, "addr="+path+":0 ") || !strings.Contains(line, "flags=U ") {
+		t.Errorf("Unexpected entry for the Unix client: %q", line)
+	}
+	if srv.ClientCount() != 2 {
+		t.Errorf("Expected 2 clients, got %d", srv.ClientCount())
+	}
+
+	resp = sendCommand(tcpConn, tcpReader, "CLIENT KILL ADDR "+path+":0")
+	if resp != ":1\r\n" {
+		t.Errorf("CLIENT KILL ADDR: got %q", resp)
+	}
+}
+
+func TestUnixSocketOnly(t *testing.T) {
+	srv, path := startUnixServer(t, store.NewStore(10), false)
+
+	if srv.Addr() != nil {
+		t.Errorf("Expected no TCP listener, got %v", srv.Addr())
+	}
+	conn, reader := dialUnix(t, path)
+	if resp := sendCommand(conn, reader, "PING"); resp != "+PONG\r\n" {
+		t.Errorf("PING: got %q", resp)
+	}
+	conn.Close()
+
+	srv.Stop()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected Stop to remove the socket file, got %v", err)
+	}
+}
+
+func TestUnixSocketReplacesStaleFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memstash.sock")
+	// A socket file left behind by a server that crashed
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	srv := server.NewServer(store.NewStore(10), 0)
+	srv.SetUnixSocket(path, 0700)
+	srv.DisableTCP()
+	<-srv.StartAndReady()
+	defer srv.Stop()
+
+	conn, reader := dialUnix(t, path)
+	defer conn.Close()
+	if resp := sendCommand(conn, reader, "PING"); resp != "+PONG\r\n" {
+		t.Errorf("PING: got %q", resp)
+	}
+	if resp := sendCommand(conn, reader, "CONFIG GET unixsocket*"); !strings.Contains(resp, path) || !strings.Contains(resp, "700") {
+		t.Errorf("CONFIG GET unixsocket*: got %q", resp)
+	}
+}
+
+func TestUnixSocketShutdownDrains(t *testing.T) {
+	srv, path := startUnixServer(t, store.NewStore(10), true)
+
+	conn, reader := dialUnix(t, path)
+	defer conn.Close()
+	sendCommand(conn, reader, "PING")
+
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := reader.ReadByte(); err != io.EOF {
+		t.Errorf("Expected the Unix connection to be closed, got %v", err)
+	}
+	if _, err := net.Dial("unix", path); err == nil {
+		t.Error("Expected the socket to refuse new connections")
+	}
+}